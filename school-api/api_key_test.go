@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"school-api/auth"
+	"school-api/dto"
+	"school-api/models"
+	"testing"
+	"time"
+)
+
+// apiKeyFixture is a test app with an administrator to manage keys
+type apiKeyFixture struct {
+	*testApp
+	token string
+}
+
+func newAPIKeyFixture(t *testing.T) *apiKeyFixture {
+	a := newTestApp(t)
+	return &apiKeyFixture{testApp: a, token: a.login("default", "admin", auth.RoleAdmin)}
+}
+
+func (f *apiKeyFixture) createKey(t *testing.T, scopes ...string) dto.APIKeySecretResponse {
+	t.Helper()
+	return decode[dto.APIKeySecretResponse](t, expect(t, f.do(t, f.token, http.MethodPost, "/api/api-keys",
+		dto.CreateAPIKeyRequest{Name: "Timetable sync", Scopes: scopes}), http.StatusCreated))
+}
+
+// rejected checks that key is refused with a challenge to present a valid one
+func (f *apiKeyFixture) rejected(t *testing.T, key string) {
+	t.Helper()
+	rec := expect(t, f.do(t, "", http.MethodGet, "/api/classes", nil, "X-API-Key", key), http.StatusUnauthorized)
+	if got := rec.Header().Get("WWW-Authenticate"); got != invalidTokenChallenge {
+		t.Errorf("got WWW-Authenticate %q, want %q", got, invalidTokenChallenge)
+	}
+}
+
+// TestAPIKeys checks that keys grant their scopes until they expire, are
+// revoked or are rotated
+func TestAPIKeys(t *testing.T) {
+	t.Run("scopes", func(t *testing.T) {
+		f := newAPIKeyFixture(t)
+		key := f.createKey(t, "read:classes")
+		expect(t, f.do(t, "", http.MethodGet, "/api/classes", nil, "X-API-Key", key.Key), http.StatusOK)
+		expect(t, f.do(t, "", http.MethodGet, "/api/classes", nil, "Authorization", "Bearer "+key.Key), http.StatusOK)
+		expect(t, f.do(t, "", http.MethodGet, "/api/students", nil, "X-API-Key", key.Key), http.StatusForbidden)
+		expect(t, f.do(t, "", http.MethodPost, "/api/classes", dto.CreateClassRequest{ClassName: "Grade 5"},
+			"X-API-Key", key.Key), http.StatusForbidden)
+		// Keys never manage users or other keys, whatever their scopes
+		expect(t, f.do(t, "", http.MethodGet, "/api/api-keys", nil, "X-API-Key", key.Key), http.StatusForbidden)
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		f := newAPIKeyFixture(t)
+		key := f.createKey(t, "read:classes")
+		f.rejected(t, key.Key+"x")
+		f.rejected(t, "sk_nothing_here")
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		f := newAPIKeyFixture(t)
+		key := f.createKey(t, "read:classes")
+		past := time.Now().Add(-time.Minute)
+		if err := f.db.Model(&models.APIKey{}).Where("id = ?", key.ID).Update("expires_at", past).Error; err != nil {
+			t.Fatal(err)
+		}
+		f.rejected(t, key.Key)
+	})
+
+	t.Run("revoked", func(t *testing.T) {
+		f := newAPIKeyFixture(t)
+		key := f.createKey(t, "read:classes")
+		expect(t, f.do(t, "", http.MethodGet, "/api/classes", nil, "X-API-Key", key.Key), http.StatusOK)
+		expect(t, f.do(t, f.token, http.MethodDelete, fmt.Sprintf("/api/api-keys/%d", key.ID), nil), http.StatusNoContent)
+		f.rejected(t, key.Key)
+		got := decode[dto.APIKeyResponse](t, expect(t, f.do(t, f.token, http.MethodGet,
+			fmt.Sprintf("/api/api-keys/%d", key.ID), nil), http.StatusOK))
+		if got.RevokedAt == nil {
+			t.Error("revoked key has no revoked_at")
+		}
+	})
+
+	t.Run("rotated", func(t *testing.T) {
+		f := newAPIKeyFixture(t)
+		key := f.createKey(t, "read:classes")
+		rotated := decode[dto.APIKeySecretResponse](t, expect(t, f.do(t, f.token, http.MethodPost,
+			fmt.Sprintf("/api/api-keys/%d/rotate", key.ID), nil), http.StatusOK))
+		if rotated.Key == key.Key {
+			t.Fatal("rotation kept the secret")
+		}
+		f.rejected(t, key.Key)
+		expect(t, f.do(t, "", http.MethodGet, "/api/classes", nil, "X-API-Key", rotated.Key), http.StatusOK)
+	})
+}
+
+// TestAPIKeyUsage checks that uses of a key are counted in memory and
+// recorded when usage is flushed
+func TestAPIKeyUsage(t *testing.T) {
+	f := newAPIKeyFixture(t)
+	key := f.createKey(t, "read:classes")
+	path := fmt.Sprintf("/api/api-keys/%d", key.ID)
+	before := time.Now()
+	for range 3 {
+		expect(t, f.do(t, "", http.MethodGet, "/api/classes", nil, "X-API-Key", key.Key), http.StatusOK)
+	}
+
+	got := decode[dto.APIKeyResponse](t, expect(t, f.do(t, f.token, http.MethodGet, path, nil), http.StatusOK))
+	if got.UsageCount != 0 || got.LastUsedAt != nil {
+		t.Errorf("usage is recorded before a flush: %d uses, last %v", got.UsageCount, got.LastUsedAt)
+	}
+
+	if err := f.app.apiKeys.FlushUsage(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	got = decode[dto.APIKeyResponse](t, expect(t, f.do(t, f.token, http.MethodGet, path, nil), http.StatusOK))
+	if got.UsageCount != 3 || got.LastUsedAt == nil || got.LastUsedAt.Before(before.Truncate(time.Second)) {
+		t.Errorf("after a flush the key has %d uses, last %v, want 3 uses since %v", got.UsageCount, got.LastUsedAt, before)
+	}
+
+	// Uses are added to those already recorded
+	expect(t, f.do(t, "", http.MethodGet, "/api/classes", nil, "X-API-Key", key.Key), http.StatusOK)
+	if err := f.app.apiKeys.FlushUsage(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	got = decode[dto.APIKeyResponse](t, expect(t, f.do(t, f.token, http.MethodGet, path, nil), http.StatusOK))
+	if got.UsageCount != 4 {
+		t.Errorf("after a second flush the key has %d uses, want 4", got.UsageCount)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"school-api/auth"
+	"school-api/config"
+	"school-api/database"
+	"school-api/dto"
+	"school-api/models"
+	"school-api/repository"
+	"school-api/service"
+	"testing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testApp is the server wired to a fresh SQLite database, configured as by
+// default unless a test changes it
+type testApp struct {
+	t   *testing.T
+	cfg *config.Config
+	db  *gorm.DB
+	uow repository.UnitOfWork
+	app *app
+}
+
+func newTestApp(t *testing.T, configure ...func(*config.Config)) *testApp {
+	t.Helper()
+	cfg := config.Default()
+	for _, c := range configure {
+		c(cfg)
+	}
+
+	db, err := database.Open(database.DriverSQLite, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close(db) })
+	db.Logger = logger.Discard
+	if err := database.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	uow := repository.NewUnitOfWork(db, cfg.RepositoryTimeouts())
+	a, err := newApp(cfg, db, uow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testApp{t: t, cfg: cfg, db: db, uow: uow, app: a}
+}
+
+// tenant returns the tenant with the given slug, creating it if needed
+func (a *testApp) tenant(slug string) *models.Tenant {
+	a.t.Helper()
+	tenants := service.NewTenantService(a.uow)
+	tenant, err := tenants.GetTenantBySlug(context.Background(), slug)
+	if err != nil {
+		tenant, err = tenants.CreateTenant(context.Background(), slug, slug)
+	}
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return tenant
+}
+
+// login creates a user of the tenant and returns an access token for it
+func (a *testApp) login(slug, username string, role auth.Role) string {
+	a.t.Helper()
+	ctx := repository.WithTenant(context.Background(), a.tenant(slug))
+	const password = "correct horse battery staple"
+	input := service.NewUser{Username: username, Password: password, Role: string(role)}
+	if _, err := service.NewUserService(a.uow).CreateUser(ctx, input); err != nil {
+		a.t.Fatal(err)
+	}
+
+	rec := a.do(a.t, "", http.MethodPost, "/api/auth/login", dto.LoginRequest{Username: username, Password: password})
+	tokens := decode[dto.TokenResponse](a.t, expect(a.t, rec, http.StatusOK))
+	return tokens.AccessToken
+}
+
+// do sends a request through the router. body is sent as is if it is a
+// string, and as JSON otherwise. headers are name, value pairs.
+func (a *testApp) do(t *testing.T, token, method, path string, body any, headers ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	var r io.Reader
+	switch b := body.(type) {
+	case nil:
+	case string:
+		r = bytes.NewBufferString(b)
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, r)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	a.app.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// expect fails the test unless the response has the given status
+func expect(t *testing.T, rec *httptest.ResponseRecorder, status int) *httptest.ResponseRecorder {
+	t.Helper()
+	if rec.Code != status {
+		t.Fatalf("got status %d, want %d: %s", rec.Code, status, rec.Body)
+	}
+	return rec
+}
+
+// decode reads the JSON body of a response
+func decode[T any](t *testing.T, rec *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal(rec.Body.Bytes(), &v); err != nil {
+		t.Fatalf("decode %s: %v", rec.Body, err)
+	}
+	return v
+}
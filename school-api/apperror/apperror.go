@@ -0,0 +1,131 @@
+package apperror
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"gorm.io/gorm"
+)
+
+// Code is a machine-readable error identifier returned to clients
+type Code string
+
+// Error codes returned by the API
+const (
+	CodeBadRequest           Code = "bad_request"
+	CodeInvalidQuery         Code = "invalid_query"
+	CodeUnauthorized         Code = "unauthorized"
+	CodeForbidden            Code = "forbidden"
+	CodeNotFound             Code = "not_found"
+	CodeNotAcceptable        Code = "not_acceptable"
+	CodeConflict             Code = "conflict"
+	CodeDuplicate            Code = "duplicate"
+	CodeReferenced           Code = "referenced"
+	CodePatchConflict        Code = "patch_conflict"
+	CodePreconditionFailed   Code = "precondition_failed"
+	CodePreconditionRequired Code = "precondition_required"
+	CodeTooLarge             Code = "too_large"
+	CodeUnsupportedMediaType Code = "unsupported_media_type"
+	CodeValidation           Code = "validation_failed"
+	CodeCanceled             Code = "request_canceled"
+	CodeTimeout              Code = "timeout"
+	CodeInternal             Code = "internal_error"
+)
+
+// StatusClientClosedRequest is the non-standard status (popularised by
+// nginx) reported when the client went away before the response was ready
+const StatusClientClosedRequest = 499
+
+// FieldError describes a problem with a single request field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error is an error that knows how it should be reported over HTTP.
+// Message is safe to show to clients; Err is the underlying cause and is
+// only logged.
+type Error struct {
+	Status  int
+	Code    Code
+	Message string
+	Fields  []FieldError
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New creates an Error with the given status, code and client-facing message
+func New(status int, code Code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// Wrap creates an Error that keeps err as its underlying cause
+func Wrap(err error, status int, code Code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message, Err: err}
+}
+
+// BadRequest reports a malformed request
+func BadRequest(message string) *Error {
+	return New(http.StatusBadRequest, CodeBadRequest, message)
+}
+
+// NotFound reports a missing resource
+func NotFound(message string) *Error {
+	return New(http.StatusNotFound, CodeNotFound, message)
+}
+
+// Conflict reports a request that clashes with the current state of a resource
+func Conflict(message string) *Error {
+	return New(http.StatusConflict, CodeConflict, message)
+}
+
+// PreconditionFailed reports a conditional request whose condition no longer holds
+func PreconditionFailed(message string) *Error {
+	return New(http.StatusPreconditionFailed, CodePreconditionFailed, message)
+}
+
+// Validation reports a well-formed request whose content is not acceptable
+func Validation(message string, fields ...FieldError) *Error {
+	return &Error{Status: http.StatusUnprocessableEntity, Code: CodeValidation, Message: message, Fields: fields}
+}
+
+// Internal reports an unexpected failure without exposing its cause
+func Internal(err error) *Error {
+	return Wrap(err, http.StatusInternalServerError, CodeInternal, "An internal error occurred")
+}
+
+// From converts any error to an *Error, translating well-known GORM errors.
+// Unrecognised errors become internal errors so their text is never shown
+// to clients.
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return Wrap(err, http.StatusNotFound, CodeNotFound, "Resource not found")
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return Wrap(err, http.StatusConflict, CodeDuplicate, "A record with the same key already exists")
+	case errors.Is(err, gorm.ErrForeignKeyViolated):
+		return Wrap(err, http.StatusConflict, CodeReferenced, "The change conflicts with related records")
+	case errors.Is(err, context.Canceled):
+		return Wrap(err, StatusClientClosedRequest, CodeCanceled, "The request was cancelled by the client")
+	case errors.Is(err, context.DeadlineExceeded):
+		return Wrap(err, http.StatusServiceUnavailable, CodeTimeout, "The request could not be completed in time")
+	default:
+		return Internal(err)
+	}
+}
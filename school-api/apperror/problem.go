@@ -0,0 +1,45 @@
+package apperror
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ContentType is the media type of problem responses (RFC 7807)
+const ContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details document
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     Code         `json:"code"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// NewProblem builds the problem document for e as a response to r
+func NewProblem(e *Error, r *http.Request) Problem {
+	title := http.StatusText(e.Status)
+	if e.Status == StatusClientClosedRequest {
+		title = "Client Closed Request"
+	}
+	return Problem{
+		Type:     "urn:school-api:error:" + string(e.Code),
+		Title:    title,
+		Status:   e.Status,
+		Detail:   e.Message,
+		Instance: r.URL.Path,
+		Code:     e.Code,
+		Errors:   e.Fields,
+	}
+}
+
+// WriteProblem writes e as an application/problem+json response
+func WriteProblem(w http.ResponseWriter, r *http.Request, e *Error) {
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(e.Status)
+	json.NewEncoder(w).Encode(NewProblem(e, r))
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"school-api/auth"
+	"school-api/dto"
+	"school-api/handler"
+	"school-api/models"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// auditedChange is what an audit entry says happened to which record
+type auditedChange struct {
+	Entity    string
+	EntityID  uint
+	Operation string
+}
+
+func (c auditedChange) String() string {
+	return fmt.Sprintf("%s %s %d", c.Operation, c.Entity, c.EntityID)
+}
+
+// auditActor is who an audit entry attributes its change to
+type auditActor struct {
+	Type string
+	ID   uint
+	Name string
+}
+
+// auditFixture is a test app with an administrator signed in
+type auditFixture struct {
+	*testApp
+	token string
+	admin auditActor
+}
+
+func newAuditFixture(t *testing.T) *auditFixture {
+	a := newTestApp(t)
+	token := a.login("default", "admin", auth.RoleAdmin)
+	var user models.User
+	if err := a.db.Where("username = ?", "admin").First(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &auditFixture{testApp: a, token: token, admin: auditActor{Type: models.ActorUser, ID: user.ID, Name: "admin"}}
+}
+
+// audited runs action and returns the audit entries written meanwhile
+func (f *auditFixture) audited(t *testing.T, action func()) []models.AuditEntry {
+	t.Helper()
+	var last uint
+	if err := f.db.Model(&models.AuditEntry{}).Select("COALESCE(MAX(id), 0)").Scan(&last).Error; err != nil {
+		t.Fatal(err)
+	}
+	action()
+	var entries []models.AuditEntry
+	if err := f.db.Where("id > ?", last).Order("id").Find(&entries).Error; err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
+
+// expectAudit checks that entries record exactly the wanted changes, one
+// entry each, all attributed to actor
+func expectAudit(t *testing.T, entries []models.AuditEntry, actor auditActor, want ...auditedChange) {
+	t.Helper()
+	got := make([]auditedChange, len(entries))
+	for i, e := range entries {
+		got[i] = auditedChange{Entity: e.Entity, EntityID: e.EntityID, Operation: e.Operation}
+		if e.ActorType != actor.Type || e.ActorID == nil || *e.ActorID != actor.ID || e.Actor != actor.Name {
+			id := "nil"
+			if e.ActorID != nil {
+				id = fmt.Sprint(*e.ActorID)
+			}
+			t.Errorf("%s is attributed to %s %s %q, want %+v", got[i], e.ActorType, id, e.Actor, actor)
+		}
+	}
+	compare := func(a, b auditedChange) int { return strings.Compare(a.String(), b.String()) }
+	slices.SortFunc(got, compare)
+	slices.SortFunc(want, compare)
+	if !slices.Equal(got, want) {
+		t.Errorf("audit entries record %v, want %v", got, want)
+	}
+}
+
+func (f *auditFixture) createClass(t *testing.T, name string) dto.ClassResponse {
+	t.Helper()
+	return decode[dto.ClassResponse](t, expect(t, f.do(t, f.token, http.MethodPost, "/api/classes",
+		dto.CreateClassRequest{ClassName: name}), http.StatusCreated))
+}
+
+func (f *auditFixture) createStudent(t *testing.T, name string, classID uint) dto.StudentResponse {
+	t.Helper()
+	return decode[dto.StudentResponse](t, expect(t, f.do(t, f.token, http.MethodPost, "/api/students",
+		dto.CreateStudentRequest{StudentName: name, ClassID: classID, Section: "A"}), http.StatusCreated))
+}
+
+func studentChange(id uint, op string) auditedChange {
+	return auditedChange{Entity: "student", EntityID: id, Operation: op}
+}
+
+func classChange(id uint, op string) auditedChange {
+	return auditedChange{Entity: "class", EntityID: id, Operation: op}
+}
+
+// TestAuditEntries checks that each way of changing records writes one
+// audit entry per changed record, attributed to whoever made the change
+func TestAuditEntries(t *testing.T) {
+	t.Run("create", func(t *testing.T) {
+		f := newAuditFixture(t)
+		class := f.createClass(t, "Grade 5")
+		key := decode[dto.APIKeySecretResponse](t, expect(t, f.do(t, f.token, http.MethodPost, "/api/api-keys",
+			dto.CreateAPIKeyRequest{Name: "Timetable sync", Scopes: []string{"write:students"}}), http.StatusCreated))
+
+		var student dto.StudentResponse
+		entries := f.audited(t, func() {
+			student = decode[dto.StudentResponse](t, expect(t, f.do(t, "", http.MethodPost, "/api/students",
+				dto.CreateStudentRequest{StudentName: "Jane Doe", ClassID: class.ID, Section: "A"}, "X-API-Key", key.Key),
+				http.StatusCreated))
+		})
+		expectAudit(t, entries, auditActor{Type: models.ActorAPIKey, ID: key.ID, Name: "Timetable sync"},
+			studentChange(student.ID, models.AuditCreate))
+	})
+
+	t.Run("update", func(t *testing.T) {
+		f := newAuditFixture(t)
+		class := f.createClass(t, "Grade 5")
+		student := f.createStudent(t, "Jane Doe", class.ID)
+
+		entries := f.audited(t, func() {
+			expect(t, f.do(t, f.token, http.MethodPut, fmt.Sprintf("/api/students/%d", student.ID),
+				dto.UpdateStudentRequest{StudentName: "Jane Roe", ClassID: class.ID, Section: "B"}), http.StatusOK)
+		})
+		expectAudit(t, entries, f.admin, studentChange(student.ID, models.AuditUpdate))
+	})
+
+	t.Run("patch", func(t *testing.T) {
+		f := newAuditFixture(t)
+		class := f.createClass(t, "Grade 5")
+		student := f.createStudent(t, "Jane Doe", class.ID)
+
+		entries := f.audited(t, func() {
+			expect(t, f.do(t, f.token, http.MethodPatch, fmt.Sprintf("/api/students/%d", student.ID),
+				`{"student_section":"C"}`, "Content-Type", "application/merge-patch+json"), http.StatusOK)
+		})
+		expectAudit(t, entries, f.admin, studentChange(student.ID, models.AuditUpdate))
+	})
+
+	t.Run("bulk", func(t *testing.T) {
+		f := newAuditFixture(t)
+		class := f.createClass(t, "Grade 5")
+
+		var created handler.BulkResponse[dto.StudentResponse]
+		entries := f.audited(t, func() {
+			created = decode[handler.BulkResponse[dto.StudentResponse]](t, expect(t, f.do(t, f.token, http.MethodPost, "/api/students/bulk",
+				[]dto.CreateStudentRequest{
+					{StudentName: "Jane Doe", ClassID: class.ID, Section: "A"},
+					{StudentName: "John Doe", ClassID: class.ID, Section: "A"},
+				}), http.StatusCreated))
+		})
+		first, second := created.Results[0].ID, created.Results[1].ID
+		expectAudit(t, entries, f.admin, studentChange(first, models.AuditCreate), studentChange(second, models.AuditCreate))
+
+		entries = f.audited(t, func() {
+			expect(t, f.do(t, f.token, http.MethodPut, "/api/students/bulk", []dto.BulkUpdateStudentRequest{
+				{ID: first, StudentName: "Jane Roe", ClassID: class.ID, Section: "B"},
+				{ID: second, StudentName: "John Roe", ClassID: class.ID, Section: "B"},
+			}), http.StatusOK)
+		})
+		expectAudit(t, entries, f.admin, studentChange(first, models.AuditUpdate), studentChange(second, models.AuditUpdate))
+
+		entries = f.audited(t, func() {
+			expect(t, f.do(t, f.token, http.MethodDelete, "/api/students/bulk",
+				[]dto.BulkDeleteStudentRequest{{ID: first}, {ID: second}}), http.StatusOK)
+		})
+		expectAudit(t, entries, f.admin, studentChange(first, models.AuditDelete), studentChange(second, models.AuditDelete))
+	})
+
+	t.Run("reassign", func(t *testing.T) {
+		f := newAuditFixture(t)
+		from, to := f.createClass(t, "Grade 5"), f.createClass(t, "Grade 6")
+		first, second := f.createStudent(t, "Jane Doe", from.ID), f.createStudent(t, "John Doe", from.ID)
+		// A student of another class must be left alone
+		f.createStudent(t, "Max Mustermann", to.ID)
+
+		entries := f.audited(t, func() {
+			expect(t, f.do(t, f.token, http.MethodDelete,
+				fmt.Sprintf("/api/classes/%d?policy=reassign&reassign_to=%d", from.ID, to.ID), nil), http.StatusNoContent)
+		})
+		expectAudit(t, entries, f.admin, classChange(from.ID, models.AuditDelete),
+			studentChange(first.ID, models.AuditUpdate), studentChange(second.ID, models.AuditUpdate))
+	})
+
+	t.Run("cascade delete", func(t *testing.T) {
+		f := newAuditFixture(t)
+		class := f.createClass(t, "Grade 5")
+		first, second := f.createStudent(t, "Jane Doe", class.ID), f.createStudent(t, "John Doe", class.ID)
+
+		entries := f.audited(t, func() {
+			expect(t, f.do(t, f.token, http.MethodDelete, fmt.Sprintf("/api/classes/%d?policy=cascade", class.ID), nil),
+				http.StatusNoContent)
+		})
+		expectAudit(t, entries, f.admin, classChange(class.ID, models.AuditDelete),
+			studentChange(first.ID, models.AuditDelete), studentChange(second.ID, models.AuditDelete))
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		f := newAuditFixture(t)
+		class := f.createClass(t, "Grade 5")
+		student := f.createStudent(t, "Jane Doe", class.ID)
+		path := fmt.Sprintf("/api/students/%d", student.ID)
+		expect(t, f.do(t, f.token, http.MethodDelete, path, nil), http.StatusNoContent)
+
+		entries := f.audited(t, func() {
+			expect(t, f.do(t, f.token, http.MethodPost, path+"/restore", nil), http.StatusOK)
+		})
+		expectAudit(t, entries, f.admin, studentChange(student.ID, models.AuditRestore))
+	})
+
+	t.Run("purge", func(t *testing.T) {
+		f := newAuditFixture(t)
+		class := f.createClass(t, "Grade 5")
+		student := f.createStudent(t, "Jane Doe", class.ID)
+		f.createStudent(t, "John Doe", class.ID)
+		expect(t, f.do(t, f.token, http.MethodDelete, fmt.Sprintf("/api/students/%d", student.ID), nil), http.StatusNoContent)
+
+		entries := f.audited(t, func() {
+			expect(t, f.do(t, f.token, http.MethodPost, "/api/admin/purge?older_than=1ns", nil), http.StatusOK)
+		})
+		expectAudit(t, entries, f.admin, studentChange(student.ID, models.AuditPurge))
+	})
+
+	t.Run("import", func(t *testing.T) {
+		f := newAuditFixture(t)
+		class := f.createClass(t, "Grade 5")
+		student := f.createStudent(t, "Jane Doe", class.ID)
+
+		roster := fmt.Sprintf("class_name,student_name,student_section,id\nGrade 5,Jane Doe,B,%d\nGrade 6,John Doe,A,\n", student.ID)
+		var report handler.ImportResponse
+		entries := f.audited(t, func() {
+			report = decode[handler.ImportResponse](t, expect(t, f.do(t, f.token, http.MethodPost, "/api/import", roster,
+				"Content-Type", "text/csv"), http.StatusOK))
+		})
+		want := []auditedChange{studentChange(student.ID, models.AuditUpdate)}
+		for _, item := range report.Items {
+			if item.Action == "create" {
+				want = append(want, auditedChange{Entity: item.Entity, EntityID: item.ID, Operation: models.AuditCreate})
+			}
+		}
+		if len(want) != 3 {
+			t.Fatalf("import reported %+v, want a class and a student created", report.Items)
+		}
+		expectAudit(t, entries, f.admin, want...)
+	})
+}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// APIKeyPrefix starts every API key, so keys are recognisable in
+// configuration files and secret scanners
+const APIKeyPrefix = "sk_"
+
+const (
+	// apiKeyIDBytes is the length of the public part of a key
+	apiKeyIDBytes = 6
+	// apiKeySecretBytes is the length of the secret part of a key
+	apiKeySecretBytes = 32
+)
+
+// apiKeyScopes maps the scopes API keys are granted to the permissions
+// they stand for. Keys only reach class and student routes.
+var apiKeyScopes = map[string]Permission{
+	"read:classes":   PermClassesRead,
+	"write:classes":  PermClassesWrite,
+	"read:students":  PermStudentsRead,
+	"write:students": PermStudentsWrite,
+}
+
+// APIKeyScopes lists the scopes an API key may be granted, sorted
+func APIKeyScopes() []string {
+	scopes := make([]string, 0, len(apiKeyScopes))
+	for s := range apiKeyScopes {
+		scopes = append(scopes, s)
+	}
+	slices.Sort(scopes)
+	return scopes
+}
+
+// ScopePermissions returns the permissions granted by scopes
+func ScopePermissions(scopes []string) ([]Permission, error) {
+	perms := make([]Permission, 0, len(scopes))
+	for _, s := range scopes {
+		p, ok := apiKeyScopes[s]
+		if !ok {
+			return nil, fmt.Errorf("unknown scope %q", s)
+		}
+		perms = append(perms, p)
+	}
+	return perms, nil
+}
+
+// NewAPIKey returns a new random API key and its prefix, the part of the
+// key that identifies it and may be shown and stored in the clear. Only a
+// hash of the rest (see HashAPIKey) is stored.
+func NewAPIKey() (key, prefix string, err error) {
+	b := make([]byte, apiKeyIDBytes+apiKeySecretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	prefix = APIKeyPrefix + hex.EncodeToString(b[:apiKeyIDBytes])
+	return prefix + "_" + hex.EncodeToString(b[apiKeyIDBytes:]), prefix, nil
+}
+
+// IsAPIKey reports whether s looks like an API key rather than an access token
+func IsAPIKey(s string) bool {
+	return strings.HasPrefix(s, APIKeyPrefix)
+}
+
+// ParseAPIKey splits a key into its prefix and secret
+func ParseAPIKey(key string) (prefix, secret string, ok bool) {
+	// The prefix itself contains the first underscore
+	i := strings.LastIndexByte(key, '_')
+	if !IsAPIKey(key) || i < len(APIKeyPrefix) {
+		return "", "", false
+	}
+	prefix, secret = key[:i], key[i+1:]
+	if len(prefix) != len(APIKeyPrefix)+2*apiKeyIDBytes || len(secret) != 2*apiKeySecretBytes {
+		return "", "", false
+	}
+	return prefix, secret, true
+}
+
+// HashAPIKey returns the form the secret of a key is stored in. The secret
+// is random, so a fast hash is enough.
+func HashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// CheckAPIKey reports whether secret matches a stored hash, in constant time
+func CheckAPIKey(hash, secret string) bool {
+	return hash != "" && subtle.ConstantTimeCompare([]byte(hash), []byte(HashAPIKey(secret))) == 1
+}
@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Supported signing algorithms
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+)
+
+// minSecretBytes is the shortest HS256 secret accepted; RFC 7518 asks for
+// a key at least as long as the hash output
+const minSecretBytes = 32
+
+// Key signs or verifies tokens. Tokens name the key they were signed with
+// in their kid header, so keys can be rotated without invalidating tokens
+// signed by the previous one.
+type Key struct {
+	ID        string
+	Algorithm string
+	secret    []byte
+	private   *rsa.PrivateKey
+	public    *rsa.PublicKey
+}
+
+// NewHMACKey creates an HS256 key from a shared secret
+func NewHMACKey(id string, secret []byte) (*Key, error) {
+	if len(secret) < minSecretBytes {
+		return nil, fmt.Errorf("key %q: HS256 secret must be at least %d bytes", id, minSecretBytes)
+	}
+	return &Key{ID: id, Algorithm: HS256, secret: secret}, nil
+}
+
+// NewRandomHMACKey creates an HS256 key with a random secret. Tokens
+// signed with it cannot be verified once the process exits.
+func NewRandomHMACKey(id string) (*Key, error) {
+	secret := make([]byte, minSecretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return NewHMACKey(id, secret)
+}
+
+// ParseRSAKey creates an RS256 key from PEM data. A key with a private key
+// can sign; one with only a public key verifies tokens signed before a
+// rotation.
+func ParseRSAKey(id string, privatePEM, publicPEM []byte) (*Key, error) {
+	key := &Key{ID: id, Algorithm: RS256}
+	var err error
+	if len(privatePEM) > 0 {
+		if key.private, err = jwt.ParseRSAPrivateKeyFromPEM(privatePEM); err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		key.public = &key.private.PublicKey
+	} else if len(publicPEM) > 0 {
+		if key.public, err = jwt.ParseRSAPublicKeyFromPEM(publicPEM); err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+	} else {
+		return nil, fmt.Errorf("key %q: RS256 needs a private or public key", id)
+	}
+	if key.public.N.BitLen() < 2048 {
+		return nil, fmt.Errorf("key %q: RSA keys must be at least 2048 bits", id)
+	}
+	return key, nil
+}
+
+// CanSign reports whether the key holds the material needed to sign
+func (k *Key) CanSign() bool {
+	return k.secret != nil || k.private != nil
+}
+
+func (k *Key) signingKey() any {
+	if k.Algorithm == HS256 {
+		return k.secret
+	}
+	return k.private
+}
+
+func (k *Key) verifyingKey() any {
+	if k.Algorithm == HS256 {
+		return k.secret
+	}
+	return k.public
+}
+
+// KeySet holds the key new tokens are signed with and every key tokens
+// are accepted from
+type KeySet struct {
+	signing *Key
+	keys    map[string]*Key
+}
+
+// NewKeySet creates a key set that signs with the key named signingID
+func NewKeySet(signingID string, keys ...*Key) (*KeySet, error) {
+	set := &KeySet{keys: make(map[string]*Key, len(keys))}
+	for _, k := range keys {
+		if _, dup := set.keys[k.ID]; dup {
+			return nil, fmt.Errorf("duplicate key ID %q", k.ID)
+		}
+		set.keys[k.ID] = k
+	}
+	set.signing = set.keys[signingID]
+	switch {
+	case set.signing == nil:
+		return nil, fmt.Errorf("signing key %q is not configured", signingID)
+	case !set.signing.CanSign():
+		return nil, fmt.Errorf("signing key %q has no private key", signingID)
+	}
+	return set, nil
+}
+
+// sign signs claims with the signing key
+func (s *KeySet) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(s.signing.Algorithm), claims)
+	token.Header["kid"] = s.signing.ID
+	return token.SignedString(s.signing.signingKey())
+}
+
+// verifyingKey finds the key a token names in its kid header. The token
+// must use the algorithm of that key, so an RSA public key can never be
+// used as an HMAC secret.
+func (s *KeySet) verifyingKey(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	if token.Method.Alg() != key.Algorithm {
+		return nil, errors.New("token algorithm does not match its key")
+	}
+	return key.verifyingKey(), nil
+}
+
+// JWK is a public key in JSON Web Key form (RFC 7517)
+type JWK struct {
+	KeyType   string `json:"kty" example:"RSA"`
+	KeyID     string `json:"kid" example:"2024-09"`
+	Algorithm string `json:"alg" example:"RS256"`
+	Use       string `json:"use" example:"sig"`
+	Modulus   string `json:"n"`
+	Exponent  string `json:"e" example:"AQAB"`
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys of the RS256 keys in the set, so that other
+// services can verify tokens without sharing a secret. HS256 keys are
+// never published.
+func (s *KeySet) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	for _, k := range s.keys {
+		if k.Algorithm != RS256 {
+			continue
+		}
+		set.Keys = append(set.Keys, JWK{
+			KeyType:   "RSA",
+			KeyID:     k.ID,
+			Algorithm: RS256,
+			Use:       "sig",
+			Modulus:   base64.RawURLEncoding.EncodeToString(k.public.N.Bytes()),
+			Exponent:  base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.public.E)).Bytes()),
+		})
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].KeyID < set.Keys[j].KeyID })
+	return set
+}
@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrPasswordTooLong is returned for passwords longer than the 72 bytes
+// bcrypt can hash
+var ErrPasswordTooLong = bcrypt.ErrPasswordTooLong
+
+// dummyHash is compared against when a user does not exist, so that a
+// failed login takes as long whether or not the username is known
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("school-api"), bcrypt.DefaultCost)
+	return hash
+})
+
+// HashPassword returns the bcrypt hash of password
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+// CheckPassword reports whether password matches hash. An empty hash
+// never matches but takes as long to check as a real one.
+func CheckPassword(hash, password string) bool {
+	if hash == "" {
+		bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"context"
+	"slices"
+)
+
+// Principal is the authenticated user or API key a request is made by
+type Principal struct {
+	UserID   uint
+	Username string
+	Role     Role
+	// TenantID is the school the principal belongs to, zero for the default
+	TenantID uint
+	// APIKeyID is set when the request was made with an API key, which
+	// has the permissions of its scopes instead of a role
+	APIKeyID    uint
+	Permissions []Permission
+}
+
+// IsAPIKey reports whether the principal is an API key
+func (p *Principal) IsAPIKey() bool {
+	return p.APIKeyID != 0
+}
+
+// Can reports whether the principal's role, or API key scopes, have
+// permission p
+func (p *Principal) Can(perm Permission) bool {
+	if p.IsAPIKey() {
+		return slices.Contains(p.Permissions, perm)
+	}
+	return p.Role.Can(perm)
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying p
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the principal stored in ctx by WithPrincipal
+func PrincipalFrom(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Role is the part a user plays at the school. It decides which
+// permissions the user has; which rows they see is decided by the service
+// layer from the role (see repository.Scope).
+type Role string
+
+// Roles
+const (
+	RoleAdmin    Role = "admin"
+	RoleTeacher  Role = "teacher"
+	RoleGuardian Role = "guardian"
+	RoleStudent  Role = "student"
+)
+
+// Roles lists every role, for validation and documentation
+var Roles = []Role{RoleAdmin, RoleTeacher, RoleGuardian, RoleStudent}
+
+// Permission is the right to use a group of routes
+type Permission string
+
+// Permissions
+const (
+	PermClassesRead   Permission = "classes:read"
+	PermClassesWrite  Permission = "classes:write"
+	PermStudentsRead  Permission = "students:read"
+	PermStudentsWrite Permission = "students:write"
+	PermRosterImport  Permission = "roster:import"
+	PermRosterSync    Permission = "roster:sync"
+	PermUsersManage   Permission = "users:manage"
+	PermAPIKeysManage Permission = "api_keys:manage"
+	PermAuditRead     Permission = "audit:read"
+	PermAdmin         Permission = "admin"
+)
+
+// rolePermissions lists what each role may do. Teachers may change the
+// students in their own classes but not the classes themselves; guardians
+// and students may only read.
+var rolePermissions = map[Role][]Permission{
+	RoleAdmin: {
+		PermClassesRead, PermClassesWrite, PermStudentsRead, PermStudentsWrite,
+		PermRosterImport, PermRosterSync, PermUsersManage, PermAPIKeysManage, PermAuditRead, PermAdmin,
+	},
+	RoleTeacher:  {PermClassesRead, PermStudentsRead, PermStudentsWrite},
+	RoleGuardian: {PermClassesRead, PermStudentsRead},
+	RoleStudent:  {PermClassesRead, PermStudentsRead},
+}
+
+// ParseRole parses a role name, ignoring case
+func ParseRole(s string) (Role, error) {
+	r := Role(strings.ToLower(strings.TrimSpace(s)))
+	if _, ok := rolePermissions[r]; !ok {
+		return "", fmt.Errorf("unknown role %q", s)
+	}
+	return r, nil
+}
+
+// Can reports whether the role has permission p. Unknown roles, including
+// the empty role of tokens issued before roles existed, have none.
+func (r Role) Can(p Permission) bool {
+	for _, granted := range rolePermissions[r] {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
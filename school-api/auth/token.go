@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrInvalidToken is returned for access tokens that are malformed,
+// expired, or not signed by a known key
+var ErrInvalidToken = errors.New("invalid token")
+
+// clockSkew is how far token times may be off between servers
+const clockSkew = 30 * time.Second
+
+// Claims are the claims carried by an access token
+type Claims struct {
+	jwt.RegisteredClaims
+	Username string `json:"preferred_username"`
+	Role     Role   `json:"role"`
+	// TenantID is the school the user belongs to; tokens issued before
+	// tenants existed have none and belong to the default tenant
+	TenantID uint `json:"tid,omitempty"`
+}
+
+// Issuer issues and verifies access tokens
+type Issuer struct {
+	keys   *KeySet
+	issuer string
+	ttl    time.Duration
+	parser *jwt.Parser
+}
+
+// NewIssuer creates an issuer that signs tokens valid for ttl with keys.
+// name is the iss claim tokens are issued with and must carry.
+func NewIssuer(keys *KeySet, name string, ttl time.Duration) *Issuer {
+	return &Issuer{
+		keys:   keys,
+		issuer: name,
+		ttl:    ttl,
+		parser: jwt.NewParser(
+			jwt.WithValidMethods([]string{HS256, RS256}),
+			jwt.WithIssuer(name),
+			jwt.WithExpirationRequired(),
+			jwt.WithIssuedAt(),
+			jwt.WithLeeway(clockSkew),
+		),
+	}
+}
+
+// Keys returns the keys tokens are signed and verified with
+func (i *Issuer) Keys() *KeySet {
+	return i.keys
+}
+
+// Issue signs an access token for p and returns it with its expiry time
+func (i *Issuer) Issue(p Principal) (string, time.Time, error) {
+	now := time.Now()
+	expires := now.Add(i.ttl)
+	token, err := i.keys.sign(Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    i.issuer,
+			Subject:   strconv.FormatUint(uint64(p.UserID), 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expires),
+		},
+		Username: p.Username,
+		Role:     p.Role,
+		TenantID: p.TenantID,
+	})
+	return token, expires, err
+}
+
+// Verify checks an access token and returns the principal it was issued to
+func (i *Issuer) Verify(token string) (*Principal, error) {
+	var claims Claims
+	if _, err := i.parser.ParseWithClaims(token, &claims, i.keys.verifyingKey); err != nil {
+		return nil, errors.Join(ErrInvalidToken, err)
+	}
+	id, err := strconv.ParseUint(claims.Subject, 10, 0)
+	if err != nil || id == 0 {
+		return nil, ErrInvalidToken
+	}
+	return &Principal{UserID: uint(id), Username: claims.Username, Role: claims.Role, TenantID: claims.TenantID}, nil
+}
+
+// NewRefreshToken returns a random opaque refresh token. Only its hash
+// (see HashRefreshToken) is stored.
+func NewRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashRefreshToken returns the form a refresh token is stored and looked
+// up in. The token is random, so a fast hash is enough.
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
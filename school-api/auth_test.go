@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"school-api/auth"
+	"school-api/config"
+	"school-api/dto"
+	"school-api/models"
+	"testing"
+	"time"
+)
+
+// invalidTokenChallenge is the WWW-Authenticate header of a rejected token
+const invalidTokenChallenge = `Bearer realm="school-api", error="invalid_token"`
+
+// TestAuthRequired checks that the default configuration protects every
+// API and OneRoster route, and that anonymous clients cannot pick a tenant
+func TestAuthRequired(t *testing.T) {
+	a := newTestApp(t)
+	a.tenant("b")
+
+	for _, path := range []string{"/api/classes", "/api/students?include_deleted=true", "/ims/oneroster/v1p2/users"} {
+		rec := expect(t, a.do(t, "", http.MethodGet, path, nil), http.StatusUnauthorized)
+		if rec.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: 401 without a WWW-Authenticate challenge", path)
+		}
+		expect(t, a.do(t, "", http.MethodGet, path, nil, "X-Tenant", "b"), http.StatusUnauthorized)
+	}
+
+	rec := expect(t, a.do(t, "not-a-token", http.MethodGet, "/api/classes", nil), http.StatusUnauthorized)
+	if got := rec.Header().Get("WWW-Authenticate"); got != invalidTokenChallenge {
+		t.Errorf("invalid token challenge is %q, want %q", got, invalidTokenChallenge)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	a := newTestApp(t)
+	token := a.login("default", "admin", auth.RoleAdmin)
+	me := decode[dto.UserResponse](t, expect(t, a.do(t, token, http.MethodGet, "/api/auth/me", nil), http.StatusOK))
+	if me.Username != "admin" || me.Role != string(auth.RoleAdmin) {
+		t.Errorf("signed in as %+v, want the admin", me)
+	}
+
+	expect(t, a.do(t, "", http.MethodPost, "/api/auth/login",
+		dto.LoginRequest{Username: "admin", Password: "wrong"}), http.StatusUnauthorized)
+	expect(t, a.do(t, "", http.MethodPost, "/api/auth/login",
+		dto.LoginRequest{Username: "nobody", Password: "wrong"}), http.StatusUnauthorized)
+}
+
+func TestRoleLacksPermission(t *testing.T) {
+	a := newTestApp(t)
+	token := a.login("default", "teacher", auth.RoleTeacher)
+	expect(t, a.do(t, token, http.MethodGet, "/api/users", nil), http.StatusForbidden)
+	expect(t, a.do(t, token, http.MethodPost, "/api/classes", dto.CreateClassRequest{ClassName: "Grade 5"}), http.StatusForbidden)
+}
+
+func TestExpiredAccessToken(t *testing.T) {
+	a := newTestApp(t, func(cfg *config.Config) {
+		cfg.Auth.SigningKey = "test"
+		cfg.Auth.Keys = []config.AuthKeyConfig{{ID: "test", Algorithm: auth.HS256, Secret: "0123456789abcdef0123456789abcdef"}}
+	})
+	a.login("default", "admin", auth.RoleAdmin)
+	var user models.User
+	if err := a.db.Where("username = ?", "admin").First(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// A token signed with the server's key that expired longer ago than
+	// the clock skew allowed for
+	keys, err := a.cfg.AuthKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, _, err := auth.NewIssuer(keys, a.cfg.Auth.Issuer, -time.Hour).Issue(auth.Principal{
+		UserID: user.ID, Username: user.Username, Role: auth.RoleAdmin, TenantID: user.TenantID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := expect(t, a.do(t, expired, http.MethodGet, "/api/classes", nil), http.StatusUnauthorized)
+	if got := rec.Header().Get("WWW-Authenticate"); got != invalidTokenChallenge {
+		t.Errorf("expired token challenge is %q, want %q", got, invalidTokenChallenge)
+	}
+}
+
+func TestRefreshToken(t *testing.T) {
+	a := newTestApp(t)
+	a.login("default", "admin", auth.RoleAdmin)
+	login := func() dto.TokenResponse {
+		return decode[dto.TokenResponse](t, expect(t, a.do(t, "", http.MethodPost, "/api/auth/login",
+			dto.LoginRequest{Username: "admin", Password: "correct horse battery staple"}), http.StatusOK))
+	}
+
+	t.Run("rotates", func(t *testing.T) {
+		tokens := login()
+		refreshed := decode[dto.TokenResponse](t, expect(t, a.do(t, "", http.MethodPost, "/api/auth/refresh",
+			dto.RefreshRequest{RefreshToken: tokens.RefreshToken}), http.StatusOK))
+		expect(t, a.do(t, refreshed.AccessToken, http.MethodGet, "/api/auth/me", nil), http.StatusOK)
+		// A refresh token is used up by the refresh
+		expect(t, a.do(t, "", http.MethodPost, "/api/auth/refresh",
+			dto.RefreshRequest{RefreshToken: tokens.RefreshToken}), http.StatusUnauthorized)
+	})
+
+	t.Run("logout", func(t *testing.T) {
+		tokens := login()
+		expect(t, a.do(t, "", http.MethodPost, "/api/auth/logout",
+			dto.RefreshRequest{RefreshToken: tokens.RefreshToken}), http.StatusNoContent)
+		expect(t, a.do(t, "", http.MethodPost, "/api/auth/refresh",
+			dto.RefreshRequest{RefreshToken: tokens.RefreshToken}), http.StatusUnauthorized)
+	})
+
+	t.Run("disabled user", func(t *testing.T) {
+		tokens := login()
+		admin := a.login("default", "admin2", auth.RoleAdmin)
+		var user models.User
+		if err := a.db.Where("username = ?", "admin").First(&user).Error; err != nil {
+			t.Fatal(err)
+		}
+		expect(t, a.do(t, admin, http.MethodPut, fmt.Sprintf("/api/users/%d", user.ID),
+			dto.UpdateUserRequest{Role: string(auth.RoleAdmin), Disabled: true}), http.StatusOK)
+
+		expect(t, a.do(t, "", http.MethodPost, "/api/auth/refresh",
+			dto.RefreshRequest{RefreshToken: tokens.RefreshToken}), http.StatusUnauthorized)
+		expect(t, a.do(t, "", http.MethodPost, "/api/auth/login",
+			dto.LoginRequest{Username: "admin", Password: "correct horse battery staple"}), http.StatusForbidden)
+	})
+}
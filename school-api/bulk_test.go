@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"school-api/auth"
+	"school-api/dto"
+	"school-api/handler"
+	"school-api/models"
+	"testing"
+)
+
+// bulkFixture is a class of students for bulk requests to work on
+type bulkFixture struct {
+	*testApp
+	token    string
+	class    dto.ClassResponse
+	students []dto.StudentResponse
+}
+
+func newBulkFixture(t *testing.T) *bulkFixture {
+	a := newTestApp(t)
+	f := &bulkFixture{testApp: a, token: a.login("default", "admin", auth.RoleAdmin)}
+	f.class = decode[dto.ClassResponse](t, expect(t, a.do(t, f.token, http.MethodPost, "/api/classes",
+		dto.CreateClassRequest{ClassName: "Grade 5"}), http.StatusCreated))
+	for _, name := range []string{"Jane Doe", "John Doe", "Max Mustermann"} {
+		f.students = append(f.students, decode[dto.StudentResponse](t, expect(t, a.do(t, f.token, http.MethodPost, "/api/students",
+			dto.CreateStudentRequest{StudentName: name, ClassID: f.class.ID, Section: "A"}), http.StatusCreated)))
+	}
+	return f
+}
+
+// student returns the student as stored, or nil if it is gone
+func (f *bulkFixture) student(t *testing.T, id uint) *dto.StudentResponse {
+	t.Helper()
+	rec := f.do(t, f.token, http.MethodGet, fmt.Sprintf("/api/students/%d", id), nil)
+	if rec.Code == http.StatusNotFound {
+		return nil
+	}
+	s := decode[dto.StudentResponse](t, expect(t, rec, http.StatusOK))
+	return &s
+}
+
+func (f *bulkFixture) studentCount(t *testing.T) int {
+	t.Helper()
+	class := decode[dto.ClassResponse](t, expect(t, f.do(t, f.token, http.MethodGet,
+		fmt.Sprintf("/api/classes/%d", f.class.ID), nil), http.StatusOK))
+	return class.StudentCount
+}
+
+func (f *bulkFixture) auditCount(t *testing.T, operation string) int64 {
+	t.Helper()
+	var n int64
+	if err := f.db.Model(&models.AuditEntry{}).Where("entity = ? AND operation = ?", "student", operation).Count(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// TestBulkPartial checks that in partial mode a failing item is rolled back
+// on its own, leaving the items before and after it applied
+func TestBulkPartial(t *testing.T) {
+	t.Run("update", func(t *testing.T) {
+		f := newBulkFixture(t)
+		jane, john := f.students[0], f.students[1]
+		// The second item is based on the version the first one replaces
+		resp := decode[handler.BulkResponse[dto.StudentResponse]](t, expect(t, f.do(t, f.token, http.MethodPut, "/api/students/bulk?mode=partial",
+			[]dto.BulkUpdateStudentRequest{
+				{ID: jane.ID, StudentName: "Jane Roe", ClassID: f.class.ID, Section: "B", Version: jane.Version},
+				{ID: jane.ID, StudentName: "Jane Poe", ClassID: f.class.ID, Section: "C", Version: jane.Version},
+				{ID: john.ID, StudentName: "John Roe", ClassID: f.class.ID, Section: "B", Version: john.Version},
+			}), http.StatusMultiStatus))
+
+		want := []int{http.StatusOK, http.StatusPreconditionFailed, http.StatusOK}
+		for i, result := range resp.Results {
+			if result.Status != want[i] {
+				t.Errorf("item %d has status %d, want %d: %+v", i, result.Status, want[i], result.Error)
+			}
+		}
+		if resp.Succeeded != 2 || resp.Failed != 1 {
+			t.Errorf("got %d succeeded and %d failed, want 2 and 1", resp.Succeeded, resp.Failed)
+		}
+		if got := f.student(t, jane.ID); got.StudentName != "Jane Roe" || got.Section != "B" {
+			t.Errorf("Jane is now %+v, want the first update kept", got)
+		}
+		if got := f.student(t, john.ID); got.StudentName != "John Roe" {
+			t.Errorf("John is now %+v, want the update after the failure applied", got)
+		}
+		if n := f.auditCount(t, models.AuditUpdate); n != 2 {
+			t.Errorf("%d updates were audited, want 2", n)
+		}
+	})
+
+	t.Run("create", func(t *testing.T) {
+		f := newBulkFixture(t)
+		resp := decode[handler.BulkResponse[dto.StudentResponse]](t, expect(t, f.do(t, f.token, http.MethodPost, "/api/students/bulk?mode=partial",
+			[]dto.CreateStudentRequest{
+				{StudentName: "Ann Lee", ClassID: f.class.ID, Section: "A"},
+				{StudentName: "Ben Lee", ClassID: f.class.ID + 100, Section: "A"},
+				{StudentName: "", ClassID: f.class.ID, Section: "A"},
+				{StudentName: "Cat Lee", ClassID: f.class.ID, Section: "A"},
+			}), http.StatusMultiStatus))
+		if resp.Succeeded != 2 || resp.Failed != 2 || resp.Results[1].Error == nil || resp.Results[2].Error == nil {
+			t.Errorf("got results %+v, want the second and third items to fail", resp.Results)
+		}
+		if n := f.studentCount(t); n != 5 {
+			t.Errorf("class has %d students, want 5", n)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		f := newBulkFixture(t)
+		jane, john := f.students[0], f.students[1]
+		resp := decode[handler.BulkResponse[dto.StudentResponse]](t, expect(t, f.do(t, f.token, http.MethodDelete, "/api/students/bulk?mode=partial",
+			[]dto.BulkDeleteStudentRequest{{ID: jane.ID}, {ID: jane.ID}, {ID: john.ID, Version: john.Version + 1}}),
+			http.StatusMultiStatus))
+		want := []int{http.StatusOK, http.StatusNotFound, http.StatusPreconditionFailed}
+		for i, result := range resp.Results {
+			if result.Status != want[i] {
+				t.Errorf("item %d has status %d, want %d", i, result.Status, want[i])
+			}
+		}
+		if f.student(t, jane.ID) != nil || f.student(t, john.ID) == nil {
+			t.Error("want Jane deleted and John kept")
+		}
+		if n := f.studentCount(t); n != 2 {
+			t.Errorf("class has %d students, want 2", n)
+		}
+	})
+}
+
+// TestBulkAtomic checks that in atomic mode one failing item leaves every
+// item unapplied
+func TestBulkAtomic(t *testing.T) {
+	t.Run("update", func(t *testing.T) {
+		f := newBulkFixture(t)
+		jane, john := f.students[0], f.students[1]
+		expect(t, f.do(t, f.token, http.MethodPut, "/api/students/bulk", []dto.BulkUpdateStudentRequest{
+			{ID: jane.ID, StudentName: "Jane Roe", ClassID: f.class.ID, Section: "B"},
+			{ID: john.ID, StudentName: "John Roe", ClassID: f.class.ID, Section: "B", Version: john.Version + 1},
+		}), http.StatusPreconditionFailed)
+		if got := f.student(t, jane.ID); got.StudentName != jane.StudentName || got.Version != jane.Version {
+			t.Errorf("Jane is now %+v, want her unchanged", got)
+		}
+		if n := f.auditCount(t, models.AuditUpdate); n != 0 {
+			t.Errorf("%d updates were audited, want none", n)
+		}
+	})
+
+	t.Run("create", func(t *testing.T) {
+		f := newBulkFixture(t)
+		expect(t, f.do(t, f.token, http.MethodPost, "/api/students/bulk", []dto.CreateStudentRequest{
+			{StudentName: "Ann Lee", ClassID: f.class.ID, Section: "A"},
+			{StudentName: "Ben Lee", ClassID: f.class.ID, Section: "Z"},
+		}), http.StatusUnprocessableEntity)
+		if n := f.studentCount(t); n != 3 {
+			t.Errorf("class has %d students, want 3", n)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		f := newBulkFixture(t)
+		jane := f.students[0]
+		expect(t, f.do(t, f.token, http.MethodDelete, "/api/students/bulk",
+			[]dto.BulkDeleteStudentRequest{{ID: jane.ID}, {ID: jane.ID + 100}}), http.StatusNotFound)
+		if f.student(t, jane.ID) == nil {
+			t.Error("Jane was deleted along with a missing student")
+		}
+	})
+}
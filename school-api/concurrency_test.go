@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"school-api/apperror"
+	"school-api/auth"
+	"school-api/config"
+	"school-api/dto"
+	"testing"
+)
+
+// TestConditionalRequests checks that writes based on a stale ETag fail with
+// 412 and leave the record alone, and that GETs honour If-None-Match
+func TestConditionalRequests(t *testing.T) {
+	a := newTestApp(t)
+	token := a.login("default", "admin", auth.RoleAdmin)
+	class := decode[dto.ClassResponse](t, expect(t, a.do(t, token, http.MethodPost, "/api/classes",
+		dto.CreateClassRequest{ClassName: "Grade 5"}), http.StatusCreated))
+	classPath := fmt.Sprintf("/api/classes/%d", class.ID)
+	createStudent := func(t *testing.T) string {
+		student := decode[dto.StudentResponse](t, expect(t, a.do(t, token, http.MethodPost, "/api/students",
+			dto.CreateStudentRequest{StudentName: "Jane Doe", ClassID: class.ID, Section: "A"}), http.StatusCreated))
+		return fmt.Sprintf("/api/students/%d", student.ID)
+	}
+	get := func(t *testing.T, path string) (dto.StudentResponse, string) {
+		rec := expect(t, a.do(t, token, http.MethodGet, path, nil), http.StatusOK)
+		return decode[dto.StudentResponse](t, rec), rec.Header().Get("ETag")
+	}
+
+	t.Run("lost update", func(t *testing.T) {
+		path := createStudent(t)
+		_, first := get(t, path)
+		_, second := get(t, path)
+
+		rec := expect(t, a.do(t, token, http.MethodPut, path,
+			dto.UpdateStudentRequest{StudentName: "Jane Roe", ClassID: class.ID, Section: "A"}, "If-Match", first), http.StatusOK)
+		if rec.Header().Get("ETag") == first {
+			t.Errorf("update kept ETag %s", first)
+		}
+		rec = expect(t, a.do(t, token, http.MethodPut, path,
+			dto.UpdateStudentRequest{StudentName: "Jane Poe", ClassID: class.ID, Section: "B"}, "If-Match", second),
+			http.StatusPreconditionFailed)
+		if problem := decode[apperror.Problem](t, rec); problem.Status != http.StatusPreconditionFailed {
+			t.Errorf("got problem %+v", problem)
+		}
+
+		student, _ := get(t, path)
+		if student.StudentName != "Jane Roe" || student.Section != "A" {
+			t.Errorf("student is now %+v, want the first update only", student)
+		}
+	})
+
+	t.Run("stale patch and delete", func(t *testing.T) {
+		path := createStudent(t)
+		_, stale := get(t, path)
+		expect(t, a.do(t, token, http.MethodPatch, path, `{"student_section":"B"}`,
+			"Content-Type", "application/merge-patch+json"), http.StatusOK)
+
+		expect(t, a.do(t, token, http.MethodPatch, path, `{"student_section":"C"}`,
+			"Content-Type", "application/merge-patch+json", "If-Match", stale), http.StatusPreconditionFailed)
+		expect(t, a.do(t, token, http.MethodDelete, path, nil, "If-Match", stale), http.StatusPreconditionFailed)
+		expect(t, a.do(t, token, http.MethodPut, path+"/upsert",
+			dto.UpdateStudentRequest{StudentName: "Jane Doe", ClassID: class.ID, Section: "C"}, "If-Match", stale),
+			http.StatusPreconditionFailed)
+		if student, _ := get(t, path); student.Section != "B" {
+			t.Errorf("student is in section %s, want B", student.Section)
+		}
+
+		_, current := get(t, path)
+		expect(t, a.do(t, token, http.MethodDelete, path, nil, "If-Match", current), http.StatusNoContent)
+	})
+
+	t.Run("class", func(t *testing.T) {
+		rec := expect(t, a.do(t, token, http.MethodGet, classPath, nil), http.StatusOK)
+		stale := rec.Header().Get("ETag")
+		expect(t, a.do(t, token, http.MethodPut, classPath, dto.UpdateClassRequest{ClassName: "Grade 5a"}, "If-Match", stale), http.StatusOK)
+		expect(t, a.do(t, token, http.MethodPut, classPath, dto.UpdateClassRequest{ClassName: "Grade 5b"}, "If-Match", stale),
+			http.StatusPreconditionFailed)
+		expect(t, a.do(t, token, http.MethodDelete, classPath, nil, "If-Match", stale), http.StatusPreconditionFailed)
+		got := decode[dto.ClassResponse](t, expect(t, a.do(t, token, http.MethodGet, classPath, nil), http.StatusOK))
+		if got.ClassName != "Grade 5a" {
+			t.Errorf("class is named %q, want %q", got.ClassName, "Grade 5a")
+		}
+	})
+
+	t.Run("if-match forms", func(t *testing.T) {
+		path := createStudent(t)
+		_, current := get(t, path)
+		update := dto.UpdateStudentRequest{StudentName: "Jane Doe", ClassID: class.ID, Section: "A"}
+		expect(t, a.do(t, token, http.MethodPut, path, update, "If-Match", "W/"+current), http.StatusPreconditionFailed)
+		expect(t, a.do(t, token, http.MethodPut, path, update, "If-Match", current+`, "99"`), http.StatusBadRequest)
+		expect(t, a.do(t, token, http.MethodPut, path, update, "If-Match", "*"), http.StatusOK)
+	})
+
+	t.Run("if-none-match", func(t *testing.T) {
+		path := createStudent(t)
+		_, current := get(t, path)
+		rec := expect(t, a.do(t, token, http.MethodGet, path, nil, "If-None-Match", "W/"+current), http.StatusNotModified)
+		if rec.Body.Len() != 0 || rec.Header().Get("ETag") != current {
+			t.Errorf("304 has ETag %q and body %q, want ETag %s and no body", rec.Header().Get("ETag"), rec.Body, current)
+		}
+		expect(t, a.do(t, token, http.MethodGet, path, nil, "If-None-Match", `"99"`), http.StatusOK)
+	})
+}
+
+// TestIfMatchRequired checks that with require_if_match set, writes must
+// name the version they are based on
+func TestIfMatchRequired(t *testing.T) {
+	a := newTestApp(t, func(cfg *config.Config) { cfg.Concurrency.RequireIfMatch = true })
+	token := a.login("default", "admin", auth.RoleAdmin)
+	class := decode[dto.ClassResponse](t, expect(t, a.do(t, token, http.MethodPost, "/api/classes",
+		dto.CreateClassRequest{ClassName: "Grade 5"}), http.StatusCreated))
+	rec := expect(t, a.do(t, token, http.MethodPost, "/api/students",
+		dto.CreateStudentRequest{StudentName: "Jane Doe", ClassID: class.ID, Section: "A"}), http.StatusCreated)
+	student := decode[dto.StudentResponse](t, rec)
+	path := fmt.Sprintf("/api/students/%d", student.ID)
+	update := dto.UpdateStudentRequest{StudentName: "Jane Roe", ClassID: class.ID, Section: "A"}
+
+	expect(t, a.do(t, token, http.MethodPut, path, update), http.StatusPreconditionRequired)
+	expect(t, a.do(t, token, http.MethodPatch, path, `{"student_section":"B"}`,
+		"Content-Type", "application/merge-patch+json"), http.StatusPreconditionRequired)
+	expect(t, a.do(t, token, http.MethodDelete, path, nil), http.StatusPreconditionRequired)
+	expect(t, a.do(t, token, http.MethodPut, "/api/students/bulk",
+		[]dto.BulkUpdateStudentRequest{{ID: student.ID, StudentName: "Jane Roe", ClassID: class.ID, Section: "A"}}),
+		http.StatusPreconditionRequired)
+
+	// Upserts can create, so they never require it
+	expect(t, a.do(t, token, http.MethodPut, path+"/upsert", update), http.StatusOK)
+	rec = expect(t, a.do(t, token, http.MethodGet, path, nil), http.StatusOK)
+	expect(t, a.do(t, token, http.MethodPut, path, update, "If-Match", rec.Header().Get("ETag")), http.StatusOK)
+}
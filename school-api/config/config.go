@@ -0,0 +1,371 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"school-api/auth"
+	"school-api/database"
+	"school-api/handler"
+	"school-api/oneroster"
+	"school-api/repository"
+	"school-api/service"
+)
+
+// Config holds all runtime settings for the API server
+type Config struct {
+	Database    DatabaseConfig    `yaml:"database" toml:"database"`
+	Server      ServerConfig      `yaml:"server" toml:"server"`
+	Swagger     SwaggerConfig     `yaml:"swagger" toml:"swagger"`
+	Classes     ClassesConfig     `yaml:"classes" toml:"classes"`
+	Concurrency ConcurrencyConfig `yaml:"concurrency" toml:"concurrency"`
+	Purge       PurgeConfig       `yaml:"purge" toml:"purge"`
+	OneRoster   OneRosterConfig   `yaml:"oneroster" toml:"oneroster"`
+	Auth        AuthConfig        `yaml:"auth" toml:"auth"`
+	Tenancy     TenancyConfig     `yaml:"tenancy" toml:"tenancy"`
+}
+
+// DatabaseConfig selects the database driver and connection string
+type DatabaseConfig struct {
+	Driver        string              `yaml:"driver" toml:"driver"`
+	DSN           string              `yaml:"dsn" toml:"dsn"`
+	QueryTimeouts QueryTimeoutsConfig `yaml:"query_timeouts" toml:"query_timeouts"`
+}
+
+// QueryTimeoutsConfig bounds how long each kind of query may run; zero disables the limit
+type QueryTimeoutsConfig struct {
+	Read   Duration `yaml:"read" toml:"read"`
+	List   Duration `yaml:"list" toml:"list"`
+	Write  Duration `yaml:"write" toml:"write"`
+	Export Duration `yaml:"export" toml:"export"`
+}
+
+// ServerConfig controls the HTTP listener and its lifecycle
+type ServerConfig struct {
+	Port            int      `yaml:"port" toml:"port"`
+	ReadTimeout     Duration `yaml:"read_timeout" toml:"read_timeout"`
+	WriteTimeout    Duration `yaml:"write_timeout" toml:"write_timeout"`
+	IdleTimeout     Duration `yaml:"idle_timeout" toml:"idle_timeout"`
+	ShutdownTimeout Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+}
+
+// SwaggerConfig controls the Swagger documentation host and browser launch
+type SwaggerConfig struct {
+	Host        string `yaml:"host" toml:"host"`
+	OpenBrowser bool   `yaml:"open_browser" toml:"open_browser"`
+}
+
+// ClassesConfig sets what happens to students when their class is deleted
+type ClassesConfig struct {
+	DeletePolicy string `yaml:"delete_policy" toml:"delete_policy"`
+	ReassignTo   uint   `yaml:"reassign_to" toml:"reassign_to"`
+}
+
+// ConcurrencyConfig controls optimistic locking of API writes
+type ConcurrencyConfig struct {
+	// RequireIfMatch makes PUT, PATCH and DELETE fail with 428 unless they
+	// send If-Match with the ETag of the record they change
+	RequireIfMatch bool `yaml:"require_if_match" toml:"require_if_match"`
+}
+
+// PurgeConfig controls how long soft-deleted records are kept
+type PurgeConfig struct {
+	// Retention is how long deleted records can still be restored
+	Retention Duration `yaml:"retention" toml:"retention"`
+	// Interval is how often expired records are purged; zero disables the schedule
+	Interval Duration `yaml:"interval" toml:"interval"`
+}
+
+// OneRosterConfig describes the organization OneRoster records belong to
+type OneRosterConfig struct {
+	OrgSourcedID string `yaml:"org_sourced_id" toml:"org_sourced_id"`
+	OrgName      string `yaml:"org_name" toml:"org_name"`
+}
+
+// AuthConfig controls sign-in and the access tokens that protect the API
+type AuthConfig struct {
+	// Enabled requires an access token on /api and OneRoster routes. It is
+	// on unless set to false, so deployments from before user accounts must
+	// create an admin with create-user, or turn it off explicitly to keep
+	// the API open.
+	Enabled bool `yaml:"enabled" toml:"enabled"`
+	// ProtectSwagger requires an access token for the Swagger UI as well
+	ProtectSwagger  bool     `yaml:"protect_swagger" toml:"protect_swagger"`
+	Issuer          string   `yaml:"issuer" toml:"issuer"`
+	AccessTokenTTL  Duration `yaml:"access_token_ttl" toml:"access_token_ttl"`
+	RefreshTokenTTL Duration `yaml:"refresh_token_ttl" toml:"refresh_token_ttl"`
+	// SigningKey is the ID of the key new tokens are signed with, by
+	// default the first key. The other keys only verify tokens, so a key is
+	// rotated by signing with a new one and removing the old one once the
+	// tokens it signed have expired.
+	SigningKey string          `yaml:"signing_key" toml:"signing_key"`
+	Keys       []AuthKeyConfig `yaml:"keys" toml:"keys"`
+}
+
+// AuthKeyConfig is a token key. HS256 keys have a secret; RS256 keys have
+// a PEM private key file, or only a public key file once they no longer sign.
+type AuthKeyConfig struct {
+	ID             string `yaml:"id" toml:"id"`
+	Algorithm      string `yaml:"algorithm" toml:"algorithm"`
+	Secret         string `yaml:"secret" toml:"secret"`
+	PrivateKeyFile string `yaml:"private_key_file" toml:"private_key_file"`
+	PublicKeyFile  string `yaml:"public_key_file" toml:"public_key_file"`
+}
+
+// TenancyConfig controls how requests that are not tied to a tenant by
+// their token or API key pick one; requests naming none use the default tenant
+type TenancyConfig struct {
+	// Header is the request header naming the tenant by its slug
+	Header string `yaml:"header" toml:"header"`
+	// BaseDomain, when set, takes the tenant from the first label of hosts
+	// under it, so acme.schools.example selects tenant acme
+	BaseDomain string `yaml:"base_domain" toml:"base_domain"`
+}
+
+// Default returns the configuration used when nothing else is provided
+func Default() *Config {
+	return &Config{
+		Database: DatabaseConfig{
+			Driver: database.DriverSQLServer,
+			QueryTimeouts: QueryTimeoutsConfig{
+				Read:   Duration(5 * time.Second),
+				List:   Duration(15 * time.Second),
+				Write:  Duration(10 * time.Second),
+				Export: Duration(5 * time.Minute),
+			},
+		},
+		Server: ServerConfig{
+			Port:            8081,
+			ReadTimeout:     Duration(15 * time.Second),
+			WriteTimeout:    Duration(30 * time.Second),
+			IdleTimeout:     Duration(60 * time.Second),
+			ShutdownTimeout: Duration(20 * time.Second),
+		},
+		Swagger: SwaggerConfig{
+			OpenBrowser: true,
+		},
+		Classes: ClassesConfig{
+			DeletePolicy: string(service.DeleteRestrict),
+		},
+		Purge: PurgeConfig{
+			Retention: Duration(30 * 24 * time.Hour),
+			Interval:  Duration(time.Hour),
+		},
+		OneRoster: OneRosterConfig{
+			OrgSourcedID: "school-api",
+			OrgName:      "School",
+		},
+		Auth: AuthConfig{
+			Enabled:         true,
+			Issuer:          "school-api",
+			AccessTokenTTL:  Duration(15 * time.Minute),
+			RefreshTokenTTL: Duration(30 * 24 * time.Hour),
+		},
+		Tenancy: TenancyConfig{
+			Header: "X-Tenant",
+		},
+	}
+}
+
+// RepositoryTimeouts returns the per-operation query deadlines
+func (c *Config) RepositoryTimeouts() repository.Timeouts {
+	return repository.Timeouts{
+		Read:   c.Database.QueryTimeouts.Read.Std(),
+		List:   c.Database.QueryTimeouts.List.Std(),
+		Write:  c.Database.QueryTimeouts.Write.Std(),
+		Export: c.Database.QueryTimeouts.Export.Std(),
+	}
+}
+
+// ClassDeleteOptions returns the default policy applied when deleting a class
+func (c *Config) ClassDeleteOptions() service.DeleteClassOptions {
+	policy, _ := service.ParseDeletePolicy(c.Classes.DeletePolicy)
+	return service.DeleteClassOptions{Policy: policy, ReassignTo: c.Classes.ReassignTo}
+}
+
+// Preconditions returns how handlers treat conditional request headers
+func (c *Config) Preconditions() handler.Preconditions {
+	return handler.Preconditions{RequireIfMatch: c.Concurrency.RequireIfMatch}
+}
+
+// OneRosterOrg returns the organization that OneRoster classes and users belong to
+func (c *Config) OneRosterOrg() oneroster.Org {
+	return oneroster.Org{SourcedID: c.OneRoster.OrgSourcedID, Name: c.OneRoster.OrgName}
+}
+
+// TenantResolver returns the middleware that picks the tenant of each request
+func (c *Config) TenantResolver(tenants service.TenantService) *handler.TenantResolver {
+	return handler.NewTenantResolver(tenants, c.Tenancy.Header, c.Tenancy.BaseDomain)
+}
+
+// AuthKeys loads the configured token keys. With no keys configured it
+// returns a random HS256 key, so tokens stop working when the server restarts.
+func (c *Config) AuthKeys() (*auth.KeySet, error) {
+	if len(c.Auth.Keys) == 0 {
+		key, err := auth.NewRandomHMACKey("ephemeral")
+		if err != nil {
+			return nil, err
+		}
+		return auth.NewKeySet(key.ID, key)
+	}
+
+	keys := make([]*auth.Key, 0, len(c.Auth.Keys))
+	for _, k := range c.Auth.Keys {
+		var (
+			key *auth.Key
+			err error
+		)
+		if k.Algorithm == auth.HS256 {
+			key, err = auth.NewHMACKey(k.ID, []byte(k.Secret))
+		} else {
+			var private, public []byte
+			if k.PrivateKeyFile != "" {
+				private, err = os.ReadFile(k.PrivateKeyFile)
+			} else {
+				public, err = os.ReadFile(k.PublicKeyFile)
+			}
+			if err == nil {
+				key, err = auth.ParseRSAKey(k.ID, private, public)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("auth.keys: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	set, err := auth.NewKeySet(c.Auth.SigningKey, keys...)
+	if err != nil {
+		return nil, fmt.Errorf("auth.signing_key: %w", err)
+	}
+	return set, nil
+}
+
+// Addr returns the address the HTTP server listens on
+func (c *Config) Addr() string {
+	return fmt.Sprintf(":%d", c.Server.Port)
+}
+
+// SwaggerURL returns the URL of the Swagger UI
+func (c *Config) SwaggerURL() string {
+	return "http://" + c.Swagger.Host + "/swagger/"
+}
+
+// applyDefaults fills in values that depend on other settings
+func (c *Config) applyDefaults() {
+	c.Database.Driver = strings.ToLower(strings.TrimSpace(c.Database.Driver))
+	if c.Database.DSN == "" {
+		c.Database.DSN = database.DefaultDSN(c.Database.Driver)
+	}
+	if c.Swagger.Host == "" {
+		c.Swagger.Host = fmt.Sprintf("localhost:%d", c.Server.Port)
+	}
+	c.Tenancy.BaseDomain = strings.Trim(strings.ToLower(strings.TrimSpace(c.Tenancy.BaseDomain)), ".")
+	for i := range c.Auth.Keys {
+		c.Auth.Keys[i].Algorithm = strings.ToUpper(strings.TrimSpace(c.Auth.Keys[i].Algorithm))
+	}
+	if c.Auth.SigningKey == "" && len(c.Auth.Keys) > 0 {
+		c.Auth.SigningKey = c.Auth.Keys[0].ID
+	}
+}
+
+// Validate checks that all settings are usable
+func (c *Config) Validate() error {
+	var errs []error
+	if _, err := database.Dialector(c.Database.Driver, c.Database.DSN); err != nil {
+		errs = append(errs, fmt.Errorf("database.driver: %w", err))
+	}
+	if strings.TrimSpace(c.Database.DSN) == "" {
+		errs = append(errs, errors.New("database.dsn: must not be empty"))
+	}
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		errs = append(errs, fmt.Errorf("server.port: %d is out of range 1-65535", c.Server.Port))
+	}
+	for name, d := range map[string]Duration{
+		"database.query_timeouts.read":   c.Database.QueryTimeouts.Read,
+		"database.query_timeouts.list":   c.Database.QueryTimeouts.List,
+		"database.query_timeouts.write":  c.Database.QueryTimeouts.Write,
+		"database.query_timeouts.export": c.Database.QueryTimeouts.Export,
+	} {
+		if d < 0 {
+			errs = append(errs, fmt.Errorf("%s: must not be negative", name))
+		}
+	}
+	for name, d := range map[string]Duration{
+		"server.read_timeout":     c.Server.ReadTimeout,
+		"server.write_timeout":    c.Server.WriteTimeout,
+		"server.idle_timeout":     c.Server.IdleTimeout,
+		"server.shutdown_timeout": c.Server.ShutdownTimeout,
+	} {
+		if d <= 0 {
+			errs = append(errs, fmt.Errorf("%s: must be positive", name))
+		}
+	}
+	if c.Purge.Retention <= 0 {
+		errs = append(errs, errors.New("purge.retention: must be positive"))
+	}
+	if c.Purge.Interval < 0 {
+		errs = append(errs, errors.New("purge.interval: must not be negative"))
+	}
+	if strings.TrimSpace(c.Swagger.Host) == "" {
+		errs = append(errs, errors.New("swagger.host: must not be empty"))
+	}
+	if strings.TrimSpace(c.OneRoster.OrgSourcedID) == "" {
+		errs = append(errs, errors.New("oneroster.org_sourced_id: must not be empty"))
+	}
+	errs = append(errs, c.Auth.validate()...)
+	if strings.TrimSpace(c.Tenancy.Header) == "" {
+		errs = append(errs, errors.New("tenancy.header: must not be empty"))
+	}
+	if policy, err := service.ParseDeletePolicy(c.Classes.DeletePolicy); err != nil {
+		errs = append(errs, fmt.Errorf("classes.delete_policy: %w", err))
+	} else if policy == service.DeleteReassign && c.Classes.ReassignTo == 0 {
+		errs = append(errs, errors.New("classes.reassign_to: required when delete_policy is reassign"))
+	}
+	return errors.Join(errs...)
+}
+
+// validate checks the auth settings; key material is checked when the keys are loaded
+func (c *AuthConfig) validate() []error {
+	var errs []error
+	if strings.TrimSpace(c.Issuer) == "" {
+		errs = append(errs, errors.New("auth.issuer: must not be empty"))
+	}
+	if c.AccessTokenTTL <= 0 {
+		errs = append(errs, errors.New("auth.access_token_ttl: must be positive"))
+	}
+	if c.RefreshTokenTTL <= 0 {
+		errs = append(errs, errors.New("auth.refresh_token_ttl: must be positive"))
+	}
+
+	ids := make(map[string]bool, len(c.Keys))
+	for i, k := range c.Keys {
+		name := fmt.Sprintf("auth.keys[%d]", i)
+		switch {
+		case strings.TrimSpace(k.ID) == "":
+			errs = append(errs, fmt.Errorf("%s.id: must not be empty", name))
+		case ids[k.ID]:
+			errs = append(errs, fmt.Errorf("%s.id: %q is used by another key", name, k.ID))
+		}
+		ids[k.ID] = true
+
+		switch k.Algorithm {
+		case auth.HS256:
+			if k.Secret == "" {
+				errs = append(errs, fmt.Errorf("%s.secret: required for HS256", name))
+			}
+		case auth.RS256:
+			if k.PrivateKeyFile == "" && k.PublicKeyFile == "" {
+				errs = append(errs, fmt.Errorf("%s: RS256 needs private_key_file or public_key_file", name))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("%s.algorithm: must be HS256 or RS256", name))
+		}
+	}
+	if len(c.Keys) > 0 && !ids[c.SigningKey] {
+		errs = append(errs, fmt.Errorf("auth.signing_key: no key has ID %q", c.SigningKey))
+	}
+	return errs
+}
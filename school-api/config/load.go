@@ -0,0 +1,221 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"school-api/auth"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Load builds the configuration from, in increasing order of precedence,
+// built-in defaults, a YAML or TOML file, environment variables and
+// command-line flags. The file is chosen with -config or CONFIG_FILE.
+func Load(args []string) (*Config, error) {
+	return LoadFlags(flag.NewFlagSet("school-api", flag.ContinueOnError), args)
+}
+
+// LoadFlags is Load with the configuration flags added to fs, so commands
+// can parse their own flags alongside them. Arguments left after the flags
+// are available from fs.Args.
+func LoadFlags(fs *flag.FlagSet, args []string) (*Config, error) {
+	cfg := Default()
+
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML or TOML config file")
+	driver := fs.String("db-driver", "", "database driver (sqlserver, postgres, sqlite)")
+	dsn := fs.String("db-dsn", "", "database connection string")
+	port := fs.Int("port", 0, "HTTP port to listen on")
+	shutdownTimeout := fs.Duration("shutdown-timeout", 0, "time allowed for in-flight requests to finish on shutdown")
+	swaggerHost := fs.String("swagger-host", "", "host:port advertised in the Swagger documentation")
+	openBrowser := fs.Bool("open-browser", false, "open the Swagger UI in a browser on startup")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *configFile != "" {
+		if err := loadFile(cfg, *configFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := loadEnv(cfg); err != nil {
+		return nil, err
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "db-driver":
+			cfg.Database.Driver = *driver
+		case "db-dsn":
+			cfg.Database.DSN = *dsn
+		case "port":
+			cfg.Server.Port = *port
+		case "shutdown-timeout":
+			cfg.Server.ShutdownTimeout = Duration(*shutdownTimeout)
+		case "swagger-host":
+			cfg.Swagger.Host = *swaggerHost
+		case "open-browser":
+			cfg.Swagger.OpenBrowser = *openBrowser
+		}
+	})
+
+	cfg.applyDefaults()
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return cfg, nil
+}
+
+// loadFile decodes a YAML or TOML file, picked by extension, over cfg
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	case ".toml":
+		err = toml.Unmarshal(data, cfg)
+	default:
+		return fmt.Errorf("unsupported config file type %q", filepath.Ext(path))
+	}
+	if err != nil {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// loadEnv overrides cfg with any environment variables that are set
+func loadEnv(cfg *Config) error {
+	if v, ok := os.LookupEnv("DB_DRIVER"); ok {
+		cfg.Database.Driver = v
+	}
+	if v, ok := os.LookupEnv("DB_DSN"); ok {
+		cfg.Database.DSN = v
+	}
+	if v, ok := os.LookupEnv("PORT"); ok {
+		port, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("PORT: %w", err)
+		}
+		cfg.Server.Port = port
+	}
+	for env, d := range map[string]*Duration{
+		"DB_READ_TIMEOUT":         &cfg.Database.QueryTimeouts.Read,
+		"DB_LIST_TIMEOUT":         &cfg.Database.QueryTimeouts.List,
+		"DB_WRITE_TIMEOUT":        &cfg.Database.QueryTimeouts.Write,
+		"DB_EXPORT_TIMEOUT":       &cfg.Database.QueryTimeouts.Export,
+		"SERVER_READ_TIMEOUT":     &cfg.Server.ReadTimeout,
+		"SERVER_WRITE_TIMEOUT":    &cfg.Server.WriteTimeout,
+		"SERVER_IDLE_TIMEOUT":     &cfg.Server.IdleTimeout,
+		"SERVER_SHUTDOWN_TIMEOUT": &cfg.Server.ShutdownTimeout,
+		"PURGE_RETENTION":         &cfg.Purge.Retention,
+		"PURGE_INTERVAL":          &cfg.Purge.Interval,
+		"AUTH_ACCESS_TOKEN_TTL":   &cfg.Auth.AccessTokenTTL,
+		"AUTH_REFRESH_TOKEN_TTL":  &cfg.Auth.RefreshTokenTTL,
+	} {
+		if v, ok := os.LookupEnv(env); ok {
+			if err := d.UnmarshalText([]byte(v)); err != nil {
+				return fmt.Errorf("%s: %w", env, err)
+			}
+		}
+	}
+	if v, ok := os.LookupEnv("ONEROSTER_ORG_SOURCED_ID"); ok {
+		cfg.OneRoster.OrgSourcedID = v
+	}
+	if v, ok := os.LookupEnv("ONEROSTER_ORG_NAME"); ok {
+		cfg.OneRoster.OrgName = v
+	}
+	if v, ok := os.LookupEnv("CLASS_DELETE_POLICY"); ok {
+		cfg.Classes.DeletePolicy = v
+	}
+	if v, ok := os.LookupEnv("CLASS_REASSIGN_TO"); ok {
+		id, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return fmt.Errorf("CLASS_REASSIGN_TO: %w", err)
+		}
+		cfg.Classes.ReassignTo = uint(id)
+	}
+	if v, ok := os.LookupEnv("REQUIRE_IF_MATCH"); ok {
+		require, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("REQUIRE_IF_MATCH: %w", err)
+		}
+		cfg.Concurrency.RequireIfMatch = require
+	}
+	for env, b := range map[string]*bool{
+		"AUTH_ENABLED":         &cfg.Auth.Enabled,
+		"AUTH_PROTECT_SWAGGER": &cfg.Auth.ProtectSwagger,
+	} {
+		if v, ok := os.LookupEnv(env); ok {
+			enabled, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("%s: %w", env, err)
+			}
+			*b = enabled
+		}
+	}
+	if v, ok := os.LookupEnv("AUTH_SIGNING_KEY"); ok {
+		cfg.Auth.SigningKey = v
+	}
+	if v, ok := os.LookupEnv("AUTH_SECRET"); ok {
+		if err := cfg.Auth.setSecret(v); err != nil {
+			return fmt.Errorf("AUTH_SECRET: %w", err)
+		}
+	}
+	if v, ok := os.LookupEnv("TENANCY_HEADER"); ok {
+		cfg.Tenancy.Header = v
+	}
+	if v, ok := os.LookupEnv("TENANCY_BASE_DOMAIN"); ok {
+		cfg.Tenancy.BaseDomain = v
+	}
+	if v, ok := os.LookupEnv("SWAGGER_HOST"); ok {
+		cfg.Swagger.Host = v
+	}
+	if v, ok := os.LookupEnv("SWAGGER_OPEN_BROWSER"); ok {
+		open, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("SWAGGER_OPEN_BROWSER: %w", err)
+		}
+		cfg.Swagger.OpenBrowser = open
+	}
+	return nil
+}
+
+// setSecret gives the signing key the secret from AUTH_SECRET, keeping it
+// out of config files. With no keys configured it creates an HS256 signing
+// key, named "default" unless signing_key names it. It fails rather than
+// guess when keys are configured but signing_key does not name one of them,
+// or names a key that has no secret.
+func (c *AuthConfig) setSecret(secret string) error {
+	if len(c.Keys) == 0 {
+		if c.SigningKey == "" {
+			c.SigningKey = "default"
+		}
+		c.Keys = append(c.Keys, AuthKeyConfig{ID: c.SigningKey, Algorithm: auth.HS256, Secret: secret})
+		return nil
+	}
+	if c.SigningKey == "" {
+		return errors.New("auth.signing_key must name the key the secret is for when auth.keys is set")
+	}
+	for i := range c.Keys {
+		k := &c.Keys[i]
+		if k.ID != c.SigningKey {
+			continue
+		}
+		if algorithm := strings.ToUpper(strings.TrimSpace(k.Algorithm)); algorithm != auth.HS256 {
+			return fmt.Errorf("signing key %q is %s, which uses key files rather than a secret", k.ID, algorithm)
+		}
+		k.Secret = secret
+		return nil
+	}
+	return fmt.Errorf("auth.signing_key: no key has ID %q", c.SigningKey)
+}
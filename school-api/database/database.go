@@ -0,0 +1,202 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"school-api/models"
+	"school-api/repository"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlserver"
+	"gorm.io/gorm"
+)
+
+// Supported database drivers
+const (
+	DriverSQLServer = "sqlserver"
+	DriverPostgres  = "postgres"
+	DriverSQLite    = "sqlite"
+)
+
+// DefaultDSN returns the connection string used when none is configured for driver
+func DefaultDSN(driver string) string {
+	switch driver {
+	case DriverPostgres:
+		return "host=localhost user=postgres dbname=schooldb port=5432 sslmode=disable"
+	case DriverSQLite:
+		return "school.db"
+	default:
+		return "sqlserver://(local)?database=schooldb&trusted_connection=true&trustservercertificate=true&encrypt=false"
+	}
+}
+
+// Dialector returns the GORM dialector for the given driver name
+func Dialector(driver, dsn string) (gorm.Dialector, error) {
+	switch strings.ToLower(driver) {
+	case DriverSQLServer:
+		return sqlserver.Open(dsn), nil
+	case DriverPostgres, "postgresql":
+		return postgres.Open(dsn), nil
+	case DriverSQLite, "sqlite3":
+		return sqlite.Open(sqliteDSN(dsn)), nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", driver)
+	}
+}
+
+// Open connects to the database using the given driver and DSN
+func Open(driver, dsn string) (*gorm.DB, error) {
+	dialector, err := Dialector(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	// TranslateError maps driver-specific constraint violations to
+	// gorm.ErrDuplicatedKey and gorm.ErrForeignKeyViolated
+	db, err := gorm.Open(dialector, &gorm.Config{TranslateError: true})
+	if err != nil {
+		return nil, err
+	}
+	// Confine queries to the tenant of their context
+	if err := db.Use(repository.TenantPlugin{}); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// Migrate creates or updates the schema for all models
+func Migrate(db *gorm.DB) error {
+	if err := checkOrphanedStudents(db); err != nil {
+		return err
+	}
+	if err := db.AutoMigrate(&models.Tenant{}, &models.Class{}, &models.Student{}, &models.User{}, &models.RefreshToken{}, &models.GuardianLink{}, &models.APIKey{}, &models.AuditEntry{}, &models.Enrollment{}); err != nil {
+		return err
+	}
+	// Rows written before tenants existed belong to the default tenant,
+	// which as the first row of the table gets ID 1 without the explicit
+	// ID some databases reject or leave their sequence behind on
+	var tenants int64
+	if err := db.Model(&models.Tenant{}).Count(&tenants).Error; err != nil {
+		return err
+	}
+	if tenants == 0 {
+		if err := db.Create(&models.Tenant{Slug: "default", Name: "Default"}).Error; err != nil {
+			return err
+		}
+	}
+	// Rows written before updated_at existed count as modified now. The
+	// time is bound from Go so it is stored like any other timestamp.
+	now := db.NowFunc()
+	for _, model := range []any{&models.Class{}, &models.Student{}} {
+		err := db.Unscoped().Model(model).Where("updated_at IS NULL").
+			UpdateColumn("updated_at", now).Error
+		if err != nil {
+			return err
+		}
+	}
+	return backfillEnrollments(db)
+}
+
+// backfillBatch is how many students backfillEnrollments handles at once
+const backfillBatch = 500
+
+// backfillEnrollments starts the enrollment history of students added
+// before it was kept, in their current class from when they joined it. That
+// is taken from the audit log, as the latest entry creating, restoring or
+// moving the student, or failing that from their last change, so history
+// from before the upgrade is approximate. Those times were stored in local
+// time, so they are read and converted to UTC here rather than copied.
+func backfillEnrollments(db *gorm.DB) error {
+	var students []models.Student
+	return db.Select("id", "tenant_id", "class_id", "updated_at").
+		Where("NOT EXISTS (SELECT 1 FROM enrollments WHERE enrollments.student_id = students.id)").
+		FindInBatches(&students, backfillBatch, func(*gorm.DB, int) error {
+			ids := make([]uint, len(students))
+			joined := make(map[uint]time.Time, len(students))
+			for i, s := range students {
+				ids[i] = s.ID
+				joined[s.ID] = s.UpdatedAt
+			}
+			var entries []models.AuditEntry
+			err := db.Select("entity_id", "created_at").
+				Where("entity = ? AND entity_id IN ?", "student", ids).
+				Where("operation IN ? OR changes LIKE ?", []string{models.AuditCreate, models.AuditRestore}, `%"class_id"%`).
+				Find(&entries).Error
+			if err != nil {
+				return err
+			}
+			latest := make(map[uint]time.Time, len(entries))
+			for _, e := range entries {
+				if e.CreatedAt.After(latest[e.EntityID]) {
+					latest[e.EntityID] = e.CreatedAt
+				}
+			}
+			for id, at := range latest {
+				joined[id] = at
+			}
+
+			enrollments := make([]models.Enrollment, len(students))
+			for i, s := range students {
+				enrollments[i] = models.Enrollment{TenantID: s.TenantID, StudentID: s.ID, ClassID: s.ClassId, ValidFrom: joined[s.ID].UTC()}
+			}
+			return db.Create(&enrollments).Error
+		}).Error
+}
+
+// maxOrphansReported caps the student IDs listed by checkOrphanedStudents
+const maxOrphansReported = 20
+
+// checkOrphanedStudents stops a migration that is about to add the foreign
+// key from students to classes while some students are in classes that no
+// longer exist, which the database would refuse with an error naming neither.
+// The students are listed so they can be moved or removed by hand; moving
+// them automatically would quietly change rosters.
+func checkOrphanedStudents(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&models.Student{}) || !migrator.HasTable(&models.Class{}) ||
+		migrator.HasConstraint(&models.Class{}, "Students") {
+		return nil
+	}
+	var orphans []struct {
+		ID      uint
+		ClassID uint
+	}
+	err := db.Raw(`SELECT id, class_id FROM students
+		WHERE NOT EXISTS (SELECT 1 FROM classes WHERE classes.id = students.class_id)
+		ORDER BY id`).Scan(&orphans).Error
+	if err != nil || len(orphans) == 0 {
+		return err
+	}
+	listed := make([]string, 0, min(len(orphans), maxOrphansReported))
+	for _, o := range orphans[:cap(listed)] {
+		listed = append(listed, fmt.Sprintf("student %d in class %d", o.ID, o.ClassID))
+	}
+	if len(orphans) > len(listed) {
+		listed = append(listed, fmt.Sprintf("and %d more", len(orphans)-len(listed)))
+	}
+	return fmt.Errorf("%d students are in classes that do not exist (%s); move them to an existing class or delete them, then start again",
+		len(orphans), strings.Join(listed, ", "))
+}
+
+// sqliteDSN turns on foreign key enforcement, which SQLite leaves off by default
+func sqliteDSN(dsn string) string {
+	if strings.Contains(dsn, "foreign_keys") {
+		return dsn
+	}
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	return dsn + sep + "_pragma=foreign_keys(1)"
+}
+
+// Close releases the connection pool held by db
+func Close(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
@@ -0,0 +1,113 @@
+package database
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"school-api/models"
+
+	"gorm.io/gorm/logger"
+)
+
+// TestMigrateReportsOrphanedStudents checks that upgrading a database from
+// before the foreign key from students to classes names the students whose
+// class is gone, rather than failing on the constraint, and goes ahead once
+// they are dealt with
+func TestMigrateReportsOrphanedStudents(t *testing.T) {
+	db, err := Open(DriverSQLite, filepath.Join(t.TempDir(), "legacy.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { Close(db) })
+	db.Logger = logger.Discard
+	for _, sql := range []string{
+		`CREATE TABLE classes (id integer PRIMARY KEY, class_name text)`,
+		`CREATE TABLE students (id integer PRIMARY KEY, student_name text, class_id integer, student_section text)`,
+		`INSERT INTO classes (id, class_name) VALUES (1, 'Grade 5')`,
+		`INSERT INTO students (id, student_name, class_id, student_section) VALUES (1, 'Jane Doe', 1, 'A'), (2, 'John Doe', 9, 'A')`,
+	} {
+		if err := db.Exec(sql).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = Migrate(db)
+	if err == nil || !strings.Contains(err.Error(), "student 2 in class 9") {
+		t.Fatalf("migrating with an orphaned student gave %v, want it reported", err)
+	}
+
+	if err := db.Exec(`UPDATE students SET class_id = 1 WHERE id = 2`).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := Migrate(db); err != nil {
+		t.Fatalf("migrating once the student is moved: %v", err)
+	}
+	if !db.Migrator().HasConstraint(&models.Class{}, "Students") {
+		t.Error("students have no foreign key to classes after migrating")
+	}
+}
+
+// TestBackfillEnrollmentsInUTC checks that history started for students from
+// before it was kept begins when the audit log says they joined their class,
+// or at their last change, stored in UTC like all other history
+func TestBackfillEnrollmentsInUTC(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("UTC+2", 2*60*60)
+	t.Cleanup(func() { time.Local = local })
+
+	db, err := Open(DriverSQLite, filepath.Join(t.TempDir(), "legacy.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { Close(db) })
+	db.Logger = logger.Discard
+	if err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	joined := time.Date(2024, 9, 2, 8, 0, 0, 0, time.Local)
+	changed := time.Date(2025, 1, 7, 9, 30, 0, 0, time.Local)
+	class := models.Class{ClassName: "Grade 5"}
+	if err := db.Create(&class).Error; err != nil {
+		t.Fatal(err)
+	}
+	audited := models.Student{StudentName: "Jane Doe", ClassId: class.ID, UpdatedAt: changed}
+	unaudited := models.Student{StudentName: "John Doe", ClassId: class.ID, UpdatedAt: changed}
+	if err := db.Create([]*models.Student{&audited, &unaudited}).Error; err != nil {
+		t.Fatal(err)
+	}
+	entry := models.AuditEntry{Entity: "student", EntityID: audited.ID, Operation: models.AuditCreate,
+		ActorType: models.ActorSystem, Changes: "{}", CreatedAt: joined}
+	if err := db.Create(&entry).Error; err != nil {
+		t.Fatal(err)
+	}
+	// The students predate enrollment history
+	if err := db.Where("1 = 1").Delete(&models.Enrollment{}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		student *models.Student
+		want    time.Time
+	}{
+		{&audited, joined},
+		{&unaudited, changed},
+	} {
+		var enrollment models.Enrollment
+		if err := db.Where("student_id = ?", tc.student.ID).First(&enrollment).Error; err != nil {
+			t.Fatalf("%s has no enrollment: %v", tc.student.StudentName, err)
+		}
+		if !enrollment.ValidFrom.Equal(tc.want) || enrollment.ClassID != class.ID {
+			t.Errorf("%s is enrolled in class %d from %v, want class %d from %v",
+				tc.student.StudentName, enrollment.ClassID, enrollment.ValidFrom, class.ID, tc.want)
+		}
+		if _, offset := enrollment.ValidFrom.Zone(); offset != 0 {
+			t.Errorf("%s is enrolled from %v, which is not stored in UTC", tc.student.StudentName, enrollment.ValidFrom)
+		}
+	}
+}
@@ -15,36 +15,135 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/classes": {
+        "/.well-known/jwks.json": {
             "get": {
-                "description": "Get a list of all classes",
+                "description": "Get the public keys of the RS256 keys tokens are signed with, as a JSON Web Key Set. HS256 keys are never published.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "classes"
+                    "auth"
                 ],
-                "summary": "Get all classes",
+                "summary": "Get the token verification keys",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/auth.JWKS"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/admin/purge": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Permanently remove classes and students that were soft-deleted longer ago than older_than, or the configured retention period, along with expired refresh tokens. The enrollment history of purged students is kept, so past rosters still list them, by ID only. Purges also run on a schedule.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Purge deleted records",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Minimum age of deleted records to purge, as a Go duration (e.g. 720h)",
+                        "name": "older_than",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.PurgeResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid older_than",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Role lacks the permission for this route",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/api-keys": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List every API key, including revoked and expired ones, newest first, with its usage.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "api-keys"
+                ],
+                "summary": "List API keys",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/models.Class"
+                                "$ref": "#/definitions/dto.APIKeyResponse"
                             }
                         }
                     },
+                    "401": {
+                        "description": "Missing or invalid access token",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Role lacks the permission for this route",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/apperror.Problem"
                         }
                     }
                 }
             },
             "post": {
-                "description": "Create a new class with the provided details",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Create an API key for a non-interactive client. The key is returned once and cannot be retrieved again. Requests made with it may use the class and student routes its scopes allow and see every class and student.",
                 "consumes": [
                     "application/json"
                 ],
@@ -52,17 +151,17 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "classes"
+                    "api-keys"
                 ],
-                "summary": "Create a new class",
+                "summary": "Create an API key",
                 "parameters": [
                     {
-                        "description": "Class object to create",
-                        "name": "class",
+                        "description": "API key to create",
+                        "name": "key",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.Class"
+                            "$ref": "#/definitions/dto.CreateAPIKeyRequest"
                         }
                     }
                 ],
@@ -70,38 +169,60 @@ const docTemplate = `{
                     "201": {
                         "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/models.Class"
+                            "$ref": "#/definitions/dto.APIKeySecretResponse"
                         }
                     },
                     "400": {
                         "description": "Invalid request body",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Role lacks the permission for this route",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/apperror.Problem"
                         }
                     }
                 }
             }
         },
-        "/classes/{id}": {
+        "/api/api-keys/{id}": {
             "get": {
-                "description": "Get a specific class by its ID",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "classes"
+                    "api-keys"
                 ],
-                "summary": "Get a class by ID",
+                "summary": "Get an API key by ID",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Class ID",
+                        "description": "API key ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -111,138 +232,280 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Class"
+                            "$ref": "#/definitions/dto.APIKeyResponse"
                         }
                     },
                     "400": {
                         "description": "Invalid ID",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Role lacks the permission for this route",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
                         }
                     },
                     "404": {
-                        "description": "Class not found",
+                        "description": "API key not found",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/apperror.Problem"
                         }
                     }
                 }
             },
-            "put": {
-                "description": "Update an existing class with the provided details",
-                "consumes": [
-                    "application/json"
-                ],
-                "produces": [
-                    "application/json"
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
                 ],
+                "description": "Stop an API key from working at once, including a replaced key still in its grace period. Revoked keys stay listed.",
                 "tags": [
-                    "classes"
+                    "api-keys"
                 ],
-                "summary": "Update a class",
+                "summary": "Revoke an API key",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Class ID",
+                        "description": "API key ID",
                         "name": "id",
                         "in": "path",
                         "required": true
-                    },
-                    {
-                        "description": "Class object to update",
-                        "name": "class",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/models.Class"
-                        }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "204": {
+                        "description": "API key revoked"
+                    },
+                    "400": {
+                        "description": "Invalid ID",
                         "schema": {
-                            "$ref": "#/definitions/models.Class"
+                            "$ref": "#/definitions/apperror.Problem"
                         }
                     },
-                    "400": {
-                        "description": "Invalid request body",
+                    "401": {
+                        "description": "Missing or invalid access token",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Role lacks the permission for this route",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "API key not found",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/apperror.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/apperror.Problem"
                         }
                     }
                 }
-            },
-            "delete": {
-                "description": "Delete a specific class by its ID",
+            }
+        },
+        "/api/api-keys/{id}/rotate": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Give an API key a new secret and return the new key once. The key keeps its ID, prefix, scopes and usage. The replaced key stops working at once, or after grace_period so clients can switch over.",
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
-                    "classes"
+                    "api-keys"
                 ],
-                "summary": "Delete a class",
+                "summary": "Rotate an API key",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Class ID",
+                        "description": "API key ID",
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "How long the replaced key keeps working, as a Go duration (e.g. 24h)",
+                        "name": "grace_period",
+                        "in": "query"
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "No Content"
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIKeySecretResponse"
+                        }
                     },
                     "400": {
-                        "description": "Invalid ID",
+                        "description": "Invalid ID or grace_period",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Role lacks the permission for this route",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "API key not found",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "API key has been revoked",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/apperror.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/apperror.Problem"
                         }
                     }
                 }
             }
         },
-        "/students": {
+        "/api/audit": {
             "get": {
-                "description": "Get a list of all students",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get a page of the audit log of the current tenant, newest first. Every create, update, delete, restore and purge of a class or student is recorded with who made it, when, and the fields it changed. Filter with field=value or field[op]=value (eq, ne, gt, gte, lt, lte, like) on entity, entity_id, operation, actor_type, actor_id, actor and created_at, e.g. entity=student\u0026actor=mrs.smith\u0026created_at[gte]=2024-09-01\u0026created_at[lt]=2024-10-01.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "students"
+                    "audit"
+                ],
+                "summary": "List the audit log",
+                "parameters": [
+                    {
+                        "enum": [
+                            "class",
+                            "student"
+                        ],
+                        "type": "string",
+                        "description": "Kind of record changed",
+                        "name": "entity",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "ID of the record changed",
+                        "name": "entity_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Username or API key name of whoever made the change",
+                        "name": "actor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only changes at or after this date or RFC 3339 time",
+                        "name": "created_at[gte]",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only changes before this date or RFC 3339 time",
+                        "name": "created_at[lt]",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size (default 50, max 500)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of entries to skip",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor from a previous page's next_cursor",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated fields, prefix with - for descending (default -id)",
+                        "name": "sort",
+                        "in": "query"
+                    }
                 ],
-                "summary": "Get all students",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/models.Student"
-                            }
+                            "$ref": "#/definitions/handler.ListResponse-dto_AuditEntryResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Role may not read the audit log",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/apperror.Problem"
                         }
                     }
                 }
-            },
+            }
+        },
+        "/api/auth/login": {
             "post": {
-                "description": "Create a new student with the provided details",
+                "description": "Exchange a username and password for an access token and a refresh token. The access token is sent on other requests as \"Authorization: Bearer \u003ctoken\u003e\".",
                 "consumes": [
                     "application/json"
                 ],
@@ -250,109 +513,158 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "students"
+                    "auth"
                 ],
-                "summary": "Create a new student",
+                "summary": "Sign in",
                 "parameters": [
                     {
-                        "description": "Student object to create",
-                        "name": "student",
+                        "description": "Username and password",
+                        "name": "credentials",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.Student"
+                            "$ref": "#/definitions/dto.LoginRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Student"
+                            "$ref": "#/definitions/dto.TokenResponse"
                         }
                     },
                     "400": {
                         "description": "Invalid request body",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid username or password",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "User is disabled",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/apperror.Problem"
                         }
                     }
                 }
             }
         },
-        "/students/{id}": {
-            "get": {
-                "description": "Get a specific student by its ID",
-                "produces": [
+        "/api/auth/logout": {
+            "post": {
+                "description": "Revoke a refresh token. Access tokens already issued stay valid until they expire.",
+                "consumes": [
                     "application/json"
                 ],
                 "tags": [
-                    "students"
+                    "auth"
                 ],
-                "summary": "Get a student by ID",
+                "summary": "Sign out",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Student ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Refresh token",
+                        "name": "token",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.RefreshRequest"
+                        }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "$ref": "#/definitions/models.Student"
-                        }
+                    "204": {
+                        "description": "Refresh token revoked"
                     },
                     "400": {
-                        "description": "Invalid ID",
+                        "description": "Invalid request body",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/apperror.Problem"
                         }
                     },
-                    "404": {
-                        "description": "Student not found",
+                    "500": {
+                        "description": "Internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/apperror.Problem"
                         }
                     }
                 }
-            },
-            "put": {
-                "description": "Update an existing student with the provided details",
-                "consumes": [
-                    "application/json"
+            }
+        },
+        "/api/auth/me": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
                 ],
+                "description": "Get the account the access token was issued to.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "students"
+                    "auth"
                 ],
-                "summary": "Update a student",
-                "parameters": [
-                    {
-                        "type": "integer",
-                        "description": "Student ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                "summary": "Get the current user",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.UserResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Request was made with an API key",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
                     },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/auth/refresh": {
+            "post": {
+                "description": "Exchange a refresh token for a new access token and refresh token. A refresh token works once; reusing one revokes every refresh token of its user.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Refresh tokens",
+                "parameters": [
                     {
-                        "description": "Student object to update",
-                        "name": "student",
+                        "description": "Refresh token",
+                        "name": "token",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.Student"
+                            "$ref": "#/definitions/dto.RefreshRequest"
                         }
                     }
                 ],
@@ -360,89 +672,4747 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Student"
+                            "$ref": "#/definitions/dto.TokenResponse"
                         }
                     },
                     "400": {
                         "description": "Invalid request body",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Refresh token is invalid, expired or already used",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "User is disabled",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/apperror.Problem"
                         }
                     }
                 }
-            },
-            "delete": {
-                "description": "Delete a specific student by its ID",
+            }
+        },
+        "/api/classes": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get a page of classes. Filter with field=value or field[op]=value (eq, ne, gt, gte, lt, lte, like) on id, class_name, student_count and teacher_id. Teachers only see the classes they teach, guardians and students only the classes of their students.",
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
-                    "students"
+                    "classes"
                 ],
-                "summary": "Delete a student",
+                "summary": "Get all classes",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Student ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Page size (default 50, max 500)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of classes to skip",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor from a previous page's next_cursor",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated fields, prefix with - for descending (e.g. -class_name,id)",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include soft-deleted classes (administrators only)",
+                        "name": "include_deleted",
+                        "in": "query"
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "No Content"
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ListResponse-dto_ClassResponse"
+                        }
                     },
                     "400": {
-                        "description": "Invalid ID",
+                        "description": "Invalid query",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token or API key",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Role or API key scopes lack the permission for this route",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/apperror.Problem"
                         }
                     }
                 }
-            }
-        }
-    },
-    "definitions": {
-        "models.Class": {
-            "type": "object",
-            "properties": {
-                "class_name": {
-                    "type": "string"
-                },
-                "id": {
-                    "type": "integer"
-                },
-                "student_count": {
-                    "type": "integer"
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Create a new class with the provided details. student_count is maintained by the server and may not be sent. Students listed in the body are created with the class; if any of them is invalid nothing is stored.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "classes"
+                ],
+                "summary": "Create a new class",
+                "parameters": [
+                    {
+                        "description": "Class to create",
+                        "name": "class",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.CreateClassRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ClassResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token or API key",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Role or API key scopes lack the permission for this route",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed (per-field details in errors)",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    }
                 }
             }
         },
-        "models.Student": {
-            "type": "object",
-            "properties": {
-                "class_id": {
-                    "type": "integer"
-                },
-                "id": {
-                    "type": "integer"
-                },
-                "student_name": {
-                    "type": "string"
-                },
-                "student_section": {
-                    "type": "string"
+        "/api/classes/export": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Download every class matching the filters and sort order of the list endpoint as CSV, Excel (XLSX) or newline-delimited JSON, chosen with format or the Accept header (CSV by default). Filter with field=value or field[op]=value on id, class_name and student_count. Rows are streamed from the database; paging parameters are ignored. In CSV files, text that would be taken for a formula (starting with =, +, -, @, tab or carriage return) is prefixed with an apostrophe; Excel files store it as plain text.",
+                "produces": [
+                    "text/csv",
+                    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+                    "application/x-ndjson"
+                ],
+                "tags": [
+                    "classes"
+                ],
+                "summary": "Export classes",
+                "parameters": [
+                    {
+                        "enum": [
+                            "csv",
+                            "xlsx",
+                            "ndjson"
+                        ],
+                        "type": "string",
+                        "description": "Export format, overriding the Accept header",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated fields, prefix with - for descending",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include soft-deleted classes (administrators only)",
+                        "name": "include_deleted",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query or format",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token or API key",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Role or API key scopes lack the permission for this route",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "No acceptable export format",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    }
                 }
             }
+        },
+        "/api/classes/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get a specific class by its ID. A class outside the caller's scope is reported as not found. The ETag header carries the class version; send it back in If-None-Match to get 304 when nothing changed.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "classes"
+                ],
+                "summary": "Get a class by ID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Class ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag from a previous response",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ClassResponse"
+                        }
+                    },
+                    "304": {
+                        "description": "Not Modified"
+                    },
+                    "400": {
+                        "description": "Invalid ID",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token or API key",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Role or API key scopes lack the permission for this route",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Class not found or outside the caller's scope",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Update an existing class with the provided details. student_count is maintained by the server and may not be sent.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "classes"
+                ],
+                "summary": "Update a class",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Class ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New class details",
+                        "name": "class",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.UpdateClassRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the version being replaced (required if the server is configured so)",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ClassResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token or API key",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Role or API key scopes lack the permission for this route",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Class not found or outside the caller's scope",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Class has changed since the given ETag",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed (per-field details in errors)",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "428": {
+                        "description": "If-Match is required",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Delete a specific class by its ID. Enrolled students are handled by the delete policy: restrict refuses, cascade deletes them, reassign moves them to reassign_to. Defaults come from configuration.",
+                "tags": [
+                    "classes"
+                ],
+                "summary": "Delete a class",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Class ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "restrict",
+                            "cascade",
+                            "reassign"
+                        ],
+                        "type": "string",
+                        "description": "Delete policy",
+                        "name": "policy",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Class ID to move students to when policy is reassign",
+                        "name": "reassign_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the version being deleted (required if the server is configured so)",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Invalid ID or policy",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token or API key",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Role or API key scopes lack the permission for this route",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Class not found or outside the caller's scope",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Class has students enrolled",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Class has changed since the given ETag",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid reassign target",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "428": {
+                        "description": "If-Match is required",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Apply a JSON Merge Patch (RFC 7396) or JSON Patch (RFC 6902) to the stored class. The patch is applied to the class's update representation and the result is validated like a PUT.",
+                "consumes": [
+                    "application/merge-patch+json",
+                    "application/json-patch+json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "classes"
+                ],
+                "summary": "Partially update a class",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Class ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Merge patch object or JSON patch operation array",
+                        "name": "patch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the version being patched (required if the server is configured so)",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ClassResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid ID or patch document",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token or API key",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Role or API key scopes lack the permission for this route",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Class not found or outside the caller's scope",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Patch cannot be applied",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Class has changed since the given ETag",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported patch media type",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed (per-field details in errors)",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "428": {
+                        "description": "If-Match is required",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/classes/{id}/restore": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Bring back a soft-deleted class. Students deleted along with it stay deleted and are restored separately.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "classes"
+                ],
+                "summary": "Restore a deleted class",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Class ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ClassResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid ID",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token or API key",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Role or API key scopes lack the permission for this route",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "No deleted class with this ID",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/classes/{id}/roster": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "List the students who were in the class at the given time, or now when as_of is omitted, ordered by name, with when each joined and left the class. Guardians and students only see the students they can see now. Students who have since been deleted are included, and purged ones too, by ID only. History from before the server kept it is approximate: such students start in their current class from when the audit log last saw them join it, or from their last change.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "classes"
+                ],
+                "summary": "Get a class roster as of a date",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Class ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Date (midnight UTC) or RFC 3339 time, e.g. 2024-03-01",
+                        "name": "as_of",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.RosterResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid ID or as_of",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token or API key",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Role or API key scopes lack the permission for this route",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Class not found or outside the caller's scope",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/classes/{id}/upsert": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Update the class with the given ID, or create it with that ID if it does not exist. If-Match is honoured when sent but, since this can create, never required.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "classes"
+                ],
+                "summary": "Create or replace a class by ID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Class ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Class details to store",
+                        "name": "class",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.UpdateClassRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the version being replaced",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ClassResponse"
+                        }
+                    },
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ClassResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token or API key",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Role or API key scopes lack the permission for this route",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "ID belongs to a deleted class or one outside the caller's scope",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Class has changed since the given ETag",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed (per-field details in errors)",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/import": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Import classes and students from a CSV or Excel file, sent either as the request body or as the \"file\" field of a multipart form. The header row names the columns: class_name (required), student_name, student_section and id. Classes are matched by name and created when missing; students are matched by id, or by name within their class. The roster is applied in a single transaction, and not at all if any row is a conflict or invalid. With dry_run the changes are reported without being made.",
+                "consumes": [
+                    "text/csv",
+                    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "import"
+                ],
+                "summary": "Import a class roster",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Report the changes without applying them",
+                        "name": "dry_run",
+                        "in": "query"
+                    },
+                    {
+                        "type": "file",
+                        "description": "Roster file, when uploading a multipart form",
+                        "name": "file",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ImportResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Unreadable roster",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Role lacks the permission for this route",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "413": {
+                        "description": "Roster too large",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported file format",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Roster has conflicts or invalid rows; nothing was imported",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ImportResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/students": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get a page of students. Filter with field=value or field[op]=value (eq, ne, gt, gte, lt, lte, like) on id, student_name, class_id and student_section. Teachers only see the students in classes they teach, guardians their linked students and students themselves.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "students"
+                ],
+                "summary": "Get all students",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Page size (default 50, max 500)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of students to skip",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor from a previous page's next_cursor",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated fields, prefix with - for descending (e.g. -student_name,id)",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include soft-deleted students (administrators only)",
+                        "name": "include_deleted",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ListResponse-dto_StudentResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token or API key",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Role or API key scopes lack the permission for this route",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Create a new student with the provided details",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "students"
+                ],
+                "summary": "Create a new student",
+                "parameters": [
+                    {
+                        "description": "Student to create",
+                        "name": "student",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.CreateStudentRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/dto.StudentResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token or API key",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Role or API key scopes lack the permission for this route",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed (per-field details in errors)",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/students/bulk": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Replace up to 1000 students in one transaction. Each item carries the student id and the version it was based on (412 if stale); the version may be left out unless the server requires If-Match (428). In atomic mode (default) the first failure rolls everything back; in partial mode failing items are rolled back on their own and the response is 207 with a result per item.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "students"
+                ],
+                "summary": "Update students in bulk",
+                "parameters": [
+                    {
+                        "description": "Students to update",
+                        "name": "students",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/dto.BulkUpdateStudentRequest"
+                            }
+                        }
+                    },
+                    {
+                        "enum": [
+                            "atomic",
+                            "partial"
+                        ],
+                        "type": "string",
+                        "description": "atomic or partial",
+                        "name": "mode",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "All updated (atomic)",
+                        "schema": {
+                            "$ref": "#/definitions/handler.BulkResponse-dto_StudentResponse"
+                        }
+                    },
+                    "207": {
+                        "description": "Per-item results (partial)",
+                        "schema": {
+                            "$ref": "#/definitions/handler.BulkResponse-dto_StudentResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body or mode",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "A student was not found (atomic)",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "A student has changed since the given version (atomic)",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "413": {
+                        "description": "Too many items",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed (atomic)",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "428": {
+                        "description": "An item has no version and the server requires one",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Create up to 1000 students. In atomic mode (default) all items are checked first and inserted in batches in one transaction; if any item is invalid nothing is stored and the errors point at items as [index].field. In partial mode valid items are stored and the response is 207 with a result per item.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "students"
+                ],
+                "summary": "Create students in bulk",
+                "parameters": [
+                    {
+                        "description": "Students to create",
+                        "name": "students",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/dto.CreateStudentRequest"
+                            }
+                        }
+                    },
+                    {
+                        "enum": [
+                            "atomic",
+                            "partial"
+                        ],
+                        "type": "string",
+                        "description": "atomic or partial",
+                        "name": "mode",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "All created (atomic)",
+                        "schema": {
+                            "$ref": "#/definitions/handler.BulkResponse-dto_StudentResponse"
+                        }
+                    },
+                    "207": {
+                        "description": "Per-item results (partial)",
+                        "schema": {
+                            "$ref": "#/definitions/handler.BulkResponse-dto_StudentResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body or mode",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "413": {
+                        "description": "Too many items",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed (atomic)",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Delete up to 1000 students in one transaction. Each item carries the student id and the version it was based on (412 if stale); the version may be left out unless the server requires If-Match (428). In atomic mode (default) the first failure rolls everything back; in partial mode the response is 207 with a result per item.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "students"
+                ],
+                "summary": "Delete students in bulk",
+                "parameters": [
+                    {
+                        "description": "Students to delete",
+                        "name": "students",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/dto.BulkDeleteStudentRequest"
+                            }
+                        }
+                    },
+                    {
+                        "enum": [
+                            "atomic",
+                            "partial"
+                        ],
+                        "type": "string",
+                        "description": "atomic or partial",
+                        "name": "mode",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "All deleted (atomic)",
+                        "schema": {
+                            "$ref": "#/definitions/handler.BulkResponse-dto_StudentResponse"
+                        }
+                    },
+                    "207": {
+                        "description": "Per-item results (partial)",
+                        "schema": {
+                            "$ref": "#/definitions/handler.BulkResponse-dto_StudentResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body or mode",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "A student was not found (atomic)",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "A student has changed since the given version (atomic)",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "413": {
+                        "description": "Too many items",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "428": {
+                        "description": "An item has no version and the server requires one",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/students/export": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Download every student matching the filters and sort order of the list endpoint as CSV, Excel (XLSX) or newline-delimited JSON, chosen with format or the Accept header (CSV by default). Filter with field=value or field[op]=value on id, student_name, class_id and student_section. Rows are streamed from the database; paging parameters are ignored. In CSV files, text that would be taken for a formula (starting with =, +, -, @, tab or carriage return) is prefixed with an apostrophe; Excel files store it as plain text.",
+                "produces": [
+                    "text/csv",
+                    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+                    "application/x-ndjson"
+                ],
+                "tags": [
+                    "students"
+                ],
+                "summary": "Export students",
+                "parameters": [
+                    {
+                        "enum": [
+                            "csv",
+                            "xlsx",
+                            "ndjson"
+                        ],
+                        "type": "string",
+                        "description": "Export format, overriding the Accept header",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated fields, prefix with - for descending",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include soft-deleted students (administrators only)",
+                        "name": "include_deleted",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query or format",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token or API key",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Role or API key scopes lack the permission for this route",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "No acceptable export format",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/students/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get a specific student by its ID. A student outside the caller's scope is reported as not found. The ETag header carries the student version; send it back in If-None-Match to get 304 when nothing changed.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "students"
+                ],
+                "summary": "Get a student by ID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Student ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag from a previous response",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.StudentResponse"
+                        }
+                    },
+                    "304": {
+                        "description": "Not Modified"
+                    },
+                    "400": {
+                        "description": "Invalid ID",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token or API key",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Role or API key scopes lack the permission for this route",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Student not found or outside the caller's scope",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Update an existing student with the provided details",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "students"
+                ],
+                "summary": "Update a student",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Student ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New student details",
+                        "name": "student",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.UpdateStudentRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the version being replaced (required if the server is configured so)",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.StudentResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token or API key",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Role or API key scopes lack the permission for this route",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Student not found or outside the caller's scope",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Student has changed since the given ETag",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed (per-field details in errors)",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "428": {
+                        "description": "If-Match is required",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Delete a specific student by its ID",
+                "tags": [
+                    "students"
+                ],
+                "summary": "Delete a student",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Student ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the version being deleted (required if the server is configured so)",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Invalid ID",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token or API key",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Role or API key scopes lack the permission for this route",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Student not found or outside the caller's scope",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Student has changed since the given ETag",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "428": {
+                        "description": "If-Match is required",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Apply a JSON Merge Patch (RFC 7396) or JSON Patch (RFC 6902) to the stored student. Fields the patch does not touch keep their stored values, and the result is validated like a PUT.",
+                "consumes": [
+                    "application/merge-patch+json",
+                    "application/json-patch+json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "students"
+                ],
+                "summary": "Partially update a student",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Student ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Merge patch object or JSON patch operation array",
+                        "name": "patch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the version being patched (required if the server is configured so)",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.StudentResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid ID or patch document",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token or API key",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Role or API key scopes lack the permission for this route",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Student not found or outside the caller's scope",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Patch cannot be applied",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Student has changed since the given ETag",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported patch media type",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed or unknown class",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "428": {
+                        "description": "If-Match is required",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/students/{id}/class-history": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "List every class the student has been in, oldest first, with when they joined and left it. The history is kept automatically as students are created, move class, are deleted and are restored, and stays when a deleted student is purged. Times are in UTC. History from before the server kept it is approximate: such students start in their current class from when the audit log last saw them join it, or from their last change.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "students"
+                ],
+                "summary": "Get a student's class history",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Student ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ClassHistoryResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid ID",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token or API key",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Role or API key scopes lack the permission for this route",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Student not found or outside the caller's scope",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/students/{id}/restore": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Bring back a soft-deleted student. The student's class must exist and not be deleted.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "students"
+                ],
+                "summary": "Restore a deleted student",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Student ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.StudentResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid ID",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token or API key",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Role or API key scopes lack the permission for this route",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "No deleted student with this ID",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "The student's class no longer exists",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/students/{id}/upsert": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Update the student with the given ID, or create it with that ID if it does not exist. If-Match is honoured when sent but, since this can create, never required.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "students"
+                ],
+                "summary": "Create or replace a student by ID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Student ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Student details to store",
+                        "name": "student",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.UpdateStudentRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the version being replaced",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated",
+                        "schema": {
+                            "$ref": "#/definitions/dto.StudentResponse"
+                        }
+                    },
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/dto.StudentResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token or API key",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Role or API key scopes lack the permission for this route",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "ID belongs to a deleted student or one outside the caller's scope",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Student has changed since the given ETag",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed (per-field details in errors)",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/tenant": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Return the tenant the request is confined to, with its settings. The tenant is the one the access token or API key belongs to; otherwise it is named by the X-Tenant header or the subdomain, and is the default tenant when neither is given.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tenant"
+                ],
+                "summary": "Get the current tenant",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Slug of the tenant, for requests not tied to one by their credentials",
+                        "name": "X-Tenant",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.TenantResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Tenant is disabled or differs from the credentials' tenant",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Tenant not found",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/tenant/settings": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Replace the settings of the current tenant. Empty values fall back to the server configuration: delete_policy and reassign_to set the default for deleting this tenant's classes, and the oneroster fields name the organization its OneRoster records belong to.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tenant"
+                ],
+                "summary": "Update the current tenant's settings",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Slug of the tenant, for requests not tied to one by their credentials",
+                        "name": "X-Tenant",
+                        "in": "header"
+                    },
+                    {
+                        "description": "New settings",
+                        "name": "settings",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.UpdateTenantSettingsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.TenantResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body or delete policy",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Role may not administer the tenant",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed or reassign_to is not a class of the tenant",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List every user account, ordered by username.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "List users",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/dto.UserResponse"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Role may not manage users",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Create a user account with a role. Teachers see the classes they are set as teacher of and the students in them; guardians see the students linked to them and those students' classes; students see their own record and class. A user with the student role must be linked to a student record.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Create a user",
+                "parameters": [
+                    {
+                        "description": "User to create",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.CreateUserRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/dto.UserResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Role may not manage users",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Username is already taken",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Get a user by ID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.UserResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid ID",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Role may not manage users",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Change a user's role, student link and disabled flag. Access tokens already issued keep the old role until they expire; disabling a user revokes their refresh tokens. The last enabled administrator cannot be demoted or disabled.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Update a user",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New role and status",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.UpdateUserRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.UserResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid ID or request body",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Role may not manage users",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "The change would leave no enabled administrator",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users/{id}/students": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the IDs of the students linked to a guardian.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Get a guardian's students",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.GuardianStudentsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid ID",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Role may not manage users",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Replace the students linked to a user with the guardian role. The guardian sees exactly these students and their classes.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Set a guardian's students",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "IDs of the linked students",
+                        "name": "students",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.GuardianStudentsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.GuardianStudentsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid ID or request body",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Role may not manage users",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "User is not a guardian or a student does not exist",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/ims/oneroster/v1p2/classes": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List classes as OneRoster classes. Page with limit (default 100) and offset; the total is returned in X-Total-Count. Sort and filter by dateLastModified and title.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "oneroster"
+                ],
+                "summary": "List OneRoster classes",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Page size (default 100, max 500)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of classes to skip",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "dateLastModified",
+                            "title"
+                        ],
+                        "type": "string",
+                        "description": "Field to sort by",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
+                        "type": "string",
+                        "description": "Sort direction",
+                        "name": "orderBy",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter expression, e.g. title~'math'",
+                        "name": "filter",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.OneRosterClassesResponse"
+                        },
+                        "headers": {
+                            "X-Total-Count": {
+                                "type": "integer",
+                                "description": "Number of matching classes"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query",
+                        "schema": {
+                            "$ref": "#/definitions/oneroster.StatusInfo"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token",
+                        "schema": {
+                            "$ref": "#/definitions/oneroster.StatusInfo"
+                        }
+                    },
+                    "403": {
+                        "description": "Role lacks the permission for this route",
+                        "schema": {
+                            "$ref": "#/definitions/oneroster.StatusInfo"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/oneroster.StatusInfo"
+                        }
+                    }
+                }
+            }
+        },
+        "/ims/oneroster/v1p2/classes/{sourcedId}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get a class by sourcedId: the ID it was imported with, or else its class ID.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "oneroster"
+                ],
+                "summary": "Get a OneRoster class",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Class sourcedId",
+                        "name": "sourcedId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.OneRosterClassResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token",
+                        "schema": {
+                            "$ref": "#/definitions/oneroster.StatusInfo"
+                        }
+                    },
+                    "403": {
+                        "description": "Role lacks the permission for this route",
+                        "schema": {
+                            "$ref": "#/definitions/oneroster.StatusInfo"
+                        }
+                    },
+                    "404": {
+                        "description": "Class not found",
+                        "schema": {
+                            "$ref": "#/definitions/oneroster.StatusInfo"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/oneroster.StatusInfo"
+                        }
+                    }
+                }
+            }
+        },
+        "/ims/oneroster/v1p2/classes/{sourcedId}/students": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List the students enrolled in a class as OneRoster users, paged and filtered like the users collection.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "oneroster"
+                ],
+                "summary": "List the students of a OneRoster class",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Class sourcedId",
+                        "name": "sourcedId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size (default 100, max 500)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of users to skip",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "dateLastModified"
+                        ],
+                        "type": "string",
+                        "description": "Field to sort by",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
+                        "type": "string",
+                        "description": "Sort direction",
+                        "name": "orderBy",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter expression",
+                        "name": "filter",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.UsersResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query",
+                        "schema": {
+                            "$ref": "#/definitions/oneroster.StatusInfo"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token",
+                        "schema": {
+                            "$ref": "#/definitions/oneroster.StatusInfo"
+                        }
+                    },
+                    "403": {
+                        "description": "Role lacks the permission for this route",
+                        "schema": {
+                            "$ref": "#/definitions/oneroster.StatusInfo"
+                        }
+                    },
+                    "404": {
+                        "description": "Class not found",
+                        "schema": {
+                            "$ref": "#/definitions/oneroster.StatusInfo"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/oneroster.StatusInfo"
+                        }
+                    }
+                }
+            }
+        },
+        "/ims/oneroster/v1p2/csv": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Download every class and student as a OneRoster 1.2 bulk CSV bundle: a zip archive of manifest.csv, orgs.csv, courses.csv, classes.csv, users.csv and enrollments.csv. Each class is also its own course; student sections are in the metadata.section column of users.csv.",
+                "produces": [
+                    "application/zip"
+                ],
+                "tags": [
+                    "oneroster"
+                ],
+                "summary": "Export a OneRoster CSV bundle",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token",
+                        "schema": {
+                            "$ref": "#/definitions/oneroster.StatusInfo"
+                        }
+                    },
+                    "403": {
+                        "description": "Role lacks the permission for this route",
+                        "schema": {
+                            "$ref": "#/definitions/oneroster.StatusInfo"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/oneroster.StatusInfo"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Import classes, student users and student enrollments from a OneRoster 1.2 CSV bundle (zip), sent as the request body or as the \"file\" field of a multipart form. Records are matched by sourcedId; records with status tobedeleted are deleted. Files follow the mode manifest.csv gives them: absent files are ignored, delta files change the records they list, and bulk classes.csv and users.csv files also delete the stored classes and students they leave out. Files the manifest does not mention, or every file when there is no manifest, are treated as delta. A bulk enrollments.csv is applied like a delta one, since students always belong to a class. Users and enrollments with other roles are skipped. The bundle is applied in a single transaction, and not at all if any record is a conflict or invalid. With dry_run the changes are reported without being made.",
+                "consumes": [
+                    "application/zip",
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "oneroster"
+                ],
+                "summary": "Import a OneRoster CSV bundle",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Report the changes without applying them",
+                        "name": "dry_run",
+                        "in": "query"
+                    },
+                    {
+                        "type": "file",
+                        "description": "Bundle, when uploading a multipart form",
+                        "name": "file",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ImportResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Unreadable bundle",
+                        "schema": {
+                            "$ref": "#/definitions/oneroster.StatusInfo"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token",
+                        "schema": {
+                            "$ref": "#/definitions/oneroster.StatusInfo"
+                        }
+                    },
+                    "403": {
+                        "description": "Role lacks the permission for this route",
+                        "schema": {
+                            "$ref": "#/definitions/oneroster.StatusInfo"
+                        }
+                    },
+                    "413": {
+                        "description": "Bundle too large",
+                        "schema": {
+                            "$ref": "#/definitions/oneroster.StatusInfo"
+                        }
+                    },
+                    "422": {
+                        "description": "Bundle has conflicts or invalid records; nothing was imported",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ImportResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/oneroster.StatusInfo"
+                        }
+                    }
+                }
+            }
+        },
+        "/ims/oneroster/v1p2/enrollments": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List the enrollment of every student in their class. Page with limit (default 100) and offset; sort and filter by dateLastModified.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "oneroster"
+                ],
+                "summary": "List OneRoster enrollments",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Page size (default 100, max 500)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of enrollments to skip",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "dateLastModified"
+                        ],
+                        "type": "string",
+                        "description": "Field to sort by",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
+                        "type": "string",
+                        "description": "Sort direction",
+                        "name": "orderBy",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter expression",
+                        "name": "filter",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.EnrollmentsResponse"
+                        },
+                        "headers": {
+                            "X-Total-Count": {
+                                "type": "integer",
+                                "description": "Number of matching enrollments"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query",
+                        "schema": {
+                            "$ref": "#/definitions/oneroster.StatusInfo"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token",
+                        "schema": {
+                            "$ref": "#/definitions/oneroster.StatusInfo"
+                        }
+                    },
+                    "403": {
+                        "description": "Role lacks the permission for this route",
+                        "schema": {
+                            "$ref": "#/definitions/oneroster.StatusInfo"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/oneroster.StatusInfo"
+                        }
+                    }
+                }
+            }
+        },
+        "/ims/oneroster/v1p2/enrollments/{sourcedId}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get a student's enrollment by sourcedId, which is the student's sourcedId prefixed with \"enrollment-\".",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "oneroster"
+                ],
+                "summary": "Get a OneRoster enrollment",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Enrollment sourcedId",
+                        "name": "sourcedId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.EnrollmentResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token",
+                        "schema": {
+                            "$ref": "#/definitions/oneroster.StatusInfo"
+                        }
+                    },
+                    "403": {
+                        "description": "Role lacks the permission for this route",
+                        "schema": {
+                            "$ref": "#/definitions/oneroster.StatusInfo"
+                        }
+                    },
+                    "404": {
+                        "description": "enrollment not found",
+                        "schema": {
+                            "$ref": "#/definitions/oneroster.StatusInfo"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/oneroster.StatusInfo"
+                        }
+                    }
+                }
+            }
+        },
+        "/ims/oneroster/v1p2/users": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List students as OneRoster users. Page with limit (default 100) and offset; the total is returned in X-Total-Count. Sort and filter by dateLastModified, e.g. filter=dateLastModified\u003e'2024-09-01'.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "oneroster"
+                ],
+                "summary": "List OneRoster users",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Page size (default 100, max 500)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of users to skip",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "dateLastModified"
+                        ],
+                        "type": "string",
+                        "description": "Field to sort by",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
+                        "type": "string",
+                        "description": "Sort direction",
+                        "name": "orderBy",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter expression, e.g. dateLastModified\u003e='2024-09-01'",
+                        "name": "filter",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.UsersResponse"
+                        },
+                        "headers": {
+                            "X-Total-Count": {
+                                "type": "integer",
+                                "description": "Number of matching users"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query",
+                        "schema": {
+                            "$ref": "#/definitions/oneroster.StatusInfo"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token",
+                        "schema": {
+                            "$ref": "#/definitions/oneroster.StatusInfo"
+                        }
+                    },
+                    "403": {
+                        "description": "Role lacks the permission for this route",
+                        "schema": {
+                            "$ref": "#/definitions/oneroster.StatusInfo"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/oneroster.StatusInfo"
+                        }
+                    }
+                }
+            }
+        },
+        "/ims/oneroster/v1p2/users/{sourcedId}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get a student as a OneRoster user by sourcedId: the ID it was imported with, or else its student ID.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "oneroster"
+                ],
+                "summary": "Get a OneRoster user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User sourcedId",
+                        "name": "sourcedId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.UserResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid access token",
+                        "schema": {
+                            "$ref": "#/definitions/oneroster.StatusInfo"
+                        }
+                    },
+                    "403": {
+                        "description": "Role lacks the permission for this route",
+                        "schema": {
+                            "$ref": "#/definitions/oneroster.StatusInfo"
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "$ref": "#/definitions/oneroster.StatusInfo"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/oneroster.StatusInfo"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "apperror.Code": {
+            "type": "string",
+            "enum": [
+                "bad_request",
+                "invalid_query",
+                "unauthorized",
+                "forbidden",
+                "not_found",
+                "not_acceptable",
+                "conflict",
+                "duplicate",
+                "referenced",
+                "patch_conflict",
+                "precondition_failed",
+                "precondition_required",
+                "too_large",
+                "unsupported_media_type",
+                "validation_failed",
+                "request_canceled",
+                "timeout",
+                "internal_error"
+            ],
+            "x-enum-varnames": [
+                "CodeBadRequest",
+                "CodeInvalidQuery",
+                "CodeUnauthorized",
+                "CodeForbidden",
+                "CodeNotFound",
+                "CodeNotAcceptable",
+                "CodeConflict",
+                "CodeDuplicate",
+                "CodeReferenced",
+                "CodePatchConflict",
+                "CodePreconditionFailed",
+                "CodePreconditionRequired",
+                "CodeTooLarge",
+                "CodeUnsupportedMediaType",
+                "CodeValidation",
+                "CodeCanceled",
+                "CodeTimeout",
+                "CodeInternal"
+            ]
+        },
+        "apperror.FieldError": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "apperror.Problem": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "$ref": "#/definitions/apperror.Code"
+                },
+                "detail": {
+                    "type": "string"
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/apperror.FieldError"
+                    }
+                },
+                "instance": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "integer"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "auth.JWK": {
+            "type": "object",
+            "properties": {
+                "alg": {
+                    "type": "string",
+                    "example": "RS256"
+                },
+                "e": {
+                    "type": "string",
+                    "example": "AQAB"
+                },
+                "kid": {
+                    "type": "string",
+                    "example": "2024-09"
+                },
+                "kty": {
+                    "type": "string",
+                    "example": "RSA"
+                },
+                "n": {
+                    "type": "string"
+                },
+                "use": {
+                    "type": "string",
+                    "example": "sig"
+                }
+            }
+        },
+        "auth.JWKS": {
+            "type": "object",
+            "properties": {
+                "keys": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/auth.JWK"
+                    }
+                }
+            }
+        },
+        "dto.APIKeyResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "created_by_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "expires_at": {
+                    "description": "ExpiresAt is omitted for keys that do not expire",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "last_used_at": {
+                    "description": "LastUsedAt and UsageCount are written about once a minute, so they\nmay not yet include the latest requests",
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Timetable sync"
+                },
+                "prefix": {
+                    "type": "string",
+                    "example": "sk_3f9a1c0b7e2d"
+                },
+                "previous_key_expires_at": {
+                    "description": "PreviousKeyExpiresAt is set after a rotation with a grace period,\nuntil which the replaced key still works",
+                    "type": "string"
+                },
+                "revoked_at": {
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "read:classes",
+                        "read:students"
+                    ]
+                },
+                "usage_count": {
+                    "type": "integer",
+                    "example": 1520
+                }
+            }
+        },
+        "dto.APIKeySecretResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "created_by_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "expires_at": {
+                    "description": "ExpiresAt is omitted for keys that do not expire",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "key": {
+                    "description": "Key is sent as \"X-API-Key: \u003ckey\u003e\" or \"Authorization: Bearer \u003ckey\u003e\"",
+                    "type": "string",
+                    "example": "sk_3f9a1c0b7e2d_0c4e..."
+                },
+                "last_used_at": {
+                    "description": "LastUsedAt and UsageCount are written about once a minute, so they\nmay not yet include the latest requests",
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Timetable sync"
+                },
+                "prefix": {
+                    "type": "string",
+                    "example": "sk_3f9a1c0b7e2d"
+                },
+                "previous_key_expires_at": {
+                    "description": "PreviousKeyExpiresAt is set after a rotation with a grace period,\nuntil which the replaced key still works",
+                    "type": "string"
+                },
+                "revoked_at": {
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "read:classes",
+                        "read:students"
+                    ]
+                },
+                "usage_count": {
+                    "type": "integer",
+                    "example": 1520
+                }
+            }
+        },
+        "dto.AuditEntryResponse": {
+            "type": "object",
+            "properties": {
+                "actor": {
+                    "description": "Actor is the username or API key name at the time of the change",
+                    "type": "string",
+                    "example": "mrs.smith"
+                },
+                "actor_id": {
+                    "description": "ActorID is the ID of the user or API key, omitted for the system",
+                    "type": "integer",
+                    "example": 3
+                },
+                "actor_type": {
+                    "type": "string",
+                    "enum": [
+                        "user",
+                        "api_key",
+                        "system"
+                    ],
+                    "example": "user"
+                },
+                "changes": {
+                    "description": "Changes maps each changed field to its value before and after the\nchange; null stands for a record that did not exist or was deleted",
+                    "type": "object"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "entity": {
+                    "type": "string",
+                    "enum": [
+                        "class",
+                        "student"
+                    ],
+                    "example": "student"
+                },
+                "entity_id": {
+                    "type": "integer",
+                    "example": 12
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 812
+                },
+                "operation": {
+                    "type": "string",
+                    "enum": [
+                        "create",
+                        "update",
+                        "delete",
+                        "restore",
+                        "purge"
+                    ],
+                    "example": "update"
+                }
+            }
+        },
+        "dto.BulkDeleteStudentRequest": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "version": {
+                    "type": "integer",
+                    "example": 3
+                }
+            }
+        },
+        "dto.BulkUpdateStudentRequest": {
+            "type": "object",
+            "properties": {
+                "class_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "student_name": {
+                    "type": "string",
+                    "maxLength": 100,
+                    "example": "Jane Doe"
+                },
+                "student_section": {
+                    "type": "string",
+                    "enum": [
+                        "A",
+                        "B",
+                        "C",
+                        "D",
+                        "E",
+                        "F"
+                    ],
+                    "example": "A"
+                },
+                "version": {
+                    "type": "integer",
+                    "example": 3
+                }
+            }
+        },
+        "dto.ClassHistoryResponse": {
+            "type": "object",
+            "properties": {
+                "enrollments": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.EnrollmentResponse"
+                    }
+                },
+                "student_id": {
+                    "type": "integer",
+                    "example": 12
+                }
+            }
+        },
+        "dto.ClassResponse": {
+            "type": "object",
+            "properties": {
+                "class_name": {
+                    "type": "string",
+                    "example": "Grade 5"
+                },
+                "deleted_at": {
+                    "description": "DeletedAt is only set on deleted classes, which are listed with include_deleted",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "sourced_id": {
+                    "description": "SourcedID is only set on classes imported from an external roster system",
+                    "type": "string",
+                    "example": "cls-1001"
+                },
+                "student_count": {
+                    "type": "integer",
+                    "example": 24
+                },
+                "students": {
+                    "description": "Students is only filled in when the class was created with students",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.StudentResponse"
+                    }
+                },
+                "teacher_id": {
+                    "type": "integer",
+                    "example": 7
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "integer",
+                    "example": 3
+                }
+            }
+        },
+        "dto.ClassStudentRequest": {
+            "type": "object",
+            "properties": {
+                "student_name": {
+                    "type": "string",
+                    "maxLength": 100,
+                    "example": "Jane Doe"
+                },
+                "student_section": {
+                    "type": "string",
+                    "enum": [
+                        "A",
+                        "B",
+                        "C",
+                        "D",
+                        "E",
+                        "F"
+                    ],
+                    "example": "A"
+                }
+            }
+        },
+        "dto.CreateAPIKeyRequest": {
+            "type": "object",
+            "properties": {
+                "expires_at": {
+                    "description": "ExpiresAt is when the key stops working; omit it for a key that does not expire",
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string",
+                    "maxLength": 100,
+                    "example": "Timetable sync"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string",
+                        "enum": [
+                            "read:classes",
+                            "write:classes",
+                            "read:students",
+                            "write:students"
+                        ]
+                    },
+                    "example": [
+                        "read:classes",
+                        "read:students"
+                    ]
+                }
+            }
+        },
+        "dto.CreateClassRequest": {
+            "type": "object",
+            "properties": {
+                "class_name": {
+                    "type": "string",
+                    "maxLength": 100,
+                    "example": "Grade 5"
+                },
+                "students": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.ClassStudentRequest"
+                    }
+                },
+                "teacher_id": {
+                    "type": "integer",
+                    "example": 7
+                }
+            }
+        },
+        "dto.CreateStudentRequest": {
+            "type": "object",
+            "properties": {
+                "class_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "student_name": {
+                    "type": "string",
+                    "maxLength": 100,
+                    "example": "Jane Doe"
+                },
+                "student_section": {
+                    "type": "string",
+                    "enum": [
+                        "A",
+                        "B",
+                        "C",
+                        "D",
+                        "E",
+                        "F"
+                    ],
+                    "example": "A"
+                }
+            }
+        },
+        "dto.CreateUserRequest": {
+            "type": "object",
+            "properties": {
+                "password": {
+                    "type": "string",
+                    "maxLength": 72,
+                    "minLength": 8,
+                    "example": "correct horse battery"
+                },
+                "role": {
+                    "type": "string",
+                    "enum": [
+                        "admin",
+                        "teacher",
+                        "guardian",
+                        "student"
+                    ],
+                    "example": "teacher"
+                },
+                "student_id": {
+                    "description": "StudentID links a new student user to their student record",
+                    "type": "integer",
+                    "example": 12
+                },
+                "username": {
+                    "type": "string",
+                    "maxLength": 100,
+                    "example": "mrs.smith"
+                }
+            }
+        },
+        "dto.EnrollmentResponse": {
+            "type": "object",
+            "properties": {
+                "class_id": {
+                    "type": "integer",
+                    "example": 5
+                },
+                "class_name": {
+                    "type": "string",
+                    "example": "Grade 5"
+                },
+                "student_id": {
+                    "type": "integer",
+                    "example": 12
+                },
+                "student_name": {
+                    "type": "string",
+                    "example": "Ann Lee"
+                },
+                "valid_from": {
+                    "type": "string"
+                },
+                "valid_to": {
+                    "description": "ValidTo is when the student left the class, omitted while they are still in it",
+                    "type": "string"
+                }
+            }
+        },
+        "dto.GuardianStudentsRequest": {
+            "type": "object",
+            "properties": {
+                "student_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    },
+                    "example": [
+                        12,
+                        13
+                    ]
+                }
+            }
+        },
+        "dto.GuardianStudentsResponse": {
+            "type": "object",
+            "properties": {
+                "student_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    },
+                    "example": [
+                        12,
+                        13
+                    ]
+                },
+                "user_id": {
+                    "type": "integer",
+                    "example": 5
+                }
+            }
+        },
+        "dto.LoginRequest": {
+            "type": "object",
+            "properties": {
+                "password": {
+                    "type": "string",
+                    "example": "correct horse battery staple"
+                },
+                "username": {
+                    "type": "string",
+                    "example": "admin"
+                }
+            }
+        },
+        "dto.RefreshRequest": {
+            "type": "object",
+            "properties": {
+                "refresh_token": {
+                    "type": "string",
+                    "example": "m3Vd0c2lH9yN4bW7...Q"
+                }
+            }
+        },
+        "dto.RosterResponse": {
+            "type": "object",
+            "properties": {
+                "as_of": {
+                    "type": "string"
+                },
+                "class_id": {
+                    "type": "integer",
+                    "example": 5
+                },
+                "students": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.EnrollmentResponse"
+                    }
+                }
+            }
+        },
+        "dto.StudentResponse": {
+            "type": "object",
+            "properties": {
+                "class_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "deleted_at": {
+                    "description": "DeletedAt is only set on deleted students, which are listed with include_deleted",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "sourced_id": {
+                    "description": "SourcedID is only set on students imported from an external roster system",
+                    "type": "string",
+                    "example": "usr-2001"
+                },
+                "student_name": {
+                    "type": "string",
+                    "example": "Jane Doe"
+                },
+                "student_section": {
+                    "type": "string",
+                    "example": "A"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "integer",
+                    "example": 3
+                }
+            }
+        },
+        "dto.TenantResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Northside Primary"
+                },
+                "settings": {
+                    "$ref": "#/definitions/dto.TenantSettings"
+                },
+                "slug": {
+                    "type": "string",
+                    "example": "northside"
+                }
+            }
+        },
+        "dto.TenantSettings": {
+            "type": "object",
+            "properties": {
+                "delete_policy": {
+                    "description": "DeletePolicy is the default policy for deleting this tenant's classes",
+                    "type": "string",
+                    "enum": [
+                        "restrict",
+                        "cascade",
+                        "reassign"
+                    ],
+                    "example": "reassign"
+                },
+                "oneroster_org_name": {
+                    "type": "string",
+                    "maxLength": 255,
+                    "example": "Northside Primary"
+                },
+                "oneroster_org_sourced_id": {
+                    "type": "string",
+                    "maxLength": 255,
+                    "example": "northside"
+                },
+                "reassign_to": {
+                    "description": "ReassignTo is the default class students are moved to by the reassign policy",
+                    "type": "integer",
+                    "example": 3
+                }
+            }
+        },
+        "dto.TokenResponse": {
+            "type": "object",
+            "properties": {
+                "access_token": {
+                    "type": "string",
+                    "example": "eyJhbGciOiJIUzI1NiIsImtpZCI6IjIwMjQtMDkifQ..."
+                },
+                "expires_in": {
+                    "description": "ExpiresIn is the lifetime of the access token in seconds",
+                    "type": "integer",
+                    "example": 900
+                },
+                "refresh_expires_in": {
+                    "description": "RefreshExpiresIn is the lifetime of the refresh token in seconds",
+                    "type": "integer",
+                    "example": 2592000
+                },
+                "refresh_token": {
+                    "type": "string",
+                    "example": "m3Vd0c2lH9yN4bW7...Q"
+                },
+                "token_type": {
+                    "type": "string",
+                    "example": "Bearer"
+                }
+            }
+        },
+        "dto.UpdateClassRequest": {
+            "type": "object",
+            "properties": {
+                "class_name": {
+                    "type": "string",
+                    "maxLength": 100,
+                    "example": "Grade 5"
+                },
+                "teacher_id": {
+                    "description": "TeacherID is the ID of a user with the teacher role; omit it to\nleave the class without a teacher",
+                    "type": "integer",
+                    "example": 7
+                }
+            }
+        },
+        "dto.UpdateStudentRequest": {
+            "type": "object",
+            "properties": {
+                "class_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "student_name": {
+                    "type": "string",
+                    "maxLength": 100,
+                    "example": "Jane Doe"
+                },
+                "student_section": {
+                    "type": "string",
+                    "enum": [
+                        "A",
+                        "B",
+                        "C",
+                        "D",
+                        "E",
+                        "F"
+                    ],
+                    "example": "A"
+                }
+            }
+        },
+        "dto.UpdateTenantSettingsRequest": {
+            "type": "object",
+            "properties": {
+                "delete_policy": {
+                    "description": "DeletePolicy is the default policy for deleting this tenant's classes",
+                    "type": "string",
+                    "enum": [
+                        "restrict",
+                        "cascade",
+                        "reassign"
+                    ],
+                    "example": "reassign"
+                },
+                "oneroster_org_name": {
+                    "type": "string",
+                    "maxLength": 255,
+                    "example": "Northside Primary"
+                },
+                "oneroster_org_sourced_id": {
+                    "type": "string",
+                    "maxLength": 255,
+                    "example": "northside"
+                },
+                "reassign_to": {
+                    "description": "ReassignTo is the default class students are moved to by the reassign policy",
+                    "type": "integer",
+                    "example": 3
+                }
+            }
+        },
+        "dto.UpdateUserRequest": {
+            "type": "object",
+            "properties": {
+                "disabled": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "role": {
+                    "type": "string",
+                    "enum": [
+                        "admin",
+                        "teacher",
+                        "guardian",
+                        "student"
+                    ],
+                    "example": "teacher"
+                },
+                "student_id": {
+                    "description": "StudentID must be sent again when the role stays student and left\nout when it changes to any other",
+                    "type": "integer",
+                    "example": 12
+                }
+            }
+        },
+        "dto.UserResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "disabled": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "role": {
+                    "type": "string",
+                    "enum": [
+                        "admin",
+                        "teacher",
+                        "guardian",
+                        "student"
+                    ],
+                    "example": "teacher"
+                },
+                "student_id": {
+                    "type": "integer",
+                    "example": 12
+                },
+                "username": {
+                    "type": "string",
+                    "example": "admin"
+                }
+            }
+        },
+        "handler.BulkItemResult-dto_StudentResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "$ref": "#/definitions/dto.StudentResponse"
+                },
+                "error": {
+                    "$ref": "#/definitions/apperror.Problem"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "index": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handler.BulkResponse-dto_StudentResponse": {
+            "type": "object",
+            "properties": {
+                "failed": {
+                    "type": "integer"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handler.BulkItemResult-dto_StudentResponse"
+                    }
+                },
+                "succeeded": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handler.EnrollmentResponse": {
+            "type": "object",
+            "properties": {
+                "enrollment": {
+                    "$ref": "#/definitions/oneroster.Enrollment"
+                }
+            }
+        },
+        "handler.EnrollmentsResponse": {
+            "type": "object",
+            "properties": {
+                "enrollments": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/oneroster.Enrollment"
+                    }
+                }
+            }
+        },
+        "handler.ImportFieldDiff": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "type": "string",
+                    "example": "student_section"
+                },
+                "from": {
+                    "type": "string",
+                    "example": "A"
+                },
+                "to": {
+                    "type": "string",
+                    "example": "B"
+                }
+            }
+        },
+        "handler.ImportItemResponse": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string",
+                    "enum": [
+                        "create",
+                        "update",
+                        "unchanged",
+                        "delete",
+                        "conflict",
+                        "invalid",
+                        "skipped"
+                    ],
+                    "example": "update"
+                },
+                "changes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handler.ImportFieldDiff"
+                    }
+                },
+                "entity": {
+                    "type": "string",
+                    "enum": [
+                        "class",
+                        "student",
+                        "enrollment"
+                    ],
+                    "example": "student"
+                },
+                "file": {
+                    "type": "string",
+                    "example": "users.csv"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 7
+                },
+                "line": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "message": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Jane Doe"
+                }
+            }
+        },
+        "handler.ImportResponse": {
+            "type": "object",
+            "properties": {
+                "committed": {
+                    "type": "boolean"
+                },
+                "dry_run": {
+                    "type": "boolean"
+                },
+                "ignored_columns": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handler.ImportItemResponse"
+                    }
+                },
+                "summary": {
+                    "$ref": "#/definitions/handler.ImportSummary"
+                }
+            }
+        },
+        "handler.ImportSummary": {
+            "type": "object",
+            "properties": {
+                "conflicts": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "creates": {
+                    "type": "integer",
+                    "example": 12
+                },
+                "deletes": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "invalid": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "skipped": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "unchanged": {
+                    "type": "integer",
+                    "example": 20
+                },
+                "updates": {
+                    "type": "integer",
+                    "example": 3
+                }
+            }
+        },
+        "handler.ListResponse-dto_AuditEntryResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.AuditEntryResponse"
+                    }
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "next": {
+                    "type": "string"
+                },
+                "next_cursor": {
+                    "type": "string"
+                },
+                "offset": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handler.ListResponse-dto_ClassResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.ClassResponse"
+                    }
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "next": {
+                    "type": "string"
+                },
+                "next_cursor": {
+                    "type": "string"
+                },
+                "offset": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handler.ListResponse-dto_StudentResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.StudentResponse"
+                    }
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "next": {
+                    "type": "string"
+                },
+                "next_cursor": {
+                    "type": "string"
+                },
+                "offset": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handler.OneRosterClassResponse": {
+            "type": "object",
+            "properties": {
+                "class": {
+                    "$ref": "#/definitions/oneroster.Class"
+                }
+            }
+        },
+        "handler.OneRosterClassesResponse": {
+            "type": "object",
+            "properties": {
+                "classes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/oneroster.Class"
+                    }
+                }
+            }
+        },
+        "handler.PurgeResponse": {
+            "type": "object",
+            "properties": {
+                "classes": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "deleted_before": {
+                    "type": "string"
+                },
+                "refresh_tokens": {
+                    "type": "integer",
+                    "example": 12
+                },
+                "students": {
+                    "type": "integer",
+                    "example": 40
+                }
+            }
+        },
+        "handler.UserResponse": {
+            "type": "object",
+            "properties": {
+                "user": {
+                    "$ref": "#/definitions/oneroster.User"
+                }
+            }
+        },
+        "handler.UsersResponse": {
+            "type": "object",
+            "properties": {
+                "users": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/oneroster.User"
+                    }
+                }
+            }
+        },
+        "oneroster.Class": {
+            "type": "object",
+            "properties": {
+                "classType": {
+                    "type": "string",
+                    "example": "homeroom"
+                },
+                "course": {
+                    "$ref": "#/definitions/oneroster.GUIDRef"
+                },
+                "dateLastModified": {
+                    "type": "string"
+                },
+                "metadata": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "school": {
+                    "$ref": "#/definitions/oneroster.GUIDRef"
+                },
+                "sourcedId": {
+                    "type": "string",
+                    "example": "12"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "active"
+                },
+                "terms": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/oneroster.GUIDRef"
+                    }
+                },
+                "title": {
+                    "type": "string",
+                    "example": "Grade 5"
+                }
+            }
+        },
+        "oneroster.CodeMinor": {
+            "type": "object",
+            "properties": {
+                "imsx_codeMinorField": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/oneroster.CodeMinorField"
+                    }
+                }
+            }
+        },
+        "oneroster.CodeMinorField": {
+            "type": "object",
+            "properties": {
+                "imsx_codeMinorFieldName": {
+                    "type": "string",
+                    "example": "TargetEndSystem"
+                },
+                "imsx_codeMinorFieldValue": {
+                    "type": "string",
+                    "example": "unknownobject"
+                }
+            }
+        },
+        "oneroster.Enrollment": {
+            "type": "object",
+            "properties": {
+                "class": {
+                    "$ref": "#/definitions/oneroster.GUIDRef"
+                },
+                "dateLastModified": {
+                    "type": "string"
+                },
+                "primary": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "role": {
+                    "type": "string",
+                    "example": "student"
+                },
+                "school": {
+                    "$ref": "#/definitions/oneroster.GUIDRef"
+                },
+                "sourcedId": {
+                    "type": "string",
+                    "example": "enrollment-7"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "active"
+                },
+                "user": {
+                    "$ref": "#/definitions/oneroster.GUIDRef"
+                }
+            }
+        },
+        "oneroster.GUIDRef": {
+            "type": "object",
+            "properties": {
+                "href": {
+                    "type": "string",
+                    "example": "/ims/oneroster/v1p2/classes/12"
+                },
+                "sourcedId": {
+                    "type": "string",
+                    "example": "12"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "class"
+                }
+            }
+        },
+        "oneroster.RoleAssignment": {
+            "type": "object",
+            "properties": {
+                "org": {
+                    "$ref": "#/definitions/oneroster.GUIDRef"
+                },
+                "role": {
+                    "type": "string",
+                    "example": "student"
+                },
+                "roleType": {
+                    "type": "string",
+                    "example": "primary"
+                }
+            }
+        },
+        "oneroster.StatusInfo": {
+            "type": "object",
+            "properties": {
+                "imsx_CodeMinor": {
+                    "$ref": "#/definitions/oneroster.CodeMinor"
+                },
+                "imsx_codeMajor": {
+                    "type": "string",
+                    "example": "failure"
+                },
+                "imsx_description": {
+                    "type": "string",
+                    "example": "user not found"
+                },
+                "imsx_severity": {
+                    "type": "string",
+                    "example": "error"
+                }
+            }
+        },
+        "oneroster.User": {
+            "type": "object",
+            "properties": {
+                "dateLastModified": {
+                    "type": "string"
+                },
+                "enabledUser": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "familyName": {
+                    "type": "string",
+                    "example": "Doe"
+                },
+                "givenName": {
+                    "type": "string",
+                    "example": "Jane"
+                },
+                "metadata": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "primaryOrg": {
+                    "$ref": "#/definitions/oneroster.GUIDRef"
+                },
+                "roles": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/oneroster.RoleAssignment"
+                    }
+                },
+                "sourcedId": {
+                    "type": "string",
+                    "example": "7"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "active"
+                },
+                "username": {
+                    "type": "string",
+                    "example": "7"
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "ApiKeyAuth": {
+            "description": "API key from POST /api/api-keys; it may also be sent as \"Authorization: Bearer \u003ckey\u003e\"",
+            "type": "apiKey",
+            "name": "X-API-Key",
+            "in": "header"
+        },
+        "BearerAuth": {
+            "description": "Access token from POST /api/auth/login, sent as \"Bearer \u003ctoken\u003e\"",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
@@ -451,7 +5421,7 @@ const docTemplate = `{
 var SwaggerInfo = &swag.Spec{
 	Version:          "1.0",
 	Host:             "localhost:8081",
-	BasePath:         "/api",
+	BasePath:         "/",
 	Schemes:          []string{},
 	Title:            "School API",
 	Description:      "This is a sample school API server.",
@@ -0,0 +1,75 @@
+package dto
+
+import (
+	"school-api/models"
+	"school-api/service"
+	"strings"
+	"time"
+)
+
+// CreateAPIKeyRequest is the body accepted when creating an API key
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name" example:"Timetable sync" maxLength:"100"`
+	Scopes []string `json:"scopes" example:"read:classes,read:students" enums:"read:classes,write:classes,read:students,write:students"`
+	// ExpiresAt is when the key stops working; omit it for a key that does not expire
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// APIKeyResponse is the representation of an API key returned to clients.
+// The key itself is never included.
+type APIKeyResponse struct {
+	ID     uint     `json:"id" example:"1"`
+	Name   string   `json:"name" example:"Timetable sync"`
+	Prefix string   `json:"prefix" example:"sk_3f9a1c0b7e2d"`
+	Scopes []string `json:"scopes" example:"read:classes,read:students"`
+	// ExpiresAt is omitted for keys that do not expire
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	// LastUsedAt and UsageCount are written about once a minute, so they
+	// may not yet include the latest requests
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	UsageCount int64      `json:"usage_count" example:"1520"`
+	// PreviousKeyExpiresAt is set after a rotation with a grace period,
+	// until which the replaced key still works
+	PreviousKeyExpiresAt *time.Time `json:"previous_key_expires_at,omitempty"`
+	CreatedByID          *uint      `json:"created_by_id,omitempty" example:"1"`
+	CreatedAt            time.Time  `json:"created_at"`
+}
+
+// APIKeySecretResponse is an API key together with the key itself, returned
+// only when the key is created or rotated
+type APIKeySecretResponse struct {
+	APIKeyResponse
+	// Key is sent as "X-API-Key: <key>" or "Authorization: Bearer <key>"
+	Key string `json:"key" example:"sk_3f9a1c0b7e2d_0c4e..."`
+}
+
+// ToInput returns the key the request asks CreateKey to issue
+func (r CreateAPIKeyRequest) ToInput() service.NewAPIKey {
+	return service.NewAPIKey{Name: r.Name, Scopes: r.Scopes, ExpiresAt: r.ExpiresAt}
+}
+
+// NewAPIKeyResponse maps a stored API key to its API representation
+func NewAPIKeyResponse(k *models.APIKey) APIKeyResponse {
+	resp := APIKeyResponse{
+		ID:          k.ID,
+		Name:        k.Name,
+		Prefix:      k.Prefix,
+		Scopes:      strings.Fields(k.Scopes),
+		ExpiresAt:   k.ExpiresAt,
+		RevokedAt:   k.RevokedAt,
+		LastUsedAt:  k.LastUsedAt,
+		UsageCount:  k.UsageCount,
+		CreatedByID: k.CreatedByID,
+		CreatedAt:   k.CreatedAt,
+	}
+	if k.PreviousExpiresAt != nil && time.Now().Before(*k.PreviousExpiresAt) {
+		resp.PreviousKeyExpiresAt = k.PreviousExpiresAt
+	}
+	return resp
+}
+
+// NewAPIKeySecretResponse is NewAPIKeyResponse with the key itself
+func NewAPIKeySecretResponse(k *models.APIKey, key string) APIKeySecretResponse {
+	return APIKeySecretResponse{APIKeyResponse: NewAPIKeyResponse(k), Key: key}
+}
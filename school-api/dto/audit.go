@@ -0,0 +1,39 @@
+package dto
+
+import (
+	"encoding/json"
+	"school-api/models"
+	"time"
+)
+
+// AuditEntryResponse is the representation of an audit log entry returned to clients
+type AuditEntryResponse struct {
+	ID        uint   `json:"id" example:"812"`
+	Entity    string `json:"entity" example:"student" enums:"class,student"`
+	EntityID  uint   `json:"entity_id" example:"12"`
+	Operation string `json:"operation" example:"update" enums:"create,update,delete,restore,purge"`
+	ActorType string `json:"actor_type" example:"user" enums:"user,api_key,system"`
+	// ActorID is the ID of the user or API key, omitted for the system
+	ActorID *uint `json:"actor_id,omitempty" example:"3"`
+	// Actor is the username or API key name at the time of the change
+	Actor string `json:"actor,omitempty" example:"mrs.smith"`
+	// Changes maps each changed field to its value before and after the
+	// change; null stands for a record that did not exist or was deleted
+	Changes   json.RawMessage `json:"changes" swaggertype:"object"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// NewAuditEntryResponse maps an audit log entry to its API representation
+func NewAuditEntryResponse(e *models.AuditEntry) AuditEntryResponse {
+	return AuditEntryResponse{
+		ID:        e.ID,
+		Entity:    e.Entity,
+		EntityID:  e.EntityID,
+		Operation: e.Operation,
+		ActorType: e.ActorType,
+		ActorID:   e.ActorID,
+		Actor:     e.Actor,
+		Changes:   json.RawMessage(e.Changes),
+		CreatedAt: e.CreatedAt,
+	}
+}
@@ -0,0 +1,64 @@
+package dto
+
+import (
+	"school-api/models"
+	"school-api/service"
+	"time"
+)
+
+// LoginRequest is the body accepted when signing in
+type LoginRequest struct {
+	Username string `json:"username" example:"admin"`
+	Password string `json:"password" example:"correct horse battery staple"`
+}
+
+// RefreshRequest is the body accepted when refreshing tokens or logging out
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" example:"m3Vd0c2lH9yN4bW7...Q"`
+}
+
+// TokenResponse is returned by a successful login or refresh. Send the
+// access token as "Authorization: Bearer <access_token>".
+type TokenResponse struct {
+	AccessToken string `json:"access_token" example:"eyJhbGciOiJIUzI1NiIsImtpZCI6IjIwMjQtMDkifQ..."`
+	TokenType   string `json:"token_type" example:"Bearer"`
+	// ExpiresIn is the lifetime of the access token in seconds
+	ExpiresIn    int    `json:"expires_in" example:"900"`
+	RefreshToken string `json:"refresh_token" example:"m3Vd0c2lH9yN4bW7...Q"`
+	// RefreshExpiresIn is the lifetime of the refresh token in seconds
+	RefreshExpiresIn int `json:"refresh_expires_in" example:"2592000"`
+}
+
+// UserResponse is the representation of a user account returned to clients
+type UserResponse struct {
+	ID        uint      `json:"id" example:"1"`
+	Username  string    `json:"username" example:"admin"`
+	Role      string    `json:"role" example:"teacher" enums:"admin,teacher,guardian,student"`
+	StudentID *uint     `json:"student_id,omitempty" example:"12"`
+	Disabled  bool      `json:"disabled" example:"false"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewTokenResponse maps issued tokens to their API representation
+func NewTokenResponse(pair *service.TokenPair) TokenResponse {
+	now := time.Now()
+	return TokenResponse{
+		AccessToken:      pair.AccessToken,
+		TokenType:        "Bearer",
+		ExpiresIn:        int(pair.ExpiresAt.Sub(now).Round(time.Second).Seconds()),
+		RefreshToken:     pair.RefreshToken,
+		RefreshExpiresIn: int(pair.RefreshExpiresAt.Sub(now).Round(time.Second).Seconds()),
+	}
+}
+
+// NewUserResponse maps a stored user to its API representation
+func NewUserResponse(u *models.User) UserResponse {
+	return UserResponse{
+		ID:        u.ID,
+		Username:  u.Username,
+		Role:      u.Role,
+		StudentID: u.StudentID,
+		Disabled:  u.Disabled,
+		CreatedAt: u.CreatedAt,
+	}
+}
@@ -0,0 +1,93 @@
+package dto
+
+import (
+	"school-api/models"
+	"time"
+)
+
+// CreateClassRequest is the body accepted when creating a class. Students
+// listed with it are enrolled in the new class in the same transaction.
+type CreateClassRequest struct {
+	ClassName string                `json:"class_name" example:"Grade 5" maxLength:"100"`
+	TeacherID *uint                 `json:"teacher_id,omitempty" example:"7"`
+	Students  []ClassStudentRequest `json:"students,omitempty"`
+}
+
+// ClassStudentRequest is a student created together with its class
+type ClassStudentRequest struct {
+	StudentName string `json:"student_name" example:"Jane Doe" maxLength:"100"`
+	Section     string `json:"student_section" example:"A" enums:"A,B,C,D,E,F"`
+}
+
+// UpdateClassRequest is the body accepted when replacing a class
+type UpdateClassRequest struct {
+	ClassName string `json:"class_name" example:"Grade 5" maxLength:"100"`
+	// TeacherID is the ID of a user with the teacher role; omit it to
+	// leave the class without a teacher
+	TeacherID *uint `json:"teacher_id" example:"7"`
+}
+
+// ClassResponse is the representation of a class returned to clients
+type ClassResponse struct {
+	ID           uint   `json:"id" example:"1"`
+	ClassName    string `json:"class_name" example:"Grade 5"`
+	StudentCount int    `json:"student_count" example:"24"`
+	TeacherID    *uint  `json:"teacher_id" example:"7"`
+	Version      uint   `json:"version" example:"3"`
+	// SourcedID is only set on classes imported from an external roster system
+	SourcedID string    `json:"sourced_id,omitempty" example:"cls-1001"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// DeletedAt is only set on deleted classes, which are listed with include_deleted
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// Students is only filled in when the class was created with students
+	Students []StudentResponse `json:"students,omitempty"`
+}
+
+// ToModel builds a new class from the request
+func (r CreateClassRequest) ToModel() *models.Class {
+	class := &models.Class{ClassName: r.ClassName, TeacherID: r.TeacherID}
+	for _, s := range r.Students {
+		class.Students = append(class.Students, models.Student{
+			StudentName: s.StudentName,
+			Section:     s.Section,
+		})
+	}
+	return class
+}
+
+// ToModel builds the replacement for the class with the given ID
+func (r UpdateClassRequest) ToModel(id uint) *models.Class {
+	return &models.Class{ID: id, ClassName: r.ClassName, TeacherID: r.TeacherID}
+}
+
+// NewUpdateClassRequest returns the update request that would leave c
+// unchanged. PATCH documents are applied to it.
+func NewUpdateClassRequest(c *models.Class) UpdateClassRequest {
+	return UpdateClassRequest{ClassName: c.ClassName, TeacherID: c.TeacherID}
+}
+
+// NewClassResponse maps a stored class to its API representation
+func NewClassResponse(c *models.Class) ClassResponse {
+	resp := ClassResponse{
+		ID:           c.ID,
+		ClassName:    c.ClassName,
+		StudentCount: c.StudentCount,
+		TeacherID:    c.TeacherID,
+		Version:      c.Version,
+		SourcedID:    c.SourcedID,
+		UpdatedAt:    c.UpdatedAt,
+		DeletedAt:    deletedAt(c.DeletedAt),
+	}
+	for i := range c.Students {
+		resp.Students = append(resp.Students, NewStudentResponse(&c.Students[i]))
+	}
+	return resp
+}
+
+// ClassColumns are the column headers of exported classes
+var ClassColumns = []string{"id", "class_name", "student_count", "version", "deleted_at"}
+
+// Record returns the class as a row of ClassColumns
+func (r ClassResponse) Record() []any {
+	return []any{r.ID, r.ClassName, r.StudentCount, r.Version, r.DeletedAt}
+}
@@ -0,0 +1,16 @@
+package dto
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// deletedAt returns the deletion time of a soft-deleted record, or nil
+func deletedAt(d gorm.DeletedAt) *time.Time {
+	if !d.Valid {
+		return nil
+	}
+	t := d.Time
+	return &t
+}
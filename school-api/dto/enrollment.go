@@ -0,0 +1,61 @@
+package dto
+
+import (
+	"school-api/models"
+	"time"
+)
+
+// EnrollmentResponse is a period during which a student was in a class.
+// Names are those of the class and student now, empty once they are purged.
+type EnrollmentResponse struct {
+	StudentID   uint      `json:"student_id" example:"12"`
+	StudentName string    `json:"student_name" example:"Ann Lee"`
+	ClassID     uint      `json:"class_id" example:"5"`
+	ClassName   string    `json:"class_name" example:"Grade 5"`
+	ValidFrom   time.Time `json:"valid_from"`
+	// ValidTo is when the student left the class, omitted while they are still in it
+	ValidTo *time.Time `json:"valid_to,omitempty"`
+}
+
+// ClassHistoryResponse lists every class a student has been in, oldest first
+type ClassHistoryResponse struct {
+	StudentID   uint                 `json:"student_id" example:"12"`
+	Enrollments []EnrollmentResponse `json:"enrollments"`
+}
+
+// RosterResponse lists the students in a class at a point in time
+type RosterResponse struct {
+	ClassID  uint                 `json:"class_id" example:"5"`
+	AsOf     time.Time            `json:"as_of"`
+	Students []EnrollmentResponse `json:"students"`
+}
+
+// NewEnrollmentResponse maps an enrollment to its API representation
+func NewEnrollmentResponse(e *models.Enrollment) EnrollmentResponse {
+	return EnrollmentResponse{
+		StudentID:   e.StudentID,
+		StudentName: e.StudentName,
+		ClassID:     e.ClassID,
+		ClassName:   e.ClassName,
+		ValidFrom:   e.ValidFrom,
+		ValidTo:     e.ValidTo,
+	}
+}
+
+// NewClassHistoryResponse builds the class history of a student
+func NewClassHistoryResponse(studentID uint, enrollments []models.Enrollment) ClassHistoryResponse {
+	return ClassHistoryResponse{StudentID: studentID, Enrollments: newEnrollmentResponses(enrollments)}
+}
+
+// NewRosterResponse builds the roster of a class at a point in time
+func NewRosterResponse(classID uint, asOf time.Time, enrollments []models.Enrollment) RosterResponse {
+	return RosterResponse{ClassID: classID, AsOf: asOf, Students: newEnrollmentResponses(enrollments)}
+}
+
+func newEnrollmentResponses(enrollments []models.Enrollment) []EnrollmentResponse {
+	resp := make([]EnrollmentResponse, len(enrollments))
+	for i := range enrollments {
+		resp[i] = NewEnrollmentResponse(&enrollments[i])
+	}
+	return resp
+}
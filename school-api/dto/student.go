@@ -0,0 +1,120 @@
+package dto
+
+import (
+	"school-api/models"
+	"school-api/service"
+	"time"
+)
+
+// CreateStudentRequest is the body accepted when creating a student
+type CreateStudentRequest struct {
+	StudentName string `json:"student_name" example:"Jane Doe" maxLength:"100"`
+	ClassID     uint   `json:"class_id" example:"1"`
+	Section     string `json:"student_section" example:"A" enums:"A,B,C,D,E,F"`
+}
+
+// UpdateStudentRequest is the body accepted when replacing a student
+type UpdateStudentRequest struct {
+	StudentName string `json:"student_name" example:"Jane Doe" maxLength:"100"`
+	ClassID     uint   `json:"class_id" example:"1"`
+	Section     string `json:"student_section" example:"A" enums:"A,B,C,D,E,F"`
+}
+
+// BulkUpdateStudentRequest is one item of a bulk update. Version is
+// optional unless the server requires If-Match; when set the update fails
+// if the student has changed since.
+type BulkUpdateStudentRequest struct {
+	ID          uint   `json:"id" example:"1"`
+	StudentName string `json:"student_name" example:"Jane Doe" maxLength:"100"`
+	ClassID     uint   `json:"class_id" example:"1"`
+	Section     string `json:"student_section" example:"A" enums:"A,B,C,D,E,F"`
+	Version     uint   `json:"version,omitempty" example:"3"`
+}
+
+// BulkDeleteStudentRequest is one item of a bulk delete. Version follows
+// the same rules as in a bulk update.
+type BulkDeleteStudentRequest struct {
+	ID      uint `json:"id" example:"1"`
+	Version uint `json:"version,omitempty" example:"3"`
+}
+
+// StudentResponse is the representation of a student returned to clients
+type StudentResponse struct {
+	ID          uint   `json:"id" example:"1"`
+	StudentName string `json:"student_name" example:"Jane Doe"`
+	ClassID     uint   `json:"class_id" example:"1"`
+	Section     string `json:"student_section" example:"A"`
+	Version     uint   `json:"version" example:"3"`
+	// SourcedID is only set on students imported from an external roster system
+	SourcedID string    `json:"sourced_id,omitempty" example:"usr-2001"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// DeletedAt is only set on deleted students, which are listed with include_deleted
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// ToModel builds a new student from the request
+func (r CreateStudentRequest) ToModel() *models.Student {
+	return &models.Student{
+		StudentName: r.StudentName,
+		ClassId:     r.ClassID,
+		Section:     r.Section,
+	}
+}
+
+// ToModel builds the replacement for the student with the given ID
+func (r UpdateStudentRequest) ToModel(id uint) *models.Student {
+	return &models.Student{
+		ID:          id,
+		StudentName: r.StudentName,
+		ClassId:     r.ClassID,
+		Section:     r.Section,
+	}
+}
+
+// ToModel builds the replacement for the student named by the item
+func (r BulkUpdateStudentRequest) ToModel() *models.Student {
+	return &models.Student{
+		ID:          r.ID,
+		StudentName: r.StudentName,
+		ClassId:     r.ClassID,
+		Section:     r.Section,
+		Version:     r.Version,
+	}
+}
+
+// ToItem names the student to delete and the version the deletion is based on
+func (r BulkDeleteStudentRequest) ToItem() service.BulkDeleteItem {
+	return service.BulkDeleteItem{ID: r.ID, Version: r.Version}
+}
+
+// NewUpdateStudentRequest returns the update request that would leave s
+// unchanged. PATCH documents are applied to it.
+func NewUpdateStudentRequest(s *models.Student) UpdateStudentRequest {
+	return UpdateStudentRequest{
+		StudentName: s.StudentName,
+		ClassID:     s.ClassId,
+		Section:     s.Section,
+	}
+}
+
+// NewStudentResponse maps a stored student to its API representation
+func NewStudentResponse(s *models.Student) StudentResponse {
+	return StudentResponse{
+		ID:          s.ID,
+		StudentName: s.StudentName,
+		ClassID:     s.ClassId,
+		Section:     s.Section,
+		Version:     s.Version,
+		SourcedID:   s.SourcedID,
+		UpdatedAt:   s.UpdatedAt,
+		DeletedAt:   deletedAt(s.DeletedAt),
+	}
+}
+
+// StudentColumns are the column headers of exported students
+var StudentColumns = []string{"id", "student_name", "class_id", "student_section", "version", "deleted_at"}
+
+// Record returns the student as a row of StudentColumns
+func (r StudentResponse) Record() []any {
+	return []any{r.ID, r.StudentName, r.ClassID, r.Section, r.Version, r.DeletedAt}
+}
@@ -0,0 +1,56 @@
+package dto
+
+import (
+	"school-api/models"
+	"school-api/service"
+	"time"
+)
+
+// TenantResponse is the representation of a tenant returned to clients
+type TenantResponse struct {
+	ID        uint           `json:"id" example:"2"`
+	Slug      string         `json:"slug" example:"northside"`
+	Name      string         `json:"name" example:"Northside Primary"`
+	Settings  TenantSettings `json:"settings"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// TenantSettings are the settings a tenant overrides; empty values fall
+// back to the server configuration
+type TenantSettings struct {
+	// DeletePolicy is the default policy for deleting this tenant's classes
+	DeletePolicy string `json:"delete_policy,omitempty" example:"reassign" enums:"restrict,cascade,reassign"`
+	// ReassignTo is the default class students are moved to by the reassign policy
+	ReassignTo            uint   `json:"reassign_to,omitempty" example:"3"`
+	OneRosterOrgSourcedID string `json:"oneroster_org_sourced_id,omitempty" example:"northside" maxLength:"255"`
+	OneRosterOrgName      string `json:"oneroster_org_name,omitempty" example:"Northside Primary" maxLength:"255"`
+}
+
+// UpdateTenantSettingsRequest replaces the settings of the current tenant
+type UpdateTenantSettingsRequest = TenantSettings
+
+// ToInput converts the settings to the service input
+func (s TenantSettings) ToInput() service.TenantSettings {
+	return service.TenantSettings{
+		DeletePolicy:          s.DeletePolicy,
+		ReassignTo:            s.ReassignTo,
+		OneRosterOrgSourcedID: s.OneRosterOrgSourcedID,
+		OneRosterOrgName:      s.OneRosterOrgName,
+	}
+}
+
+// NewTenantResponse maps a tenant to its API representation
+func NewTenantResponse(t *models.Tenant) TenantResponse {
+	return TenantResponse{
+		ID:   t.ID,
+		Slug: t.Slug,
+		Name: t.Name,
+		Settings: TenantSettings{
+			DeletePolicy:          t.DeletePolicy,
+			ReassignTo:            t.ReassignTo,
+			OneRosterOrgSourcedID: t.OneRosterOrgSourcedID,
+			OneRosterOrgName:      t.OneRosterOrgName,
+		},
+		CreatedAt: t.CreatedAt,
+	}
+}
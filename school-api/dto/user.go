@@ -0,0 +1,50 @@
+package dto
+
+import "school-api/service"
+
+// CreateUserRequest is the body accepted when an administrator creates a user
+type CreateUserRequest struct {
+	Username string `json:"username" example:"mrs.smith" maxLength:"100"`
+	Password string `json:"password" example:"correct horse battery" minLength:"8" maxLength:"72"`
+	Role     string `json:"role" example:"teacher" enums:"admin,teacher,guardian,student"`
+	// StudentID links a new student user to their student record
+	StudentID *uint `json:"student_id,omitempty" example:"12"`
+}
+
+// UpdateUserRequest is the body accepted when changing a user's access
+type UpdateUserRequest struct {
+	Role string `json:"role" example:"teacher" enums:"admin,teacher,guardian,student"`
+	// StudentID must be sent again when the role stays student and left
+	// out when it changes to any other
+	StudentID *uint `json:"student_id,omitempty" example:"12"`
+	Disabled  bool  `json:"disabled" example:"false"`
+}
+
+// GuardianStudentsRequest lists the students a guardian may see
+type GuardianStudentsRequest struct {
+	StudentIDs []uint `json:"student_ids" example:"12,13"`
+}
+
+// GuardianStudentsResponse lists the students linked to a guardian
+type GuardianStudentsResponse struct {
+	UserID     uint   `json:"user_id" example:"5"`
+	StudentIDs []uint `json:"student_ids" example:"12,13"`
+}
+
+// ToInput returns the account CreateUser is to add
+func (r CreateUserRequest) ToInput() service.NewUser {
+	return service.NewUser{Username: r.Username, Password: r.Password, Role: r.Role, StudentID: r.StudentID}
+}
+
+// ToInput returns the role and status UpdateUser is to give the user
+func (r UpdateUserRequest) ToInput() service.UserUpdate {
+	return service.UserUpdate{Role: r.Role, StudentID: r.StudentID, Disabled: r.Disabled}
+}
+
+// NewGuardianStudentsResponse builds the response for a guardian's students
+func NewGuardianStudentsResponse(userID uint, studentIDs []uint) GuardianStudentsResponse {
+	if studentIDs == nil {
+		studentIDs = []uint{}
+	}
+	return GuardianStudentsResponse{UserID: userID, StudentIDs: studentIDs}
+}
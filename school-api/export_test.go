@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"school-api/auth"
+	"school-api/dto"
+	"school-api/handler"
+	"school-api/service"
+	"school-api/spreadsheet"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestExportImportRoundTrip checks that names which look like formulas come
+// back unchanged when an Excel export is imported again
+func TestExportImportRoundTrip(t *testing.T) {
+	a := newTestApp(t)
+	token := a.login("default", "admin", auth.RoleAdmin)
+	class := decode[dto.ClassResponse](t, expect(t, a.do(t, token, http.MethodPost, "/api/classes",
+		dto.CreateClassRequest{ClassName: "Grade 5"}), http.StatusCreated))
+	names := []string{"-Ann", "@home", "=Bob", "+Cy", "Dee"}
+	for _, name := range names {
+		expect(t, a.do(t, token, http.MethodPost, "/api/students",
+			dto.CreateStudentRequest{StudentName: name, ClassID: class.ID, Section: "A"}), http.StatusCreated)
+	}
+
+	t.Run("csv escapes formulas", func(t *testing.T) {
+		rec := expect(t, a.do(t, token, http.MethodGet, "/api/students/export?format=csv", nil), http.StatusOK)
+		for _, name := range []string{"'-Ann", "'@home", "'=Bob", "'+Cy"} {
+			if !strings.Contains(rec.Body.String(), ","+name+",") {
+				t.Errorf("CSV export does not escape %q:\n%s", name[1:], rec.Body)
+			}
+		}
+	})
+
+	rec := expect(t, a.do(t, token, http.MethodGet, "/api/students/export?format=xlsx&sort=id", nil), http.StatusOK)
+	sheet, err := spreadsheet.ReadSheet(bytes.NewReader(rec.Body.Bytes()), spreadsheet.XLSX)
+	if err != nil {
+		t.Fatal(err)
+	}
+	column := slices.Index(sheet.Rows[0], "student_name")
+	var exported []string
+	for _, row := range sheet.Rows[1:] {
+		exported = append(exported, row[column])
+	}
+	if !slices.Equal(exported, names) {
+		t.Fatalf("Excel export has names %q, want %q", exported, names)
+	}
+
+	// The export names classes by ID; an import needs their names
+	var roster bytes.Buffer
+	w, err := spreadsheet.NewWriter(&roster, spreadsheet.XLSX)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, row := range sheet.Rows {
+		record := []any{class.ClassName}
+		if i == 0 {
+			record[0] = "class_name"
+		}
+		for _, cell := range row {
+			record = append(record, cell)
+		}
+		if err := w.Write(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	report := decode[handler.ImportResponse](t, expect(t, a.do(t, token, http.MethodPost, "/api/import", roster.String(),
+		"Content-Type", spreadsheet.XLSXMediaType), http.StatusOK))
+	if report.Summary.Unchanged != len(names) {
+		t.Errorf("re-importing the export reports %+v, want %d students unchanged", report.Summary, len(names))
+	}
+	for _, item := range report.Items {
+		if item.Action != string(service.ImportUnchanged) {
+			t.Errorf("re-importing the export would %s %s %q: %+v", item.Action, item.Entity, item.Name, item.Changes)
+		}
+	}
+	students := decode[handler.ListResponse[dto.StudentResponse]](t, expect(t,
+		a.do(t, token, http.MethodGet, "/api/students?sort=id", nil), http.StatusOK))
+	for i, s := range students.Data {
+		if s.StudentName != names[i] {
+			t.Errorf("student %d is now named %q, want %q", s.ID, s.StudentName, names[i])
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"school-api/apperror"
+	"school-api/service"
+	"time"
+)
+
+// PurgeResponse reports what a purge removed
+type PurgeResponse struct {
+	DeletedBefore time.Time `json:"deleted_before"`
+	Classes       int64     `json:"classes" example:"2"`
+	Students      int64     `json:"students" example:"40"`
+	RefreshTokens int64     `json:"refresh_tokens" example:"12"`
+}
+
+type AdminHandler struct {
+	purgeService service.PurgeService
+}
+
+func NewAdminHandler(purgeService service.PurgeService) *AdminHandler {
+	return &AdminHandler{purgeService: purgeService}
+}
+
+// @Summary Purge deleted records
+// @Description Permanently remove classes and students that were soft-deleted longer ago than older_than, or the configured retention period, along with expired refresh tokens. The enrollment history of purged students is kept, so past rosters still list them, by ID only. Purges also run on a schedule.
+// @Tags admin
+// @Produce json
+// @Param older_than query string false "Minimum age of deleted records to purge, as a Go duration (e.g. 720h)"
+// @Success 200 {object} PurgeResponse
+// @Failure 400 {object} apperror.Problem "Invalid older_than"
+// @Failure 401 {object} apperror.Problem "Missing or invalid access token"
+// @Failure 403 {object} apperror.Problem "Role lacks the permission for this route"
+// @Failure 500 {object} apperror.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /api/admin/purge [post]
+func (h *AdminHandler) Purge(w http.ResponseWriter, r *http.Request) {
+	var olderThan time.Duration
+	if v := r.URL.Query().Get("older_than"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			writeError(w, r, apperror.BadRequest("Invalid older_than: must be a positive duration such as 720h"))
+			return
+		}
+		olderThan = d
+	}
+
+	result, err := h.purgeService.Purge(r.Context(), olderThan)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(PurgeResponse{
+		DeletedBefore: result.DeletedBefore,
+		Classes:       result.Classes,
+		Students:      result.Students,
+		RefreshTokens: result.RefreshTokens,
+	})
+}
@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"school-api/apperror"
+	"school-api/dto"
+	"school-api/service"
+	"time"
+)
+
+type APIKeyHandler struct {
+	service service.APIKeyService
+}
+
+func NewAPIKeyHandler(service service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{service: service}
+}
+
+// @Summary Create an API key
+// @Description Create an API key for a non-interactive client. The key is returned once and cannot be retrieved again. Requests made with it may use the class and student routes its scopes allow and see every class and student.
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Param key body dto.CreateAPIKeyRequest true "API key to create"
+// @Success 201 {object} dto.APIKeySecretResponse
+// @Failure 400 {object} apperror.Problem "Invalid request body"
+// @Failure 401 {object} apperror.Problem "Missing or invalid access token"
+// @Failure 403 {object} apperror.Problem "Role lacks the permission for this route"
+// @Failure 422 {object} apperror.Problem "Validation failed"
+// @Failure 500 {object} apperror.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /api/api-keys [post]
+func (h *APIKeyHandler) CreateKey(w http.ResponseWriter, r *http.Request) {
+	var req dto.CreateAPIKeyRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	key, secret, err := h.service.CreateKey(r.Context(), req.ToInput())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(dto.NewAPIKeySecretResponse(key, secret))
+}
+
+// @Summary List API keys
+// @Description List every API key, including revoked and expired ones, newest first, with its usage.
+// @Tags api-keys
+// @Produce json
+// @Success 200 {array} dto.APIKeyResponse
+// @Failure 401 {object} apperror.Problem "Missing or invalid access token"
+// @Failure 403 {object} apperror.Problem "Role lacks the permission for this route"
+// @Failure 500 {object} apperror.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /api/api-keys [get]
+func (h *APIKeyHandler) ListKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.service.ListKeys(r.Context())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	resp := make([]dto.APIKeyResponse, len(keys))
+	for i := range keys {
+		resp[i] = dto.NewAPIKeyResponse(&keys[i])
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// @Summary Get an API key by ID
+// @Tags api-keys
+// @Produce json
+// @Param id path int true "API key ID"
+// @Success 200 {object} dto.APIKeyResponse
+// @Failure 400 {object} apperror.Problem "Invalid ID"
+// @Failure 401 {object} apperror.Problem "Missing or invalid access token"
+// @Failure 403 {object} apperror.Problem "Role lacks the permission for this route"
+// @Failure 404 {object} apperror.Problem "API key not found"
+// @Security BearerAuth
+// @Router /api/api-keys/{id} [get]
+func (h *APIKeyHandler) GetKey(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	key, err := h.service.GetKey(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.NewAPIKeyResponse(key))
+}
+
+// @Summary Rotate an API key
+// @Description Give an API key a new secret and return the new key once. The key keeps its ID, prefix, scopes and usage. The replaced key stops working at once, or after grace_period so clients can switch over.
+// @Tags api-keys
+// @Produce json
+// @Param id path int true "API key ID"
+// @Param grace_period query string false "How long the replaced key keeps working, as a Go duration (e.g. 24h)"
+// @Success 200 {object} dto.APIKeySecretResponse
+// @Failure 400 {object} apperror.Problem "Invalid ID or grace_period"
+// @Failure 401 {object} apperror.Problem "Missing or invalid access token"
+// @Failure 403 {object} apperror.Problem "Role lacks the permission for this route"
+// @Failure 404 {object} apperror.Problem "API key not found"
+// @Failure 409 {object} apperror.Problem "API key has been revoked"
+// @Failure 500 {object} apperror.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /api/api-keys/{id}/rotate [post]
+func (h *APIKeyHandler) RotateKey(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	var grace time.Duration
+	if v := r.URL.Query().Get("grace_period"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			writeError(w, r, apperror.BadRequest("Invalid grace_period: must be a duration such as 24h"))
+			return
+		}
+		grace = d
+	}
+
+	key, secret, err := h.service.RotateKey(r.Context(), id, grace)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(dto.NewAPIKeySecretResponse(key, secret))
+}
+
+// @Summary Revoke an API key
+// @Description Stop an API key from working at once, including a replaced key still in its grace period. Revoked keys stay listed.
+// @Tags api-keys
+// @Param id path int true "API key ID"
+// @Success 204 "API key revoked"
+// @Failure 400 {object} apperror.Problem "Invalid ID"
+// @Failure 401 {object} apperror.Problem "Missing or invalid access token"
+// @Failure 403 {object} apperror.Problem "Role lacks the permission for this route"
+// @Failure 404 {object} apperror.Problem "API key not found"
+// @Failure 500 {object} apperror.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /api/api-keys/{id} [delete]
+func (h *APIKeyHandler) RevokeKey(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.service.RevokeKey(r.Context(), id); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
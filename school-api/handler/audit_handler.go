@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"net/http"
+	"school-api/dto"
+	"school-api/service"
+)
+
+type AuditHandler struct {
+	service service.AuditService
+}
+
+func NewAuditHandler(service service.AuditService) *AuditHandler {
+	return &AuditHandler{service: service}
+}
+
+// @Summary List the audit log
+// @Description Get a page of the audit log of the current tenant, newest first. Every create, update, delete, restore and purge of a class or student is recorded with who made it, when, and the fields it changed. Filter with field=value or field[op]=value (eq, ne, gt, gte, lt, lte, like) on entity, entity_id, operation, actor_type, actor_id, actor and created_at, e.g. entity=student&actor=mrs.smith&created_at[gte]=2024-09-01&created_at[lt]=2024-10-01.
+// @Tags audit
+// @Produce json
+// @Param entity query string false "Kind of record changed" Enums(class, student)
+// @Param entity_id query int false "ID of the record changed"
+// @Param actor query string false "Username or API key name of whoever made the change"
+// @Param created_at[gte] query string false "Only changes at or after this date or RFC 3339 time"
+// @Param created_at[lt] query string false "Only changes before this date or RFC 3339 time"
+// @Param limit query int false "Page size (default 50, max 500)"
+// @Param offset query int false "Number of entries to skip"
+// @Param cursor query string false "Cursor from a previous page's next_cursor"
+// @Param sort query string false "Comma-separated fields, prefix with - for descending (default -id)"
+// @Success 200 {object} ListResponse[dto.AuditEntryResponse]
+// @Failure 400 {object} apperror.Problem "Invalid query"
+// @Failure 401 {object} apperror.Problem "Missing or invalid access token"
+// @Failure 403 {object} apperror.Problem "Role may not read the audit log"
+// @Failure 500 {object} apperror.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /api/audit [get]
+func (h *AuditHandler) ListEntries(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseQueryOptions(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	page, err := h.service.ListEntries(r.Context(), opts)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeList(w, r, page, dto.NewAuditEntryResponse)
+}
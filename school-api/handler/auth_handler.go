@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"school-api/auth"
+	"school-api/dto"
+	"school-api/service"
+)
+
+type AuthHandler struct {
+	service service.AuthService
+	keys    *auth.KeySet
+}
+
+func NewAuthHandler(service service.AuthService, keys *auth.KeySet) *AuthHandler {
+	return &AuthHandler{service: service, keys: keys}
+}
+
+// @Summary Sign in
+// @Description Exchange a username and password for an access token and a refresh token. The access token is sent on other requests as "Authorization: Bearer <token>".
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param credentials body dto.LoginRequest true "Username and password"
+// @Success 200 {object} dto.TokenResponse
+// @Failure 400 {object} apperror.Problem "Invalid request body"
+// @Failure 401 {object} apperror.Problem "Invalid username or password"
+// @Failure 403 {object} apperror.Problem "User is disabled"
+// @Failure 500 {object} apperror.Problem "Internal server error"
+// @Router /api/auth/login [post]
+func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
+	var req dto.LoginRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	pair, err := h.service.Login(r.Context(), req.Username, req.Password)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeTokens(w, pair)
+}
+
+// @Summary Refresh tokens
+// @Description Exchange a refresh token for a new access token and refresh token. A refresh token works once; reusing one revokes every refresh token of its user.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param token body dto.RefreshRequest true "Refresh token"
+// @Success 200 {object} dto.TokenResponse
+// @Failure 400 {object} apperror.Problem "Invalid request body"
+// @Failure 401 {object} apperror.Problem "Refresh token is invalid, expired or already used"
+// @Failure 403 {object} apperror.Problem "User is disabled"
+// @Failure 500 {object} apperror.Problem "Internal server error"
+// @Router /api/auth/refresh [post]
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req dto.RefreshRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	pair, err := h.service.Refresh(r.Context(), req.RefreshToken)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeTokens(w, pair)
+}
+
+// @Summary Sign out
+// @Description Revoke a refresh token. Access tokens already issued stay valid until they expire.
+// @Tags auth
+// @Accept json
+// @Param token body dto.RefreshRequest true "Refresh token"
+// @Success 204 "Refresh token revoked"
+// @Failure 400 {object} apperror.Problem "Invalid request body"
+// @Failure 500 {object} apperror.Problem "Internal server error"
+// @Router /api/auth/logout [post]
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	var req dto.RefreshRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if err := h.service.Logout(r.Context(), req.RefreshToken); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Get the current user
+// @Description Get the account the access token was issued to.
+// @Tags auth
+// @Produce json
+// @Success 200 {object} dto.UserResponse
+// @Failure 401 {object} apperror.Problem "Missing or invalid access token"
+// @Failure 403 {object} apperror.Problem "Request was made with an API key"
+// @Failure 500 {object} apperror.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /api/auth/me [get]
+func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
+	principal, ok := auth.PrincipalFrom(r.Context())
+	if !ok {
+		writeError(w, r, errUnauthenticated)
+		return
+	}
+	if principal.IsAPIKey() {
+		writeError(w, r, errNotAUser)
+		return
+	}
+	user, err := h.service.GetUser(r.Context(), principal.UserID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.NewUserResponse(user))
+}
+
+// @Summary Get the token verification keys
+// @Description Get the public keys of the RS256 keys tokens are signed with, as a JSON Web Key Set. HS256 keys are never published.
+// @Tags auth
+// @Produce json
+// @Success 200 {object} auth.JWKS
+// @Router /.well-known/jwks.json [get]
+func (h *AuthHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "max-age=300")
+	json.NewEncoder(w).Encode(h.keys.JWKS())
+}
+
+// writeTokens writes issued tokens; they must never be cached
+func writeTokens(w http.ResponseWriter, pair *service.TokenPair) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(dto.NewTokenResponse(pair))
+}
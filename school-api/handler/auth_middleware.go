@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"school-api/apperror"
+	"school-api/auth"
+	"school-api/service"
+	"strings"
+)
+
+var (
+	errUnauthenticated = apperror.New(http.StatusUnauthorized, apperror.CodeUnauthorized,
+		"Authentication required: send an access token as Authorization: Bearer <token>")
+	errInvalidToken = apperror.New(http.StatusUnauthorized, apperror.CodeUnauthorized,
+		"The access token is invalid or has expired")
+	errNotAUser = apperror.New(http.StatusForbidden, apperror.CodeForbidden,
+		"API keys are not user accounts")
+	errForbidden = apperror.New(http.StatusForbidden, apperror.CodeForbidden,
+		"Your role or API key scopes do not permit this request")
+)
+
+// invalidTokenChallenge is the WWW-Authenticate header sent when the access
+// token or API key of a request is not accepted
+const invalidTokenChallenge = `Bearer realm="school-api", error="invalid_token"`
+
+// Authenticator rejects requests that do not carry a valid access token or
+// API key and records the principal of those that do in the request context
+type Authenticator struct {
+	issuer  *auth.Issuer
+	apiKeys service.APIKeyService
+}
+
+func NewAuthenticator(issuer *auth.Issuer, apiKeys service.APIKeyService) *Authenticator {
+	return &Authenticator{issuer: issuer, apiKeys: apiKeys}
+}
+
+// Middleware protects API routes, reporting failures as problem documents
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return a.protect(next, writeError)
+}
+
+// OneRosterMiddleware protects OneRoster routes, reporting failures as
+// imsx_StatusInfo documents
+func (a *Authenticator) OneRosterMiddleware(next http.Handler) http.Handler {
+	return a.protect(next, writeOneRosterError)
+}
+
+func (a *Authenticator) protect(next http.Handler, fail func(http.ResponseWriter, *http.Request, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+		token = strings.TrimSpace(token)
+		key := r.Header.Get("X-API-Key")
+		if key == "" && strings.EqualFold(scheme, "Bearer") && auth.IsAPIKey(token) {
+			key = token
+		}
+		if key != "" {
+			principal, err := a.apiKeys.Authenticate(r.Context(), key)
+			if errors.Is(err, service.ErrInvalidAPIKey) {
+				// API keys can also be sent as bearer tokens, so the
+				// challenge is the one for an invalid token
+				w.Header().Set("WWW-Authenticate", invalidTokenChallenge)
+			}
+			if err != nil {
+				fail(w, r, err)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+			return
+		}
+
+		if !strings.EqualFold(scheme, "Bearer") || token == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="school-api"`)
+			fail(w, r, errUnauthenticated)
+			return
+		}
+
+		principal, err := a.issuer.Verify(token)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", invalidTokenChallenge)
+			fail(w, r, errInvalidToken)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+	})
+}
+
+// Require wraps an API handler so only principals whose role has perm
+// reach it; others get 403. Requests without a principal only get this far
+// when authentication is off, and are let through.
+func (a *Authenticator) Require(perm auth.Permission, h http.HandlerFunc) http.HandlerFunc {
+	return authorize(perm, h, writeError)
+}
+
+// RequireOneRoster is Require for OneRoster routes
+func (a *Authenticator) RequireOneRoster(perm auth.Permission, h http.HandlerFunc) http.HandlerFunc {
+	return authorize(perm, h, writeOneRosterError)
+}
+
+func authorize(perm auth.Permission, h http.HandlerFunc, fail func(http.ResponseWriter, *http.Request, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if principal, ok := auth.PrincipalFrom(r.Context()); ok && !principal.Can(perm) {
+			fail(w, r, errForbidden)
+			return
+		}
+		h(w, r)
+	}
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"school-api/apperror"
+	"school-api/service"
+)
+
+// maxBulkItems caps the number of items accepted by one bulk request
+const maxBulkItems = 1000
+
+// BulkResponse reports the outcome of every item of a bulk request
+type BulkResponse[T any] struct {
+	Succeeded int                 `json:"succeeded"`
+	Failed    int                 `json:"failed"`
+	Results   []BulkItemResult[T] `json:"results"`
+}
+
+// BulkItemResult is the outcome of one item, identified by its position in
+// the request. Status is the HTTP status the item would have had on its own.
+type BulkItemResult[T any] struct {
+	Index  int               `json:"index"`
+	Status int               `json:"status"`
+	ID     uint              `json:"id,omitempty"`
+	Data   *T                `json:"data,omitempty"`
+	Error  *apperror.Problem `json:"error,omitempty"`
+}
+
+// parseBulkMode reads the mode query parameter, defaulting to atomic
+func parseBulkMode(r *http.Request) (service.BulkMode, error) {
+	v := r.URL.Query().Get("mode")
+	if v == "" {
+		return service.BulkAtomic, nil
+	}
+	return service.ParseBulkMode(v)
+}
+
+// checkBulkSize rejects empty and oversized bulk requests
+func checkBulkSize(n int) error {
+	if n == 0 {
+		return apperror.BadRequest("Bulk request must contain at least one item")
+	}
+	if n > maxBulkItems {
+		return apperror.New(http.StatusRequestEntityTooLarge, apperror.CodeTooLarge,
+			fmt.Sprintf("Bulk request may contain at most %d items", maxBulkItems))
+	}
+	return nil
+}
+
+// checkBulkVersions rejects a bulk request with 428 Precondition Required
+// if required is set and any item lacks the version it is based on, so that
+// bulk writes cannot bypass a server that requires If-Match
+func checkBulkVersions(required bool, versions []uint) error {
+	if !required {
+		return nil
+	}
+	for i, version := range versions {
+		if version == 0 {
+			return apperror.New(http.StatusPreconditionRequired, apperror.CodePreconditionRequired,
+				fmt.Sprintf("item %d: Each item must carry the version it is based on", i))
+		}
+	}
+	return nil
+}
+
+// writeBulk writes the per-item results of a bulk request. Atomic batches
+// only get here when every item succeeded and use successStatus; partial
+// batches use 207 Multi-Status.
+func writeBulk[R any](w http.ResponseWriter, r *http.Request, mode service.BulkMode, successStatus int, results []service.BulkResult, toResponse func(service.BulkResult) *R) {
+	resp := BulkResponse[R]{Results: make([]BulkItemResult[R], len(results))}
+	for i, result := range results {
+		item := BulkItemResult[R]{Index: result.Index, ID: result.ID}
+		if result.Err != nil {
+			appErr := toAppError(r, result.Err)
+			problem := apperror.NewProblem(appErr, r)
+			item.Status, item.Error = appErr.Status, &problem
+			resp.Failed++
+		} else {
+			item.Status, item.Data = successStatus, toResponse(result)
+			resp.Succeeded++
+		}
+		resp.Results[i] = item
+	}
+
+	status := successStatus
+	if mode == service.BulkPartial {
+		status = http.StatusMultiStatus
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
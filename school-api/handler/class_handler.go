@@ -4,146 +4,445 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"time"
+	"school-api/apperror"
+	"school-api/dto"
 	"school-api/models"
 	"school-api/service"
 	"github.com/gorilla/mux"
 )
 
 type ClassHandler struct {
-	service service.ClassService
+	service       service.ClassService
+	preconditions Preconditions
 }
 
-func NewClassHandler(service service.ClassService) *ClassHandler {
-	return &ClassHandler{service: service}
+func NewClassHandler(service service.ClassService, preconditions Preconditions) *ClassHandler {
+	return &ClassHandler{service: service, preconditions: preconditions}
 }
 
 // @Summary Create a new class
-// @Description Create a new class with the provided details
+// @Description Create a new class with the provided details. student_count is maintained by the server and may not be sent. Students listed in the body are created with the class; if any of them is invalid nothing is stored.
 // @Tags classes
 // @Accept json
 // @Produce json
-// @Param class body models.Class true "Class object to create"
-// @Success 201 {object} models.Class
-// @Failure 400 {string} string "Invalid request body"
-// @Failure 500 {string} string "Internal server error"
-// @Router /classes [post]
+// @Param class body dto.CreateClassRequest true "Class to create"
+// @Success 201 {object} dto.ClassResponse
+// @Failure 400 {object} apperror.Problem "Invalid request body"
+// @Failure 401 {object} apperror.Problem "Missing or invalid access token or API key"
+// @Failure 403 {object} apperror.Problem "Role or API key scopes lack the permission for this route"
+// @Failure 422 {object} apperror.Problem "Validation failed (per-field details in errors)"
+// @Failure 500 {object} apperror.Problem "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/classes [post]
 func (h *ClassHandler) CreateClass(w http.ResponseWriter, r *http.Request) {
-	var class models.Class
-	if err := json.NewDecoder(r.Body).Decode(&class); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	var req dto.CreateClassRequest
+	if err := decodeJSON(r, &req, "id", "student_count"); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	if err := h.service.CreateClass(&class); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	class := req.ToModel()
+	if err := h.service.CreateClass(r.Context(), class); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
+	setETag(w, class.Version)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(class)
+	json.NewEncoder(w).Encode(dto.NewClassResponse(class))
 }
 
 // @Summary Get all classes
-// @Description Get a list of all classes
+// @Description Get a page of classes. Filter with field=value or field[op]=value (eq, ne, gt, gte, lt, lte, like) on id, class_name, student_count and teacher_id. Teachers only see the classes they teach, guardians and students only the classes of their students.
 // @Tags classes
 // @Produce json
-// @Success 200 {array} models.Class
-// @Failure 500 {string} string "Internal server error"
-// @Router /classes [get]
+// @Param limit query int false "Page size (default 50, max 500)"
+// @Param offset query int false "Number of classes to skip"
+// @Param cursor query string false "Cursor from a previous page's next_cursor"
+// @Param sort query string false "Comma-separated fields, prefix with - for descending (e.g. -class_name,id)"
+// @Param include_deleted query bool false "Include soft-deleted classes (administrators only)"
+// @Success 200 {object} ListResponse[dto.ClassResponse]
+// @Failure 400 {object} apperror.Problem "Invalid query"
+// @Failure 401 {object} apperror.Problem "Missing or invalid access token or API key"
+// @Failure 403 {object} apperror.Problem "Role or API key scopes lack the permission for this route"
+// @Failure 500 {object} apperror.Problem "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/classes [get]
 func (h *ClassHandler) GetAllClasses(w http.ResponseWriter, r *http.Request) {
-	classes, err := h.service.GetAllClasses()
+	opts, err := parseQueryOptions(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(classes)
+	page, err := h.service.GetAllClasses(r.Context(), opts)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeList(w, r, page, dto.NewClassResponse)
+}
+
+// @Summary Export classes
+// @Description Download every class matching the filters and sort order of the list endpoint as CSV, Excel (XLSX) or newline-delimited JSON, chosen with format or the Accept header (CSV by default). Filter with field=value or field[op]=value on id, class_name and student_count. Rows are streamed from the database; paging parameters are ignored. In CSV files, text that would be taken for a formula (starting with =, +, -, @, tab or carriage return) is prefixed with an apostrophe; Excel files store it as plain text.
+// @Tags classes
+// @Produce text/csv
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Produce application/x-ndjson
+// @Param format query string false "Export format, overriding the Accept header" Enums(csv, xlsx, ndjson)
+// @Param sort query string false "Comma-separated fields, prefix with - for descending"
+// @Param include_deleted query bool false "Include soft-deleted classes (administrators only)"
+// @Success 200 {file} file
+// @Failure 400 {object} apperror.Problem "Invalid query or format"
+// @Failure 401 {object} apperror.Problem "Missing or invalid access token or API key"
+// @Failure 403 {object} apperror.Problem "Role or API key scopes lack the permission for this route"
+// @Failure 406 {object} apperror.Problem "No acceptable export format"
+// @Failure 500 {object} apperror.Problem "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/classes/export [get]
+func (h *ClassHandler) ExportClasses(w http.ResponseWriter, r *http.Request) {
+	writeExport(w, r, "classes", dto.ClassColumns, h.service.ExportClasses,
+		dto.NewClassResponse, dto.ClassResponse.Record)
 }
 
 // @Summary Get a class by ID
-// @Description Get a specific class by its ID
+// @Description Get a specific class by its ID. A class outside the caller's scope is reported as not found. The ETag header carries the class version; send it back in If-None-Match to get 304 when nothing changed.
 // @Tags classes
 // @Produce json
 // @Param id path int true "Class ID"
-// @Success 200 {object} models.Class
-// @Failure 400 {string} string "Invalid ID"
-// @Failure 404 {string} string "Class not found"
-// @Router /classes/{id} [get]
+// @Param If-None-Match header string false "ETag from a previous response"
+// @Success 200 {object} dto.ClassResponse
+// @Success 304 "Not Modified"
+// @Failure 400 {object} apperror.Problem "Invalid ID"
+// @Failure 401 {object} apperror.Problem "Missing or invalid access token or API key"
+// @Failure 403 {object} apperror.Problem "Role or API key scopes lack the permission for this route"
+// @Failure 404 {object} apperror.Problem "Class not found or outside the caller's scope"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/classes/{id} [get]
 func (h *ClassHandler) GetClassByID(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseUint(vars["id"], 10, 32)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		writeError(w, r, apperror.BadRequest("Invalid ID"))
 		return
 	}
 
-	class, err := h.service.GetClassByID(uint(id))
+	class, err := h.service.GetClassByID(r.Context(), uint(id))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		writeError(w, r, err)
+		return
+	}
+	if notModified(w, r, class.Version) {
 		return
 	}
 
+	setETag(w, class.Version)
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(class)
+	json.NewEncoder(w).Encode(dto.NewClassResponse(class))
+}
+
+// @Summary Get a class roster as of a date
+// @Description List the students who were in the class at the given time, or now when as_of is omitted, ordered by name, with when each joined and left the class. Guardians and students only see the students they can see now. Students who have since been deleted are included, and purged ones too, by ID only. History from before the server kept it is approximate: such students start in their current class from when the audit log last saw them join it, or from their last change.
+// @Tags classes
+// @Produce json
+// @Param id path int true "Class ID"
+// @Param as_of query string false "Date (midnight UTC) or RFC 3339 time, e.g. 2024-03-01"
+// @Success 200 {object} dto.RosterResponse
+// @Failure 400 {object} apperror.Problem "Invalid ID or as_of"
+// @Failure 401 {object} apperror.Problem "Missing or invalid access token or API key"
+// @Failure 403 {object} apperror.Problem "Role or API key scopes lack the permission for this route"
+// @Failure 404 {object} apperror.Problem "Class not found or outside the caller's scope"
+// @Failure 500 {object} apperror.Problem "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/classes/{id}/roster [get]
+func (h *ClassHandler) GetRoster(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	asOf := time.Now().UTC()
+	if v := r.URL.Query().Get("as_of"); v != "" {
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			if t, err = time.Parse(time.DateOnly, v); err != nil {
+				writeError(w, r, apperror.BadRequest("Invalid as_of: must be a date or RFC 3339 time"))
+				return
+			}
+		}
+		asOf = t
+	}
+
+	roster, err := h.service.GetRoster(r.Context(), id, asOf)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.NewRosterResponse(id, asOf, roster))
 }
 
 // @Summary Update a class
-// @Description Update an existing class with the provided details
+// @Description Update an existing class with the provided details. student_count is maintained by the server and may not be sent.
 // @Tags classes
 // @Accept json
 // @Produce json
 // @Param id path int true "Class ID"
-// @Param class body models.Class true "Class object to update"
-// @Success 200 {object} models.Class
-// @Failure 400 {string} string "Invalid request body"
-// @Failure 500 {string} string "Internal server error"
-// @Router /classes/{id} [put]
+// @Param class body dto.UpdateClassRequest true "New class details"
+// @Param If-Match header string false "ETag of the version being replaced (required if the server is configured so)"
+// @Success 200 {object} dto.ClassResponse
+// @Failure 400 {object} apperror.Problem "Invalid request body"
+// @Failure 401 {object} apperror.Problem "Missing or invalid access token or API key"
+// @Failure 403 {object} apperror.Problem "Role or API key scopes lack the permission for this route"
+// @Failure 404 {object} apperror.Problem "Class not found or outside the caller's scope"
+// @Failure 412 {object} apperror.Problem "Class has changed since the given ETag"
+// @Failure 422 {object} apperror.Problem "Validation failed (per-field details in errors)"
+// @Failure 428 {object} apperror.Problem "If-Match is required"
+// @Failure 500 {object} apperror.Problem "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/classes/{id} [put]
 func (h *ClassHandler) UpdateClass(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseUint(vars["id"], 10, 32)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		writeError(w, r, apperror.BadRequest("Invalid ID"))
+		return
+	}
+
+	version, err := ifMatch(r, h.preconditions.RequireIfMatch)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var req dto.UpdateClassRequest
+	if err := decodeJSON(r, &req, "id", "student_count"); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	class := req.ToModel(uint(id))
+	class.Version = version
+	if err := h.service.UpdateClass(r.Context(), class); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	setETag(w, class.Version)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.NewClassResponse(class))
+}
+
+// @Summary Create or replace a class by ID
+// @Description Update the class with the given ID, or create it with that ID if it does not exist. If-Match is honoured when sent but, since this can create, never required.
+// @Tags classes
+// @Accept json
+// @Produce json
+// @Param id path int true "Class ID"
+// @Param class body dto.UpdateClassRequest true "Class details to store"
+// @Param If-Match header string false "ETag of the version being replaced"
+// @Success 200 {object} dto.ClassResponse "Updated"
+// @Success 201 {object} dto.ClassResponse "Created"
+// @Failure 400 {object} apperror.Problem "Invalid request body"
+// @Failure 401 {object} apperror.Problem "Missing or invalid access token or API key"
+// @Failure 403 {object} apperror.Problem "Role or API key scopes lack the permission for this route"
+// @Failure 404 {object} apperror.Problem "ID belongs to a deleted class or one outside the caller's scope"
+// @Failure 412 {object} apperror.Problem "Class has changed since the given ETag"
+// @Failure 422 {object} apperror.Problem "Validation failed (per-field details in errors)"
+// @Failure 500 {object} apperror.Problem "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/classes/{id}/upsert [put]
+func (h *ClassHandler) UpsertClass(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil || id == 0 {
+		writeError(w, r, apperror.BadRequest("Invalid ID"))
+		return
+	}
+
+	version, err := ifMatch(r, false)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var req dto.UpdateClassRequest
+	if err := decodeJSON(r, &req, "id", "student_count"); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	class := req.ToModel(uint(id))
+	class.Version = version
+	created, err := h.service.UpsertClass(r.Context(), class)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	setETag(w, class.Version)
+	w.Header().Set("Content-Type", "application/json")
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(dto.NewClassResponse(class))
+}
+
+// @Summary Partially update a class
+// @Description Apply a JSON Merge Patch (RFC 7396) or JSON Patch (RFC 6902) to the stored class. The patch is applied to the class's update representation and the result is validated like a PUT.
+// @Tags classes
+// @Accept application/merge-patch+json,application/json-patch+json
+// @Produce json
+// @Param id path int true "Class ID"
+// @Param patch body object true "Merge patch object or JSON patch operation array"
+// @Param If-Match header string false "ETag of the version being patched (required if the server is configured so)"
+// @Success 200 {object} dto.ClassResponse
+// @Failure 400 {object} apperror.Problem "Invalid ID or patch document"
+// @Failure 401 {object} apperror.Problem "Missing or invalid access token or API key"
+// @Failure 403 {object} apperror.Problem "Role or API key scopes lack the permission for this route"
+// @Failure 404 {object} apperror.Problem "Class not found or outside the caller's scope"
+// @Failure 409 {object} apperror.Problem "Patch cannot be applied"
+// @Failure 412 {object} apperror.Problem "Class has changed since the given ETag"
+// @Failure 415 {object} apperror.Problem "Unsupported patch media type"
+// @Failure 422 {object} apperror.Problem "Validation failed (per-field details in errors)"
+// @Failure 428 {object} apperror.Problem "If-Match is required"
+// @Failure 500 {object} apperror.Problem "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/classes/{id} [patch]
+func (h *ClassHandler) PatchClass(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		writeError(w, r, apperror.BadRequest("Invalid ID"))
 		return
 	}
 
-	var class models.Class
-	if err := json.NewDecoder(r.Body).Decode(&class); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	version, err := ifMatch(r, h.preconditions.RequireIfMatch)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	class.ID = uint(id)
-	if err := h.service.UpdateClass(&class); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	patch, err := readPatch(r)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
+	class, err := h.service.PatchClass(r.Context(), uint(id), version, func(class *models.Class) error {
+		var req dto.UpdateClassRequest
+		if err := patch.apply(dto.NewUpdateClassRequest(class), &req, "id", "student_count"); err != nil {
+			return err
+		}
+		*class = *req.ToModel(class.ID)
+		return nil
+	})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	setETag(w, class.Version)
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(class)
+	json.NewEncoder(w).Encode(dto.NewClassResponse(class))
 }
 
 // @Summary Delete a class
-// @Description Delete a specific class by its ID
+// @Description Delete a specific class by its ID. Enrolled students are handled by the delete policy: restrict refuses, cascade deletes them, reassign moves them to reassign_to. Defaults come from configuration.
 // @Tags classes
 // @Param id path int true "Class ID"
+// @Param policy query string false "Delete policy" Enums(restrict, cascade, reassign)
+// @Param reassign_to query int false "Class ID to move students to when policy is reassign"
+// @Param If-Match header string false "ETag of the version being deleted (required if the server is configured so)"
 // @Success 204 "No Content"
-// @Failure 400 {string} string "Invalid ID"
-// @Failure 500 {string} string "Internal server error"
-// @Router /classes/{id} [delete]
+// @Failure 400 {object} apperror.Problem "Invalid ID or policy"
+// @Failure 401 {object} apperror.Problem "Missing or invalid access token or API key"
+// @Failure 403 {object} apperror.Problem "Role or API key scopes lack the permission for this route"
+// @Failure 404 {object} apperror.Problem "Class not found or outside the caller's scope"
+// @Failure 409 {object} apperror.Problem "Class has students enrolled"
+// @Failure 412 {object} apperror.Problem "Class has changed since the given ETag"
+// @Failure 422 {object} apperror.Problem "Invalid reassign target"
+// @Failure 428 {object} apperror.Problem "If-Match is required"
+// @Failure 500 {object} apperror.Problem "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/classes/{id} [delete]
 func (h *ClassHandler) DeleteClass(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseUint(vars["id"], 10, 32)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		writeError(w, r, apperror.BadRequest("Invalid ID"))
+		return
+	}
+
+	var opts service.DeleteClassOptions
+	if opts.Version, err = ifMatch(r, h.preconditions.RequireIfMatch); err != nil {
+		writeError(w, r, err)
 		return
 	}
+	if v := r.URL.Query().Get("policy"); v != "" {
+		if opts.Policy, err = service.ParseDeletePolicy(v); err != nil {
+			writeError(w, r, err)
+			return
+		}
+	}
+	if v := r.URL.Query().Get("reassign_to"); v != "" {
+		target, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			writeError(w, r, apperror.BadRequest("Invalid reassign_to"))
+			return
+		}
+		opts.ReassignTo = uint(target)
+	}
 
-	if err := h.service.DeleteClass(uint(id)); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := h.service.DeleteClass(r.Context(), uint(id), opts); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
-} 
\ No newline at end of file
+}
+
+// @Summary Restore a deleted class
+// @Description Bring back a soft-deleted class. Students deleted along with it stay deleted and are restored separately.
+// @Tags classes
+// @Produce json
+// @Param id path int true "Class ID"
+// @Success 200 {object} dto.ClassResponse
+// @Failure 400 {object} apperror.Problem "Invalid ID"
+// @Failure 401 {object} apperror.Problem "Missing or invalid access token or API key"
+// @Failure 403 {object} apperror.Problem "Role or API key scopes lack the permission for this route"
+// @Failure 404 {object} apperror.Problem "No deleted class with this ID"
+// @Failure 500 {object} apperror.Problem "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/classes/{id}/restore [post]
+func (h *ClassHandler) RestoreClass(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		writeError(w, r, apperror.BadRequest("Invalid ID"))
+		return
+	}
+
+	class, err := h.service.RestoreClass(r.Context(), uint(id))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	setETag(w, class.Version)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.NewClassResponse(class))
+}
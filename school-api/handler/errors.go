@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"school-api/apperror"
+	"school-api/repository"
+)
+
+// writeError reports err to the client as application/problem+json.
+// Server-side failures are logged with their cause, which is never sent.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	apperror.WriteProblem(w, r, toAppError(r, err))
+}
+
+// toAppError converts err to the error reported for r, logging server-side failures
+func toAppError(r *http.Request, err error) *apperror.Error {
+	var appErr *apperror.Error
+	switch {
+	case errors.Is(r.Context().Err(), context.Canceled):
+		// The client disconnected; whatever failed, nobody is waiting for it
+		appErr = apperror.Wrap(err, apperror.StatusClientClosedRequest, apperror.CodeCanceled, "The request was cancelled by the client")
+	case errors.Is(err, repository.ErrInvalidQuery):
+		appErr = apperror.Wrap(err, http.StatusBadRequest, apperror.CodeInvalidQuery, err.Error())
+	case errors.Is(err, repository.ErrNotFound):
+		appErr = apperror.Wrap(err, http.StatusNotFound, apperror.CodeNotFound, "Resource not found")
+	case errors.Is(err, repository.ErrVersionConflict):
+		appErr = apperror.Wrap(err, http.StatusPreconditionFailed, apperror.CodePreconditionFailed, "The resource has been changed")
+	default:
+		appErr = apperror.From(err)
+	}
+
+	if appErr.Status >= http.StatusInternalServerError {
+		log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+	}
+	return appErr
+}
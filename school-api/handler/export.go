@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"school-api/apperror"
+	"school-api/repository"
+	"school-api/spreadsheet"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// exportFormat is a file format records can be exported as
+type exportFormat string
+
+const (
+	exportCSV    exportFormat = "csv"
+	exportXLSX   exportFormat = "xlsx"
+	exportNDJSON exportFormat = "ndjson"
+)
+
+// ndjsonMediaType is the media type of newline-delimited JSON
+const ndjsonMediaType = "application/x-ndjson"
+
+// exportMediaTypes maps the media types clients may Accept to formats
+var exportMediaTypes = map[string]exportFormat{
+	spreadsheet.CSVMediaType:  exportCSV,
+	"application/csv":         exportCSV,
+	"text/*":                  exportCSV,
+	"*/*":                     exportCSV,
+	spreadsheet.XLSXMediaType: exportXLSX,
+	ndjsonMediaType:           exportNDJSON,
+	"application/jsonl":       exportNDJSON,
+}
+
+func (f exportFormat) mediaType() string {
+	switch f {
+	case exportXLSX:
+		return spreadsheet.XLSXMediaType
+	case exportNDJSON:
+		return ndjsonMediaType
+	default:
+		return spreadsheet.CSVMediaType + "; charset=utf-8"
+	}
+}
+
+// negotiateExport picks the export format from the format query parameter
+// or, failing that, the Accept header. CSV is the default.
+func negotiateExport(r *http.Request) (exportFormat, error) {
+	if v := r.URL.Query().Get("format"); v != "" {
+		switch f := exportFormat(strings.ToLower(v)); f {
+		case exportCSV, exportXLSX, exportNDJSON:
+			return f, nil
+		case "jsonl":
+			return exportNDJSON, nil
+		}
+		return "", apperror.BadRequest("Invalid format: must be csv, xlsx or ndjson")
+	}
+
+	accept := r.Header.Get("Accept")
+	if strings.TrimSpace(accept) == "" {
+		return exportCSV, nil
+	}
+	var (
+		best  exportFormat
+		bestQ float64
+	)
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if format, ok := exportMediaTypes[mediaType]; ok && q > bestQ {
+			best, bestQ = format, q
+		}
+	}
+	if best == "" {
+		return "", apperror.New(http.StatusNotAcceptable, apperror.CodeNotAcceptable,
+			fmt.Sprintf("Exports are available as %s, %s or %s", spreadsheet.CSVMediaType, spreadsheet.XLSXMediaType, ndjsonMediaType))
+	}
+	return best, nil
+}
+
+// writeExport streams every record matching the request's filters and sort
+// order as a file attachment named after name. Nothing is sent until the
+// first record arrives, so earlier failures get a problem response; a
+// failure after that aborts the connection so that a truncated file cannot
+// be mistaken for a complete one.
+func writeExport[T, R any](w http.ResponseWriter, r *http.Request, name string, columns []string,
+	export func(context.Context, repository.QueryOptions, func(*T) error) error,
+	toResponse func(*T) R, record func(R) []any) {
+	format, err := negotiateExport(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	query := r.URL.Query()
+	query.Del("format")
+	opts, err := parseQuery(r, query)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	liftWriteDeadline(w)
+
+	var (
+		started bool
+		write   func(R) error
+		finish  func() error
+	)
+	start := func() error {
+		w.Header().Set("Content-Type", format.mediaType())
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+"."+string(format)))
+		w.Header().Set("Vary", "Accept")
+		w.WriteHeader(http.StatusOK)
+		started = true
+
+		if format == exportNDJSON {
+			enc := json.NewEncoder(w)
+			write = func(v R) error { return enc.Encode(v) }
+			finish = func() error { return nil }
+			return nil
+		}
+		sheet, err := spreadsheet.NewWriter(w, spreadsheet.Format(format))
+		if err != nil {
+			return err
+		}
+		header := make([]any, len(columns))
+		for i, c := range columns {
+			header[i] = c
+		}
+		write = func(v R) error { return sheet.Write(record(v)) }
+		finish = sheet.Close
+		return sheet.Write(header)
+	}
+
+	err = export(r.Context(), opts, func(entity *T) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		return write(toResponse(entity))
+	})
+	if err == nil && !started {
+		err = start()
+	}
+	if err == nil {
+		err = finish()
+	}
+	if err == nil {
+		return
+	}
+	if !started {
+		writeError(w, r, err)
+		return
+	}
+	toAppError(r, err) // logs server-side failures
+	panic(http.ErrAbortHandler)
+}
+
+// liftWriteDeadline lets a response be written past the server's write
+// timeout. Large exports outlast it; the export query timeout bounds them
+// instead.
+func liftWriteDeadline(w http.ResponseWriter) {
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+}
@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+
+	"gorm.io/gorm"
+)
+
+// HealthHandler reports liveness and readiness of the server
+type HealthHandler struct {
+	db    *gorm.DB
+	ready atomic.Bool
+}
+
+func NewHealthHandler(db *gorm.DB) *HealthHandler {
+	return &HealthHandler{db: db}
+}
+
+// SetReady marks the server as able (or no longer able) to take traffic
+func (h *HealthHandler) SetReady(ready bool) {
+	h.ready.Store(ready)
+}
+
+// Live reports that the process is running
+func (h *HealthHandler) Live(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// Ready reports whether the server is accepting traffic and the database is reachable
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	status := http.StatusOK
+	body := map[string]string{"status": "ready"}
+
+	if !h.ready.Load() {
+		status = http.StatusServiceUnavailable
+		body["status"] = "not ready"
+	} else if sqlDB, err := h.db.DB(); err != nil || sqlDB.PingContext(r.Context()) != nil {
+		status = http.StatusServiceUnavailable
+		body["status"] = "database unavailable"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,190 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"school-api/apperror"
+	"school-api/service"
+	"school-api/spreadsheet"
+	"strconv"
+	"strings"
+)
+
+// maxImportBytes caps the size of an uploaded roster
+const maxImportBytes = 10 << 20
+
+// ImportResponse reports what a roster import did, or would do on a dry run
+type ImportResponse struct {
+	DryRun         bool                 `json:"dry_run"`
+	Committed      bool                 `json:"committed"`
+	IgnoredColumns []string             `json:"ignored_columns,omitempty"`
+	Summary        ImportSummary        `json:"summary"`
+	Items          []ImportItemResponse `json:"items"`
+}
+
+// ImportSummary counts the items of an import by action
+type ImportSummary struct {
+	Creates   int `json:"creates" example:"12"`
+	Updates   int `json:"updates" example:"3"`
+	Unchanged int `json:"unchanged" example:"20"`
+	Deletes   int `json:"deletes" example:"0"`
+	Conflicts int `json:"conflicts" example:"0"`
+	Invalid   int `json:"invalid" example:"0"`
+	Skipped   int `json:"skipped" example:"0"`
+}
+
+// ImportItemResponse is the outcome for one class or student of a roster
+type ImportItemResponse struct {
+	File    string            `json:"file,omitempty" example:"users.csv"`
+	Line    int               `json:"line,omitempty" example:"2"`
+	Entity  string            `json:"entity" enums:"class,student,enrollment" example:"student"`
+	Action  string            `json:"action" enums:"create,update,unchanged,delete,conflict,invalid,skipped" example:"update"`
+	ID      uint              `json:"id,omitempty" example:"7"`
+	Name    string            `json:"name,omitempty" example:"Jane Doe"`
+	Changes []ImportFieldDiff `json:"changes,omitempty"`
+	Message string            `json:"message,omitempty"`
+}
+
+// ImportFieldDiff is one field an import changes
+type ImportFieldDiff struct {
+	Field string `json:"field" example:"student_section"`
+	From  string `json:"from" example:"A"`
+	To    string `json:"to" example:"B"`
+}
+
+// NewImportResponse converts a service report to its JSON form
+func NewImportResponse(report *service.ImportReport) ImportResponse {
+	resp := ImportResponse{
+		DryRun:         report.DryRun,
+		Committed:      report.Committed,
+		IgnoredColumns: report.IgnoredColumns,
+		Summary:        ImportSummary(report.Summary),
+		Items:          make([]ImportItemResponse, len(report.Items)),
+	}
+	for i, item := range report.Items {
+		changes := make([]ImportFieldDiff, len(item.Changes))
+		for j, c := range item.Changes {
+			changes[j] = ImportFieldDiff(c)
+		}
+		resp.Items[i] = ImportItemResponse{
+			File:    item.File,
+			Line:    item.Line,
+			Entity:  item.Entity,
+			Action:  string(item.Action),
+			ID:      item.ID,
+			Name:    item.Name,
+			Changes: changes,
+			Message: item.Message,
+		}
+	}
+	return resp
+}
+
+type ImportHandler struct {
+	service service.ImportService
+}
+
+func NewImportHandler(service service.ImportService) *ImportHandler {
+	return &ImportHandler{service: service}
+}
+
+// @Summary Import a class roster
+// @Description Import classes and students from a CSV or Excel file, sent either as the request body or as the "file" field of a multipart form. The header row names the columns: class_name (required), student_name, student_section and id. Classes are matched by name and created when missing; students are matched by id, or by name within their class. The roster is applied in a single transaction, and not at all if any row is a conflict or invalid. With dry_run the changes are reported without being made.
+// @Tags import
+// @Accept text/csv
+// @Accept application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Accept multipart/form-data
+// @Produce json
+// @Param dry_run query bool false "Report the changes without applying them"
+// @Param file formData file false "Roster file, when uploading a multipart form"
+// @Success 200 {object} ImportResponse
+// @Failure 400 {object} apperror.Problem "Unreadable roster"
+// @Failure 401 {object} apperror.Problem "Missing or invalid access token"
+// @Failure 403 {object} apperror.Problem "Role lacks the permission for this route"
+// @Failure 413 {object} apperror.Problem "Roster too large"
+// @Failure 415 {object} apperror.Problem "Unsupported file format"
+// @Failure 422 {object} ImportResponse "Roster has conflicts or invalid rows; nothing was imported"
+// @Failure 500 {object} apperror.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /api/import [post]
+func (h *ImportHandler) ImportRoster(w http.ResponseWriter, r *http.Request) {
+	var dryRun bool
+	if v := r.URL.Query().Get("dry_run"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			writeError(w, r, apperror.BadRequest("Invalid dry_run: must be true or false"))
+			return
+		}
+		dryRun = b
+	}
+
+	sheet, err := readRoster(w, r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	report, err := h.service.ImportRoster(r.Context(), sheet, service.ImportOptions{DryRun: dryRun})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	status := http.StatusOK
+	if report.Blocked() {
+		status = http.StatusUnprocessableEntity
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(NewImportResponse(report))
+}
+
+// readRoster reads the rows of an uploaded spreadsheet. Multipart uploads
+// take their format from the file name, raw bodies from the Content-Type.
+func readRoster(w http.ResponseWriter, r *http.Request) (*spreadsheet.Sheet, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+
+	var (
+		body   io.Reader = r.Body
+		format spreadsheet.Format
+		err    error
+	)
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, header, ferr := r.FormFile("file")
+		if ferr != nil {
+			if tooLarge(ferr) {
+				return nil, errRosterTooLarge
+			}
+			return nil, apperror.BadRequest("Multipart upload must include a file field")
+		}
+		defer file.Close()
+		body = file
+		format, err = spreadsheet.FormatFromFilename(header.Filename)
+	} else {
+		format, err = spreadsheet.FormatFromMediaType(r.Header.Get("Content-Type"))
+	}
+	if err != nil {
+		return nil, apperror.New(http.StatusUnsupportedMediaType, apperror.CodeUnsupportedMediaType,
+			"Roster must be a CSV (.csv, text/csv) or Excel (.xlsx) file")
+	}
+
+	sheet, err := spreadsheet.ReadSheet(body, format)
+	if err != nil {
+		if tooLarge(err) {
+			return nil, errRosterTooLarge
+		}
+		return nil, apperror.BadRequest("Roster could not be read as " + strings.ToUpper(string(format)))
+	}
+	return sheet, nil
+}
+
+var errRosterTooLarge = apperror.New(http.StatusRequestEntityTooLarge, apperror.CodeTooLarge,
+	"Roster may be at most "+strconv.Itoa(maxImportBytes>>20)+" MiB")
+
+// tooLarge reports whether err came from exceeding the body size limit
+func tooLarge(err error) bool {
+	var maxErr *http.MaxBytesError
+	return errors.As(err, &maxErr)
+}
@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"school-api/apperror"
+	"school-api/auth"
+	"school-api/repository"
+	"strconv"
+	"strings"
+)
+
+// ListResponse is the envelope returned by list endpoints
+type ListResponse[T any] struct {
+	Data       []T    `json:"data"`
+	Total      int64  `json:"total"`
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Next       string `json:"next,omitempty"`
+}
+
+// parseQueryOptions reads paging, sorting and filtering from the query string:
+//
+//	?limit=20&offset=40           offset paging
+//	?limit=20&cursor=...          cursor paging (cursor from a previous page)
+//	?sort=-class_name,id          sort, "-" for descending
+//	?class_name=Math              equality filter
+//	?student_count[gte]=10        range filter (eq, ne, gt, gte, lt, lte)
+//	?student_name[like]=ali       substring filter
+//	?include_deleted=true         include soft-deleted rows (administrators only)
+func parseQueryOptions(r *http.Request) (repository.QueryOptions, error) {
+	return parseQuery(r, r.URL.Query())
+}
+
+// parseQuery is parseQueryOptions for query parameters of r that have
+// already been parsed
+func parseQuery(r *http.Request, query url.Values) (repository.QueryOptions, error) {
+	var opts repository.QueryOptions
+	for key, values := range query {
+		value := values[len(values)-1]
+		switch key {
+		case "limit", "offset":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return opts, fmt.Errorf("%w: %s must be a non-negative integer", repository.ErrInvalidQuery, key)
+			}
+			if key == "limit" {
+				opts.Limit = n
+			} else {
+				opts.Offset = n
+			}
+		case "cursor":
+			opts.Cursor = value
+		case "include_deleted":
+			include, err := strconv.ParseBool(value)
+			if err != nil {
+				return opts, fmt.Errorf("%w: include_deleted must be true or false", repository.ErrInvalidQuery)
+			}
+			// Deleted rows are kept for recovery, not for everyday reading
+			if principal, ok := auth.PrincipalFrom(r.Context()); include && ok && !principal.Can(auth.PermAdmin) {
+				return opts, errIncludeDeleted
+			}
+			opts.IncludeDeleted = include
+		case "sort":
+			for _, field := range strings.Split(value, ",") {
+				field = strings.TrimSpace(field)
+				if field == "" {
+					continue
+				}
+				desc := strings.HasPrefix(field, "-")
+				opts.Sort = append(opts.Sort, repository.SortField{
+					Field: strings.TrimPrefix(field, "-"),
+					Desc:  desc,
+				})
+			}
+		default:
+			field, op := key, repository.OpEq
+			if i := strings.Index(key, "["); i > 0 && strings.HasSuffix(key, "]") {
+				parsed, err := repository.ParseFilterOp(key[i+1 : len(key)-1])
+				if err != nil {
+					return opts, err
+				}
+				field, op = key[:i], parsed
+			}
+			for _, v := range values {
+				opts.Filters = append(opts.Filters, repository.Filter{Field: field, Op: op, Value: v})
+			}
+		}
+	}
+	return opts, nil
+}
+
+var errIncludeDeleted = apperror.New(http.StatusForbidden, apperror.CodeForbidden,
+	"Only administrators may list deleted records")
+
+// newListResponse maps a page of results to response items and builds the
+// link to the next page
+func newListResponse[T, R any](r *http.Request, page *repository.Page[T], toResponse func(*T) R) ListResponse[R] {
+	resp := ListResponse[R]{
+		Data:       make([]R, len(page.Items)),
+		Total:      page.Total,
+		Limit:      page.Limit,
+		Offset:     page.Offset,
+		NextCursor: page.NextCursor,
+	}
+	for i := range page.Items {
+		resp.Data[i] = toResponse(&page.Items[i])
+	}
+
+	if page.HasMore() {
+		query := r.URL.Query()
+		query.Set("limit", strconv.Itoa(page.Limit))
+		if query.Get("cursor") != "" {
+			query.Set("cursor", page.NextCursor)
+		} else {
+			query.Set("offset", strconv.Itoa(page.Offset+len(page.Items)))
+		}
+		resp.Next = r.URL.Path + "?" + query.Encode()
+	}
+	return resp
+}
+
+// writeList writes a list response, advertising the next page in a Link header
+func writeList[T, R any](w http.ResponseWriter, r *http.Request, page *repository.Page[T], toResponse func(*T) R) {
+	resp := newListResponse(r, page, toResponse)
+	if resp.Next != "" {
+		w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", resp.Next))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
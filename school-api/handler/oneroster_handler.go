@@ -0,0 +1,417 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"school-api/apperror"
+	"school-api/models"
+	"school-api/oneroster"
+	"school-api/repository"
+	"school-api/service"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// maxBundleBytes caps the size of an uploaded OneRoster CSV bundle
+const maxBundleBytes = 50 << 20
+
+// OneRoster response envelopes
+type (
+	UsersResponse struct {
+		Users []oneroster.User `json:"users"`
+	}
+	UserResponse struct {
+		User oneroster.User `json:"user"`
+	}
+	OneRosterClassesResponse struct {
+		Classes []oneroster.Class `json:"classes"`
+	}
+	OneRosterClassResponse struct {
+		Class oneroster.Class `json:"class"`
+	}
+	EnrollmentsResponse struct {
+		Enrollments []oneroster.Enrollment `json:"enrollments"`
+	}
+	EnrollmentResponse struct {
+		Enrollment oneroster.Enrollment `json:"enrollment"`
+	}
+)
+
+type OneRosterHandler struct {
+	service service.OneRosterService
+	// configured is the organization of the default tenant
+	configured oneroster.Org
+}
+
+func NewOneRosterHandler(service service.OneRosterService, org oneroster.Org) *OneRosterHandler {
+	return &OneRosterHandler{service: service, configured: org}
+}
+
+// @Summary List OneRoster users
+// @Description List students as OneRoster users. Page with limit (default 100) and offset; the total is returned in X-Total-Count. Sort and filter by dateLastModified, e.g. filter=dateLastModified>'2024-09-01'.
+// @Tags oneroster
+// @Produce json
+// @Param limit query int false "Page size (default 100, max 500)"
+// @Param offset query int false "Number of users to skip"
+// @Param sort query string false "Field to sort by" Enums(dateLastModified)
+// @Param orderBy query string false "Sort direction" Enums(asc, desc)
+// @Param filter query string false "Filter expression, e.g. dateLastModified>='2024-09-01'"
+// @Success 200 {object} UsersResponse
+// @Header 200 {integer} X-Total-Count "Number of matching users"
+// @Failure 400 {object} oneroster.StatusInfo "Invalid query"
+// @Failure 401 {object} oneroster.StatusInfo "Missing or invalid access token"
+// @Failure 403 {object} oneroster.StatusInfo "Role lacks the permission for this route"
+// @Failure 500 {object} oneroster.StatusInfo "Internal server error"
+// @Security BearerAuth
+// @Router /ims/oneroster/v1p2/users [get]
+func (h *OneRosterHandler) GetUsers(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseOneRosterQuery(r, oneRosterUserFields)
+	if err != nil {
+		writeOneRosterError(w, r, err)
+		return
+	}
+	page, err := h.service.Users(r.Context(), opts)
+	if err != nil {
+		writeOneRosterError(w, r, err)
+		return
+	}
+	writeOneRosterPage(w, r, "users", page, h.user(r))
+}
+
+// @Summary Get a OneRoster user
+// @Description Get a student as a OneRoster user by sourcedId: the ID it was imported with, or else its student ID.
+// @Tags oneroster
+// @Produce json
+// @Param sourcedId path string true "User sourcedId"
+// @Success 200 {object} UserResponse
+// @Failure 401 {object} oneroster.StatusInfo "Missing or invalid access token"
+// @Failure 403 {object} oneroster.StatusInfo "Role lacks the permission for this route"
+// @Failure 404 {object} oneroster.StatusInfo "User not found"
+// @Failure 500 {object} oneroster.StatusInfo "Internal server error"
+// @Security BearerAuth
+// @Router /ims/oneroster/v1p2/users/{sourcedId} [get]
+func (h *OneRosterHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	student, err := h.service.User(r.Context(), mux.Vars(r)["sourcedId"])
+	if err != nil {
+		writeOneRosterError(w, r, err)
+		return
+	}
+	writeOneRosterJSON(w, http.StatusOK, UserResponse{User: h.user(r)(student)})
+}
+
+// @Summary List OneRoster classes
+// @Description List classes as OneRoster classes. Page with limit (default 100) and offset; the total is returned in X-Total-Count. Sort and filter by dateLastModified and title.
+// @Tags oneroster
+// @Produce json
+// @Param limit query int false "Page size (default 100, max 500)"
+// @Param offset query int false "Number of classes to skip"
+// @Param sort query string false "Field to sort by" Enums(dateLastModified, title)
+// @Param orderBy query string false "Sort direction" Enums(asc, desc)
+// @Param filter query string false "Filter expression, e.g. title~'math'"
+// @Success 200 {object} OneRosterClassesResponse
+// @Header 200 {integer} X-Total-Count "Number of matching classes"
+// @Failure 400 {object} oneroster.StatusInfo "Invalid query"
+// @Failure 401 {object} oneroster.StatusInfo "Missing or invalid access token"
+// @Failure 403 {object} oneroster.StatusInfo "Role lacks the permission for this route"
+// @Failure 500 {object} oneroster.StatusInfo "Internal server error"
+// @Security BearerAuth
+// @Router /ims/oneroster/v1p2/classes [get]
+func (h *OneRosterHandler) GetClasses(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseOneRosterQuery(r, oneRosterClassFields)
+	if err != nil {
+		writeOneRosterError(w, r, err)
+		return
+	}
+	page, err := h.service.Classes(r.Context(), opts)
+	if err != nil {
+		writeOneRosterError(w, r, err)
+		return
+	}
+	writeOneRosterPage(w, r, "classes", page, h.class(r))
+}
+
+// @Summary Get a OneRoster class
+// @Description Get a class by sourcedId: the ID it was imported with, or else its class ID.
+// @Tags oneroster
+// @Produce json
+// @Param sourcedId path string true "Class sourcedId"
+// @Success 200 {object} OneRosterClassResponse
+// @Failure 401 {object} oneroster.StatusInfo "Missing or invalid access token"
+// @Failure 403 {object} oneroster.StatusInfo "Role lacks the permission for this route"
+// @Failure 404 {object} oneroster.StatusInfo "Class not found"
+// @Failure 500 {object} oneroster.StatusInfo "Internal server error"
+// @Security BearerAuth
+// @Router /ims/oneroster/v1p2/classes/{sourcedId} [get]
+func (h *OneRosterHandler) GetClass(w http.ResponseWriter, r *http.Request) {
+	class, err := h.service.Class(r.Context(), mux.Vars(r)["sourcedId"])
+	if err != nil {
+		writeOneRosterError(w, r, err)
+		return
+	}
+	writeOneRosterJSON(w, http.StatusOK, OneRosterClassResponse{Class: h.class(r)(class)})
+}
+
+// @Summary List the students of a OneRoster class
+// @Description List the students enrolled in a class as OneRoster users, paged and filtered like the users collection.
+// @Tags oneroster
+// @Produce json
+// @Param sourcedId path string true "Class sourcedId"
+// @Param limit query int false "Page size (default 100, max 500)"
+// @Param offset query int false "Number of users to skip"
+// @Param sort query string false "Field to sort by" Enums(dateLastModified)
+// @Param orderBy query string false "Sort direction" Enums(asc, desc)
+// @Param filter query string false "Filter expression"
+// @Success 200 {object} UsersResponse
+// @Failure 400 {object} oneroster.StatusInfo "Invalid query"
+// @Failure 401 {object} oneroster.StatusInfo "Missing or invalid access token"
+// @Failure 403 {object} oneroster.StatusInfo "Role lacks the permission for this route"
+// @Failure 404 {object} oneroster.StatusInfo "Class not found"
+// @Failure 500 {object} oneroster.StatusInfo "Internal server error"
+// @Security BearerAuth
+// @Router /ims/oneroster/v1p2/classes/{sourcedId}/students [get]
+func (h *OneRosterHandler) GetStudentsForClass(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseOneRosterQuery(r, oneRosterUserFields)
+	if err != nil {
+		writeOneRosterError(w, r, err)
+		return
+	}
+	class, err := h.service.Class(r.Context(), mux.Vars(r)["sourcedId"])
+	if err != nil {
+		writeOneRosterError(w, r, err)
+		return
+	}
+	opts.Filters = append(opts.Filters, repository.Filter{Field: "class_id", Op: repository.OpEq, Value: strconv.FormatUint(uint64(class.ID), 10)})
+	page, err := h.service.Users(r.Context(), opts)
+	if err != nil {
+		writeOneRosterError(w, r, err)
+		return
+	}
+	writeOneRosterPage(w, r, "users", page, h.user(r))
+}
+
+// @Summary List OneRoster enrollments
+// @Description List the enrollment of every student in their class. Page with limit (default 100) and offset; sort and filter by dateLastModified.
+// @Tags oneroster
+// @Produce json
+// @Param limit query int false "Page size (default 100, max 500)"
+// @Param offset query int false "Number of enrollments to skip"
+// @Param sort query string false "Field to sort by" Enums(dateLastModified)
+// @Param orderBy query string false "Sort direction" Enums(asc, desc)
+// @Param filter query string false "Filter expression"
+// @Success 200 {object} EnrollmentsResponse
+// @Header 200 {integer} X-Total-Count "Number of matching enrollments"
+// @Failure 400 {object} oneroster.StatusInfo "Invalid query"
+// @Failure 401 {object} oneroster.StatusInfo "Missing or invalid access token"
+// @Failure 403 {object} oneroster.StatusInfo "Role lacks the permission for this route"
+// @Failure 500 {object} oneroster.StatusInfo "Internal server error"
+// @Security BearerAuth
+// @Router /ims/oneroster/v1p2/enrollments [get]
+func (h *OneRosterHandler) GetEnrollments(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseOneRosterQuery(r, oneRosterEnrollmentFields)
+	if err != nil {
+		writeOneRosterError(w, r, err)
+		return
+	}
+	page, err := h.service.Users(r.Context(), opts)
+	if err != nil {
+		writeOneRosterError(w, r, err)
+		return
+	}
+	classes, err := h.service.ClassSourcedIDs(r.Context(), page.Items)
+	if err != nil {
+		writeOneRosterError(w, r, err)
+		return
+	}
+	org := h.org(r)
+	writeOneRosterPage(w, r, "enrollments", page, func(s *models.Student) oneroster.Enrollment {
+		return oneroster.NewEnrollment(s, classes[s.ClassId], org)
+	})
+}
+
+// @Summary Get a OneRoster enrollment
+// @Description Get a student's enrollment by sourcedId, which is the student's sourcedId prefixed with "enrollment-".
+// @Tags oneroster
+// @Produce json
+// @Param sourcedId path string true "Enrollment sourcedId"
+// @Success 200 {object} EnrollmentResponse
+// @Failure 401 {object} oneroster.StatusInfo "Missing or invalid access token"
+// @Failure 403 {object} oneroster.StatusInfo "Role lacks the permission for this route"
+// @Failure 404 {object} oneroster.StatusInfo "enrollment not found"
+// @Failure 500 {object} oneroster.StatusInfo "Internal server error"
+// @Security BearerAuth
+// @Router /ims/oneroster/v1p2/enrollments/{sourcedId} [get]
+func (h *OneRosterHandler) GetEnrollment(w http.ResponseWriter, r *http.Request) {
+	userID, ok := oneroster.UserSourcedID(mux.Vars(r)["sourcedId"])
+	if !ok {
+		writeOneRosterError(w, r, apperror.NotFound("enrollment not found"))
+		return
+	}
+	student, err := h.service.User(r.Context(), userID)
+	if errors.Is(err, service.ErrStudentNotFound) {
+		err = apperror.NotFound("enrollment not found")
+	}
+	if err != nil {
+		writeOneRosterError(w, r, err)
+		return
+	}
+	classes, err := h.service.ClassSourcedIDs(r.Context(), []models.Student{*student})
+	if err != nil {
+		writeOneRosterError(w, r, err)
+		return
+	}
+	writeOneRosterJSON(w, http.StatusOK, EnrollmentResponse{Enrollment: oneroster.NewEnrollment(student, classes[student.ClassId], h.org(r))})
+}
+
+// @Summary Export a OneRoster CSV bundle
+// @Description Download every class and student as a OneRoster 1.2 bulk CSV bundle: a zip archive of manifest.csv, orgs.csv, courses.csv, classes.csv, users.csv and enrollments.csv. Each class is also its own course; student sections are in the metadata.section column of users.csv.
+// @Tags oneroster
+// @Produce application/zip
+// @Success 200 {file} file
+// @Failure 401 {object} oneroster.StatusInfo "Missing or invalid access token"
+// @Failure 403 {object} oneroster.StatusInfo "Role lacks the permission for this route"
+// @Failure 500 {object} oneroster.StatusInfo "Internal server error"
+// @Security BearerAuth
+// @Router /ims/oneroster/v1p2/csv [get]
+func (h *OneRosterHandler) ExportCSV(w http.ResponseWriter, r *http.Request) {
+	liftWriteDeadline(w)
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="oneroster.zip"`)
+	out := &sentWriter{ResponseWriter: w}
+	bundle := oneroster.NewBundleWriter(out)
+	err := h.service.ExportCSV(r.Context(), bundle)
+	if err == nil {
+		err = bundle.Close()
+	}
+	if err == nil {
+		return
+	}
+	// As with the other exports, a truncated archive must not look complete
+	if out.sent {
+		toAppError(r, err) // logs server-side failures
+		panic(http.ErrAbortHandler)
+	}
+	w.Header().Del("Content-Disposition")
+	writeOneRosterError(w, r, err)
+}
+
+// sentWriter records whether any of the response body has been written
+type sentWriter struct {
+	http.ResponseWriter
+	sent bool
+}
+
+func (w *sentWriter) Write(p []byte) (int, error) {
+	w.sent = true
+	return w.ResponseWriter.Write(p)
+}
+
+// @Summary Import a OneRoster CSV bundle
+// @Description Import classes, student users and student enrollments from a OneRoster 1.2 CSV bundle (zip), sent as the request body or as the "file" field of a multipart form. Records are matched by sourcedId; records with status tobedeleted are deleted. Files follow the mode manifest.csv gives them: absent files are ignored, delta files change the records they list, and bulk classes.csv and users.csv files also delete the stored classes and students they leave out. Files the manifest does not mention, or every file when there is no manifest, are treated as delta. A bulk enrollments.csv is applied like a delta one, since students always belong to a class. Users and enrollments with other roles are skipped. The bundle is applied in a single transaction, and not at all if any record is a conflict or invalid. With dry_run the changes are reported without being made.
+// @Tags oneroster
+// @Accept application/zip
+// @Accept multipart/form-data
+// @Produce json
+// @Param dry_run query bool false "Report the changes without applying them"
+// @Param file formData file false "Bundle, when uploading a multipart form"
+// @Success 200 {object} ImportResponse
+// @Failure 400 {object} oneroster.StatusInfo "Unreadable bundle"
+// @Failure 401 {object} oneroster.StatusInfo "Missing or invalid access token"
+// @Failure 403 {object} oneroster.StatusInfo "Role lacks the permission for this route"
+// @Failure 413 {object} oneroster.StatusInfo "Bundle too large"
+// @Failure 422 {object} ImportResponse "Bundle has conflicts or invalid records; nothing was imported"
+// @Failure 500 {object} oneroster.StatusInfo "Internal server error"
+// @Security BearerAuth
+// @Router /ims/oneroster/v1p2/csv [post]
+func (h *OneRosterHandler) ImportCSV(w http.ResponseWriter, r *http.Request) {
+	var dryRun bool
+	if v := r.URL.Query().Get("dry_run"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			writeOneRosterError(w, r, apperror.BadRequest("Invalid dry_run: must be true or false"))
+			return
+		}
+		dryRun = b
+	}
+
+	bundle, err := readBundle(w, r)
+	if err != nil {
+		writeOneRosterError(w, r, err)
+		return
+	}
+	report, err := h.service.ImportCSV(r.Context(), bundle, service.ImportOptions{DryRun: dryRun})
+	if err != nil {
+		writeOneRosterError(w, r, err)
+		return
+	}
+
+	status := http.StatusOK
+	if report.Blocked() {
+		status = http.StatusUnprocessableEntity
+	}
+	writeOneRosterJSON(w, status, NewImportResponse(report))
+}
+
+// readBundle reads an uploaded OneRoster zip archive
+func readBundle(w http.ResponseWriter, r *http.Request) (oneroster.Bundle, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBundleBytes)
+
+	var (
+		reader io.ReaderAt
+		size   int64
+	)
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			if tooLarge(err) {
+				return nil, errBundleTooLarge
+			}
+			return nil, apperror.BadRequest("Multipart upload must include a file field")
+		}
+		defer file.Close()
+		reader, size = file, header.Size
+	} else {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			if tooLarge(err) {
+				return nil, errBundleTooLarge
+			}
+			return nil, apperror.BadRequest("Invalid request body")
+		}
+		reader, size = bytes.NewReader(body), int64(len(body))
+	}
+
+	bundle, err := oneroster.ReadBundle(reader, size)
+	if err != nil {
+		return nil, apperror.BadRequest(err.Error())
+	}
+	return bundle, nil
+}
+
+var errBundleTooLarge = apperror.New(http.StatusRequestEntityTooLarge, apperror.CodeTooLarge,
+	"Bundle may be at most "+strconv.Itoa(maxBundleBytes>>20)+" MiB")
+
+// org returns the organization of the tenant the request is for
+func (h *OneRosterHandler) org(r *http.Request) oneroster.Org {
+	return service.TenantOrg(r.Context(), h.configured)
+}
+
+// user returns the mapping of students to users for the request
+func (h *OneRosterHandler) user(r *http.Request) func(*models.Student) oneroster.User {
+	org := h.org(r)
+	return func(s *models.Student) oneroster.User {
+		return oneroster.NewUser(s, org)
+	}
+}
+
+// class returns the mapping of classes for the request
+func (h *OneRosterHandler) class(r *http.Request) func(*models.Class) oneroster.Class {
+	org := h.org(r)
+	return func(c *models.Class) oneroster.Class {
+		return oneroster.NewClass(c, org)
+	}
+}
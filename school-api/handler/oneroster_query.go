@@ -0,0 +1,184 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"school-api/apperror"
+	"school-api/oneroster"
+	"school-api/repository"
+	"strconv"
+	"strings"
+)
+
+// Error codes for OneRoster requests, named after the imsx minor codes they map to
+const (
+	codeInvalidFilterField apperror.Code = "invalid_filter_field"
+	codeInvalidSortField   apperror.Code = "invalid_sort_field"
+)
+
+// oneRosterDefaultLimit is the page size OneRoster specifies when none is given
+const oneRosterDefaultLimit = 100
+
+// OneRoster fields each collection can be filtered and sorted by, mapped to
+// the fields of the underlying list query
+var (
+	oneRosterUserFields       = map[string]string{"dateLastModified": "updated_at"}
+	oneRosterClassFields      = map[string]string{"dateLastModified": "updated_at", "title": "class_name"}
+	oneRosterEnrollmentFields = map[string]string{"dateLastModified": "updated_at"}
+)
+
+// oneRosterOperators are the filter operators, longest first so that >=
+// is not read as >
+var oneRosterOperators = []struct {
+	token string
+	op    repository.FilterOp
+}{
+	{"!=", repository.OpNe},
+	{">=", repository.OpGte},
+	{"<=", repository.OpLte},
+	{"=", repository.OpEq},
+	{">", repository.OpGt},
+	{"<", repository.OpLt},
+	{"~", repository.OpLike},
+}
+
+// parseOneRosterQuery reads the OneRoster paging, sorting and filtering
+// parameters:
+//
+//	?limit=100&offset=200                       offset paging
+//	?sort=dateLastModified&orderBy=desc         sort
+//	?filter=dateLastModified>'2024-01-01'       filter; predicates may be joined with AND
+func parseOneRosterQuery(r *http.Request, fields map[string]string) (repository.QueryOptions, error) {
+	query := r.URL.Query()
+	opts := repository.QueryOptions{Limit: oneRosterDefaultLimit}
+	for _, key := range []string{"limit", "offset"} {
+		v := query.Get(key)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || (key == "limit" && n == 0) {
+			return opts, apperror.BadRequest(fmt.Sprintf("Invalid %s: must be a positive integer", key))
+		}
+		if key == "limit" {
+			opts.Limit = n
+		} else {
+			opts.Offset = n
+		}
+	}
+
+	if sort := query.Get("sort"); sort != "" {
+		field, ok := fields[sort]
+		if !ok {
+			return opts, apperror.New(http.StatusBadRequest, codeInvalidSortField, fmt.Sprintf("Cannot sort by %q", sort))
+		}
+		var desc bool
+		switch strings.ToLower(query.Get("orderBy")) {
+		case "", "asc":
+		case "desc":
+			desc = true
+		default:
+			return opts, apperror.BadRequest("Invalid orderBy: must be asc or desc")
+		}
+		opts.Sort = []repository.SortField{{Field: field, Desc: desc}}
+	}
+
+	if filter := strings.TrimSpace(query.Get("filter")); filter != "" {
+		if strings.Contains(filter, " OR ") {
+			return opts, apperror.New(http.StatusBadRequest, codeInvalidFilterField, "Filters joined with OR are not supported")
+		}
+		for _, predicate := range strings.Split(filter, " AND ") {
+			f, err := parseOneRosterPredicate(strings.TrimSpace(predicate), fields)
+			if err != nil {
+				return opts, err
+			}
+			opts.Filters = append(opts.Filters, f)
+		}
+	}
+	return opts, nil
+}
+
+// parseOneRosterPredicate parses one field-operator-value predicate such as
+// title~'math'
+func parseOneRosterPredicate(predicate string, fields map[string]string) (repository.Filter, error) {
+	for _, o := range oneRosterOperators {
+		i := strings.Index(predicate, o.token)
+		if i <= 0 {
+			continue
+		}
+		name, value := strings.TrimSpace(predicate[:i]), strings.TrimSpace(predicate[i+len(o.token):])
+		if strings.ContainsAny(name, "!<>=~") {
+			continue // an earlier operator character; try the next token
+		}
+		field, ok := fields[name]
+		if !ok {
+			return repository.Filter{}, apperror.New(http.StatusBadRequest, codeInvalidFilterField, fmt.Sprintf("Cannot filter by %q", name))
+		}
+		if len(value) < 2 || value[0] != '\'' || value[len(value)-1] != '\'' {
+			return repository.Filter{}, apperror.New(http.StatusBadRequest, codeInvalidFilterField,
+				fmt.Sprintf("Filter value for %s must be in single quotes", name))
+		}
+		return repository.Filter{Field: field, Op: o.op, Value: value[1 : len(value)-1]}, nil
+	}
+	return repository.Filter{}, apperror.New(http.StatusBadRequest, codeInvalidFilterField, fmt.Sprintf("Invalid filter %q", predicate))
+}
+
+// writeOneRosterPage writes a page of a OneRoster collection under key,
+// with the total in X-Total-Count and links to the next and last pages
+func writeOneRosterPage[T, R any](w http.ResponseWriter, r *http.Request, key string, page *repository.Page[T], toResponse func(*T) R) {
+	items := make([]R, len(page.Items))
+	for i := range page.Items {
+		items[i] = toResponse(&page.Items[i])
+	}
+
+	w.Header().Set("X-Total-Count", strconv.FormatInt(page.Total, 10))
+	link := func(offset int, rel string) string {
+		query := r.URL.Query()
+		query.Set("limit", strconv.Itoa(page.Limit))
+		query.Set("offset", strconv.Itoa(offset))
+		return fmt.Sprintf("<%s?%s>; rel=%q", r.URL.Path, query.Encode(), rel)
+	}
+	var links []string
+	if next := page.Offset + len(page.Items); int64(next) < page.Total {
+		links = append(links, link(next, "next"))
+	}
+	if page.Total > 0 {
+		links = append(links, link(int((page.Total-1)/int64(page.Limit))*page.Limit, "last"))
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+
+	writeOneRosterJSON(w, http.StatusOK, map[string][]R{key: items})
+}
+
+// writeOneRosterError reports err as an imsx_StatusInfo document
+func writeOneRosterError(w http.ResponseWriter, r *http.Request, err error) {
+	appErr := toAppError(r, err)
+	var codeMinor string
+	switch {
+	case appErr.Code == codeInvalidFilterField || appErr.Code == codeInvalidSortField:
+		codeMinor = string(appErr.Code)
+	case appErr.Status == http.StatusUnauthorized:
+		codeMinor = "unauthorisedrequest"
+	case appErr.Status == http.StatusForbidden:
+		codeMinor = "forbidden"
+	case appErr.Status == http.StatusNotFound:
+		codeMinor = "unknownobject"
+	case appErr.Status == http.StatusServiceUnavailable:
+		codeMinor = "server_busy"
+	case appErr.Status >= http.StatusInternalServerError:
+		codeMinor = "internal_server_error"
+	default:
+		codeMinor = "invalid_data"
+	}
+	writeOneRosterJSON(w, appErr.Status, oneroster.NewStatusInfo(codeMinor, appErr.Message))
+}
+
+// writeOneRosterJSON writes v as a OneRoster JSON response
+func writeOneRosterJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"school-api/apperror"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+)
+
+// Media types accepted by PATCH endpoints
+const (
+	mergePatchType = "application/merge-patch+json"
+	jsonPatchType  = "application/json-patch+json"
+)
+
+// patchRequest is a PATCH body that has been read but not yet applied
+type patchRequest struct {
+	mediaType string
+	body      []byte
+}
+
+// readPatch reads a PATCH body, checking that it is a JSON Merge Patch
+// (RFC 7396) or a JSON Patch (RFC 6902). Plain application/json is treated
+// as a merge patch.
+func readPatch(r *http.Request) (*patchRequest, error) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		mediaType = ""
+	}
+	switch mediaType {
+	case mergePatchType, jsonPatchType:
+	case "application/json":
+		mediaType = mergePatchType
+	default:
+		return nil, apperror.New(http.StatusUnsupportedMediaType, apperror.CodeUnsupportedMediaType,
+			"PATCH requires Content-Type "+mergePatchType+" or "+jsonPatchType)
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, apperror.BadRequest("Invalid request body")
+	}
+	return &patchRequest{mediaType: mediaType, body: body}, nil
+}
+
+// apply patches the JSON form of current and decodes the result into
+// target, with the same checks as decodeJSON
+func (p *patchRequest) apply(current, target any, readOnly ...string) error {
+	doc, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+
+	var patched []byte
+	switch p.mediaType {
+	case mergePatchType:
+		if !json.Valid(p.body) {
+			return apperror.BadRequest("Invalid merge patch document")
+		}
+		if patched, err = jsonpatch.MergePatch(doc, p.body); err != nil {
+			return apperror.Wrap(err, http.StatusBadRequest, apperror.CodeBadRequest, "Invalid merge patch document")
+		}
+	case jsonPatchType:
+		patch, err := jsonpatch.DecodePatch(p.body)
+		if err != nil {
+			return apperror.Wrap(err, http.StatusBadRequest, apperror.CodeBadRequest, "Invalid JSON patch document")
+		}
+		if patched, err = patch.Apply(doc); err != nil {
+			return patchError(err)
+		}
+	}
+	return decodeBody(patched, target, readOnly...)
+}
+
+// patchError reports a JSON patch that is well formed but cannot be applied
+// to the current document, such as a failed test or a missing path
+func patchError(err error) error {
+	message := "Patch cannot be applied"
+	switch {
+	case errors.Is(err, jsonpatch.ErrTestFailed):
+		message = "Patch test operation failed"
+	case errors.Is(err, jsonpatch.ErrMissing):
+		message = "Patch refers to a path that does not exist"
+	case errors.Is(err, jsonpatch.ErrUnknownType), errors.Is(err, jsonpatch.ErrInvalid):
+		return apperror.Wrap(err, http.StatusBadRequest, apperror.CodeBadRequest, "Invalid JSON patch document")
+	}
+	return apperror.Wrap(err, http.StatusConflict, apperror.CodePatchConflict, message)
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"net/http"
+	"school-api/apperror"
+	"strconv"
+	"strings"
+)
+
+// Preconditions configures how handlers treat conditional request headers
+type Preconditions struct {
+	// RequireIfMatch rejects PUT, PATCH and DELETE requests that do not
+	// send If-Match with 428 Precondition Required
+	RequireIfMatch bool
+}
+
+// etag formats a record version as a strong entity tag
+func etag(version uint) string {
+	return `"` + strconv.FormatUint(uint64(version), 10) + `"`
+}
+
+// setETag advertises the version of the record in the response
+func setETag(w http.ResponseWriter, version uint) {
+	w.Header().Set("ETag", etag(version))
+}
+
+// ifMatch returns the version named by the If-Match header. It returns 0
+// when the header is absent or "*", meaning any stored version is accepted.
+// required makes a missing header an error.
+func ifMatch(r *http.Request, required bool) (uint, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		if required {
+			return 0, apperror.New(http.StatusPreconditionRequired, apperror.CodePreconditionRequired,
+				"This request must be made conditional with If-Match")
+		}
+		return 0, nil
+	}
+	if header == "*" {
+		return 0, nil
+	}
+
+	tags := strings.Split(header, ",")
+	if len(tags) > 1 {
+		return 0, apperror.BadRequest("If-Match must name a single entity tag")
+	}
+	// If-Match uses strong comparison, so weak tags never match
+	tag := strings.TrimSpace(tags[0])
+	version, err := strconv.ParseUint(strings.Trim(tag, `"`), 10, 32)
+	if err != nil || strings.HasPrefix(tag, "W/") || version == 0 {
+		return 0, apperror.PreconditionFailed("If-Match does not match the current version")
+	}
+	return uint(version), nil
+}
+
+// notModified reports whether If-None-Match names the given version, in
+// which case it writes 304 Not Modified and the caller must not write a body
+func notModified(w http.ResponseWriter, r *http.Request, version uint) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+
+	current := etag(version)
+	for _, tag := range strings.Split(header, ",") {
+		// If-None-Match uses weak comparison
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == current {
+			setETag(w, version)
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
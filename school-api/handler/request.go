@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"school-api/apperror"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// decodeJSON decodes the request body into v, rejecting bodies that set any
+// of the given read-only fields or fields v does not have
+func decodeJSON(r *http.Request, v any, readOnly ...string) error {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return apperror.BadRequest("Invalid request body")
+	}
+	return decodeBody(body, v, readOnly...)
+}
+
+// decodeBody is decodeJSON for a body that has already been read
+func decodeBody(body []byte, v any, readOnly ...string) error {
+	if len(readOnly) > 0 {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(body, &fields); err != nil {
+			return apperror.BadRequest("Invalid request body")
+		}
+		for _, name := range readOnly {
+			if _, ok := fields[name]; ok {
+				return apperror.Validation(name+" is read-only",
+					apperror.FieldError{Field: name, Message: "is maintained by the server and cannot be set"})
+			}
+		}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		if strings.HasPrefix(err.Error(), "json: unknown field") {
+			return apperror.BadRequest("Invalid request body: " + strings.TrimPrefix(err.Error(), "json: "))
+		}
+		return apperror.BadRequest("Invalid request body")
+	}
+	return nil
+}
+
+// pathID parses the numeric ID from the path, writing a 400 if it is invalid
+func pathID(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		writeError(w, r, apperror.BadRequest("Invalid ID"))
+		return 0, false
+	}
+	return uint(id), true
+}
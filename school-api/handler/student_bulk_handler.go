@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"net/http"
+	"school-api/dto"
+	"school-api/models"
+	"school-api/service"
+)
+
+// @Summary Create students in bulk
+// @Description Create up to 1000 students. In atomic mode (default) all items are checked first and inserted in batches in one transaction; if any item is invalid nothing is stored and the errors point at items as [index].field. In partial mode valid items are stored and the response is 207 with a result per item.
+// @Tags students
+// @Accept json
+// @Produce json
+// @Param students body []dto.CreateStudentRequest true "Students to create"
+// @Param mode query string false "atomic or partial" Enums(atomic, partial)
+// @Success 201 {object} BulkResponse[dto.StudentResponse] "All created (atomic)"
+// @Success 207 {object} BulkResponse[dto.StudentResponse] "Per-item results (partial)"
+// @Failure 400 {object} apperror.Problem "Invalid request body or mode"
+// @Failure 401 {object} apperror.Problem "Missing or invalid access token"
+// @Failure 413 {object} apperror.Problem "Too many items"
+// @Failure 422 {object} apperror.Problem "Validation failed (atomic)"
+// @Failure 500 {object} apperror.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /api/students/bulk [post]
+func (h *studentHandler) BulkCreateStudents(w http.ResponseWriter, r *http.Request) {
+	mode, err := parseBulkMode(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var reqs []dto.CreateStudentRequest
+	if err := decodeJSON(r, &reqs); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := checkBulkSize(len(reqs)); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	students := make([]models.Student, len(reqs))
+	for i, req := range reqs {
+		students[i] = *req.ToModel()
+	}
+	results, err := h.studentService.BulkCreateStudents(r.Context(), students, mode)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeBulk(w, r, mode, http.StatusCreated, results, bulkStudentResponse)
+}
+
+// @Summary Update students in bulk
+// @Description Replace up to 1000 students in one transaction. Each item carries the student id and the version it was based on (412 if stale); the version may be left out unless the server requires If-Match (428). In atomic mode (default) the first failure rolls everything back; in partial mode failing items are rolled back on their own and the response is 207 with a result per item.
+// @Tags students
+// @Accept json
+// @Produce json
+// @Param students body []dto.BulkUpdateStudentRequest true "Students to update"
+// @Param mode query string false "atomic or partial" Enums(atomic, partial)
+// @Success 200 {object} BulkResponse[dto.StudentResponse] "All updated (atomic)"
+// @Success 207 {object} BulkResponse[dto.StudentResponse] "Per-item results (partial)"
+// @Failure 400 {object} apperror.Problem "Invalid request body or mode"
+// @Failure 401 {object} apperror.Problem "Missing or invalid access token"
+// @Failure 404 {object} apperror.Problem "A student was not found (atomic)"
+// @Failure 412 {object} apperror.Problem "A student has changed since the given version (atomic)"
+// @Failure 413 {object} apperror.Problem "Too many items"
+// @Failure 422 {object} apperror.Problem "Validation failed (atomic)"
+// @Failure 428 {object} apperror.Problem "An item has no version and the server requires one"
+// @Failure 500 {object} apperror.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /api/students/bulk [put]
+func (h *studentHandler) BulkUpdateStudents(w http.ResponseWriter, r *http.Request) {
+	mode, err := parseBulkMode(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var reqs []dto.BulkUpdateStudentRequest
+	if err := decodeJSON(r, &reqs); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := checkBulkSize(len(reqs)); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	students := make([]models.Student, len(reqs))
+	versions := make([]uint, len(reqs))
+	for i, req := range reqs {
+		students[i], versions[i] = *req.ToModel(), req.Version
+	}
+	if err := checkBulkVersions(h.preconditions.RequireIfMatch, versions); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	results, err := h.studentService.BulkUpdateStudents(r.Context(), students, mode)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeBulk(w, r, mode, http.StatusOK, results, bulkStudentResponse)
+}
+
+// @Summary Delete students in bulk
+// @Description Delete up to 1000 students in one transaction. Each item carries the student id and the version it was based on (412 if stale); the version may be left out unless the server requires If-Match (428). In atomic mode (default) the first failure rolls everything back; in partial mode the response is 207 with a result per item.
+// @Tags students
+// @Accept json
+// @Produce json
+// @Param students body []dto.BulkDeleteStudentRequest true "Students to delete"
+// @Param mode query string false "atomic or partial" Enums(atomic, partial)
+// @Success 200 {object} BulkResponse[dto.StudentResponse] "All deleted (atomic)"
+// @Success 207 {object} BulkResponse[dto.StudentResponse] "Per-item results (partial)"
+// @Failure 400 {object} apperror.Problem "Invalid request body or mode"
+// @Failure 401 {object} apperror.Problem "Missing or invalid access token"
+// @Failure 404 {object} apperror.Problem "A student was not found (atomic)"
+// @Failure 412 {object} apperror.Problem "A student has changed since the given version (atomic)"
+// @Failure 413 {object} apperror.Problem "Too many items"
+// @Failure 428 {object} apperror.Problem "An item has no version and the server requires one"
+// @Failure 500 {object} apperror.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /api/students/bulk [delete]
+func (h *studentHandler) BulkDeleteStudents(w http.ResponseWriter, r *http.Request) {
+	mode, err := parseBulkMode(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var reqs []dto.BulkDeleteStudentRequest
+	if err := decodeJSON(r, &reqs); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := checkBulkSize(len(reqs)); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	items := make([]service.BulkDeleteItem, len(reqs))
+	versions := make([]uint, len(reqs))
+	for i, req := range reqs {
+		items[i], versions[i] = req.ToItem(), req.Version
+	}
+	if err := checkBulkVersions(h.preconditions.RequireIfMatch, versions); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	results, err := h.studentService.BulkDeleteStudents(r.Context(), items, mode)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeBulk(w, r, mode, http.StatusOK, results, func(service.BulkResult) *dto.StudentResponse { return nil })
+}
+
+// bulkStudentResponse maps the student stored by a bulk item
+func bulkStudentResponse(result service.BulkResult) *dto.StudentResponse {
+	resp := dto.NewStudentResponse(result.Student)
+	return &resp
+}
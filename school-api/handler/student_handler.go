@@ -3,6 +3,8 @@ package handler
 import (
 	"encoding/json"
 	"net/http"
+	"school-api/apperror"
+	"school-api/dto"
 	"school-api/models"
 	"school-api/service"
 	"strconv"
@@ -13,18 +15,28 @@ import (
 type StudentHandler interface {
 	CreateStudent(w http.ResponseWriter, r *http.Request)
 	GetAllStudents(w http.ResponseWriter, r *http.Request)
+	ExportStudents(w http.ResponseWriter, r *http.Request)
 	GetStudentByID(w http.ResponseWriter, r *http.Request)
+	GetClassHistory(w http.ResponseWriter, r *http.Request)
 	UpdateStudent(w http.ResponseWriter, r *http.Request)
+	UpsertStudent(w http.ResponseWriter, r *http.Request)
+	PatchStudent(w http.ResponseWriter, r *http.Request)
 	DeleteStudent(w http.ResponseWriter, r *http.Request)
+	RestoreStudent(w http.ResponseWriter, r *http.Request)
+	BulkCreateStudents(w http.ResponseWriter, r *http.Request)
+	BulkUpdateStudents(w http.ResponseWriter, r *http.Request)
+	BulkDeleteStudents(w http.ResponseWriter, r *http.Request)
 }
 
 type studentHandler struct {
 	studentService service.StudentService
+	preconditions  Preconditions
 }
 
-func NewStudentHandler(studentService service.StudentService) StudentHandler {
+func NewStudentHandler(studentService service.StudentService, preconditions Preconditions) StudentHandler {
 	return &studentHandler{
 		studentService: studentService,
+		preconditions:  preconditions,
 	}
 }
 
@@ -33,70 +45,156 @@ func NewStudentHandler(studentService service.StudentService) StudentHandler {
 // @Tags students
 // @Accept json
 // @Produce json
-// @Param student body models.Student true "Student object to create"
-// @Success 201 {object} models.Student
-// @Failure 400 {string} string "Invalid request body"
-// @Failure 500 {string} string "Internal server error"
-// @Router /students [post]
+// @Param student body dto.CreateStudentRequest true "Student to create"
+// @Success 201 {object} dto.StudentResponse
+// @Failure 400 {object} apperror.Problem "Invalid request body"
+// @Failure 401 {object} apperror.Problem "Missing or invalid access token or API key"
+// @Failure 403 {object} apperror.Problem "Role or API key scopes lack the permission for this route"
+// @Failure 422 {object} apperror.Problem "Validation failed (per-field details in errors)"
+// @Failure 500 {object} apperror.Problem "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/students [post]
 func (h *studentHandler) CreateStudent(w http.ResponseWriter, r *http.Request) {
-	var student models.Student
-	if err := json.NewDecoder(r.Body).Decode(&student); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	var req dto.CreateStudentRequest
+	if err := decodeJSON(r, &req, "id"); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	if err := h.studentService.CreateStudent(&student); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	student := req.ToModel()
+	if err := h.studentService.CreateStudent(r.Context(), student); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
+	setETag(w, student.Version)
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(student)
+	json.NewEncoder(w).Encode(dto.NewStudentResponse(student))
 }
 
 // @Summary Get all students
-// @Description Get a list of all students
+// @Description Get a page of students. Filter with field=value or field[op]=value (eq, ne, gt, gte, lt, lte, like) on id, student_name, class_id and student_section. Teachers only see the students in classes they teach, guardians their linked students and students themselves.
 // @Tags students
 // @Produce json
-// @Success 200 {array} models.Student
-// @Failure 500 {string} string "Internal server error"
-// @Router /students [get]
+// @Param limit query int false "Page size (default 50, max 500)"
+// @Param offset query int false "Number of students to skip"
+// @Param cursor query string false "Cursor from a previous page's next_cursor"
+// @Param sort query string false "Comma-separated fields, prefix with - for descending (e.g. -student_name,id)"
+// @Param include_deleted query bool false "Include soft-deleted students (administrators only)"
+// @Success 200 {object} ListResponse[dto.StudentResponse]
+// @Failure 400 {object} apperror.Problem "Invalid query"
+// @Failure 401 {object} apperror.Problem "Missing or invalid access token or API key"
+// @Failure 403 {object} apperror.Problem "Role or API key scopes lack the permission for this route"
+// @Failure 500 {object} apperror.Problem "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/students [get]
 func (h *studentHandler) GetAllStudents(w http.ResponseWriter, r *http.Request) {
-	students, err := h.studentService.GetAllStudents()
+	opts, err := parseQueryOptions(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(students)
+	page, err := h.studentService.GetAllStudents(r.Context(), opts)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeList(w, r, page, dto.NewStudentResponse)
+}
+
+// @Summary Export students
+// @Description Download every student matching the filters and sort order of the list endpoint as CSV, Excel (XLSX) or newline-delimited JSON, chosen with format or the Accept header (CSV by default). Filter with field=value or field[op]=value on id, student_name, class_id and student_section. Rows are streamed from the database; paging parameters are ignored. In CSV files, text that would be taken for a formula (starting with =, +, -, @, tab or carriage return) is prefixed with an apostrophe; Excel files store it as plain text.
+// @Tags students
+// @Produce text/csv
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Produce application/x-ndjson
+// @Param format query string false "Export format, overriding the Accept header" Enums(csv, xlsx, ndjson)
+// @Param sort query string false "Comma-separated fields, prefix with - for descending"
+// @Param include_deleted query bool false "Include soft-deleted students (administrators only)"
+// @Success 200 {file} file
+// @Failure 400 {object} apperror.Problem "Invalid query or format"
+// @Failure 401 {object} apperror.Problem "Missing or invalid access token or API key"
+// @Failure 403 {object} apperror.Problem "Role or API key scopes lack the permission for this route"
+// @Failure 406 {object} apperror.Problem "No acceptable export format"
+// @Failure 500 {object} apperror.Problem "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/students/export [get]
+func (h *studentHandler) ExportStudents(w http.ResponseWriter, r *http.Request) {
+	writeExport(w, r, "students", dto.StudentColumns, h.studentService.ExportStudents,
+		dto.NewStudentResponse, dto.StudentResponse.Record)
 }
 
 // @Summary Get a student by ID
-// @Description Get a specific student by its ID
+// @Description Get a specific student by its ID. A student outside the caller's scope is reported as not found. The ETag header carries the student version; send it back in If-None-Match to get 304 when nothing changed.
 // @Tags students
 // @Produce json
 // @Param id path int true "Student ID"
-// @Success 200 {object} models.Student
-// @Failure 400 {string} string "Invalid ID"
-// @Failure 404 {string} string "Student not found"
-// @Router /students/{id} [get]
+// @Param If-None-Match header string false "ETag from a previous response"
+// @Success 200 {object} dto.StudentResponse
+// @Success 304 "Not Modified"
+// @Failure 400 {object} apperror.Problem "Invalid ID"
+// @Failure 401 {object} apperror.Problem "Missing or invalid access token or API key"
+// @Failure 403 {object} apperror.Problem "Role or API key scopes lack the permission for this route"
+// @Failure 404 {object} apperror.Problem "Student not found or outside the caller's scope"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/students/{id} [get]
 func (h *studentHandler) GetStudentByID(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseUint(vars["id"], 10, 32)
 	if err != nil {
-		http.Error(w, "Invalid student ID", http.StatusBadRequest)
+		writeError(w, r, apperror.BadRequest("Invalid student ID"))
 		return
 	}
 
-	student, err := h.studentService.GetStudentByID(uint(id))
+	student, err := h.studentService.GetStudentByID(r.Context(), uint(id))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		writeError(w, r, err)
+		return
+	}
+	if notModified(w, r, student.Version) {
 		return
 	}
 
+	setETag(w, student.Version)
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(student)
+	json.NewEncoder(w).Encode(dto.NewStudentResponse(student))
+}
+
+// @Summary Get a student's class history
+// @Description List every class the student has been in, oldest first, with when they joined and left it. The history is kept automatically as students are created, move class, are deleted and are restored, and stays when a deleted student is purged. Times are in UTC. History from before the server kept it is approximate: such students start in their current class from when the audit log last saw them join it, or from their last change.
+// @Tags students
+// @Produce json
+// @Param id path int true "Student ID"
+// @Success 200 {object} dto.ClassHistoryResponse
+// @Failure 400 {object} apperror.Problem "Invalid ID"
+// @Failure 401 {object} apperror.Problem "Missing or invalid access token or API key"
+// @Failure 403 {object} apperror.Problem "Role or API key scopes lack the permission for this route"
+// @Failure 404 {object} apperror.Problem "Student not found or outside the caller's scope"
+// @Failure 500 {object} apperror.Problem "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/students/{id}/class-history [get]
+func (h *studentHandler) GetClassHistory(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	enrollments, err := h.studentService.GetClassHistory(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.NewClassHistoryResponse(id, enrollments))
 }
 
 // @Summary Update a student
@@ -105,55 +203,236 @@ func (h *studentHandler) GetStudentByID(w http.ResponseWriter, r *http.Request)
 // @Accept json
 // @Produce json
 // @Param id path int true "Student ID"
-// @Param student body models.Student true "Student object to update"
-// @Success 200 {object} models.Student
-// @Failure 400 {string} string "Invalid request body"
-// @Failure 500 {string} string "Internal server error"
-// @Router /students/{id} [put]
+// @Param student body dto.UpdateStudentRequest true "New student details"
+// @Param If-Match header string false "ETag of the version being replaced (required if the server is configured so)"
+// @Success 200 {object} dto.StudentResponse
+// @Failure 400 {object} apperror.Problem "Invalid request body"
+// @Failure 401 {object} apperror.Problem "Missing or invalid access token or API key"
+// @Failure 403 {object} apperror.Problem "Role or API key scopes lack the permission for this route"
+// @Failure 404 {object} apperror.Problem "Student not found or outside the caller's scope"
+// @Failure 412 {object} apperror.Problem "Student has changed since the given ETag"
+// @Failure 428 {object} apperror.Problem "If-Match is required"
+// @Failure 422 {object} apperror.Problem "Validation failed (per-field details in errors)"
+// @Failure 500 {object} apperror.Problem "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/students/{id} [put]
 func (h *studentHandler) UpdateStudent(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseUint(vars["id"], 10, 32)
 	if err != nil {
-		http.Error(w, "Invalid student ID", http.StatusBadRequest)
+		writeError(w, r, apperror.BadRequest("Invalid student ID"))
 		return
 	}
 
-	var student models.Student
-	if err := json.NewDecoder(r.Body).Decode(&student); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	version, err := ifMatch(r, h.preconditions.RequireIfMatch)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	student.ID = uint(id)
-	if err := h.studentService.UpdateStudent(&student); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	var req dto.UpdateStudentRequest
+	if err := decodeJSON(r, &req, "id"); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
+	student := req.ToModel(uint(id))
+	student.Version = version
+	if err := h.studentService.UpdateStudent(r.Context(), student); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	setETag(w, student.Version)
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(student)
+	json.NewEncoder(w).Encode(dto.NewStudentResponse(student))
+}
+
+// @Summary Create or replace a student by ID
+// @Description Update the student with the given ID, or create it with that ID if it does not exist. If-Match is honoured when sent but, since this can create, never required.
+// @Tags students
+// @Accept json
+// @Produce json
+// @Param id path int true "Student ID"
+// @Param student body dto.UpdateStudentRequest true "Student details to store"
+// @Param If-Match header string false "ETag of the version being replaced"
+// @Success 200 {object} dto.StudentResponse "Updated"
+// @Success 201 {object} dto.StudentResponse "Created"
+// @Failure 400 {object} apperror.Problem "Invalid request body"
+// @Failure 401 {object} apperror.Problem "Missing or invalid access token or API key"
+// @Failure 403 {object} apperror.Problem "Role or API key scopes lack the permission for this route"
+// @Failure 404 {object} apperror.Problem "ID belongs to a deleted student or one outside the caller's scope"
+// @Failure 412 {object} apperror.Problem "Student has changed since the given ETag"
+// @Failure 422 {object} apperror.Problem "Validation failed (per-field details in errors)"
+// @Failure 500 {object} apperror.Problem "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/students/{id}/upsert [put]
+func (h *studentHandler) UpsertStudent(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil || id == 0 {
+		writeError(w, r, apperror.BadRequest("Invalid student ID"))
+		return
+	}
+
+	version, err := ifMatch(r, false)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var req dto.UpdateStudentRequest
+	if err := decodeJSON(r, &req, "id"); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	student := req.ToModel(uint(id))
+	student.Version = version
+	created, err := h.studentService.UpsertStudent(r.Context(), student)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	setETag(w, student.Version)
+	w.Header().Set("Content-Type", "application/json")
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(dto.NewStudentResponse(student))
+}
+
+// @Summary Partially update a student
+// @Description Apply a JSON Merge Patch (RFC 7396) or JSON Patch (RFC 6902) to the stored student. Fields the patch does not touch keep their stored values, and the result is validated like a PUT.
+// @Tags students
+// @Accept application/merge-patch+json,application/json-patch+json
+// @Produce json
+// @Param id path int true "Student ID"
+// @Param patch body object true "Merge patch object or JSON patch operation array"
+// @Param If-Match header string false "ETag of the version being patched (required if the server is configured so)"
+// @Success 200 {object} dto.StudentResponse
+// @Failure 400 {object} apperror.Problem "Invalid ID or patch document"
+// @Failure 401 {object} apperror.Problem "Missing or invalid access token or API key"
+// @Failure 403 {object} apperror.Problem "Role or API key scopes lack the permission for this route"
+// @Failure 404 {object} apperror.Problem "Student not found or outside the caller's scope"
+// @Failure 409 {object} apperror.Problem "Patch cannot be applied"
+// @Failure 412 {object} apperror.Problem "Student has changed since the given ETag"
+// @Failure 415 {object} apperror.Problem "Unsupported patch media type"
+// @Failure 422 {object} apperror.Problem "Validation failed or unknown class"
+// @Failure 428 {object} apperror.Problem "If-Match is required"
+// @Failure 500 {object} apperror.Problem "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/students/{id} [patch]
+func (h *studentHandler) PatchStudent(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		writeError(w, r, apperror.BadRequest("Invalid student ID"))
+		return
+	}
+
+	version, err := ifMatch(r, h.preconditions.RequireIfMatch)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	patch, err := readPatch(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	student, err := h.studentService.PatchStudent(r.Context(), uint(id), version, func(student *models.Student) error {
+		var req dto.UpdateStudentRequest
+		if err := patch.apply(dto.NewUpdateStudentRequest(student), &req, "id"); err != nil {
+			return err
+		}
+		*student = *req.ToModel(student.ID)
+		return nil
+	})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	setETag(w, student.Version)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.NewStudentResponse(student))
 }
 
 // @Summary Delete a student
 // @Description Delete a specific student by its ID
 // @Tags students
 // @Param id path int true "Student ID"
+// @Param If-Match header string false "ETag of the version being deleted (required if the server is configured so)"
 // @Success 204 "No Content"
-// @Failure 400 {string} string "Invalid ID"
-// @Failure 500 {string} string "Internal server error"
-// @Router /students/{id} [delete]
+// @Failure 400 {object} apperror.Problem "Invalid ID"
+// @Failure 401 {object} apperror.Problem "Missing or invalid access token or API key"
+// @Failure 403 {object} apperror.Problem "Role or API key scopes lack the permission for this route"
+// @Failure 404 {object} apperror.Problem "Student not found or outside the caller's scope"
+// @Failure 412 {object} apperror.Problem "Student has changed since the given ETag"
+// @Failure 428 {object} apperror.Problem "If-Match is required"
+// @Failure 500 {object} apperror.Problem "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/students/{id} [delete]
 func (h *studentHandler) DeleteStudent(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseUint(vars["id"], 10, 32)
 	if err != nil {
-		http.Error(w, "Invalid student ID", http.StatusBadRequest)
+		writeError(w, r, apperror.BadRequest("Invalid student ID"))
+		return
+	}
+
+	version, err := ifMatch(r, h.preconditions.RequireIfMatch)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	if err := h.studentService.DeleteStudent(uint(id)); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := h.studentService.DeleteStudent(r.Context(), uint(id), version); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
-} 
\ No newline at end of file
+}
+
+// @Summary Restore a deleted student
+// @Description Bring back a soft-deleted student. The student's class must exist and not be deleted.
+// @Tags students
+// @Produce json
+// @Param id path int true "Student ID"
+// @Success 200 {object} dto.StudentResponse
+// @Failure 400 {object} apperror.Problem "Invalid ID"
+// @Failure 401 {object} apperror.Problem "Missing or invalid access token or API key"
+// @Failure 403 {object} apperror.Problem "Role or API key scopes lack the permission for this route"
+// @Failure 404 {object} apperror.Problem "No deleted student with this ID"
+// @Failure 422 {object} apperror.Problem "The student's class no longer exists"
+// @Failure 500 {object} apperror.Problem "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/students/{id}/restore [post]
+func (h *studentHandler) RestoreStudent(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		writeError(w, r, apperror.BadRequest("Invalid student ID"))
+		return
+	}
+
+	student, err := h.studentService.RestoreStudent(r.Context(), uint(id))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	setETag(w, student.Version)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.NewStudentResponse(student))
+}
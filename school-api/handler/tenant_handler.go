@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"school-api/dto"
+	"school-api/repository"
+	"school-api/service"
+)
+
+type TenantHandler struct {
+	service service.TenantService
+}
+
+func NewTenantHandler(service service.TenantService) *TenantHandler {
+	return &TenantHandler{service: service}
+}
+
+// @Summary Get the current tenant
+// @Description Return the tenant the request is confined to, with its settings. The tenant is the one the access token or API key belongs to; otherwise it is named by the X-Tenant header or the subdomain, and is the default tenant when neither is given.
+// @Tags tenant
+// @Produce json
+// @Param X-Tenant header string false "Slug of the tenant, for requests not tied to one by their credentials"
+// @Success 200 {object} dto.TenantResponse
+// @Failure 401 {object} apperror.Problem "Missing or invalid access token"
+// @Failure 403 {object} apperror.Problem "Tenant is disabled or differs from the credentials' tenant"
+// @Failure 404 {object} apperror.Problem "Tenant not found"
+// @Failure 500 {object} apperror.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /api/tenant [get]
+func (h *TenantHandler) GetTenant(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := repository.TenantFrom(r.Context())
+	if !ok {
+		writeError(w, r, service.ErrTenantNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.NewTenantResponse(tenant))
+}
+
+// @Summary Update the current tenant's settings
+// @Description Replace the settings of the current tenant. Empty values fall back to the server configuration: delete_policy and reassign_to set the default for deleting this tenant's classes, and the oneroster fields name the organization its OneRoster records belong to.
+// @Tags tenant
+// @Accept json
+// @Produce json
+// @Param X-Tenant header string false "Slug of the tenant, for requests not tied to one by their credentials"
+// @Param settings body dto.UpdateTenantSettingsRequest true "New settings"
+// @Success 200 {object} dto.TenantResponse
+// @Failure 400 {object} apperror.Problem "Invalid request body or delete policy"
+// @Failure 401 {object} apperror.Problem "Missing or invalid access token"
+// @Failure 403 {object} apperror.Problem "Role may not administer the tenant"
+// @Failure 422 {object} apperror.Problem "Validation failed or reassign_to is not a class of the tenant"
+// @Failure 500 {object} apperror.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /api/tenant/settings [put]
+func (h *TenantHandler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	var req dto.UpdateTenantSettingsRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	tenant, err := h.service.UpdateSettings(r.Context(), req.ToInput())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.NewTenantResponse(tenant))
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"net"
+	"net/http"
+	"school-api/auth"
+	"school-api/repository"
+	"school-api/service"
+	"strings"
+)
+
+// TenantResolver confines each request to one tenant. The tenant of the
+// principal's token or API key wins; otherwise the tenant is named by a
+// header or by the subdomain of the host, and is the default tenant when
+// neither is present.
+type TenantResolver struct {
+	service    service.TenantService
+	header     string
+	baseDomain string
+}
+
+func NewTenantResolver(service service.TenantService, header, baseDomain string) *TenantResolver {
+	return &TenantResolver{service: service, header: header, baseDomain: baseDomain}
+}
+
+// Middleware resolves the tenant of API routes, reporting failures as problem documents
+func (t *TenantResolver) Middleware(next http.Handler) http.Handler {
+	return t.resolve(next, writeError)
+}
+
+// OneRosterMiddleware resolves the tenant of OneRoster routes, reporting
+// failures as imsx_StatusInfo documents
+func (t *TenantResolver) OneRosterMiddleware(next http.Handler) http.Handler {
+	return t.resolve(next, writeOneRosterError)
+}
+
+func (t *TenantResolver) resolve(next http.Handler, fail func(http.ResponseWriter, *http.Request, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, _ := auth.PrincipalFrom(r.Context())
+		tenant, err := t.service.Resolve(r.Context(), t.slug(r), principal)
+		if err != nil {
+			fail(w, r, err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(repository.WithTenant(r.Context(), tenant)))
+	})
+}
+
+// slug returns the tenant the request names, if any: the header, or the
+// first label of a host under the base domain
+func (t *TenantResolver) slug(r *http.Request) string {
+	if slug := r.Header.Get(t.header); slug != "" {
+		return slug
+	}
+	if t.baseDomain == "" {
+		return ""
+	}
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	sub, ok := strings.CutSuffix(strings.ToLower(host), "."+t.baseDomain)
+	if !ok || strings.Contains(sub, ".") {
+		return ""
+	}
+	return sub
+}
@@ -0,0 +1,204 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"school-api/dto"
+	"school-api/service"
+)
+
+type UserHandler struct {
+	service service.UserService
+}
+
+func NewUserHandler(service service.UserService) *UserHandler {
+	return &UserHandler{service: service}
+}
+
+// @Summary Create a user
+// @Description Create a user account with a role. Teachers see the classes they are set as teacher of and the students in them; guardians see the students linked to them and those students' classes; students see their own record and class. A user with the student role must be linked to a student record.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param user body dto.CreateUserRequest true "User to create"
+// @Success 201 {object} dto.UserResponse
+// @Failure 400 {object} apperror.Problem "Invalid request body"
+// @Failure 401 {object} apperror.Problem "Missing or invalid access token"
+// @Failure 403 {object} apperror.Problem "Role may not manage users"
+// @Failure 409 {object} apperror.Problem "Username is already taken"
+// @Failure 422 {object} apperror.Problem "Validation failed"
+// @Failure 500 {object} apperror.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /api/users [post]
+func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	var req dto.CreateUserRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	user, err := h.service.CreateUser(r.Context(), req.ToInput())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(dto.NewUserResponse(user))
+}
+
+// @Summary List users
+// @Description List every user account, ordered by username.
+// @Tags users
+// @Produce json
+// @Success 200 {array} dto.UserResponse
+// @Failure 401 {object} apperror.Problem "Missing or invalid access token"
+// @Failure 403 {object} apperror.Problem "Role may not manage users"
+// @Failure 500 {object} apperror.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /api/users [get]
+func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := h.service.ListUsers(r.Context())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	resp := make([]dto.UserResponse, len(users))
+	for i := range users {
+		resp[i] = dto.NewUserResponse(&users[i])
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// @Summary Get a user by ID
+// @Tags users
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} dto.UserResponse
+// @Failure 400 {object} apperror.Problem "Invalid ID"
+// @Failure 401 {object} apperror.Problem "Missing or invalid access token"
+// @Failure 403 {object} apperror.Problem "Role may not manage users"
+// @Failure 404 {object} apperror.Problem "User not found"
+// @Security BearerAuth
+// @Router /api/users/{id} [get]
+func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := h.service.GetUser(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.NewUserResponse(user))
+}
+
+// @Summary Update a user
+// @Description Change a user's role, student link and disabled flag. Access tokens already issued keep the old role until they expire; disabling a user revokes their refresh tokens. The last enabled administrator cannot be demoted or disabled.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param user body dto.UpdateUserRequest true "New role and status"
+// @Success 200 {object} dto.UserResponse
+// @Failure 400 {object} apperror.Problem "Invalid ID or request body"
+// @Failure 401 {object} apperror.Problem "Missing or invalid access token"
+// @Failure 403 {object} apperror.Problem "Role may not manage users"
+// @Failure 404 {object} apperror.Problem "User not found"
+// @Failure 409 {object} apperror.Problem "The change would leave no enabled administrator"
+// @Failure 422 {object} apperror.Problem "Validation failed"
+// @Failure 500 {object} apperror.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /api/users/{id} [put]
+func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	var req dto.UpdateUserRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	user, err := h.service.UpdateUser(r.Context(), id, req.ToInput())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.NewUserResponse(user))
+}
+
+// @Summary Get a guardian's students
+// @Description Get the IDs of the students linked to a guardian.
+// @Tags users
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} dto.GuardianStudentsResponse
+// @Failure 400 {object} apperror.Problem "Invalid ID"
+// @Failure 401 {object} apperror.Problem "Missing or invalid access token"
+// @Failure 403 {object} apperror.Problem "Role may not manage users"
+// @Failure 404 {object} apperror.Problem "User not found"
+// @Security BearerAuth
+// @Router /api/users/{id}/students [get]
+func (h *UserHandler) GetGuardianStudents(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	ids, err := h.service.GetGuardianStudents(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.NewGuardianStudentsResponse(id, ids))
+}
+
+// @Summary Set a guardian's students
+// @Description Replace the students linked to a user with the guardian role. The guardian sees exactly these students and their classes.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param students body dto.GuardianStudentsRequest true "IDs of the linked students"
+// @Success 200 {object} dto.GuardianStudentsResponse
+// @Failure 400 {object} apperror.Problem "Invalid ID or request body"
+// @Failure 401 {object} apperror.Problem "Missing or invalid access token"
+// @Failure 403 {object} apperror.Problem "Role may not manage users"
+// @Failure 404 {object} apperror.Problem "User not found"
+// @Failure 422 {object} apperror.Problem "User is not a guardian or a student does not exist"
+// @Failure 500 {object} apperror.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /api/users/{id}/students [put]
+func (h *UserHandler) SetGuardianStudents(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	var req dto.GuardianStudentsRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	ids, err := h.service.SetGuardianStudents(r.Context(), id, req.StudentIDs)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.NewGuardianStudentsResponse(id, ids))
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"school-api/config"
+	"school-api/database"
+	"school-api/oneroster"
+	"school-api/repository"
+	"school-api/service"
+	"school-api/spreadsheet"
+	"strings"
+	"text/tabwriter"
+)
+
+// errImportBlocked is returned when conflicts or invalid rows stop an import
+var errImportBlocked = errors.New("roster has conflicts or invalid rows; nothing was imported")
+
+// runImport implements "school-api import [flags] FILE", which imports a
+// roster spreadsheet or a OneRoster CSV bundle (.zip) straight into the
+// database configured by the usual flags
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("school-api import", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "report the changes without applying them")
+	formatName := fs.String("format", "", "file format, csv, xlsx or oneroster (default: from the file extension)")
+	tenant := fs.String("tenant", "default", "slug of the tenant to import into")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: school-api import [flags] FILE")
+		fs.PrintDefaults()
+	}
+	cfg, err := config.LoadFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("import needs exactly one roster file")
+	}
+	path := fs.Arg(0)
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var load func(context.Context, repository.UnitOfWork) (*service.ImportReport, error)
+	opts := service.ImportOptions{DryRun: *dryRun}
+	if *formatName == "oneroster" || (*formatName == "" && strings.EqualFold(filepath.Ext(path), ".zip")) {
+		info, err := file.Stat()
+		if err != nil {
+			return err
+		}
+		bundle, err := oneroster.ReadBundle(file, info.Size())
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		load = func(ctx context.Context, uow repository.UnitOfWork) (*service.ImportReport, error) {
+			return service.NewOneRosterService(uow, cfg.OneRosterOrg()).ImportCSV(ctx, bundle, opts)
+		}
+	} else {
+		format, err := spreadsheet.FormatFromFilename(path)
+		if *formatName != "" {
+			format, err = spreadsheet.ParseFormat(*formatName)
+		}
+		if err != nil {
+			return err
+		}
+		sheet, err := spreadsheet.ReadSheet(file, format)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		load = func(ctx context.Context, uow repository.UnitOfWork) (*service.ImportReport, error) {
+			return service.NewImportService(uow).ImportRoster(ctx, sheet, opts)
+		}
+	}
+
+	db, err := database.Open(cfg.Database.Driver, cfg.Database.DSN)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer database.Close(db)
+	if err := database.Migrate(db); err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	uow := repository.NewUnitOfWork(db, cfg.RepositoryTimeouts())
+	ctx, err := tenantContext(context.Background(), uow, *tenant)
+	if err != nil {
+		return err
+	}
+	report, err := load(ctx, uow)
+	if err != nil {
+		return err
+	}
+
+	printImportReport(os.Stdout, report)
+	if report.Blocked() {
+		return errImportBlocked
+	}
+	return nil
+}
+
+// printImportReport writes a report as a table followed by a summary line
+func printImportReport(out io.Writer, report *service.ImportReport) {
+	if len(report.IgnoredColumns) > 0 {
+		fmt.Fprintf(out, "Ignored columns: %s\n\n", strings.Join(report.IgnoredColumns, ", "))
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "LINE\tENTITY\tACTION\tID\tNAME\tDETAILS")
+	for _, item := range report.Items {
+		id := ""
+		if item.ID != 0 {
+			id = fmt.Sprint(item.ID)
+		}
+		details := item.Message
+		for _, c := range item.Changes {
+			if details != "" {
+				details += "; "
+			}
+			details += fmt.Sprintf("%s: %q -> %q", c.Field, c.From, c.To)
+		}
+		line := fmt.Sprint(item.Line)
+		switch {
+		case item.File != "" && item.Line == 0:
+			line = item.File
+		case item.File != "":
+			line = item.File + ":" + line
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", line, item.Entity, item.Action, id, item.Name, details)
+	}
+	tw.Flush()
+
+	s := report.Summary
+	fmt.Fprintf(out, "\n%d to create, %d to update, %d unchanged, %d to delete, %d conflicts, %d invalid, %d skipped\n",
+		s.Creates, s.Updates, s.Unchanged, s.Deletes, s.Conflicts, s.Invalid, s.Skipped)
+	switch {
+	case report.Committed:
+		fmt.Fprintln(out, "Import committed.")
+	case report.DryRun:
+		fmt.Fprintln(out, "Dry run: nothing was written.")
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"school-api/auth"
+	"school-api/dto"
+	"school-api/handler"
+	"school-api/models"
+	"school-api/service"
+	"testing"
+)
+
+// importFixture is a class with a few students for rosters to match against
+type importFixture struct {
+	*testApp
+	token string
+	class dto.ClassResponse
+	jane  dto.StudentResponse
+}
+
+func newImportFixture(t *testing.T) *importFixture {
+	a := newTestApp(t)
+	f := &importFixture{testApp: a, token: a.login("default", "admin", auth.RoleAdmin)}
+	f.class = decode[dto.ClassResponse](t, expect(t, a.do(t, f.token, http.MethodPost, "/api/classes",
+		dto.CreateClassRequest{ClassName: "Grade 5"}), http.StatusCreated))
+	for _, name := range []string{"Jane Doe", "John Doe", "John Doe"} {
+		s := decode[dto.StudentResponse](t, expect(t, a.do(t, f.token, http.MethodPost, "/api/students",
+			dto.CreateStudentRequest{StudentName: name, ClassID: f.class.ID, Section: "A"}), http.StatusCreated))
+		if name == "Jane Doe" {
+			f.jane = s
+		}
+	}
+	return f
+}
+
+func (f *importFixture) importRoster(t *testing.T, query, roster string, status int) handler.ImportResponse {
+	t.Helper()
+	return decode[handler.ImportResponse](t, expect(t, f.do(t, f.token, http.MethodPost, "/api/import"+query, roster,
+		"Content-Type", "text/csv"), status))
+}
+
+func (f *importFixture) count(t *testing.T, model any) int64 {
+	t.Helper()
+	var n int64
+	if err := f.db.Model(model).Count(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// TestImportDryRun checks that a dry run reports what an import would do
+// without doing it, and that the import then does just that
+func TestImportDryRun(t *testing.T) {
+	f := newImportFixture(t)
+	roster := fmt.Sprintf("class_name,student_name,student_section,id\n"+
+		"Grade 5,Jane Doe,B,%d\n"+
+		"Grade 6,Ann Lee,A,\n", f.jane.ID)
+	students, audits := f.count(t, &models.Student{}), f.count(t, &models.AuditEntry{})
+
+	dry := f.importRoster(t, "?dry_run=true", roster, http.StatusOK)
+	want := handler.ImportSummary{Creates: 2, Updates: 1}
+	if !dry.DryRun || dry.Committed || dry.Summary != want {
+		t.Errorf("dry run reported %+v committed=%v, want %+v uncommitted", dry.Summary, dry.Committed, want)
+	}
+	if got := f.count(t, &models.Student{}); got != students {
+		t.Errorf("dry run left %d students, want %d", got, students)
+	}
+	if got := f.count(t, &models.AuditEntry{}); got != audits {
+		t.Errorf("dry run wrote %d audit entries", got-audits)
+	}
+	jane := decode[dto.StudentResponse](t, expect(t, f.do(t, f.token, http.MethodGet,
+		fmt.Sprintf("/api/students/%d", f.jane.ID), nil), http.StatusOK))
+	if jane.Section != "A" || jane.Version != f.jane.Version {
+		t.Errorf("dry run changed Jane to %+v", jane)
+	}
+	for _, item := range dry.Items {
+		if item.Action == string(service.ImportUpdate) && (len(item.Changes) != 1 || item.Changes[0].Field != "student_section") {
+			t.Errorf("dry run reports update of %s %q as %+v, want only student_section", item.Entity, item.Name, item.Changes)
+		}
+	}
+
+	done := f.importRoster(t, "", roster, http.StatusOK)
+	if done.DryRun || !done.Committed || done.Summary != dry.Summary {
+		t.Errorf("import reported %+v committed=%v, want what the dry run reported: %+v", done.Summary, done.Committed, dry.Summary)
+	}
+	jane = decode[dto.StudentResponse](t, expect(t, f.do(t, f.token, http.MethodGet,
+		fmt.Sprintf("/api/students/%d", f.jane.ID), nil), http.StatusOK))
+	if jane.Section != "B" {
+		t.Errorf("Jane is in section %s after the import, want B", jane.Section)
+	}
+	if got := f.count(t, &models.Student{}); got != students+1 {
+		t.Errorf("import left %d students, want %d", got, students+1)
+	}
+
+	again := f.importRoster(t, "", roster, http.StatusOK)
+	if want := (handler.ImportSummary{Unchanged: 2}); again.Summary != want {
+		t.Errorf("importing the roster again reported %+v, want %+v", again.Summary, want)
+	}
+}
+
+// TestImportConflicts checks that rows which cannot be matched unambiguously
+// or fail validation block the whole import, and are reported by line
+func TestImportConflicts(t *testing.T) {
+	f := newImportFixture(t)
+	roster := fmt.Sprintf("class_name,student_name,student_section,id\n"+
+		"Grade 5,Jane Doe,B,%d\n"+ // line 2: fine on its own
+		"Grade 5,Max Mustermann,A,9999\n"+ // line 3: no such student
+		"Grade 5,John Doe,B,\n"+ // line 4: two John Does
+		"Grade 5,Jane Doe,C,%d\n"+ // line 5: Jane again
+		"Grade 5,Ann Lee,Z,\n"+ // line 6: no section Z
+		"Grade 7,Ben Lee,A,\n", // line 7: fine on its own
+		f.jane.ID, f.jane.ID)
+	classes, students := f.count(t, &models.Class{}), f.count(t, &models.Student{})
+
+	for _, query := range []string{"?dry_run=true", ""} {
+		report := f.importRoster(t, query, roster, http.StatusUnprocessableEntity)
+		if report.Committed || report.Summary.Conflicts != 3 || report.Summary.Invalid != 1 {
+			t.Errorf("import%s reported %+v committed=%v, want 3 conflicts and 1 invalid row", query, report.Summary, report.Committed)
+		}
+		lines := map[int]string{}
+		for _, item := range report.Items {
+			if item.Action == string(service.ImportConflict) || item.Action == string(service.ImportInvalid) {
+				lines[item.Line] = item.Action
+			}
+		}
+		want := map[int]string{3: "conflict", 4: "conflict", 5: "conflict", 6: "invalid"}
+		if fmt.Sprint(lines) != fmt.Sprint(want) {
+			t.Errorf("import%s reports problems on lines %v, want %v", query, lines, want)
+		}
+	}
+
+	if got := f.count(t, &models.Class{}); got != classes {
+		t.Errorf("blocked import left %d classes, want %d", got, classes)
+	}
+	if got := f.count(t, &models.Student{}); got != students {
+		t.Errorf("blocked import left %d students, want %d", got, students)
+	}
+}
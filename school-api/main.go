@@ -1,98 +1,342 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"school-api/auth"
+	"school-api/config"
+	"school-api/database"
 	"school-api/docs"
 	"school-api/handler"
-	"school-api/models"
+	"school-api/oneroster"
 	"school-api/repository"
 	"school-api/service"
+	"syscall"
 	"time"
-	"os/exec"
-	"runtime"
 
 	"github.com/gorilla/mux"
 	httpSwagger "github.com/swaggo/http-swagger"
-	"gorm.io/driver/sqlserver"
 	"gorm.io/gorm"
 )
 
+// usageFlushInterval is how often API key usage is written to the database
+const usageFlushInterval = time.Minute
+
 // @title School API
 // @version 1.0
 // @description This is a sample school API server.
 // @host localhost:8081
-// @BasePath /api
+// @BasePath /
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description Access token from POST /api/auth/login, sent as "Bearer <token>"
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-API-Key
+// @description API key from POST /api/api-keys; it may also be sent as "Authorization: Bearer <key>"
 func main() {
+	// Subcommands
+	if len(os.Args) > 1 {
+		var command func([]string) error
+		switch os.Args[1] {
+		case "import":
+			command = runImport
+		case "create-user":
+			command = runCreateUser
+		case "create-tenant":
+			command = runCreateTenant
+		}
+		if command != nil {
+			if err := command(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+
+	// Load configuration
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := run(cfg); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run starts the server and blocks until it is shut down by SIGINT or SIGTERM
+func run(cfg *config.Config) error {
 	// Swagger documentation setup
 	docs.SwaggerInfo.Title = "School API"
 	docs.SwaggerInfo.Description = "This is a sample school API server."
 	docs.SwaggerInfo.Version = "1.0"
-	docs.SwaggerInfo.Host = "localhost:8081"
-	docs.SwaggerInfo.BasePath = "/api"
+	docs.SwaggerInfo.Host = cfg.Swagger.Host
+	docs.SwaggerInfo.BasePath = "/"
 	docs.SwaggerInfo.Schemes = []string{"http"}
 
 	// Database connection
-	dsn := "sqlserver://(local)?database=schooldb&trusted_connection=true&trustservercertificate=true&encrypt=false"
-	db, err := gorm.Open(sqlserver.Open(dsn), &gorm.Config{})
+	db, err := database.Open(cfg.Database.Driver, cfg.Database.DSN)
 	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+		return fmt.Errorf("failed to connect to database: %w", err)
 	}
+	defer func() {
+		if err := database.Close(db); err != nil {
+			log.Printf("Failed to close database: %v", err)
+		}
+		log.Println("Database connections closed")
+	}()
 
 	// Auto Migrate the schema (only creates tables if they don't exist)
-	err = db.AutoMigrate(&models.Class{}, &models.Student{})
-	if err != nil {
-		log.Fatal("Failed to migrate database:", err)
+	if err := database.Migrate(db); err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
 	}
 
 	// Initialize repositories
-	classRepo := repository.NewClassRepository(db)
-	studentRepo := repository.NewStudentRepository(db)
+	timeouts := cfg.RepositoryTimeouts()
+	uow := repository.NewUnitOfWork(db, timeouts)
+
+	// Bring stored student counts in line with actual enrollments
+	if err := uow.Classes().RefreshStudentCounts(context.Background()); err != nil {
+		return fmt.Errorf("failed to refresh student counts: %w", err)
+	}
+
+	app, err := newApp(cfg, db, uow)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{
+		Addr:         cfg.Addr(),
+		Handler:      app.router,
+		ReadTimeout:  cfg.Server.ReadTimeout.Std(),
+		WriteTimeout: cfg.Server.WriteTimeout.Std(),
+		IdleTimeout:  cfg.Server.IdleTimeout.Std(),
+	}
+
+	// Bind the port before serving so the server is known to be reachable
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Start server in a goroutine
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.Serve(listener)
+	}()
+	app.health.SetReady(true)
+	log.Printf("Server listening on %s", listener.Addr())
+
+	// Purge records deleted longer ago than the retention period
+	if interval := cfg.Purge.Interval.Std(); interval > 0 {
+		go app.purge.Run(ctx, interval)
+	}
+	go app.apiKeys.Run(ctx, usageFlushInterval)
+
+	// Open Swagger in default browser
+	if cfg.Swagger.OpenBrowser {
+		openBrowser(cfg.SwaggerURL())
+	}
+
+	// Wait for a shutdown signal or a server failure
+	select {
+	case err := <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	case <-ctx.Done():
+	}
+	stop()
+
+	log.Println("Shutting down, draining in-flight requests...")
+	app.health.SetReady(false)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout.Std())
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		server.Close()
+		return fmt.Errorf("graceful shutdown did not complete: %w", err)
+	}
+	if err := app.apiKeys.FlushUsage(shutdownCtx); err != nil {
+		log.Printf("Recording API key usage failed: %v", err)
+	}
+	log.Println("Server stopped")
+	return nil
+}
+
+// app is the routes of the server along with the services its background
+// jobs run on
+type app struct {
+	router  *mux.Router
+	health  *handler.HealthHandler
+	purge   service.PurgeService
+	apiKeys service.APIKeyService
+}
+
+// newApp wires the services and handlers on top of uow to their routes
+func newApp(cfg *config.Config, db *gorm.DB, uow repository.UnitOfWork) (*app, error) {
+	// Token keys
+	keys, err := cfg.AuthKeys()
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.Auth.Keys) == 0 {
+		log.Println("No auth keys configured; signing tokens with a random key that is lost on restart")
+	}
+	issuer := auth.NewIssuer(keys, cfg.Auth.Issuer, cfg.Auth.AccessTokenTTL.Std())
 
 	// Initialize services
-	classService := service.NewClassService(classRepo)
-	studentService := service.NewStudentService(studentRepo)
+	classService := service.NewClassService(uow, cfg.ClassDeleteOptions())
+	studentService := service.NewStudentService(uow)
+	purgeService := service.NewPurgeService(uow, cfg.Purge.Retention.Std())
+	importService := service.NewImportService(uow)
+	oneRosterService := service.NewOneRosterService(uow, cfg.OneRosterOrg())
+	authService := service.NewAuthService(uow, issuer, cfg.Auth.RefreshTokenTTL.Std())
+	userService := service.NewUserService(uow)
+	apiKeyService := service.NewAPIKeyService(uow)
+	tenantService := service.NewTenantService(uow)
+	auditService := service.NewAuditService(uow)
 
 	// Initialize handlers
-	classHandler := handler.NewClassHandler(classService)
-	studentHandler := handler.NewStudentHandler(studentService)
+	classHandler := handler.NewClassHandler(classService, cfg.Preconditions())
+	studentHandler := handler.NewStudentHandler(studentService, cfg.Preconditions())
+	adminHandler := handler.NewAdminHandler(purgeService)
+	importHandler := handler.NewImportHandler(importService)
+	oneRosterHandler := handler.NewOneRosterHandler(oneRosterService, cfg.OneRosterOrg())
+	authHandler := handler.NewAuthHandler(authService, keys)
+	userHandler := handler.NewUserHandler(userService)
+	apiKeyHandler := handler.NewAPIKeyHandler(apiKeyService)
+	tenantHandler := handler.NewTenantHandler(tenantService)
+	auditHandler := handler.NewAuditHandler(auditService)
+	healthHandler := handler.NewHealthHandler(db)
+	authenticator := handler.NewAuthenticator(issuer, apiKeyService)
+	tenants := cfg.TenantResolver(tenantService)
 
 	// Router setup
 	router := mux.NewRouter()
 
+	// Health checks
+	router.HandleFunc("/healthz", healthHandler.Live).Methods("GET")
+	router.HandleFunc("/readyz", healthHandler.Ready).Methods("GET")
+
 	// Swagger documentation
-	router.PathPrefix("/swagger/").Handler(httpSwagger.Handler(
-		httpSwagger.URL("http://localhost:8081/swagger/doc.json"),
+	swagger := router.PathPrefix("/swagger/").Subrouter()
+	if cfg.Auth.Enabled && cfg.Auth.ProtectSwagger {
+		swagger.Use(authenticator.Middleware)
+	}
+	swagger.PathPrefix("/").Handler(httpSwagger.Handler(
+		httpSwagger.URL(cfg.SwaggerURL()+"doc.json"),
 		httpSwagger.DeepLinking(true),
 		httpSwagger.DocExpansion("none"),
 		httpSwagger.DomID("swagger-ui"),
 	))
 
+	// Auth Routes, open to anonymous clients
+	router.HandleFunc("/api/auth/login", authHandler.Login).Methods("POST")
+	router.HandleFunc("/api/auth/refresh", authHandler.Refresh).Methods("POST")
+	router.HandleFunc("/api/auth/logout", authHandler.Logout).Methods("POST")
+	router.HandleFunc("/.well-known/jwks.json", authHandler.JWKS).Methods("GET")
+
+	// Every other API and OneRoster route needs an access token or API key,
+	// and each needs a permission of the token's role or the key's scopes
+	api := router.PathPrefix("/api").Subrouter()
+	oneRoster := router.PathPrefix(oneroster.BasePath).Subrouter()
+	if cfg.Auth.Enabled {
+		api.Use(authenticator.Middleware)
+		oneRoster.Use(authenticator.OneRosterMiddleware)
+	} else {
+		log.Println("WARNING: auth.enabled is false; every API and OneRoster route is open to anyone, for any tenant")
+	}
+	// Each request then only reaches the data of its tenant
+	api.Use(tenants.Middleware)
+	oneRoster.Use(tenants.OneRosterMiddleware)
+	can, canSync := authenticator.Require, authenticator.RequireOneRoster
+	api.HandleFunc("/auth/me", authHandler.Me).Methods("GET")
+
 	// Class Routes
-	router.HandleFunc("/api/classes", classHandler.CreateClass).Methods("POST")
-	router.HandleFunc("/api/classes", classHandler.GetAllClasses).Methods("GET")
-	router.HandleFunc("/api/classes/{id}", classHandler.GetClassByID).Methods("GET")
-	router.HandleFunc("/api/classes/{id}", classHandler.UpdateClass).Methods("PUT")
-	router.HandleFunc("/api/classes/{id}", classHandler.DeleteClass).Methods("DELETE")
+	api.HandleFunc("/classes", can(auth.PermClassesWrite, classHandler.CreateClass)).Methods("POST")
+	api.HandleFunc("/classes", can(auth.PermClassesRead, classHandler.GetAllClasses)).Methods("GET")
+	api.HandleFunc("/classes/export", can(auth.PermClassesRead, classHandler.ExportClasses)).Methods("GET")
+	api.HandleFunc("/classes/{id}", can(auth.PermClassesRead, classHandler.GetClassByID)).Methods("GET")
+	api.HandleFunc("/classes/{id}/roster", can(auth.PermStudentsRead, classHandler.GetRoster)).Methods("GET")
+	api.HandleFunc("/classes/{id}", can(auth.PermClassesWrite, classHandler.UpdateClass)).Methods("PUT")
+	api.HandleFunc("/classes/{id}", can(auth.PermClassesWrite, classHandler.PatchClass)).Methods("PATCH")
+	api.HandleFunc("/classes/{id}/upsert", can(auth.PermClassesWrite, classHandler.UpsertClass)).Methods("PUT")
+	api.HandleFunc("/classes/{id}", can(auth.PermClassesWrite, classHandler.DeleteClass)).Methods("DELETE")
+	api.HandleFunc("/classes/{id}/restore", can(auth.PermClassesWrite, classHandler.RestoreClass)).Methods("POST")
 
 	// Student Routes
-	router.HandleFunc("/api/students", studentHandler.CreateStudent).Methods("POST")
-	router.HandleFunc("/api/students", studentHandler.GetAllStudents).Methods("GET")
-	router.HandleFunc("/api/students/{id}", studentHandler.GetStudentByID).Methods("GET")
-	router.HandleFunc("/api/students/{id}", studentHandler.UpdateStudent).Methods("PUT")
-	router.HandleFunc("/api/students/{id}", studentHandler.DeleteStudent).Methods("DELETE")
+	api.HandleFunc("/students", can(auth.PermStudentsWrite, studentHandler.CreateStudent)).Methods("POST")
+	api.HandleFunc("/students", can(auth.PermStudentsRead, studentHandler.GetAllStudents)).Methods("GET")
+	api.HandleFunc("/students/export", can(auth.PermStudentsRead, studentHandler.ExportStudents)).Methods("GET")
+	api.HandleFunc("/students/bulk", can(auth.PermStudentsWrite, studentHandler.BulkCreateStudents)).Methods("POST")
+	api.HandleFunc("/students/bulk", can(auth.PermStudentsWrite, studentHandler.BulkUpdateStudents)).Methods("PUT")
+	api.HandleFunc("/students/bulk", can(auth.PermStudentsWrite, studentHandler.BulkDeleteStudents)).Methods("DELETE")
+	api.HandleFunc("/students/{id}", can(auth.PermStudentsRead, studentHandler.GetStudentByID)).Methods("GET")
+	api.HandleFunc("/students/{id}/class-history", can(auth.PermStudentsRead, studentHandler.GetClassHistory)).Methods("GET")
+	api.HandleFunc("/students/{id}", can(auth.PermStudentsWrite, studentHandler.UpdateStudent)).Methods("PUT")
+	api.HandleFunc("/students/{id}", can(auth.PermStudentsWrite, studentHandler.PatchStudent)).Methods("PATCH")
+	api.HandleFunc("/students/{id}/upsert", can(auth.PermStudentsWrite, studentHandler.UpsertStudent)).Methods("PUT")
+	api.HandleFunc("/students/{id}", can(auth.PermStudentsWrite, studentHandler.DeleteStudent)).Methods("DELETE")
+	api.HandleFunc("/students/{id}/restore", can(auth.PermStudentsWrite, studentHandler.RestoreStudent)).Methods("POST")
 
-	// Start server in a goroutine
-	go func() {
-		log.Println("Server starting on port 8081...")
-		log.Fatal(http.ListenAndServe(":8081", router))
-	}()
+	// Import Routes
+	api.HandleFunc("/import", can(auth.PermRosterImport, importHandler.ImportRoster)).Methods("POST")
 
-	// Wait for server to start
-	time.Sleep(1 * time.Second)
+	// OneRoster Routes
+	oneRoster.HandleFunc("/users", canSync(auth.PermRosterSync, oneRosterHandler.GetUsers)).Methods("GET")
+	oneRoster.HandleFunc("/users/{sourcedId}", canSync(auth.PermRosterSync, oneRosterHandler.GetUser)).Methods("GET")
+	oneRoster.HandleFunc("/classes", canSync(auth.PermRosterSync, oneRosterHandler.GetClasses)).Methods("GET")
+	oneRoster.HandleFunc("/classes/{sourcedId}", canSync(auth.PermRosterSync, oneRosterHandler.GetClass)).Methods("GET")
+	oneRoster.HandleFunc("/classes/{sourcedId}/students", canSync(auth.PermRosterSync, oneRosterHandler.GetStudentsForClass)).Methods("GET")
+	oneRoster.HandleFunc("/enrollments", canSync(auth.PermRosterSync, oneRosterHandler.GetEnrollments)).Methods("GET")
+	oneRoster.HandleFunc("/enrollments/{sourcedId}", canSync(auth.PermRosterSync, oneRosterHandler.GetEnrollment)).Methods("GET")
+	oneRoster.HandleFunc("/csv", canSync(auth.PermRosterSync, oneRosterHandler.ExportCSV)).Methods("GET")
+	oneRoster.HandleFunc("/csv", canSync(auth.PermRosterSync, oneRosterHandler.ImportCSV)).Methods("POST")
 
-	// Open Swagger in default browser
-	url := "http://localhost:8081/swagger/"
+	// User Routes
+	api.HandleFunc("/users", can(auth.PermUsersManage, userHandler.CreateUser)).Methods("POST")
+	api.HandleFunc("/users", can(auth.PermUsersManage, userHandler.ListUsers)).Methods("GET")
+	api.HandleFunc("/users/{id}", can(auth.PermUsersManage, userHandler.GetUser)).Methods("GET")
+	api.HandleFunc("/users/{id}", can(auth.PermUsersManage, userHandler.UpdateUser)).Methods("PUT")
+	api.HandleFunc("/users/{id}/students", can(auth.PermUsersManage, userHandler.GetGuardianStudents)).Methods("GET")
+	api.HandleFunc("/users/{id}/students", can(auth.PermUsersManage, userHandler.SetGuardianStudents)).Methods("PUT")
+
+	// API Key Routes
+	api.HandleFunc("/api-keys", can(auth.PermAPIKeysManage, apiKeyHandler.CreateKey)).Methods("POST")
+	api.HandleFunc("/api-keys", can(auth.PermAPIKeysManage, apiKeyHandler.ListKeys)).Methods("GET")
+	api.HandleFunc("/api-keys/{id}", can(auth.PermAPIKeysManage, apiKeyHandler.GetKey)).Methods("GET")
+	api.HandleFunc("/api-keys/{id}/rotate", can(auth.PermAPIKeysManage, apiKeyHandler.RotateKey)).Methods("POST")
+	api.HandleFunc("/api-keys/{id}", can(auth.PermAPIKeysManage, apiKeyHandler.RevokeKey)).Methods("DELETE")
+
+	// Tenant Routes
+	api.HandleFunc("/tenant", tenantHandler.GetTenant).Methods("GET")
+	api.HandleFunc("/tenant/settings", can(auth.PermAdmin, tenantHandler.UpdateSettings)).Methods("PUT")
+
+	// Audit Routes
+	api.HandleFunc("/audit", can(auth.PermAuditRead, auditHandler.ListEntries)).Methods("GET")
+
+	// Admin Routes
+	api.HandleFunc("/admin/purge", can(auth.PermAdmin, adminHandler.Purge)).Methods("POST")
+
+	return &app{router: router, health: healthHandler, purge: purgeService, apiKeys: apiKeyService}, nil
+}
+
+// openBrowser opens url with the platform's default browser
+func openBrowser(url string) {
 	var errOpen error
 	switch runtime.GOOS {
 	case "windows":
@@ -105,7 +349,4 @@ func main() {
 	if errOpen != nil {
 		log.Printf("Failed to open browser: %v", errOpen)
 	}
-
-	// Keep the program running
-	select {}
-} 
\ No newline at end of file
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// APIKey is a credential for a non-interactive client. The key is shown
+// once when it is created or rotated; only its prefix and a hash of its
+// secret are stored.
+type APIKey struct {
+	ID uint `gorm:"primaryKey" json:"id"`
+	// TenantID is the school the key belongs to
+	TenantID uint   `gorm:"not null;default:1;index" json:"-"`
+	Name     string `gorm:"size:100;not null" json:"name"`
+	// Prefix is the public start of the key, which identifies it
+	Prefix     string `gorm:"size:32;not null;uniqueIndex" json:"prefix"`
+	SecretHash string `gorm:"size:64;not null" json:"-"`
+	// PreviousSecretHash keeps the secret replaced by a rotation working
+	// until PreviousExpiresAt, so clients can switch over
+	PreviousSecretHash string     `gorm:"size:64;not null;default:''" json:"-"`
+	PreviousExpiresAt  *time.Time `json:"-"`
+	// Scopes is the space-separated list of scopes granted to the key
+	Scopes string `gorm:"size:255;not null" json:"scopes"`
+	// ExpiresAt is when the key stops working, nil for never
+	ExpiresAt  *time.Time `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	UsageCount int64      `gorm:"not null;default:0" json:"usage_count"`
+	// CreatedByID is the user who created the key, nil when it was created
+	// without authentication
+	CreatedByID *uint     `json:"created_by_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// Audited operations
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+	AuditPurge   = "purge"
+)
+
+// Kinds of actor an audit entry is attributed to
+const (
+	ActorUser   = "user"
+	ActorAPIKey = "api_key"
+	// ActorSystem covers changes made without a principal: command-line
+	// tools, scheduled jobs and requests while authentication is off
+	ActorSystem = "system"
+)
+
+// AuditEntry records one change to a class or student. Entries are only
+// ever added, never changed or removed.
+type AuditEntry struct {
+	ID uint `gorm:"primaryKey" json:"id"`
+	// TenantID is the school of the changed record
+	TenantID uint `gorm:"not null;default:1;index" json:"-"`
+	// Entity is the kind of record changed, such as "class" or "student"
+	Entity    string `gorm:"size:50;not null;index:idx_audit_entries_entity" json:"entity"`
+	EntityID  uint   `gorm:"not null;index:idx_audit_entries_entity" json:"entity_id"`
+	Operation string `gorm:"size:20;not null" json:"operation"`
+	ActorType string `gorm:"size:20;not null" json:"actor_type"`
+	// ActorID is the user or API key that made the change, nil for the system
+	ActorID *uint `gorm:"index" json:"actor_id"`
+	// Actor is the username or API key name at the time of the change
+	Actor string `gorm:"size:100;not null;default:'';index" json:"actor"`
+	// Changes is a JSON object mapping each changed field to its "from" and
+	// "to" values; null stands for a record that did not exist or was deleted
+	Changes   string    `gorm:"not null" json:"changes"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
@@ -1,7 +1,28 @@
 package models
 
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
 type Class struct {
-	ID          uint      `gorm:"primaryKey" json:"id"`
-	ClassName   string    `gorm:"not null" json:"class_name"`
-	StudentCount int      `json:"student_count"`
-} 
\ No newline at end of file
+	ID uint `gorm:"primaryKey" json:"id"`
+	// TenantID is the school the class belongs to
+	TenantID  uint   `gorm:"not null;default:1;index" json:"-"`
+	ClassName string `gorm:"not null" json:"class_name" validate:"required,notblank,max=100"`
+	// StudentCount is maintained from enrollments and cannot be set by clients
+	StudentCount int `gorm:"not null;default:0" json:"student_count" validate:"gte=0"`
+	// SourcedID is the identifier of the class in an external roster system,
+	// empty for classes created through this API
+	SourcedID string `gorm:"size:255;not null;default:'';index" json:"sourced_id"`
+	// TeacherID is the user who teaches the class, if any
+	TeacherID *uint `gorm:"index" json:"teacher_id"`
+	// Version is bumped on every change and guards updates against lost writes
+	Version   uint      `gorm:"not null;default:1" json:"version"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// DeletedAt is set when the class is deleted; deleted classes are hidden
+	// from queries until they are restored or purged
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at"`
+	Students  []Student      `gorm:"foreignKey:ClassId;constraint:OnUpdate:CASCADE" json:"-"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Enrollment is a period during which a student was in a class. Periods
+// are half-open: they include ValidFrom but not ValidTo, which is nil for
+// a student's current class. They are kept by the repository whenever a
+// student is created, moves class, is deleted or is restored, and outlive
+// students who are purged. Times are in UTC.
+type Enrollment struct {
+	ID uint `gorm:"primaryKey" json:"id"`
+	// TenantID is the school of the student
+	TenantID  uint       `gorm:"not null;default:1;index" json:"-"`
+	StudentID uint       `gorm:"not null;index" json:"student_id"`
+	ClassID   uint       `gorm:"not null;index:idx_enrollments_class" json:"class_id"`
+	ValidFrom time.Time  `gorm:"not null;index:idx_enrollments_class" json:"valid_from"`
+	ValidTo   *time.Time `json:"valid_to"`
+	// ClassName and StudentName are read along with the enrollment, from
+	// the class and student as they are now, and are empty for purged
+	// ones; they are not stored
+	ClassName   string `gorm:"->;-:migration" json:"class_name"`
+	StudentName string `gorm:"->;-:migration" json:"student_name"`
+}
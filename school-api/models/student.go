@@ -1,8 +1,27 @@
 package models
 
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
 type Student struct {
-	ID          uint   `gorm:"primaryKey" json:"id"`
-	StudentName string `gorm:"not null" json:"student_name"`
-	ClassId     int    `gorm:"not null" json:"class_id"`
-	Secsion     string `gorm:"null" json:"student_section"`
+	ID uint `gorm:"primaryKey" json:"id"`
+	// TenantID is the school the student belongs to
+	TenantID    uint   `gorm:"not null;default:1;index" json:"-"`
+	StudentName string `gorm:"not null" json:"student_name" validate:"required,notblank,max=100"`
+	ClassId     uint   `gorm:"not null;index" json:"class_id" validate:"required,gt=0"`
+	// Section is stored in the historically misspelled "secsion" column
+	Section string `gorm:"column:secsion;null" json:"student_section" validate:"omitempty,oneof=A B C D E F"`
+	// SourcedID is the identifier of the student in an external roster
+	// system, empty for students created through this API
+	SourcedID string `gorm:"size:255;not null;default:'';index" json:"sourced_id"`
+	// Version is bumped on every change and guards updates against lost writes
+	Version   uint      `gorm:"not null;default:1" json:"version"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// DeletedAt is set when the student is deleted; deleted students are
+	// hidden from queries until they are restored or purged
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at"`
+	Class     *Class         `gorm:"foreignKey:ClassId" json:"-"`
 }
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// DefaultTenantID is the tenant that records created before multi-tenancy,
+// and deployments serving a single school, belong to
+const DefaultTenantID uint = 1
+
+// Tenant is a school served by the deployment. Classes, students, users and
+// API keys belong to exactly one tenant and are invisible to the others.
+type Tenant struct {
+	ID uint `gorm:"primaryKey" json:"id"`
+	// Slug names the tenant in subdomains and the tenant header
+	Slug     string `gorm:"size:63;not null;uniqueIndex" json:"slug"`
+	Name     string `gorm:"size:100;not null" json:"name"`
+	Disabled bool   `gorm:"not null;default:false" json:"disabled"`
+
+	// The settings below override the server configuration for the
+	// tenant; empty or zero values fall back to it
+
+	// DeletePolicy is the default policy for deleting classes with students
+	DeletePolicy string `gorm:"size:20;not null;default:''" json:"delete_policy"`
+	// ReassignTo is the class students are moved to under the reassign policy
+	ReassignTo            uint   `gorm:"not null;default:0" json:"reassign_to"`
+	OneRosterOrgSourcedID string `gorm:"column:oneroster_org_sourced_id;size:255;not null;default:''" json:"oneroster_org_sourced_id"`
+	OneRosterOrgName      string `gorm:"column:oneroster_org_name;size:255;not null;default:''" json:"oneroster_org_name"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
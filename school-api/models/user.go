@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// User is an account that can sign in to the API
+type User struct {
+	ID uint `gorm:"primaryKey" json:"id"`
+	// TenantID is the school the user belongs to
+	TenantID uint `gorm:"not null;default:1;index" json:"-"`
+	// Username is stored in lower case and is unique regardless of case
+	// across all tenants, so signing in does not need to name a tenant
+	Username     string `gorm:"size:100;not null;uniqueIndex" json:"username" validate:"required,notblank,max=100"`
+	PasswordHash string `gorm:"size:255;not null" json:"-"`
+	// Role decides what the user may do and which rows they see. Accounts
+	// created before roles existed were administrators, hence the default.
+	Role string `gorm:"size:20;not null;default:'admin'" json:"role"`
+	// StudentID links a user with the student role to their student record
+	StudentID *uint `gorm:"index" json:"student_id"`
+	// Disabled users cannot sign in or refresh their tokens
+	Disabled  bool      `gorm:"not null;default:false" json:"disabled"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// RefreshToken is an issued refresh token. Only a hash of the token is
+// stored. A token is used once: refreshing revokes it and issues a new one.
+type RefreshToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null;index"`
+	TokenHash string    `gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"not null"`
+	// RevokedAt is set when the token is used, or on logout
+	RevokedAt *time.Time
+	CreatedAt time.Time
+	User      *User `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// GuardianLink gives a user with the guardian role access to a student
+type GuardianLink struct {
+	UserID    uint `gorm:"primaryKey"`
+	StudentID uint `gorm:"primaryKey;index"`
+	CreatedAt time.Time
+	User      *User    `gorm:"constraint:OnDelete:CASCADE"`
+	Student   *Student `gorm:"constraint:OnDelete:CASCADE"`
+}
@@ -0,0 +1,299 @@
+package oneroster
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"school-api/models"
+	"school-api/spreadsheet"
+	"strings"
+)
+
+// Files of a OneRoster CSV bundle
+const (
+	FileManifest    = "manifest.csv"
+	FileOrgs        = "orgs.csv"
+	FileCourses     = "courses.csv"
+	FileClasses     = "classes.csv"
+	FileUsers       = "users.csv"
+	FileEnrollments = "enrollments.csv"
+)
+
+// Processing modes a manifest gives each file
+const (
+	ModeBulk   = "bulk"
+	ModeDelta  = "delta"
+	ModeAbsent = "absent"
+)
+
+// Column headers of the files school-api reads and writes
+var (
+	OrgColumns = []string{"sourcedId", "status", "dateLastModified", "name", "type", "identifier", "parentSourcedId"}
+
+	CourseColumns = []string{"sourcedId", "status", "dateLastModified", "schoolYearSourcedId", "title", "courseCode",
+		"grades", "orgSourcedId", "subjects", "subjectCodes"}
+
+	ClassColumns = []string{"sourcedId", "status", "dateLastModified", "title", "grades", "courseSourcedId", "classCode",
+		"classType", "location", "schoolSourcedId", "termSourcedIds", "subjects", "subjectCodes", "periods"}
+
+	UserColumns = []string{"sourcedId", "status", "dateLastModified", "enabledUser", "orgSourcedIds", "role", "username",
+		"userIds", "givenName", "familyName", "middleName", "identifier", "email", "sms", "phone", "agentSourcedIds",
+		"grades", "password", "userMasterIdentifier", "resourceSourcedIds", "preferredGivenName", "preferredMiddleName",
+		"preferredFamilyName", "primaryOrgSourcedId", "pronouns", "metadata.section"}
+
+	EnrollmentColumns = []string{"sourcedId", "status", "dateLastModified", "classSourcedId", "schoolSourcedId",
+		"userSourcedId", "role", "primary", "beginDate", "endDate"}
+)
+
+// rosteringFiles are the files a manifest lists, in the order they are written
+var rosteringFiles = []string{"academicSessions", "categories", "classes", "classResources", "courses",
+	"courseResources", "demographics", "enrollments", "lineItems", "orgs", "resources", "results", "roles",
+	"userProfiles", "userResources", "users"}
+
+// maxFileBytes caps the uncompressed size of each file in a bundle
+const maxFileBytes = 64 << 20
+
+// ErrInvalidBundle is returned for archives that are not OneRoster CSV bundles
+var ErrInvalidBundle = errors.New("invalid OneRoster bundle")
+
+// BundleWriter writes a OneRoster CSV bundle: a zip archive holding a
+// manifest and one CSV file per kind of record. Files are written one
+// after another, each row as it comes.
+type BundleWriter struct {
+	zip     *zip.Writer
+	csv     *csv.Writer
+	columns []string
+	row     []string
+}
+
+func NewBundleWriter(w io.Writer) *BundleWriter {
+	return &BundleWriter{zip: zip.NewWriter(w)}
+}
+
+// WriteManifest writes manifest.csv, declaring the given files as bulk
+// files and every other rostering file as absent
+func (b *BundleWriter) WriteManifest(files ...string) error {
+	if err := b.Begin(FileManifest, []string{"propertyName", "value"}); err != nil {
+		return err
+	}
+	rows := [][2]string{{"manifest.version", "1.0"}, {"oneroster.version", "1.2"}}
+	for _, name := range rosteringFiles {
+		mode := ModeAbsent
+		for _, f := range files {
+			if f == name+".csv" {
+				mode = ModeBulk
+			}
+		}
+		rows = append(rows, [2]string{"file." + name, mode})
+	}
+	rows = append(rows, [2]string{"source.systemName", "school-api"})
+	for _, row := range rows {
+		if err := b.Write(map[string]string{"propertyName": row[0], "value": row[1]}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Begin starts the next file of the bundle and writes its header row
+func (b *BundleWriter) Begin(name string, columns []string) error {
+	if err := b.flush(); err != nil {
+		return err
+	}
+	w, err := b.zip.Create(name)
+	if err != nil {
+		return err
+	}
+	b.csv, b.columns = csv.NewWriter(w), columns
+	return b.csv.Write(columns)
+}
+
+// Write adds a row to the current file. Values are keyed by column; missing
+// columns are left empty.
+func (b *BundleWriter) Write(values map[string]string) error {
+	b.row = b.row[:0]
+	for _, c := range b.columns {
+		b.row = append(b.row, values[c])
+	}
+	return b.csv.Write(b.row)
+}
+
+// Close finishes the current file and the archive
+func (b *BundleWriter) Close() error {
+	if err := b.flush(); err != nil {
+		return err
+	}
+	return b.zip.Close()
+}
+
+func (b *BundleWriter) flush() error {
+	if b.csv == nil {
+		return nil
+	}
+	b.csv.Flush()
+	return b.csv.Error()
+}
+
+// OrgRecord returns the orgs.csv row of the school
+func OrgRecord(org Org) map[string]string {
+	return map[string]string{"sourcedId": org.SourcedID, "name": org.Name, "type": "school"}
+}
+
+// CourseRecord returns the courses.csv row of the course a class stands for
+func CourseRecord(c *models.Class, org Org) map[string]string {
+	return map[string]string{
+		"sourcedId":    SourcedID(c.SourcedID, c.ID),
+		"title":        c.ClassName,
+		"orgSourcedId": org.SourcedID,
+	}
+}
+
+// ClassRecord returns the classes.csv row of a class
+func ClassRecord(c *models.Class, org Org) map[string]string {
+	id := SourcedID(c.SourcedID, c.ID)
+	return map[string]string{
+		"sourcedId":       id,
+		"title":           c.ClassName,
+		"courseSourcedId": id,
+		"classType":       "homeroom",
+		"schoolSourcedId": org.SourcedID,
+	}
+}
+
+// UserRecord returns the users.csv row of a student
+func UserRecord(s *models.Student, org Org) map[string]string {
+	user := NewUser(s, org)
+	return map[string]string{
+		"sourcedId":           user.SourcedID,
+		"enabledUser":         "true",
+		"orgSourcedIds":       org.SourcedID,
+		"role":                RoleStudent,
+		"username":            user.Username,
+		"givenName":           user.GivenName,
+		"familyName":          user.FamilyName,
+		"primaryOrgSourcedId": org.SourcedID,
+		"metadata.section":    s.Section,
+	}
+}
+
+// EnrollmentRecord returns the enrollments.csv row of a student's enrollment
+func EnrollmentRecord(s *models.Student, classSourcedID string, org Org) map[string]string {
+	user := UserRef(s)
+	return map[string]string{
+		"sourcedId":       EnrollmentSourcedID(user.SourcedID),
+		"classSourcedId":  classSourcedID,
+		"schoolSourcedId": org.SourcedID,
+		"userSourcedId":   user.SourcedID,
+		"role":            RoleStudent,
+		"primary":         "true",
+	}
+}
+
+// Table is one CSV file of a bundle
+type Table struct {
+	columns map[string]int
+	// Rows holds the data rows; the header is not included
+	Rows  [][]string
+	lines []int
+}
+
+// Line returns the line of the file data row n starts on
+func (t *Table) Line(n int) int {
+	if n < len(t.lines) {
+		return t.lines[n]
+	}
+	return n + 2
+}
+
+// Get returns the value of column in row, or "" when the file has no such column
+func (t *Table) Get(row []string, column string) string {
+	i, ok := t.columns[column]
+	if !ok || i >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[i])
+}
+
+// Has reports whether the file has the given column
+func (t *Table) Has(column string) bool {
+	_, ok := t.columns[column]
+	return ok
+}
+
+// Bundle holds the CSV files of a OneRoster bundle, keyed by file name
+type Bundle map[string]*Table
+
+// Mode returns how the manifest says a file is to be processed. Files the
+// manifest does not mention, or all files when there is no manifest, are
+// delta if present and absent otherwise: without a manifest saying so, a
+// file is not taken to be complete.
+func (b Bundle) Mode(file string) string {
+	if manifest, ok := b[FileManifest]; ok {
+		property := "file." + strings.TrimSuffix(file, ".csv")
+		for _, row := range manifest.Rows {
+			if manifest.Get(row, "propertyName") == property {
+				return strings.ToLower(manifest.Get(row, "value"))
+			}
+		}
+	}
+	if _, ok := b[file]; ok {
+		return ModeDelta
+	}
+	return ModeAbsent
+}
+
+// ReadBundle reads the CSV files of a OneRoster zip archive. Files may sit
+// in a folder inside the archive; anything other than CSV files is ignored.
+func ReadBundle(r io.ReaderAt, size int64) (Bundle, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+
+	bundle := make(Bundle)
+	for _, f := range archive.File {
+		name := path.Base(f.Name)
+		if f.FileInfo().IsDir() || !strings.EqualFold(path.Ext(name), ".csv") {
+			continue
+		}
+		table, err := readTable(f)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidBundle, name, err)
+		}
+		bundle[name] = table
+	}
+	if len(bundle) == 0 {
+		return nil, fmt.Errorf("%w: archive holds no CSV files", ErrInvalidBundle)
+	}
+	return bundle, nil
+}
+
+func readTable(f *zip.File) (*Table, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	limited := &io.LimitedReader{R: rc, N: maxFileBytes + 1}
+	sheet, err := spreadsheet.ReadSheet(limited, spreadsheet.CSV)
+	if err != nil {
+		return nil, err
+	}
+	if limited.N <= 0 {
+		return nil, fmt.Errorf("larger than %d MiB", maxFileBytes>>20)
+	}
+
+	table := &Table{columns: make(map[string]int)}
+	if len(sheet.Rows) == 0 {
+		return table, nil
+	}
+	for i, column := range sheet.Rows[0] {
+		table.columns[strings.TrimSpace(column)] = i
+	}
+	table.Rows, table.lines = sheet.Rows[1:], sheet.Lines[1:]
+	return table, nil
+}
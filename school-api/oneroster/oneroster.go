@@ -0,0 +1,232 @@
+// Package oneroster maps classes and students to the IMS OneRoster 1.2
+// rostering model. Each student is a user with the student role and a
+// single enrollment in their class; every class is its own course and
+// belongs to one configured school.
+package oneroster
+
+import (
+	"school-api/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BasePath is the root of the OneRoster REST endpoints
+const BasePath = "/ims/oneroster/v1p2"
+
+// Statuses of OneRoster records
+const (
+	StatusActive      = "active"
+	StatusToBeDeleted = "tobedeleted"
+)
+
+// RoleStudent is the only user and enrollment role stored by school-api
+const RoleStudent = "student"
+
+// enrollmentPrefix distinguishes a student's enrollment from the student
+const enrollmentPrefix = "enrollment-"
+
+// Org is the school every class and student belongs to
+type Org struct {
+	SourcedID string
+	Name      string
+}
+
+// Ref returns a reference to the org
+func (o Org) Ref() GUIDRef {
+	return GUIDRef{Href: BasePath + "/orgs/" + o.SourcedID, SourcedID: o.SourcedID, Type: "org"}
+}
+
+// GUIDRef points at another OneRoster record
+type GUIDRef struct {
+	Href      string `json:"href" example:"/ims/oneroster/v1p2/classes/12"`
+	SourcedID string `json:"sourcedId" example:"12"`
+	Type      string `json:"type" example:"class"`
+}
+
+// User is a OneRoster user; only students are represented
+type User struct {
+	SourcedID        string            `json:"sourcedId" example:"7"`
+	Status           string            `json:"status" example:"active"`
+	DateLastModified time.Time         `json:"dateLastModified"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+	EnabledUser      bool              `json:"enabledUser" example:"true"`
+	Username         string            `json:"username" example:"7"`
+	GivenName        string            `json:"givenName" example:"Jane"`
+	FamilyName       string            `json:"familyName" example:"Doe"`
+	Roles            []RoleAssignment  `json:"roles"`
+	PrimaryOrg       GUIDRef           `json:"primaryOrg"`
+}
+
+// RoleAssignment is a role a user holds at an org
+type RoleAssignment struct {
+	RoleType string  `json:"roleType" example:"primary"`
+	Role     string  `json:"role" example:"student"`
+	Org      GUIDRef `json:"org"`
+}
+
+// Class is a OneRoster class
+type Class struct {
+	SourcedID        string            `json:"sourcedId" example:"12"`
+	Status           string            `json:"status" example:"active"`
+	DateLastModified time.Time         `json:"dateLastModified"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+	Title            string            `json:"title" example:"Grade 5"`
+	ClassType        string            `json:"classType" example:"homeroom"`
+	Course           GUIDRef           `json:"course"`
+	School           GUIDRef           `json:"school"`
+	Terms            []GUIDRef         `json:"terms"`
+}
+
+// Enrollment places a user in a class
+type Enrollment struct {
+	SourcedID        string    `json:"sourcedId" example:"enrollment-7"`
+	Status           string    `json:"status" example:"active"`
+	DateLastModified time.Time `json:"dateLastModified"`
+	User             GUIDRef   `json:"user"`
+	Class            GUIDRef   `json:"class"`
+	School           GUIDRef   `json:"school"`
+	Role             string    `json:"role" example:"student"`
+	Primary          bool      `json:"primary" example:"true"`
+}
+
+// SourcedID returns the OneRoster identifier of a record: the sourced ID it
+// was imported with, or else its numeric ID
+func SourcedID(sourcedID string, id uint) string {
+	if sourcedID != "" {
+		return sourcedID
+	}
+	return strconv.FormatUint(uint64(id), 10)
+}
+
+// EnrollmentSourcedID returns the identifier of a student's enrollment
+func EnrollmentSourcedID(userSourcedID string) string {
+	return enrollmentPrefix + userSourcedID
+}
+
+// UserSourcedID returns the user whose enrollment has the given identifier
+func UserSourcedID(enrollmentSourcedID string) (string, bool) {
+	return strings.CutPrefix(enrollmentSourcedID, enrollmentPrefix)
+}
+
+// SplitName splits a full name into given and family names at the last space
+func SplitName(name string) (given, family string) {
+	name = strings.TrimSpace(name)
+	if i := strings.LastIndex(name, " "); i > 0 {
+		return strings.TrimSpace(name[:i]), name[i+1:]
+	}
+	return name, ""
+}
+
+// JoinName is the inverse of SplitName
+func JoinName(given, family string) string {
+	return strings.TrimSpace(strings.TrimSpace(given) + " " + strings.TrimSpace(family))
+}
+
+// status reports whether a record is active or has been deleted
+func status(deleted bool) string {
+	if deleted {
+		return StatusToBeDeleted
+	}
+	return StatusActive
+}
+
+// UserRef returns a reference to a student
+func UserRef(s *models.Student) GUIDRef {
+	id := SourcedID(s.SourcedID, s.ID)
+	return GUIDRef{Href: BasePath + "/users/" + id, SourcedID: id, Type: "user"}
+}
+
+// ClassRef returns a reference to the class with the given sourced ID
+func ClassRef(sourcedID string) GUIDRef {
+	return GUIDRef{Href: BasePath + "/classes/" + sourcedID, SourcedID: sourcedID, Type: "class"}
+}
+
+// courseRef returns a reference to the course of the class with the given sourced ID
+func courseRef(sourcedID string) GUIDRef {
+	return GUIDRef{Href: BasePath + "/courses/" + sourcedID, SourcedID: sourcedID, Type: "course"}
+}
+
+// NewUser maps a student to a OneRoster user. The student's section, which
+// OneRoster has no field for, is carried in metadata.
+func NewUser(s *models.Student, org Org) User {
+	id := SourcedID(s.SourcedID, s.ID)
+	given, family := SplitName(s.StudentName)
+	user := User{
+		SourcedID:        id,
+		Status:           status(s.DeletedAt.Valid),
+		DateLastModified: s.UpdatedAt.UTC(),
+		EnabledUser:      true,
+		Username:         id,
+		GivenName:        given,
+		FamilyName:       family,
+		Roles:            []RoleAssignment{{RoleType: "primary", Role: RoleStudent, Org: org.Ref()}},
+		PrimaryOrg:       org.Ref(),
+	}
+	if s.Section != "" {
+		user.Metadata = map[string]string{"section": s.Section}
+	}
+	return user
+}
+
+// NewClass maps a class to a OneRoster class
+func NewClass(c *models.Class, org Org) Class {
+	id := SourcedID(c.SourcedID, c.ID)
+	return Class{
+		SourcedID:        id,
+		Status:           status(c.DeletedAt.Valid),
+		DateLastModified: c.UpdatedAt.UTC(),
+		Metadata:         map[string]string{"studentCount": strconv.Itoa(c.StudentCount)},
+		Title:            c.ClassName,
+		ClassType:        "homeroom",
+		Course:           courseRef(id),
+		School:           org.Ref(),
+		Terms:            []GUIDRef{},
+	}
+}
+
+// NewEnrollment maps a student to their enrollment in the class with the
+// given sourced ID
+func NewEnrollment(s *models.Student, classSourcedID string, org Org) Enrollment {
+	user := UserRef(s)
+	return Enrollment{
+		SourcedID:        EnrollmentSourcedID(user.SourcedID),
+		Status:           status(s.DeletedAt.Valid),
+		DateLastModified: s.UpdatedAt.UTC(),
+		User:             user,
+		Class:            ClassRef(classSourcedID),
+		School:           org.Ref(),
+		Role:             RoleStudent,
+		Primary:          true,
+	}
+}
+
+// StatusInfo is the imsx_StatusInfo document OneRoster services return
+// when a request fails
+type StatusInfo struct {
+	CodeMajor   string    `json:"imsx_codeMajor" example:"failure"`
+	Severity    string    `json:"imsx_severity" example:"error"`
+	Description string    `json:"imsx_description" example:"user not found"`
+	CodeMinor   CodeMinor `json:"imsx_CodeMinor"`
+}
+
+// CodeMinor holds the detailed status codes of a StatusInfo
+type CodeMinor struct {
+	Fields []CodeMinorField `json:"imsx_codeMinorField"`
+}
+
+// CodeMinorField is one detailed status code
+type CodeMinorField struct {
+	Name  string `json:"imsx_codeMinorFieldName" example:"TargetEndSystem"`
+	Value string `json:"imsx_codeMinorFieldValue" example:"unknownobject"`
+}
+
+// NewStatusInfo builds a failure status with the given minor code
+func NewStatusInfo(codeMinor, description string) StatusInfo {
+	return StatusInfo{
+		CodeMajor:   "failure",
+		Severity:    "error",
+		Description: description,
+		CodeMinor:   CodeMinor{Fields: []CodeMinorField{{Name: "TargetEndSystem", Value: codeMinor}}},
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"school-api/auth"
+	"school-api/dto"
+	"school-api/handler"
+	"school-api/models"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// pages follows next_cursor from path and returns every item listed,
+// failing if a page repeats an item or the walk does not end
+func pages[T any](t *testing.T, a *testApp, token, path string, id func(T) uint) []T {
+	t.Helper()
+	var (
+		items  []T
+		seen   = map[uint]bool{}
+		cursor string
+	)
+	for range 100 {
+		next := path
+		if cursor != "" {
+			next += "&cursor=" + url.QueryEscape(cursor)
+		}
+		page := decode[handler.ListResponse[T]](t, expect(t, a.do(t, token, http.MethodGet, next, nil), http.StatusOK))
+		for _, item := range page.Data {
+			if seen[id(item)] {
+				t.Fatalf("%s lists %d twice", path, id(item))
+			}
+			seen[id(item)] = true
+		}
+		items = append(items, page.Data...)
+		if page.NextCursor == "" {
+			return items
+		}
+		cursor = page.NextCursor
+	}
+	t.Fatalf("%s did not end after 100 pages", path)
+	return nil
+}
+
+func classID(c dto.ClassResponse) uint { return c.ID }
+
+// TestKeysetPagingOverNulls checks that paging by a column holding NULLs
+// lists every row once, with NULLs first ascending and last descending
+func TestKeysetPagingOverNulls(t *testing.T) {
+	a := newTestApp(t)
+	token := a.login("default", "admin", auth.RoleAdmin)
+	var teachers []uint
+	for _, name := range []string{"teacher-1", "teacher-2"} {
+		a.login("default", name, auth.RoleTeacher)
+		var user models.User
+		if err := a.db.Where("username = ?", name).First(&user).Error; err != nil {
+			t.Fatal(err)
+		}
+		teachers = append(teachers, user.ID)
+	}
+
+	create := func(teacherID *uint) uint {
+		return decode[dto.ClassResponse](t, expect(t, a.do(t, token, http.MethodPost, "/api/classes",
+			dto.CreateClassRequest{ClassName: "Class", TeacherID: teacherID}), http.StatusCreated)).ID
+	}
+	var withoutTeacher []uint
+	for range 5 {
+		withoutTeacher = append(withoutTeacher, create(nil))
+	}
+	// The second teacher's class comes first so that id order differs from
+	// teacher order
+	second, first := create(&teachers[1]), create(&teachers[0])
+
+	t.Run("only nulls", func(t *testing.T) {
+		expect(t, a.do(t, token, http.MethodDelete, "/api/classes/"+itoa(second), nil), http.StatusNoContent)
+		expect(t, a.do(t, token, http.MethodDelete, "/api/classes/"+itoa(first), nil), http.StatusNoContent)
+		t.Cleanup(func() {
+			expect(t, a.do(t, token, http.MethodPost, "/api/classes/"+itoa(second)+"/restore", nil), http.StatusOK)
+			expect(t, a.do(t, token, http.MethodPost, "/api/classes/"+itoa(first)+"/restore", nil), http.StatusOK)
+		})
+		got := pages(t, a, token, "/api/classes?limit=2&sort=teacher_id", classID)
+		if ids := idsOf(got, classID); !slices.Equal(ids, withoutTeacher) {
+			t.Errorf("listed classes %v, want %v", ids, withoutTeacher)
+		}
+	})
+
+	for _, tc := range []struct {
+		sort string
+		want []uint
+	}{
+		{"teacher_id", append(slices.Clone(withoutTeacher), first, second)},
+		{"-teacher_id", append([]uint{second, first}, withoutTeacher...)},
+		{"-teacher_id,-id", append([]uint{second, first}, reversed(withoutTeacher)...)},
+	} {
+		t.Run(tc.sort, func(t *testing.T) {
+			got := pages(t, a, token, "/api/classes?limit=2&sort="+tc.sort, classID)
+			if ids := idsOf(got, classID); !slices.Equal(ids, tc.want) {
+				t.Errorf("listed classes %v, want %v", ids, tc.want)
+			}
+		})
+	}
+}
+
+func idsOf[T any](items []T, id func(T) uint) []uint {
+	ids := make([]uint, len(items))
+	for i, item := range items {
+		ids[i] = id(item)
+	}
+	return ids
+}
+
+func reversed(ids []uint) []uint {
+	r := slices.Clone(ids)
+	slices.Reverse(r)
+	return r
+}
+
+func itoa(id uint) string { return strconv.FormatUint(uint64(id), 10) }
+
+func studentID(s dto.StudentResponse) uint { return s.ID }
+
+// TestListQueries checks offset and cursor paging, sorting and filtering on
+// the list endpoints
+func TestListQueries(t *testing.T) {
+	a := newTestApp(t)
+	token := a.login("default", "admin", auth.RoleAdmin)
+	create := func(name string) uint {
+		return decode[dto.ClassResponse](t, expect(t, a.do(t, token, http.MethodPost, "/api/classes",
+			dto.CreateClassRequest{ClassName: name}), http.StatusCreated)).ID
+	}
+	grade5, grade6 := create("Grade 5"), create("Grade 6")
+	names := []string{"Eve", "Ann", "Dan", "Ben", "Cat"}
+	var ids []uint
+	for i, name := range names {
+		classID := grade5
+		if i >= 3 {
+			classID = grade6
+		}
+		ids = append(ids, decode[dto.StudentResponse](t, expect(t, a.do(t, token, http.MethodPost, "/api/students",
+			dto.CreateStudentRequest{StudentName: name, ClassID: classID, Section: "A"}), http.StatusCreated)).ID)
+	}
+	sorted := slices.Sorted(slices.Values(names))
+
+	t.Run("offset", func(t *testing.T) {
+		var got []string
+		path := "/api/students?sort=student_name&limit=2"
+		for path != "" {
+			rec := expect(t, a.do(t, token, http.MethodGet, path, nil), http.StatusOK)
+			page := decode[handler.ListResponse[dto.StudentResponse]](t, rec)
+			if page.Total != int64(len(names)) {
+				t.Errorf("%s reports %d students in total, want %d", path, page.Total, len(names))
+			}
+			if page.Next != "" && rec.Header().Get("Link") != `<`+page.Next+`>; rel="next"` {
+				t.Errorf("%s links to %q, want next %q", path, rec.Header().Get("Link"), page.Next)
+			}
+			got = append(got, namesOf(page.Data)...)
+			path = page.Next
+			if len(got) > len(names) {
+				t.Fatalf("offset paging lists %v", got)
+			}
+		}
+		if !slices.Equal(got, sorted) {
+			t.Errorf("offset paging lists %v, want %v", got, sorted)
+		}
+	})
+
+	for _, tc := range []struct {
+		sort string
+		want []uint
+	}{
+		{"-student_name", []uint{ids[0], ids[2], ids[4], ids[3], ids[1]}},
+		{"class_id,-student_name", []uint{ids[0], ids[2], ids[1], ids[4], ids[3]}},
+		{"updated_at", ids},
+		{"-id", reversed(ids)},
+	} {
+		t.Run("cursor by "+tc.sort, func(t *testing.T) {
+			got := pages(t, a, token, "/api/students?limit=2&sort="+tc.sort, studentID)
+			if ids := idsOf(got, studentID); !slices.Equal(ids, tc.want) {
+				t.Errorf("listed students %v, want %v", ids, tc.want)
+			}
+		})
+	}
+
+	for _, tc := range []struct {
+		query string
+		want  []string
+	}{
+		{fmt.Sprintf("class_id=%d", grade6), []string{"Ben", "Cat"}},
+		{"student_name[like]=en", []string{"Ben"}},
+		{fmt.Sprintf("id[gt]=%d&id[lte]=%d", ids[1], ids[3]), []string{"Ben", "Dan"}},
+		{"student_name[ne]=Eve&student_name[ne]=Ann", []string{"Ben", "Cat", "Dan"}},
+	} {
+		t.Run("filter "+tc.query, func(t *testing.T) {
+			page := decode[handler.ListResponse[dto.StudentResponse]](t, expect(t,
+				a.do(t, token, http.MethodGet, "/api/students?sort=student_name&"+tc.query, nil), http.StatusOK))
+			if got := namesOf(page.Data); !slices.Equal(got, tc.want) || page.Total != int64(len(tc.want)) {
+				t.Errorf("listed %v of %d, want %v", got, page.Total, tc.want)
+			}
+		})
+	}
+
+	t.Run("classes by student count", func(t *testing.T) {
+		page := decode[handler.ListResponse[dto.ClassResponse]](t, expect(t,
+			a.do(t, token, http.MethodGet, "/api/classes?student_count[gte]=3", nil), http.StatusOK))
+		if got := idsOf(page.Data, classID); !slices.Equal(got, []uint{grade5}) {
+			t.Errorf("listed classes %v, want %v", got, []uint{grade5})
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, query := range []string{"sort=secsion", "secsion=A", "limit=-1", "id[around]=1", "cursor=not-a-cursor", "updated_at[gt]=yesterday"} {
+			expect(t, a.do(t, token, http.MethodGet, "/api/students?"+query, nil), http.StatusBadRequest)
+		}
+	})
+}
+
+func namesOf(students []dto.StudentResponse) []string {
+	names := make([]string, len(students))
+	for i, s := range students {
+		names[i] = s.StudentName
+	}
+	return names
+}
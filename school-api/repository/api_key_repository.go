@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"school-api/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *models.APIKey) error
+	List(ctx context.Context) ([]models.APIKey, error)
+	GetByID(ctx context.Context, id uint) (*models.APIKey, error)
+	GetByPrefix(ctx context.Context, prefix string) (*models.APIKey, error)
+	Rotate(ctx context.Context, key *models.APIKey) error
+	Revoke(ctx context.Context, id uint) error
+	RecordUse(ctx context.Context, id uint, at time.Time, uses int64) error
+}
+
+type apiKeyRepository struct {
+	conn
+}
+
+func NewAPIKeyRepository(db *gorm.DB, timeouts Timeouts) APIKeyRepository {
+	return &apiKeyRepository{conn: conn{db: db, timeouts: timeouts}}
+}
+
+// Create stores a new API key
+func (r *apiKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	db, finish := r.write(ctx)
+	return finish(db.Create(key).Error)
+}
+
+// List returns every API key, revoked or not, newest first
+func (r *apiKeyRepository) List(ctx context.Context) ([]models.APIKey, error) {
+	db, finish := r.list(ctx)
+	var keys []models.APIKey
+	err := finish(db.Order("id DESC").Find(&keys).Error)
+	return keys, err
+}
+
+// GetByID finds an API key by ID, returning ErrNotFound if there is none
+func (r *apiKeyRepository) GetByID(ctx context.Context, id uint) (*models.APIKey, error) {
+	db, finish := r.read(ctx)
+	var key models.APIKey
+	err := finish(db.First(&key, id).Error)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// GetByPrefix finds an API key by the public prefix of the key
+func (r *apiKeyRepository) GetByPrefix(ctx context.Context, prefix string) (*models.APIKey, error) {
+	db, finish := r.read(ctx)
+	var key models.APIKey
+	err := finish(db.Where("prefix = ?", prefix).First(&key).Error)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// Rotate stores the new secret of a key along with the previous one and
+// when it stops working
+func (r *apiKeyRepository) Rotate(ctx context.Context, key *models.APIKey) error {
+	db, finish := r.write(ctx)
+	return finish(checkAffected(db.Model(key).
+		Select("secret_hash", "previous_secret_hash", "previous_expires_at", "updated_at").
+		Updates(key)))
+}
+
+// Revoke stops a key from working. Revoking a revoked key keeps the
+// original time.
+func (r *apiKeyRepository) Revoke(ctx context.Context, id uint) error {
+	db, finish := r.write(ctx)
+	return finish(db.Model(&models.APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", db.NowFunc()).Error)
+}
+
+// RecordUse adds uses to a key's usage count, the last of them made at the
+// given time
+func (r *apiKeyRepository) RecordUse(ctx context.Context, id uint, at time.Time, uses int64) error {
+	db, finish := r.write(ctx)
+	return finish(db.Model(&models.APIKey{}).
+		Where("id = ?", id).
+		UpdateColumns(map[string]any{
+			"last_used_at": at,
+			"usage_count":  gorm.Expr("usage_count + ?", uses),
+		}).Error)
+}
@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"school-api/auth"
+	"school-api/models"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// auditIgnored are fields that change on every write and would only add
+// noise to the recorded changes
+var auditIgnored = map[string]bool{"updated_at": true, "version": true}
+
+// rowChange is a row as it was before and after a change; either is nil
+// where the row did not exist or was soft-deleted
+type rowChange struct {
+	before, after any
+}
+
+// fieldChange is the value of one field before and after a change
+type fieldChange struct {
+	From json.RawMessage `json:"from"`
+	To   json.RawMessage `json:"to"`
+}
+
+// record keeps what is derived from changes up to date: the audit log
+// and, for students, the enrollment history
+func record(db *gorm.DB, op string, changes ...rowChange) error {
+	if err := audit(db, op, changes...); err != nil {
+		return err
+	}
+	return trackEnrollments(db, op, changes)
+}
+
+// mutate runs change on the row of model with the given ID in a
+// transaction, recording the row as it was before and after (see record).
+// If change fails, nothing is written.
+func mutate(db *gorm.DB, op string, model any, id uint, change func(tx *gorm.DB) error) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		before, err := snapshot(tx, model, id)
+		if err != nil {
+			return err
+		}
+		if err := change(tx); err != nil {
+			return err
+		}
+		after, err := snapshot(tx, model, id)
+		if err != nil {
+			return err
+		}
+		return record(tx, op, rowChange{before: before, after: after})
+	})
+}
+
+// purgeWhere permanently removes the rows of T that where selects,
+// recording each (see record), and returns how many were removed
+func purgeWhere[T any](db *gorm.DB, where func(tx *gorm.DB) *gorm.DB) (int64, error) {
+	var purged int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var rows []T
+		if err := where(tx.Unscoped()).Find(&rows).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		result := where(tx.Unscoped()).Delete(new(T))
+		if result.Error != nil {
+			return result.Error
+		}
+		purged = result.RowsAffected
+		changes := make([]rowChange, len(rows))
+		for i := range rows {
+			changes[i] = rowChange{before: &rows[i]}
+		}
+		return record(tx, models.AuditPurge, changes...)
+	})
+	return purged, err
+}
+
+// snapshot loads the row of model's type with the given ID, returning nil
+// if there is none or it is soft-deleted
+func snapshot(db *gorm.DB, model any, id uint) (any, error) {
+	row := reflect.New(reflect.TypeOf(model).Elem()).Interface()
+	// Find rather than First, as a missing row is expected and should not
+	// be logged as an error
+	result := db.Session(&gorm.Session{NewDB: true}).Limit(1).Find(row, id)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return row, nil
+}
+
+// audit appends an entry to the audit log for each change, attributed to
+// the principal of db's context. All changes must be of rows of one model.
+func audit(db *gorm.DB, op string, changes ...rowChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	ctx := db.Statement.Context
+	actorType, actorID, actor := auditActor(ctx)
+
+	entries := make([]models.AuditEntry, 0, len(changes))
+	stmt := &gorm.Statement{DB: db}
+	for _, c := range changes {
+		row := c.after
+		if row == nil {
+			row = c.before
+		}
+		if row == nil {
+			continue
+		}
+		if stmt.Schema == nil {
+			if err := stmt.Parse(row); err != nil {
+				return err
+			}
+		}
+		diff, err := diffRows(c.before, c.after)
+		if err != nil {
+			return err
+		}
+
+		rv := reflect.Indirect(reflect.ValueOf(row))
+		entry := models.AuditEntry{
+			Entity:    strings.ToLower(stmt.Schema.Name),
+			Operation: op,
+			ActorType: actorType,
+			ActorID:   actorID,
+			Actor:     actor,
+			Changes:   string(diff),
+		}
+		if id, _ := stmt.Schema.PrioritizedPrimaryField.ValueOf(ctx, rv); id != nil {
+			entry.EntityID, _ = id.(uint)
+		}
+		// Entries belong to the tenant of the row, which matters for jobs
+		// that change rows of every tenant
+		if field := stmt.Schema.LookUpField("TenantID"); field != nil {
+			tenant, _ := field.ValueOf(ctx, rv)
+			entry.TenantID, _ = tenant.(uint)
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	return db.Session(&gorm.Session{NewDB: true}).CreateInBatches(entries, BatchSize).Error
+}
+
+// auditActor names who the changes made with ctx are attributed to
+func auditActor(ctx context.Context) (actorType string, actorID *uint, actor string) {
+	principal, ok := auth.PrincipalFrom(ctx)
+	switch {
+	case !ok:
+		return models.ActorSystem, nil, ""
+	case principal.IsAPIKey():
+		id := principal.APIKeyID
+		return models.ActorAPIKey, &id, principal.Username
+	default:
+		id := principal.UserID
+		return models.ActorUser, &id, principal.Username
+	}
+}
+
+// diffRows returns the JSON object of fields whose JSON values differ
+// between before and after, either of which may be nil
+func diffRows(before, after any) ([]byte, error) {
+	from, err := jsonFields(before)
+	if err != nil {
+		return nil, err
+	}
+	to, err := jsonFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := make(map[string]fieldChange)
+	for name, value := range from {
+		if !auditIgnored[name] && !bytes.Equal(value, to[name]) {
+			diff[name] = fieldChange{From: value, To: to[name]}
+		}
+	}
+	for name, value := range to {
+		if _, seen := from[name]; !seen && !auditIgnored[name] {
+			diff[name] = fieldChange{To: value}
+		}
+	}
+	return json.Marshal(diff)
+}
+
+// jsonFields returns the fields of row as its JSON encoding has them, with
+// JSON nulls left out so they compare equal to missing fields
+func jsonFields(row any) (map[string]json.RawMessage, error) {
+	if row == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(row)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for name, value := range fields {
+		if string(value) == "null" {
+			delete(fields, name)
+		}
+	}
+	return fields, nil
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"school-api/models"
+
+	"gorm.io/gorm"
+)
+
+// AuditRepository reads the audit log. Entries are written by the other
+// repositories as part of the changes they record, and the log has no way
+// to change or remove them.
+type AuditRepository interface {
+	List(ctx context.Context, opts QueryOptions) (*Page[models.AuditEntry], error)
+}
+
+// auditQueryFields are the fields clients may sort and filter the audit log by
+var auditQueryFields = map[string]string{
+	"id":         "id",
+	"entity":     "entity",
+	"entity_id":  "entity_id",
+	"operation":  "operation",
+	"actor_type": "actor_type",
+	"actor_id":   "actor_id",
+	"actor":      "actor",
+	"created_at": "created_at",
+}
+
+type auditRepository struct {
+	entries ReadRepository[models.AuditEntry]
+}
+
+func NewAuditRepository(db *gorm.DB, timeouts Timeouts) AuditRepository {
+	return &auditRepository{
+		entries: NewReadRepository[models.AuditEntry](db, timeouts, auditQueryFields, nil),
+	}
+}
+
+// List returns one page of entries matching opts, newest first unless
+// opts asks for another order
+func (r *auditRepository) List(ctx context.Context, opts QueryOptions) (*Page[models.AuditEntry], error) {
+	if len(opts.Sort) == 0 {
+		opts.Sort = []SortField{{Field: "id", Desc: true}}
+	}
+	return r.entries.List(ctx, opts)
+}
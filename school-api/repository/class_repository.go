@@ -1,24 +1,122 @@
 package repository
 
 import (
+	"context"
 	"school-api/models"
+	"strings"
+	"time"
 	"gorm.io/gorm"
 )
 
 type ClassRepository interface {
-	Create(class *models.Class) error
-	GetAll() ([]models.Class, error)
-	GetByID(id uint) (*models.Class, error)
-	Update(class *models.Class) error
-	Delete(id uint) error
+	Create(ctx context.Context, class *models.Class) error
+	CreateBatch(ctx context.Context, classes []models.Class) error
+	List(ctx context.Context, opts QueryOptions) (*Page[models.Class], error)
+	Stream(ctx context.Context, opts QueryOptions, fn func(*models.Class) error) error
+	GetByID(ctx context.Context, id uint) (*models.Class, error)
+	GetInScope(ctx context.Context, scope Scope, id uint) (*models.Class, error)
+	Exists(ctx context.Context, id uint) (bool, error)
+	ExistsInScope(ctx context.Context, scope Scope, id uint) (bool, error)
+	Update(ctx context.Context, class *models.Class) error
+	Upsert(ctx context.Context, class *models.Class) (created bool, err error)
+	Delete(ctx context.Context, id uint) error
+	Restore(ctx context.Context, id uint) error
+	Purge(ctx context.Context, deletedBefore time.Time) (int64, error)
+	FindByIDs(ctx context.Context, ids []uint) ([]models.Class, error)
+	FindByNames(ctx context.Context, names []string) ([]models.Class, error)
+	FindBySourcedIDs(ctx context.Context, sourcedIDs []string) ([]models.Class, error)
+	RefreshStudentCounts(ctx context.Context, ids ...uint) error
+}
+
+// classQueryFields are the fields clients may sort and filter classes by
+var classQueryFields = map[string]string{
+	"id":            "id",
+	"class_name":    "class_name",
+	"student_count": "student_count",
+	"teacher_id":    "teacher_id",
+	"sourced_id":    "sourced_id",
+	"updated_at":    "updated_at",
 }
 
 type classRepository struct {
 	GenericRepository[models.Class]
+	conn
 }
 
-func NewClassRepository(db *gorm.DB) ClassRepository {
+func NewClassRepository(db *gorm.DB, timeouts Timeouts) ClassRepository {
 	return &classRepository{
-		GenericRepository: NewGenericRepository[models.Class](db),
+		GenericRepository: NewGenericRepository[models.Class](db, timeouts, classQueryFields, scopeClasses),
+		conn:              conn{db: db, timeouts: timeouts},
 	}
-} 
\ No newline at end of file
+}
+
+// Update modifies an existing class. student_count is derived from
+// enrollments and sourced_id is only set when a class is created, so
+// neither is written from the entity.
+func (r *classRepository) Update(ctx context.Context, class *models.Class) error {
+	db, finish := r.write(ctx)
+	return finish(mutate(db, models.AuditUpdate, class, class.ID, func(tx *gorm.DB) error {
+		return updateAll(tx, class, "student_count", "sourced_id")
+	}))
+}
+
+// Upsert updates the class if it exists and creates it with its ID otherwise
+func (r *classRepository) Upsert(ctx context.Context, class *models.Class) (bool, error) {
+	return upsert(ctx, r.conn, class, r.Update)
+}
+
+// FindByIDs returns the classes with the given IDs; missing IDs are skipped
+func (r *classRepository) FindByIDs(ctx context.Context, ids []uint) ([]models.Class, error) {
+	db, finish := r.list(ctx)
+	classes, err := findIn[models.Class](db, "id", ids)
+	return classes, finish(err)
+}
+
+// FindByNames returns the classes whose name matches one of names, ignoring case
+func (r *classRepository) FindByNames(ctx context.Context, names []string) ([]models.Class, error) {
+	lower := make([]string, len(names))
+	for i, name := range names {
+		lower[i] = strings.ToLower(name)
+	}
+	db, finish := r.list(ctx)
+	classes, err := findIn[models.Class](db, "LOWER(class_name)", lower)
+	return classes, finish(err)
+}
+
+// FindBySourcedIDs returns the classes identified by the given sourced IDs
+// (see findBySourcedIDs)
+func (r *classRepository) FindBySourcedIDs(ctx context.Context, sourcedIDs []string) ([]models.Class, error) {
+	db, finish := r.list(ctx)
+	classes, err := findBySourcedIDs[models.Class](db, sourcedIDs)
+	return classes, finish(err)
+}
+
+// Purge permanently removes classes soft-deleted before the given time.
+// Classes that students, deleted or not, still refer to are kept until
+// those students are purged or moved.
+func (r *classRepository) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	db, finish := r.write(ctx)
+	purged, err := purgeWhere[models.Class](db, func(tx *gorm.DB) *gorm.DB {
+		referenced := tx.Session(&gorm.Session{NewDB: true}).Unscoped().
+			Model(&models.Student{}).Select("1").Where("students.class_id = classes.id")
+		return tx.Where("deleted_at < ?", deletedBefore).Where("NOT EXISTS (?)", referenced)
+	})
+	return purged, finish(err)
+}
+
+// RefreshStudentCounts recomputes student_count from the students table for
+// the given classes, or for every class when no IDs are given. Classes whose
+// count changes get a new version. The counts are derived, so their
+// changes are not audited.
+func (r *classRepository) RefreshStudentCounts(ctx context.Context, ids ...uint) error {
+	db, finish := r.write(ctx)
+	count := db.Model(&models.Student{}).Select("COUNT(*)").Where("students.class_id = classes.id")
+	query := db.Model(&models.Class{}).Where("student_count <> (?)", count)
+	if len(ids) > 0 {
+		query = query.Where("id IN ?", ids)
+	}
+	return finish(query.Updates(map[string]any{
+		"student_count": count,
+		"version":       gorm.Expr("version + 1"),
+	}).Error)
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Timeouts bounds how long each kind of query may run. A zero value means
+// the query is limited only by the caller's context. Export covers reading
+// every matching row for a streamed export.
+type Timeouts struct {
+	Read   time.Duration
+	List   time.Duration
+	Write  time.Duration
+	Export time.Duration
+}
+
+// conn is a database handle together with the timeouts applied to queries
+// made through it
+type conn struct {
+	db       *gorm.DB
+	timeouts Timeouts
+}
+
+func (c conn) read(ctx context.Context) (*gorm.DB, func(error) error) {
+	return c.begin(ctx, c.timeouts.Read)
+}
+
+func (c conn) list(ctx context.Context) (*gorm.DB, func(error) error) {
+	return c.begin(ctx, c.timeouts.List)
+}
+
+func (c conn) export(ctx context.Context) (*gorm.DB, func(error) error) {
+	return c.begin(ctx, c.timeouts.Export)
+}
+
+func (c conn) write(ctx context.Context) (*gorm.DB, func(error) error) {
+	return c.begin(ctx, c.timeouts.Write)
+}
+
+// begin binds the handle to ctx, limited by timeout. If ctx carries a
+// transaction started by UnitOfWork.Do, the query joins it. The returned
+// finish function must be called with the query's error: it releases the
+// timeout and, when the query failed because the context was cancelled or
+// timed out, reports that instead of the driver's error.
+func (c conn) begin(ctx context.Context, timeout time.Duration) (*gorm.DB, func(error) error) {
+	db := c.db
+	if tx, ok := txFromContext(ctx); ok {
+		db = tx
+	}
+
+	cancel := context.CancelFunc(func() {})
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+
+	finish := func(err error) error {
+		defer cancel()
+		if err == nil {
+			return nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil && !errors.Is(err, ctxErr) {
+			return fmt.Errorf("%w: %v", ctxErr, err)
+		}
+		return err
+	}
+	return db.WithContext(ctx), finish
+}
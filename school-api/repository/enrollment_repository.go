@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"school-api/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// EnrollmentRepository reads the enrollment history of students. The
+// history is written by the student repository as students change.
+type EnrollmentRepository interface {
+	// History returns every enrollment of a student, oldest first
+	History(ctx context.Context, studentID uint) ([]models.Enrollment, error)
+	// Roster returns the enrollments of a class in effect at the given
+	// time, ordered by student name, of the students visible in scope.
+	// Deleted students count as visible to those who could see them.
+	Roster(ctx context.Context, classID uint, at time.Time, scope Scope) ([]models.Enrollment, error)
+}
+
+type enrollmentRepository struct {
+	conn
+}
+
+func NewEnrollmentRepository(db *gorm.DB, timeouts Timeouts) EnrollmentRepository {
+	return &enrollmentRepository{conn: conn{db: db, timeouts: timeouts}}
+}
+
+// named selects enrollments along with the names of their class and student
+func named(db *gorm.DB) *gorm.DB {
+	return db.Model(&models.Enrollment{}).
+		Select("enrollments.*, classes.class_name AS class_name, students.student_name AS student_name").
+		Joins("LEFT JOIN classes ON classes.id = enrollments.class_id").
+		Joins("LEFT JOIN students ON students.id = enrollments.student_id")
+}
+
+func (r *enrollmentRepository) History(ctx context.Context, studentID uint) ([]models.Enrollment, error) {
+	db, finish := r.read(ctx)
+	var enrollments []models.Enrollment
+	err := named(db).
+		Where("enrollments.student_id = ?", studentID).
+		Order("enrollments.valid_from, enrollments.id").
+		Find(&enrollments).Error
+	return enrollments, finish(err)
+}
+
+func (r *enrollmentRepository) Roster(ctx context.Context, classID uint, at time.Time, scope Scope) ([]models.Enrollment, error) {
+	db, finish := r.list(ctx)
+	// Enrollment times are stored in UTC, and compare as such
+	at = at.UTC()
+	query := named(db).
+		Where("enrollments.class_id = ?", classID).
+		Where("enrollments.valid_from <= ?", at).
+		Where("(enrollments.valid_to IS NULL OR enrollments.valid_to > ?)", at)
+	if scope.Restricted() {
+		sub := db.Session(&gorm.Session{NewDB: true}).Unscoped()
+		visible := scopeStudents(sub.Model(&models.Student{}).Select("students.id"), scope)
+		query = query.Where("enrollments.student_id IN (?)", visible)
+	}
+	var enrollments []models.Enrollment
+	err := query.Order("students.student_name, enrollments.student_id").Find(&enrollments).Error
+	return enrollments, finish(err)
+}
+
+// trackEnrollments brings the enrollment history in line with changes to
+// students; changes to other rows are ignored. A student who leaves a
+// class, by moving or being deleted, has their enrollment closed; one who
+// joins a class, by being created, moving or being restored, gets a new
+// one. Purging a student keeps their history, which was closed when they
+// were deleted, so past rosters do not change. Times are stored in UTC.
+func trackEnrollments(db *gorm.DB, op string, changes []rowChange) error {
+	if op == models.AuditPurge {
+		return nil
+	}
+	var closing []uint
+	var opening []models.Enrollment
+	now := db.NowFunc().UTC()
+	for _, c := range changes {
+		before, _ := c.before.(*models.Student)
+		after, _ := c.after.(*models.Student)
+		switch {
+		case before == nil && after == nil:
+			continue
+		case before != nil && after != nil && before.ClassId == after.ClassId:
+			continue
+		}
+		if before != nil {
+			closing = append(closing, before.ID)
+		}
+		if after != nil {
+			opening = append(opening, models.Enrollment{
+				TenantID:  after.TenantID,
+				StudentID: after.ID,
+				ClassID:   after.ClassId,
+				ValidFrom: now,
+			})
+		}
+	}
+
+	db = db.Session(&gorm.Session{NewDB: true})
+	for start := 0; start < len(closing); start += maxInValues {
+		ids := closing[start:min(start+maxInValues, len(closing))]
+		err := db.Model(&models.Enrollment{}).
+			Where("student_id IN ? AND valid_to IS NULL", ids).
+			Update("valid_to", now).Error
+		if err != nil {
+			return err
+		}
+	}
+	if len(opening) == 0 {
+		return nil
+	}
+	return db.CreateInBatches(opening, BatchSize).Error
+}
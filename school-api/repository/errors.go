@@ -0,0 +1,16 @@
+package repository
+
+import "errors"
+
+var (
+	// ErrNotFound is returned when the entity being read, updated or deleted does not exist
+	ErrNotFound = errors.New("record not found")
+
+	// ErrVersionConflict is returned when an update targets a version of the
+	// entity that has since been changed
+	ErrVersionConflict = errors.New("version conflict")
+
+	// ErrInvalidQuery is returned when list options refer to unknown fields,
+	// unsupported operators or a malformed cursor
+	ErrInvalidQuery = errors.New("invalid query")
+)
@@ -1,57 +1,628 @@
 package repository
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"school-api/models"
+	"strconv"
+	"strings"
+	"time"
+
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ReadRepository defines the queries of GenericRepository, for models that
+// must not be changed through a repository of their own
+type ReadRepository[T any] interface {
+	List(ctx context.Context, opts QueryOptions) (*Page[T], error)
+	Stream(ctx context.Context, opts QueryOptions, fn func(*T) error) error
+	GetByID(ctx context.Context, id uint) (*T, error)
+	GetInScope(ctx context.Context, scope Scope, id uint) (*T, error)
+	Exists(ctx context.Context, id uint) (bool, error)
+	ExistsInScope(ctx context.Context, scope Scope, id uint) (bool, error)
+}
+
 // GenericRepository defines the interface for generic repository operations
 type GenericRepository[T any] interface {
-	Create(entity *T) error
-	GetAll() ([]T, error)
-	GetByID(id uint) (*T, error)
-	Update(entity *T) error
-	Delete(id uint) error
+	ReadRepository[T]
+	Create(ctx context.Context, entity *T) error
+	CreateBatch(ctx context.Context, entities []T) error
+	Update(ctx context.Context, entity *T) error
+	Upsert(ctx context.Context, entity *T) (created bool, err error)
+	Delete(ctx context.Context, id uint) error
+	Restore(ctx context.Context, id uint) error
+	Purge(ctx context.Context, deletedBefore time.Time) (int64, error)
+}
+
+// readRepository implements ReadRepository for any type T
+type readRepository[T any] struct {
+	conn
+	fields map[string]string
+	scope  ScopeFunc
 }
 
 // genericRepository implements GenericRepository for any type T
 type genericRepository[T any] struct {
-	db *gorm.DB
+	readRepository[T]
+}
+
+// NewGenericRepository creates a new generic repository for type T.
+// fields maps the names clients may sort and filter by to column names.
+// scope applies restricted scopes to queries on T; if it is nil, a
+// restricted scope sees no rows at all.
+func NewGenericRepository[T any](db *gorm.DB, timeouts Timeouts, fields map[string]string, scope ScopeFunc) GenericRepository[T] {
+	return &genericRepository[T]{readRepository: readRepository[T]{conn: conn{db: db, timeouts: timeouts}, fields: fields, scope: scope}}
+}
+
+// NewReadRepository creates a repository that only queries T, taking
+// fields and scope as NewGenericRepository does
+func NewReadRepository[T any](db *gorm.DB, timeouts Timeouts, fields map[string]string, scope ScopeFunc) ReadRepository[T] {
+	return &readRepository[T]{conn: conn{db: db, timeouts: timeouts}, fields: fields, scope: scope}
 }
 
-// NewGenericRepository creates a new generic repository for type T
-func NewGenericRepository[T any](db *gorm.DB) GenericRepository[T] {
-	return &genericRepository[T]{db: db}
+// Create adds a new entity to the database. Like every change made through
+// the repository, it is recorded in the audit log in the same transaction.
+func (r *genericRepository[T]) Create(ctx context.Context, entity *T) error {
+	db, finish := r.write(ctx)
+	return finish(db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(entity).Error; err != nil {
+			return err
+		}
+		return record(tx, models.AuditCreate, rowChange{after: entity})
+	}))
 }
 
-// Create adds a new entity to the database
-func (r *genericRepository[T]) Create(entity *T) error {
-	return r.db.Create(entity).Error
+// CreateBatch adds entities in multi-row inserts of up to BatchSize rows,
+// filling in their IDs
+func (r *genericRepository[T]) CreateBatch(ctx context.Context, entities []T) error {
+	db, finish := r.write(ctx)
+	return finish(db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.CreateInBatches(entities, BatchSize).Error; err != nil {
+			return err
+		}
+		changes := make([]rowChange, len(entities))
+		for i := range entities {
+			changes[i] = rowChange{after: &entities[i]}
+		}
+		return record(tx, models.AuditCreate, changes...)
+	}))
 }
 
-// GetAll retrieves all entities of type T
-func (r *genericRepository[T]) GetAll() ([]T, error) {
+// List retrieves one page of entities matching opts, along with the total
+// number of matches
+func (r *readRepository[T]) List(ctx context.Context, opts QueryOptions) (*Page[T], error) {
+	db, finish := r.list(ctx)
+	page, err := r.listPage(db, opts)
+	if err = finish(err); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+func (r *readRepository[T]) listPage(db *gorm.DB, opts QueryOptions) (*Page[T], error) {
+	query, err := r.filter(db, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	order, err := r.orderBy(opts.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := opts.normalizedLimit()
+	page := &Page[T]{Total: total, Limit: limit}
+	if opts.Cursor != "" {
+		values, err := decodeCursor(opts.Cursor, len(order))
+		if err != nil {
+			return nil, err
+		}
+		for i, o := range order {
+			if s, ok := values[i].(string); ok && r.isTime(o.Column.Name) {
+				if values[i], err = parseTime(s); err != nil {
+					return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+				}
+			}
+		}
+		sql, args := keysetCondition(order, values, r.isNullable)
+		query = query.Where(sql, args...)
+	} else if opts.Offset > 0 {
+		page.Offset = opts.Offset
+		query = query.Offset(opts.Offset)
+	}
+
+	query = r.sorted(query, order)
+
 	var entities []T
-	err := r.db.Find(&entities).Error
-	return entities, err
+	if err := query.Limit(limit + 1).Find(&entities).Error; err != nil {
+		return nil, err
+	}
+
+	if len(entities) > limit {
+		entities = entities[:limit]
+		cursor, err := r.cursorFor(&entities[limit-1], order)
+		if err != nil {
+			return nil, err
+		}
+		page.NextCursor = cursor
+	}
+	page.Items = entities
+	return page, nil
+}
+
+// Stream calls fn for every entity matching the filters and sort order of
+// opts, reading rows one at a time rather than loading them all. Paging
+// options are ignored. Stopping early by returning an error from fn is
+// reported as that error.
+func (r *readRepository[T]) Stream(ctx context.Context, opts QueryOptions, fn func(*T) error) error {
+	db, finish := r.export(ctx)
+	return finish(r.stream(db, opts, fn))
+}
+
+func (r *readRepository[T]) stream(db *gorm.DB, opts QueryOptions, fn func(*T) error) error {
+	query, err := r.filter(db, opts)
+	if err != nil {
+		return err
+	}
+	order, err := r.orderBy(opts.Sort)
+	if err != nil {
+		return err
+	}
+	query = r.sorted(query, order)
+
+	rows, err := query.Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var entity T
+		if err := query.ScanRows(rows, &entity); err != nil {
+			return err
+		}
+		if err := fn(&entity); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// filter starts a query for the entities matching the filters of opts
+func (r *readRepository[T]) filter(db *gorm.DB, opts QueryOptions) (*gorm.DB, error) {
+	if opts.IncludeDeleted {
+		db = db.Unscoped()
+	}
+	query := r.restrict(db.Model(new(T)), opts.Scope)
+	for _, f := range opts.Filters {
+		column, err := r.column(f.Field)
+		if err != nil {
+			return nil, err
+		}
+		operator, ok := filterOperators[f.Op]
+		if !ok {
+			return nil, fmt.Errorf("%w: unsupported filter operator %q", ErrInvalidQuery, f.Op)
+		}
+		value := any(f.Value)
+		if f.Op == OpLike {
+			value = "%" + f.Value + "%"
+		} else if r.isTime(column) {
+			t, err := parseTime(f.Value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s must be a date or RFC 3339 timestamp", ErrInvalidQuery, f.Field)
+			}
+			value = t
+		}
+		query = query.Where(fmt.Sprintf("%s %s ?", column, operator), value)
+	}
+	return query.Session(&gorm.Session{}), nil
+}
+
+// isTime reports whether column holds timestamps, whose filter values must
+// be parsed rather than compared as text
+func (r *readRepository[T]) isTime(column string) bool {
+	stmt := &gorm.Statement{DB: r.db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return false
+	}
+	field := stmt.Schema.LookUpField(column)
+	return field != nil && field.FieldType == reflect.TypeOf(time.Time{})
+}
+
+// isNullable reports whether column may hold NULL, which sorts before every
+// value and must be matched with IS NULL rather than compared
+func (r *readRepository[T]) isNullable(column string) bool {
+	stmt := &gorm.Statement{DB: r.db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return false
+	}
+	field := stmt.Schema.LookUpField(column)
+	return field != nil && field.FieldType.Kind() == reflect.Ptr
+}
+
+// parseTime accepts RFC 3339 timestamps and plain dates (midnight UTC)
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, s)
+}
+
+// GetByID retrieves an entity by its ID, returning ErrNotFound if it does not exist
+func (r *readRepository[T]) GetByID(ctx context.Context, id uint) (*T, error) {
+	db, finish := r.read(ctx)
+	var entity T
+	err := finish(db.First(&entity, id).Error)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entity, nil
 }
 
-// GetByID retrieves an entity by its ID
-func (r *genericRepository[T]) GetByID(id uint) (*T, error) {
+// GetInScope is GetByID for an entity that must also be visible in scope;
+// one that is not is reported as ErrNotFound too
+func (r *readRepository[T]) GetInScope(ctx context.Context, scope Scope, id uint) (*T, error) {
+	db, finish := r.read(ctx)
 	var entity T
-	err := r.db.First(&entity, id).Error
+	err := finish(r.restrict(db.Model(new(T)), scope).First(&entity, id).Error)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
 	return &entity, nil
 }
 
-// Update modifies an existing entity
-func (r *genericRepository[T]) Update(entity *T) error {
-	return r.db.Save(entity).Error
+// Exists reports whether an entity with the given ID exists
+func (r *readRepository[T]) Exists(ctx context.Context, id uint) (bool, error) {
+	return r.ExistsInScope(ctx, Scope{}, id)
 }
 
-// Delete removes an entity by its ID
-func (r *genericRepository[T]) Delete(id uint) error {
-	var entity T
-	return r.db.Delete(&entity, id).Error
-} 
\ No newline at end of file
+// ExistsInScope reports whether an entity with the given ID exists and is
+// visible in scope
+func (r *readRepository[T]) ExistsInScope(ctx context.Context, scope Scope, id uint) (bool, error) {
+	db, finish := r.read(ctx)
+	var count int64
+	err := finish(r.restrict(db.Model(new(T)), scope).Where("id = ?", id).Count(&count).Error)
+	return count > 0, err
+}
+
+// restrict limits query to the rows visible in scope
+func (r *readRepository[T]) restrict(query *gorm.DB, scope Scope) *gorm.DB {
+	switch {
+	case !scope.Restricted():
+		return query
+	case r.scope == nil:
+		return query.Where("1 = 0")
+	default:
+		return r.scope(query, scope)
+	}
+}
+
+// Update overwrites every column of an existing entity, returning
+// ErrNotFound if no row has its ID. Entities with a version column are only
+// written if the stored version still matches theirs, otherwise
+// ErrVersionConflict is returned; on success the version is incremented.
+func (r *genericRepository[T]) Update(ctx context.Context, entity *T) error {
+	db, finish := r.write(ctx)
+	return finish(mutate(db, models.AuditUpdate, entity, primaryID(db, entity), func(tx *gorm.DB) error {
+		return updateAll(tx, entity)
+	}))
+}
+
+// Upsert updates the entity if a row with its ID exists and creates it with
+// that ID otherwise
+func (r *genericRepository[T]) Upsert(ctx context.Context, entity *T) (bool, error) {
+	return upsert(ctx, r.conn, entity, r.Update)
+}
+
+// Delete removes an entity by its ID, returning ErrNotFound if no row was
+// deleted. Entities with a DeletedAt field are soft-deleted.
+func (r *genericRepository[T]) Delete(ctx context.Context, id uint) error {
+	db, finish := r.write(ctx)
+	return finish(mutate(db, models.AuditDelete, new(T), id, func(tx *gorm.DB) error {
+		return checkAffected(tx.Delete(new(T), id))
+	}))
+}
+
+// Restore undoes the soft delete of an entity, returning ErrNotFound if
+// there is no deleted entity with that ID
+func (r *genericRepository[T]) Restore(ctx context.Context, id uint) error {
+	db, finish := r.write(ctx)
+	return finish(mutate(db, models.AuditRestore, new(T), id, func(tx *gorm.DB) error {
+		return restore(tx, new(T), id)
+	}))
+}
+
+// Purge permanently removes entities that were soft-deleted before the
+// given time and returns how many were removed
+func (r *genericRepository[T]) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	db, finish := r.write(ctx)
+	purged, err := purgeWhere[T](db, func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("deleted_at < ?", deletedBefore)
+	})
+	return purged, finish(err)
+}
+
+// updateAll writes all columns of entity except omitted ones, matching on its
+// primary key and, for versioned entities, on its version. tenant_id is
+// never written, so rows stay with the tenant that created them.
+func updateAll(db *gorm.DB, entity any, omit ...string) error {
+	query := db.Model(entity).Select("*").Omit(append([]string{"tenant_id"}, omit...)...)
+
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(entity); err != nil {
+		return err
+	}
+	version := stmt.Schema.LookUpField("version")
+	if version == nil {
+		return checkAffected(query.Updates(entity))
+	}
+
+	ctx := db.Statement.Context
+	rv := reflect.ValueOf(entity).Elem()
+	value, _ := version.ValueOf(ctx, rv)
+	expected, _ := value.(uint)
+	if err := version.Set(ctx, rv, expected+1); err != nil {
+		return err
+	}
+
+	err := checkAffected(query.Where(version.DBName+" = ?", expected).Updates(entity))
+	if err == nil {
+		return nil
+	}
+	version.Set(ctx, rv, expected)
+	if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	// No row matched: either the entity is gone or its version moved on
+	id, _ := stmt.Schema.PrioritizedPrimaryField.ValueOf(ctx, rv)
+	var count int64
+	if err := db.Session(&gorm.Session{NewDB: true}).Model(entity).Where(stmt.Schema.PrioritizedPrimaryField.DBName+" = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrVersionConflict
+	}
+	return ErrNotFound
+}
+
+// upsert runs update for an entity that already exists and inserts it with
+// its current ID otherwise. An ID the update cannot see but the insert
+// collides with, such as one of another tenant or of a deleted entity, is
+// reported as ErrNotFound rather than as a duplicate, so it does not reveal
+// that the ID is taken.
+func upsert[T any](ctx context.Context, c conn, entity *T, update func(context.Context, *T) error) (bool, error) {
+	err := update(ctx, entity)
+	if !errors.Is(err, ErrNotFound) {
+		return false, err
+	}
+
+	db, finish := c.write(ctx)
+	var created bool
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if created, err = insertWithID(tx, entity); err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return ErrNotFound
+			}
+			return err
+		}
+		return record(tx, models.AuditCreate, rowChange{after: entity})
+	})
+	return created, finish(err)
+}
+
+// insertWithID creates entity keeping the ID it already carries
+func insertWithID(db *gorm.DB, entity any) (bool, error) {
+	if err := db.Create(entity).Error; err != nil {
+		return false, err
+	}
+	// PostgreSQL does not advance the id sequence for explicit IDs
+	if db.Dialector.Name() == "postgres" {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(entity); err != nil {
+			return true, err
+		}
+		table := stmt.Schema.Table
+		err := db.Exec("SELECT setval(pg_get_serial_sequence(?, 'id'), (SELECT MAX(id) FROM "+stmt.Quote(table)+"))", table).Error
+		return true, err
+	}
+	return true, nil
+}
+
+// maxInValues bounds the values bound to one IN clause, keeping clear of
+// driver parameter limits (SQL Server allows about 2100)
+const maxInValues = 1000
+
+// findIn loads the rows of T whose column is one of values, querying in chunks
+func findIn[T, V any](db *gorm.DB, column string, values []V) ([]T, error) {
+	var all []T
+	for start := 0; start < len(values); start += maxInValues {
+		end := min(start+maxInValues, len(values))
+		var chunk []T
+		if err := db.Where(column+" IN ?", values[start:end]).Order("id").Find(&chunk).Error; err != nil {
+			return nil, err
+		}
+		all = append(all, chunk...)
+	}
+	return all, nil
+}
+
+// findBySourcedIDs loads the rows of T identified by sourced IDs. A row is
+// identified by its sourced_id, or by its numeric ID when it has no
+// sourced_id, so records that originated here keep a stable identifier.
+func findBySourcedIDs[T any](db *gorm.DB, sourcedIDs []string) ([]T, error) {
+	var numeric []uint
+	for _, id := range sourcedIDs {
+		if n, err := strconv.ParseUint(id, 10, 32); err == nil && n > 0 {
+			numeric = append(numeric, uint(n))
+		}
+	}
+	bySourcedID, err := findIn[T](db, "sourced_id", sourcedIDs)
+	if err != nil {
+		return nil, err
+	}
+	byID, err := findIn[T](db.Where("sourced_id = ?", "").Session(&gorm.Session{}), "id", numeric)
+	if err != nil {
+		return nil, err
+	}
+	return append(bySourcedID, byID...), nil
+}
+
+// restore clears deleted_at on the soft-deleted row of model with the given
+// ID, bumping its version if it has one
+func restore(db *gorm.DB, model any, id uint) error {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return err
+	}
+	updates := map[string]any{"deleted_at": nil}
+	if version := stmt.Schema.LookUpField("version"); version != nil {
+		updates[version.DBName] = gorm.Expr(version.DBName + " + 1")
+	}
+	return checkAffected(db.Unscoped().Model(model).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Updates(updates))
+}
+
+// primaryID returns the primary key of entity, zero if it has none
+func primaryID(db *gorm.DB, entity any) uint {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(entity); err != nil || stmt.Schema.PrioritizedPrimaryField == nil {
+		return 0
+	}
+	id, _ := stmt.Schema.PrioritizedPrimaryField.ValueOf(db.Statement.Context, reflect.ValueOf(entity).Elem())
+	n, _ := id.(uint)
+	return n
+}
+
+// checkAffected converts a write that touched no rows into ErrNotFound
+func checkAffected(result *gorm.DB) error {
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// column resolves a client-facing field name against the whitelist
+func (r *readRepository[T]) column(field string) (string, error) {
+	column, ok := r.fields[field]
+	if !ok {
+		return "", fmt.Errorf("%w: unknown field %q", ErrInvalidQuery, field)
+	}
+	return column, nil
+}
+
+// orderBy converts sort fields to columns, always ending with the primary
+// key so that pages are stable and cursors are unique
+func (r *readRepository[T]) orderBy(sort []SortField) ([]clause.OrderByColumn, error) {
+	order := make([]clause.OrderByColumn, 0, len(sort)+1)
+	hasID := false
+	for _, s := range sort {
+		column, err := r.column(s.Field)
+		if err != nil {
+			return nil, err
+		}
+		hasID = hasID || column == "id"
+		order = append(order, clause.OrderByColumn{Column: clause.Column{Name: column}, Desc: s.Desc})
+	}
+	if !hasID {
+		order = append(order, clause.OrderByColumn{Column: clause.Column{Name: "id"}})
+	}
+	return order, nil
+}
+
+// sorted applies order to query. Databases disagree on where NULLs go, so
+// nullable columns are first sorted on whether they are NULL, putting NULLs
+// first in ascending order and last in descending order everywhere, as
+// keysetCondition expects.
+func (r *readRepository[T]) sorted(query *gorm.DB, order []clause.OrderByColumn) *gorm.DB {
+	for _, o := range order {
+		if r.isNullable(o.Column.Name) {
+			query = query.Order(clause.OrderByColumn{
+				Column: clause.Column{Name: fmt.Sprintf("CASE WHEN %s IS NULL THEN 0 ELSE 1 END", o.Column.Name), Raw: true},
+				Desc:   o.Desc,
+			})
+		}
+		query = query.Order(o)
+	}
+	return query
+}
+
+// cursorFor encodes the sort key of entity so the next page can start after it
+func (r *readRepository[T]) cursorFor(entity *T, order []clause.OrderByColumn) (string, error) {
+	stmt := &gorm.Statement{DB: r.db}
+	if err := stmt.Parse(entity); err != nil {
+		return "", err
+	}
+
+	values := make([]any, len(order))
+	for i, o := range order {
+		field := stmt.Schema.LookUpField(o.Column.Name)
+		if field == nil {
+			return "", fmt.Errorf("%w: unknown column %q", ErrInvalidQuery, o.Column.Name)
+		}
+		values[i], _ = field.ValueOf(context.Background(), reflect.ValueOf(entity).Elem())
+	}
+	return encodeCursor(values)
+}
+
+// keysetCondition builds a WHERE clause selecting rows that sort after values:
+// (a > ?) OR (a = ? AND b > ?) OR ...
+// A NULL in a nullable column sorts before every value, as arranged by sorted,
+// so it is matched with IS NULL and followed by every non-NULL value.
+func keysetCondition(order []clause.OrderByColumn, values []any, nullable func(column string) bool) (string, []any) {
+	var (
+		terms []string
+		args  []any
+	)
+	for i, o := range order {
+		var (
+			parts    []string
+			termArgs []any
+		)
+		for j := 0; j < i; j++ {
+			if values[j] == nil {
+				parts = append(parts, order[j].Column.Name+" IS NULL")
+				continue
+			}
+			parts = append(parts, order[j].Column.Name+" = ?")
+			termArgs = append(termArgs, values[j])
+		}
+		switch {
+		case values[i] == nil && o.Desc:
+			// Nothing sorts after NULL in descending order
+			continue
+		case values[i] == nil:
+			parts = append(parts, o.Column.Name+" IS NOT NULL")
+		case o.Desc && nullable(o.Column.Name):
+			parts = append(parts, fmt.Sprintf("(%s < ? OR %s IS NULL)", o.Column.Name, o.Column.Name))
+			termArgs = append(termArgs, values[i])
+		default:
+			operator := ">"
+			if o.Desc {
+				operator = "<"
+			}
+			parts = append(parts, fmt.Sprintf("%s %s ?", o.Column.Name, operator))
+			termArgs = append(termArgs, values[i])
+		}
+		terms = append(terms, "("+strings.Join(parts, " AND ")+")")
+		args = append(args, termArgs...)
+	}
+	return "(" + strings.Join(terms, " OR ") + ")", args
+}
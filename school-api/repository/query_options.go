@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Paging defaults applied when the caller does not ask for a size
+const (
+	DefaultLimit = 50
+	MaxLimit     = 500
+)
+
+// BatchSize is the number of rows written per statement by batch operations
+const BatchSize = 100
+
+// FilterOp is a comparison applied by a Filter
+type FilterOp string
+
+// Supported filter operators
+const (
+	OpEq   FilterOp = "eq"
+	OpNe   FilterOp = "ne"
+	OpGt   FilterOp = "gt"
+	OpGte  FilterOp = "gte"
+	OpLt   FilterOp = "lt"
+	OpLte  FilterOp = "lte"
+	OpLike FilterOp = "like"
+)
+
+var filterOperators = map[FilterOp]string{
+	OpEq:   "=",
+	OpNe:   "<>",
+	OpGt:   ">",
+	OpGte:  ">=",
+	OpLt:   "<",
+	OpLte:  "<=",
+	OpLike: "LIKE",
+}
+
+// ParseFilterOp validates an operator name
+func ParseFilterOp(s string) (FilterOp, error) {
+	op := FilterOp(strings.ToLower(s))
+	if _, ok := filterOperators[op]; !ok {
+		return "", fmt.Errorf("%w: unsupported filter operator %q", ErrInvalidQuery, s)
+	}
+	return op, nil
+}
+
+// Filter restricts a list query to rows whose Field compares to Value
+type Filter struct {
+	Field string
+	Op    FilterOp
+	Value string
+}
+
+// SortField orders a list query by Field
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// QueryOptions describes paging, sorting and filtering for list queries.
+// Field names are the public (JSON) names and are checked against the
+// whitelist the repository was created with. When Cursor is set, Offset
+// is ignored and results continue after the row the cursor points at.
+// Soft-deleted rows are left out unless IncludeDeleted is set, and rows
+// outside Scope are always left out.
+type QueryOptions struct {
+	Limit          int
+	Offset         int
+	Cursor         string
+	Sort           []SortField
+	Filters        []Filter
+	IncludeDeleted bool
+	Scope          Scope
+}
+
+// Page is one page of list results
+type Page[T any] struct {
+	Items      []T
+	Total      int64
+	Limit      int
+	Offset     int
+	NextCursor string
+}
+
+// HasMore reports whether there are results after this page
+func (p *Page[T]) HasMore() bool {
+	return p.NextCursor != ""
+}
+
+// normalizedLimit clamps the requested limit to the allowed range
+func (o QueryOptions) normalizedLimit() int {
+	switch {
+	case o.Limit <= 0:
+		return DefaultLimit
+	case o.Limit > MaxLimit:
+		return MaxLimit
+	default:
+		return o.Limit
+	}
+}
+
+// encodeCursor packs the sort key values of the last row of a page
+func encodeCursor(values []any) (string, error) {
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor unpacks a cursor produced by encodeCursor
+func decodeCursor(cursor string, want int) ([]any, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.UseNumber()
+	var values []any
+	if err := dec.Decode(&values); err != nil || len(values) != want {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+
+	for i, v := range values {
+		if n, ok := v.(json.Number); ok {
+			if iv, err := n.Int64(); err == nil {
+				values[i] = iv
+			} else if fv, err := n.Float64(); err == nil {
+				values[i] = fv
+			}
+		}
+	}
+	return values, nil
+}
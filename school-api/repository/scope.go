@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"school-api/models"
+
+	"gorm.io/gorm"
+)
+
+// ScopeKind says whose view of the data a Scope gives
+type ScopeKind string
+
+// Scope kinds; the zero kind sees everything and ScopeNone nothing
+const (
+	ScopeAll      ScopeKind = ""
+	ScopeNone     ScopeKind = "none"
+	ScopeTeacher  ScopeKind = "teacher"
+	ScopeGuardian ScopeKind = "guardian"
+	ScopeStudent  ScopeKind = "student"
+)
+
+// Scope restricts queries to the rows a user may see. A teacher sees the
+// classes they teach and the students in them, a guardian their linked
+// students and those students' classes, and a student their own record and
+// class. UserID is the user whose view it is.
+type Scope struct {
+	Kind   ScopeKind
+	UserID uint
+}
+
+// Restricted reports whether the scope hides any rows
+func (s Scope) Restricted() bool {
+	return s.Kind != ScopeAll
+}
+
+// ScopeFunc adds the conditions of a restricted scope to a query on one
+// table. db is the query being built; subqueries start from a new session.
+type ScopeFunc func(db *gorm.DB, scope Scope) *gorm.DB
+
+// scopeStudents limits a query on students to those visible in scope
+func scopeStudents(db *gorm.DB, scope Scope) *gorm.DB {
+	sub := db.Session(&gorm.Session{NewDB: true})
+	switch scope.Kind {
+	case ScopeTeacher:
+		taught := sub.Model(&models.Class{}).Select("id").Where("teacher_id = ?", scope.UserID)
+		return db.Where("students.class_id IN (?)", taught)
+	case ScopeGuardian:
+		linked := sub.Model(&models.GuardianLink{}).Select("student_id").Where("user_id = ?", scope.UserID)
+		return db.Where("students.id IN (?)", linked)
+	case ScopeStudent:
+		self := sub.Model(&models.User{}).Select("student_id").Where("id = ?", scope.UserID)
+		return db.Where("students.id IN (?)", self)
+	default:
+		return db.Where("1 = 0")
+	}
+}
+
+// scopeClasses limits a query on classes to those visible in scope
+func scopeClasses(db *gorm.DB, scope Scope) *gorm.DB {
+	switch scope.Kind {
+	case ScopeTeacher:
+		return db.Where("classes.teacher_id = ?", scope.UserID)
+	case ScopeGuardian, ScopeStudent:
+		sub := db.Session(&gorm.Session{NewDB: true})
+		enrolled := scopeStudents(sub.Model(&models.Student{}).Select("class_id"), scope)
+		return db.Where("classes.id IN (?)", enrolled)
+	default:
+		return db.Where("1 = 0")
+	}
+}
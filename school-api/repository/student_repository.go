@@ -1,24 +1,168 @@
 package repository
 
 import (
+	"context"
 	"school-api/models"
+	"time"
 	"gorm.io/gorm"
 )
 
 type StudentRepository interface {
-	Create(student *models.Student) error
-	GetAll() ([]models.Student, error)
-	GetByID(id uint) (*models.Student, error)
-	Update(student *models.Student) error
-	Delete(id uint) error
+	Create(ctx context.Context, student *models.Student) error
+	CreateBatch(ctx context.Context, students []models.Student) error
+	List(ctx context.Context, opts QueryOptions) (*Page[models.Student], error)
+	Stream(ctx context.Context, opts QueryOptions, fn func(*models.Student) error) error
+	GetByID(ctx context.Context, id uint) (*models.Student, error)
+	GetInScope(ctx context.Context, scope Scope, id uint) (*models.Student, error)
+	Exists(ctx context.Context, id uint) (bool, error)
+	ExistsInScope(ctx context.Context, scope Scope, id uint) (bool, error)
+	Update(ctx context.Context, student *models.Student) error
+	Upsert(ctx context.Context, student *models.Student) (created bool, err error)
+	Delete(ctx context.Context, id uint) error
+	Restore(ctx context.Context, id uint) error
+	Purge(ctx context.Context, deletedBefore time.Time) (int64, error)
+	CountByClass(ctx context.Context, classID uint) (int64, error)
+	ReassignClass(ctx context.Context, fromClassID, toClassID uint) (int64, error)
+	DeleteByClass(ctx context.Context, classID uint) (int64, error)
+	FindByIDs(ctx context.Context, ids []uint) ([]models.Student, error)
+	FindByClasses(ctx context.Context, classIDs []uint) ([]models.Student, error)
+	FindBySourcedIDs(ctx context.Context, sourcedIDs []string) ([]models.Student, error)
+}
+
+// studentQueryFields are the fields clients may sort and filter students by
+var studentQueryFields = map[string]string{
+	"id":              "id",
+	"student_name":    "student_name",
+	"class_id":        "class_id",
+	"student_section": "secsion",
+	"sourced_id":      "sourced_id",
+	"updated_at":      "updated_at",
 }
 
 type studentRepository struct {
 	GenericRepository[models.Student]
+	conn
 }
 
-func NewStudentRepository(db *gorm.DB) StudentRepository {
+func NewStudentRepository(db *gorm.DB, timeouts Timeouts) StudentRepository {
 	return &studentRepository{
-		GenericRepository: NewGenericRepository[models.Student](db),
+		GenericRepository: NewGenericRepository[models.Student](db, timeouts, studentQueryFields, scopeStudents),
+		conn:              conn{db: db, timeouts: timeouts},
 	}
-} 
\ No newline at end of file
+}
+
+// Update modifies an existing student. sourced_id is only set when a
+// student is created and is never written from the entity.
+func (r *studentRepository) Update(ctx context.Context, student *models.Student) error {
+	db, finish := r.write(ctx)
+	return finish(mutate(db, models.AuditUpdate, student, student.ID, func(tx *gorm.DB) error {
+		return updateAll(tx, student, "sourced_id")
+	}))
+}
+
+// Upsert updates the student if it exists and creates it with its ID otherwise
+func (r *studentRepository) Upsert(ctx context.Context, student *models.Student) (bool, error) {
+	return upsert(ctx, r.conn, student, r.Update)
+}
+
+// CountByClass returns the number of students enrolled in a class
+func (r *studentRepository) CountByClass(ctx context.Context, classID uint) (int64, error) {
+	db, finish := r.read(ctx)
+	var count int64
+	err := db.Model(&models.Student{}).Where("class_id = ?", classID).Count(&count).Error
+	return count, finish(err)
+}
+
+// ReassignClass moves every student in one class to another and returns
+// how many were moved. Only the students found in the class are moved, so
+// the audit log records exactly the rows that changed.
+func (r *studentRepository) ReassignClass(ctx context.Context, fromClassID, toClassID uint) (int64, error) {
+	db, finish := r.write(ctx)
+	var moved int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var before []models.Student
+		if err := tx.Where("class_id = ?", fromClassID).Order("id").Find(&before).Error; err != nil {
+			return err
+		}
+		if len(before) == 0 {
+			return nil
+		}
+		ids := make([]uint, len(before))
+		for i, s := range before {
+			ids[i] = s.ID
+		}
+		for start := 0; start < len(ids); start += maxInValues {
+			end := min(start+maxInValues, len(ids))
+			result := tx.Model(&models.Student{}).Where("id IN ?", ids[start:end]).Updates(map[string]any{
+				"class_id": toClassID,
+				"version":  gorm.Expr("version + 1"),
+			})
+			if result.Error != nil {
+				return result.Error
+			}
+			moved += result.RowsAffected
+		}
+
+		after, err := findIn[models.Student](tx, "id", ids)
+		if err != nil {
+			return err
+		}
+		byID := make(map[uint]*models.Student, len(after))
+		for i := range after {
+			byID[after[i].ID] = &after[i]
+		}
+		changes := make([]rowChange, 0, len(before))
+		for i := range before {
+			if a, ok := byID[before[i].ID]; ok {
+				changes = append(changes, rowChange{before: &before[i], after: a})
+			}
+		}
+		return record(tx, models.AuditUpdate, changes...)
+	})
+	return moved, finish(err)
+}
+
+// DeleteByClass removes every student in a class and returns how many were deleted
+func (r *studentRepository) DeleteByClass(ctx context.Context, classID uint) (int64, error) {
+	db, finish := r.write(ctx)
+	var deleted int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var before []models.Student
+		if err := tx.Where("class_id = ?", classID).Find(&before).Error; err != nil {
+			return err
+		}
+		result := tx.Where("class_id = ?", classID).Delete(&models.Student{})
+		if result.Error != nil {
+			return result.Error
+		}
+		deleted = result.RowsAffected
+		changes := make([]rowChange, len(before))
+		for i := range before {
+			changes[i] = rowChange{before: &before[i]}
+		}
+		return record(tx, models.AuditDelete, changes...)
+	})
+	return deleted, finish(err)
+}
+
+// FindByIDs returns the students with the given IDs; missing IDs are skipped
+func (r *studentRepository) FindByIDs(ctx context.Context, ids []uint) ([]models.Student, error) {
+	db, finish := r.list(ctx)
+	students, err := findIn[models.Student](db, "id", ids)
+	return students, finish(err)
+}
+
+// FindByClasses returns every student enrolled in one of the given classes
+func (r *studentRepository) FindByClasses(ctx context.Context, classIDs []uint) ([]models.Student, error) {
+	db, finish := r.list(ctx)
+	students, err := findIn[models.Student](db, "class_id", classIDs)
+	return students, finish(err)
+}
+
+// FindBySourcedIDs returns the students identified by the given sourced IDs
+// (see findBySourcedIDs)
+func (r *studentRepository) FindBySourcedIDs(ctx context.Context, sourcedIDs []string) ([]models.Student, error) {
+	db, finish := r.list(ctx)
+	students, err := findBySourcedIDs[models.Student](db, sourcedIDs)
+	return students, finish(err)
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+	"school-api/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+type tenantKey struct{}
+
+// WithTenant returns a copy of ctx whose queries are confined to tenant.
+// Every query on a model with a TenantID field run with the context only
+// sees and changes rows of that tenant, and rows it creates belong to it.
+func WithTenant(ctx context.Context, tenant *models.Tenant) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFrom returns the tenant stored in ctx by WithTenant. Contexts
+// without one, such as those of scheduled jobs, reach every tenant.
+func TenantFrom(ctx context.Context) (*models.Tenant, bool) {
+	t, ok := ctx.Value(tenantKey{}).(*models.Tenant)
+	return t, ok && t != nil
+}
+
+// withoutTenant returns a copy of ctx that reaches every tenant, for the
+// few lookups, such as of usernames, that are global by design
+func withoutTenant(ctx context.Context) context.Context {
+	return context.WithValue(ctx, tenantKey{}, (*models.Tenant)(nil))
+}
+
+// TenantPlugin is the gorm plugin that enforces WithTenant. It works on
+// the statements gorm builds, so it covers the generic repository and
+// every other query alike, including subqueries.
+//
+// It cannot see into SQL passed to Raw or Exec, which therefore runs
+// unfiltered and must add the tenant condition itself. A context without a
+// tenant reaches every tenant's rows, so request paths must always go
+// through WithTenant.
+type TenantPlugin struct{}
+
+func (TenantPlugin) Name() string {
+	return "school-api:tenant"
+}
+
+func (TenantPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Create().Before("gorm:create").Register("tenant:create", assignTenant); err != nil {
+		return err
+	}
+	if err := cb.Query().Before("gorm:query").Register("tenant:query", filterTenant); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("tenant:update", filterTenant); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:delete").Register("tenant:delete", filterTenant); err != nil {
+		return err
+	}
+	return cb.Row().Before("gorm:row").Register("tenant:row", filterTenant)
+}
+
+// tenantField returns the TenantID field of the statement's model and the
+// tenant of its context, if both exist
+func tenantField(db *gorm.DB) (*models.Tenant, *schema.Field, bool) {
+	tenant, ok := TenantFrom(db.Statement.Context)
+	if !ok || db.Statement.Schema == nil {
+		return nil, nil, false
+	}
+	field := db.Statement.Schema.LookUpField("TenantID")
+	return tenant, field, field != nil
+}
+
+// filterTenant limits a query, update or delete to the rows of the tenant
+func filterTenant(db *gorm.DB) {
+	tenant, field, ok := tenantField(db)
+	if !ok {
+		return
+	}
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: tenant.ID},
+	}})
+}
+
+// assignTenant makes created rows belong to the tenant, whatever the
+// caller set
+func assignTenant(db *gorm.DB) {
+	tenant, field, ok := tenantField(db)
+	if !ok {
+		return
+	}
+	set := func(rv reflect.Value) {
+		if err := field.Set(db.Statement.Context, rv, tenant.ID); err != nil {
+			db.AddError(err)
+		}
+	}
+	switch rv := reflect.Indirect(db.Statement.ReflectValue); rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			set(reflect.Indirect(rv.Index(i)))
+		}
+	case reflect.Struct:
+		set(rv)
+	}
+}
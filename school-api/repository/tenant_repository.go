@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"school-api/models"
+
+	"gorm.io/gorm"
+)
+
+type TenantRepository interface {
+	Create(ctx context.Context, tenant *models.Tenant) error
+	List(ctx context.Context) ([]models.Tenant, error)
+	GetByID(ctx context.Context, id uint) (*models.Tenant, error)
+	GetBySlug(ctx context.Context, slug string) (*models.Tenant, error)
+	Update(ctx context.Context, tenant *models.Tenant) error
+}
+
+type tenantRepository struct {
+	conn
+}
+
+func NewTenantRepository(db *gorm.DB, timeouts Timeouts) TenantRepository {
+	return &tenantRepository{conn: conn{db: db, timeouts: timeouts}}
+}
+
+// Create adds a new tenant
+func (r *tenantRepository) Create(ctx context.Context, tenant *models.Tenant) error {
+	db, finish := r.write(ctx)
+	return finish(db.Create(tenant).Error)
+}
+
+// List returns every tenant ordered by slug
+func (r *tenantRepository) List(ctx context.Context) ([]models.Tenant, error) {
+	db, finish := r.list(ctx)
+	var tenants []models.Tenant
+	err := finish(db.Order("slug").Find(&tenants).Error)
+	return tenants, err
+}
+
+// GetByID finds a tenant by ID, returning ErrNotFound if there is none
+func (r *tenantRepository) GetByID(ctx context.Context, id uint) (*models.Tenant, error) {
+	db, finish := r.read(ctx)
+	var tenant models.Tenant
+	err := finish(db.First(&tenant, id).Error)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tenant, nil
+}
+
+// GetBySlug finds a tenant by its lower-case slug
+func (r *tenantRepository) GetBySlug(ctx context.Context, slug string) (*models.Tenant, error) {
+	db, finish := r.read(ctx)
+	var tenant models.Tenant
+	err := finish(db.Where("slug = ?", slug).First(&tenant).Error)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tenant, nil
+}
+
+// Update stores a tenant's name, status and settings. The slug is left alone.
+func (r *tenantRepository) Update(ctx context.Context, tenant *models.Tenant) error {
+	db, finish := r.write(ctx)
+	return finish(checkAffected(db.Model(tenant).
+		Select("name", "disabled", "delete_policy", "reassign_to",
+			"oneroster_org_sourced_id", "oneroster_org_name", "updated_at").
+		Updates(tenant)))
+}
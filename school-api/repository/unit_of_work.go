@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// Repositories gives access to every repository through one database
+// handle, which may be a transaction
+type Repositories interface {
+	Classes() ClassRepository
+	Students() StudentRepository
+	Users() UserRepository
+	RefreshTokens() RefreshTokenRepository
+	APIKeys() APIKeyRepository
+	Tenants() TenantRepository
+	Audit() AuditRepository
+	Enrollments() EnrollmentRepository
+}
+
+// UnitOfWork hands out repositories and runs work that spans several of
+// them in a single transaction
+type UnitOfWork interface {
+	Repositories
+
+	// Do runs fn in a transaction and passes it repositories bound to that
+	// transaction. The transaction is committed if fn returns nil and rolled
+	// back if it returns an error or panics. Calling Do again with the ctx
+	// given to fn nests the work in a savepoint, so an inner failure rolls
+	// back only the inner work.
+	Do(ctx context.Context, fn func(ctx context.Context, repos Repositories) error) error
+}
+
+type txKey struct{}
+
+// txFromContext returns the transaction started by Do for ctx, if any
+func txFromContext(ctx context.Context) (*gorm.DB, bool) {
+	tx, ok := ctx.Value(txKey{}).(*gorm.DB)
+	return tx, ok
+}
+
+type repositories struct {
+	classes       ClassRepository
+	students      StudentRepository
+	users         UserRepository
+	refreshTokens RefreshTokenRepository
+	apiKeys       APIKeyRepository
+	tenants       TenantRepository
+	audit         AuditRepository
+	enrollments   EnrollmentRepository
+}
+
+func newRepositories(db *gorm.DB, timeouts Timeouts) *repositories {
+	return &repositories{
+		classes:       NewClassRepository(db, timeouts),
+		students:      NewStudentRepository(db, timeouts),
+		users:         NewUserRepository(db, timeouts),
+		refreshTokens: NewRefreshTokenRepository(db, timeouts),
+		apiKeys:       NewAPIKeyRepository(db, timeouts),
+		tenants:       NewTenantRepository(db, timeouts),
+		audit:         NewAuditRepository(db, timeouts),
+		enrollments:   NewEnrollmentRepository(db, timeouts),
+	}
+}
+
+func (r *repositories) Classes() ClassRepository {
+	return r.classes
+}
+
+func (r *repositories) Students() StudentRepository {
+	return r.students
+}
+
+func (r *repositories) Users() UserRepository {
+	return r.users
+}
+
+func (r *repositories) RefreshTokens() RefreshTokenRepository {
+	return r.refreshTokens
+}
+
+func (r *repositories) APIKeys() APIKeyRepository {
+	return r.apiKeys
+}
+
+func (r *repositories) Tenants() TenantRepository {
+	return r.tenants
+}
+
+func (r *repositories) Audit() AuditRepository {
+	return r.audit
+}
+
+func (r *repositories) Enrollments() EnrollmentRepository {
+	return r.enrollments
+}
+
+type unitOfWork struct {
+	*repositories
+	db       *gorm.DB
+	timeouts Timeouts
+}
+
+// NewUnitOfWork creates a unit of work over db. Repositories it hands out
+// outside Do run each statement on its own.
+func NewUnitOfWork(db *gorm.DB, timeouts Timeouts) UnitOfWork {
+	return &unitOfWork{
+		repositories: newRepositories(db, timeouts),
+		db:           db,
+		timeouts:     timeouts,
+	}
+}
+
+func (u *unitOfWork) Do(ctx context.Context, fn func(ctx context.Context, repos Repositories) error) error {
+	db := u.db
+	if tx, ok := txFromContext(ctx); ok {
+		// gorm turns a transaction started inside another into a savepoint
+		db = tx
+	}
+
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		txCtx := context.WithValue(ctx, txKey{}, tx)
+		return fn(txCtx, newRepositories(tx, u.timeouts))
+	})
+}
@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"school-api/auth"
+	"school-api/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type UserRepository interface {
+	Create(ctx context.Context, user *models.User) error
+	GetByID(ctx context.Context, id uint) (*models.User, error)
+	GetByUsername(ctx context.Context, username string) (*models.User, error)
+	List(ctx context.Context) ([]models.User, error)
+	Update(ctx context.Context, user *models.User) error
+	CountEnabled(ctx context.Context, role auth.Role) (int64, error)
+	GuardianStudentIDs(ctx context.Context, userID uint) ([]uint, error)
+	SetGuardianStudents(ctx context.Context, userID uint, studentIDs []uint) error
+}
+
+type userRepository struct {
+	conn
+}
+
+func NewUserRepository(db *gorm.DB, timeouts Timeouts) UserRepository {
+	return &userRepository{conn: conn{db: db, timeouts: timeouts}}
+}
+
+// Create adds a new user
+func (r *userRepository) Create(ctx context.Context, user *models.User) error {
+	db, finish := r.write(ctx)
+	return finish(db.Create(user).Error)
+}
+
+// GetByID finds a user by ID, returning ErrNotFound if there is none
+func (r *userRepository) GetByID(ctx context.Context, id uint) (*models.User, error) {
+	db, finish := r.read(ctx)
+	var user models.User
+	err := finish(db.First(&user, id).Error)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetByUsername finds a user by their lower-case username. Usernames are
+// unique across tenants, so every tenant is searched.
+func (r *userRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	db, finish := r.read(withoutTenant(ctx))
+	var user models.User
+	err := finish(db.Where("username = ?", username).First(&user).Error)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// List returns every user ordered by username
+func (r *userRepository) List(ctx context.Context) ([]models.User, error) {
+	db, finish := r.list(ctx)
+	var users []models.User
+	err := finish(db.Order("username").Find(&users).Error)
+	return users, err
+}
+
+// Update stores a user's role, student link and disabled flag. The
+// username and password hash are left alone.
+func (r *userRepository) Update(ctx context.Context, user *models.User) error {
+	db, finish := r.write(ctx)
+	return finish(checkAffected(db.Model(user).
+		Select("role", "student_id", "disabled", "updated_at").
+		Updates(user)))
+}
+
+// CountEnabled returns how many users with the given role are not disabled
+func (r *userRepository) CountEnabled(ctx context.Context, role auth.Role) (int64, error) {
+	db, finish := r.read(ctx)
+	var count int64
+	err := finish(db.Model(&models.User{}).Where("role = ? AND disabled = ?", string(role), false).Count(&count).Error)
+	return count, err
+}
+
+// GuardianStudentIDs returns the IDs of the students linked to a guardian
+func (r *userRepository) GuardianStudentIDs(ctx context.Context, userID uint) ([]uint, error) {
+	db, finish := r.read(ctx)
+	var ids []uint
+	err := finish(db.Model(&models.GuardianLink{}).
+		Where("user_id = ?", userID).
+		Order("student_id").
+		Pluck("student_id", &ids).Error)
+	return ids, err
+}
+
+// SetGuardianStudents replaces the students linked to a guardian
+func (r *userRepository) SetGuardianStudents(ctx context.Context, userID uint, studentIDs []uint) error {
+	db, finish := r.write(ctx)
+	err := db.Where("user_id = ?", userID).Delete(&models.GuardianLink{}).Error
+	if err == nil && len(studentIDs) > 0 {
+		links := make([]models.GuardianLink, len(studentIDs))
+		for i, id := range studentIDs {
+			links[i] = models.GuardianLink{UserID: userID, StudentID: id}
+		}
+		err = db.Create(&links).Error
+	}
+	return finish(err)
+}
+
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, token *models.RefreshToken) error
+	GetByHash(ctx context.Context, hash string) (*models.RefreshToken, error)
+	Revoke(ctx context.Context, id uint) (bool, error)
+	RevokeAll(ctx context.Context, userID uint) error
+	Purge(ctx context.Context, expiredBefore time.Time) (int64, error)
+}
+
+type refreshTokenRepository struct {
+	conn
+}
+
+func NewRefreshTokenRepository(db *gorm.DB, timeouts Timeouts) RefreshTokenRepository {
+	return &refreshTokenRepository{conn: conn{db: db, timeouts: timeouts}}
+}
+
+// Create stores a newly issued refresh token
+func (r *refreshTokenRepository) Create(ctx context.Context, token *models.RefreshToken) error {
+	db, finish := r.write(ctx)
+	return finish(db.Create(token).Error)
+}
+
+// GetByHash finds a refresh token, revoked or not, by its hash
+func (r *refreshTokenRepository) GetByHash(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	db, finish := r.read(ctx)
+	var token models.RefreshToken
+	err := finish(db.Where("token_hash = ?", hash).First(&token).Error)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// Revoke revokes a token and reports whether this call revoked it. Of two
+// concurrent calls for the same token only one gets true.
+func (r *refreshTokenRepository) Revoke(ctx context.Context, id uint) (bool, error) {
+	db, finish := r.write(ctx)
+	result := db.Model(&models.RefreshToken{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", db.NowFunc())
+	return result.RowsAffected == 1, finish(result.Error)
+}
+
+// RevokeAll revokes every outstanding token of a user
+func (r *refreshTokenRepository) RevokeAll(ctx context.Context, userID uint) error {
+	db, finish := r.write(ctx)
+	return finish(db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", db.NowFunc()).Error)
+}
+
+// Purge removes tokens that expired before the given time
+func (r *refreshTokenRepository) Purge(ctx context.Context, expiredBefore time.Time) (int64, error) {
+	db, finish := r.write(ctx)
+	result := db.Where("expires_at < ?", expiredBefore).Delete(&models.RefreshToken{})
+	return result.RowsAffected, finish(result.Error)
+}
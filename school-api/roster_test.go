@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"school-api/auth"
+	"school-api/dto"
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestRosterAtPointInTime checks that a class roster lists the students in
+// the class at the time asked for, whatever its time zone, as they move
+// class and are deleted
+func TestRosterAtPointInTime(t *testing.T) {
+	a := newTestApp(t)
+	token := a.login("default", "admin", auth.RoleAdmin)
+	createClass := func(name string) uint {
+		return decode[dto.ClassResponse](t, expect(t, a.do(t, token, http.MethodPost, "/api/classes",
+			dto.CreateClassRequest{ClassName: name}), http.StatusCreated)).ID
+	}
+	createStudent := func(name string, classID uint) uint {
+		return decode[dto.StudentResponse](t, expect(t, a.do(t, token, http.MethodPost, "/api/students",
+			dto.CreateStudentRequest{StudentName: name, ClassID: classID, Section: "A"}), http.StatusCreated)).ID
+	}
+	// instant returns a time between the changes before and after it, in a
+	// zone other than UTC
+	instant := func() time.Time {
+		time.Sleep(10 * time.Millisecond)
+		defer time.Sleep(10 * time.Millisecond)
+		return time.Now().In(time.FixedZone("UTC-5", -5*60*60))
+	}
+
+	grade5, grade6 := createClass("Grade 5"), createClass("Grade 6")
+	beforeAll := instant()
+	jane, john := createStudent("Jane Doe", grade5), createStudent("John Doe", grade5)
+	afterJoining := instant()
+	expect(t, a.do(t, token, http.MethodPut, fmt.Sprintf("/api/students/%d", jane),
+		dto.UpdateStudentRequest{StudentName: "Jane Doe", ClassID: grade6, Section: "A"}), http.StatusOK)
+	afterMove := instant()
+	expect(t, a.do(t, token, http.MethodDelete, fmt.Sprintf("/api/students/%d", john), nil), http.StatusNoContent)
+
+	roster := func(t *testing.T, classID uint, at time.Time) []uint {
+		t.Helper()
+		path := fmt.Sprintf("/api/classes/%d/roster", classID)
+		if !at.IsZero() {
+			path += "?as_of=" + url.QueryEscape(at.Format(time.RFC3339Nano))
+		}
+		got := decode[dto.RosterResponse](t, expect(t, a.do(t, token, http.MethodGet, path, nil), http.StatusOK))
+		var ids []uint
+		for _, s := range got.Students {
+			ids = append(ids, s.StudentID)
+		}
+		return ids
+	}
+	for _, tc := range []struct {
+		name    string
+		classID uint
+		at      time.Time
+		want    []uint
+	}{
+		{"before anyone joined", grade5, beforeAll, nil},
+		{"after joining", grade5, afterJoining, []uint{jane, john}},
+		{"not yet moved to", grade6, afterJoining, nil},
+		{"after moving out", grade5, afterMove, []uint{john}},
+		{"after moving in", grade6, afterMove, []uint{jane}},
+		{"after deletion", grade5, time.Time{}, nil},
+		{"now", grade6, time.Time{}, []uint{jane}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := roster(t, tc.classID, tc.at); !slices.Equal(got, tc.want) {
+				t.Errorf("class %d had students %v at %v, want %v", tc.classID, got, tc.at, tc.want)
+			}
+		})
+	}
+}
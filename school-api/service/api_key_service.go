@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"school-api/apperror"
+	"school-api/auth"
+	"school-api/models"
+	"school-api/repository"
+	"school-api/validation"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CodeInvalidAPIKey is returned for API keys that are unknown, expired or revoked
+const CodeInvalidAPIKey apperror.Code = "invalid_api_key"
+
+var (
+	// ErrAPIKeyNotFound is returned when the API key being operated on does not exist
+	ErrAPIKeyNotFound = apperror.NotFound("API key not found")
+	// ErrInvalidAPIKey is returned for API keys that are unknown, expired or revoked
+	ErrInvalidAPIKey = apperror.New(http.StatusUnauthorized, CodeInvalidAPIKey, "the API key is invalid, expired or revoked")
+	// ErrAPIKeyRevoked is returned when rotating a revoked key
+	ErrAPIKeyRevoked = apperror.Conflict("the API key has been revoked")
+)
+
+// NewAPIKey is the input to CreateKey
+type NewAPIKey struct {
+	Name      string   `json:"name" validate:"required,notblank,max=100"`
+	Scopes    []string `json:"scopes" validate:"required,min=1"`
+	ExpiresAt *time.Time
+}
+
+type APIKeyService interface {
+	// CreateKey creates an API key and returns it with the key itself,
+	// which is not stored and cannot be shown again
+	CreateKey(ctx context.Context, input NewAPIKey) (*models.APIKey, string, error)
+	ListKeys(ctx context.Context) ([]models.APIKey, error)
+	GetKey(ctx context.Context, id uint) (*models.APIKey, error)
+	// RotateKey gives a key a new secret and returns the new key. The old
+	// one keeps working for grace, so clients can switch over.
+	RotateKey(ctx context.Context, id uint, grace time.Duration) (*models.APIKey, string, error)
+	// RevokeKey stops a key from working at once
+	RevokeKey(ctx context.Context, id uint) error
+	// Authenticate checks an API key, counts its use and returns the
+	// principal requests made with it act as. Uses are written by
+	// FlushUsage, so checking a key never writes to the database.
+	Authenticate(ctx context.Context, key string) (*auth.Principal, error)
+	// FlushUsage writes the uses counted since the last flush. Uses that
+	// cannot be written are kept for the next one.
+	FlushUsage(ctx context.Context) error
+	// Run flushes usage every interval until ctx is done
+	Run(ctx context.Context, interval time.Duration)
+}
+
+type apiKeyService struct {
+	uow   repository.UnitOfWork
+	usage *keyUsage
+}
+
+func NewAPIKeyService(uow repository.UnitOfWork) APIKeyService {
+	return &apiKeyService{uow: uow, usage: &keyUsage{uses: make(map[uint]keyUse)}}
+}
+
+func (s *apiKeyService) CreateKey(ctx context.Context, input NewAPIKey) (*models.APIKey, string, error) {
+	if err := validation.Struct(input); err != nil {
+		return nil, "", err
+	}
+	scopes := slices.Clone(input.Scopes)
+	slices.Sort(scopes)
+	scopes = slices.Compact(scopes)
+	if _, err := auth.ScopePermissions(scopes); err != nil {
+		return nil, "", apperror.Validation("scopes contains an unknown scope",
+			apperror.FieldError{Field: "scopes", Message: "must be some of " + strings.Join(auth.APIKeyScopes(), ", ")})
+	}
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		return nil, "", apperror.Validation("expires_at is in the past",
+			apperror.FieldError{Field: "expires_at", Message: "must be in the future"})
+	}
+
+	secret, prefix, err := auth.NewAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+	key := &models.APIKey{
+		Name:       strings.TrimSpace(input.Name),
+		Prefix:     prefix,
+		SecretHash: auth.HashAPIKey(secret),
+		Scopes:     strings.Join(scopes, " "),
+		ExpiresAt:  input.ExpiresAt,
+	}
+	if p, ok := auth.PrincipalFrom(ctx); ok && !p.IsAPIKey() {
+		key.CreatedByID = &p.UserID
+	}
+	if err := s.uow.APIKeys().Create(ctx, key); err != nil {
+		return nil, "", err
+	}
+	return key, secret, nil
+}
+
+func (s *apiKeyService) ListKeys(ctx context.Context) ([]models.APIKey, error) {
+	return s.uow.APIKeys().List(ctx)
+}
+
+func (s *apiKeyService) GetKey(ctx context.Context, id uint) (*models.APIKey, error) {
+	return getAPIKey(ctx, s.uow, id)
+}
+
+func (s *apiKeyService) RotateKey(ctx context.Context, id uint, grace time.Duration) (*models.APIKey, string, error) {
+	var (
+		key    *models.APIKey
+		secret string
+	)
+	err := s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		var err error
+		if key, err = getAPIKey(ctx, repos, id); err != nil {
+			return err
+		}
+		if key.RevokedAt != nil {
+			return ErrAPIKeyRevoked
+		}
+		var prefix string
+		if secret, prefix, err = auth.NewAPIKey(); err != nil {
+			return err
+		}
+		// The prefix identifies the key, so the new key keeps the old one
+		secret = key.Prefix + strings.TrimPrefix(secret, prefix)
+
+		key.PreviousSecretHash, key.PreviousExpiresAt = "", nil
+		if grace > 0 {
+			until := time.Now().Add(grace)
+			key.PreviousSecretHash, key.PreviousExpiresAt = key.SecretHash, &until
+		}
+		key.SecretHash = auth.HashAPIKey(secret)
+		return repos.APIKeys().Rotate(ctx, key)
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return key, secret, nil
+}
+
+func (s *apiKeyService) RevokeKey(ctx context.Context, id uint) error {
+	return s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		if _, err := getAPIKey(ctx, repos, id); err != nil {
+			return err
+		}
+		return repos.APIKeys().Revoke(ctx, id)
+	})
+}
+
+func (s *apiKeyService) Authenticate(ctx context.Context, secret string) (*auth.Principal, error) {
+	prefix, _, ok := auth.ParseAPIKey(secret)
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+	key, err := s.uow.APIKeys().GetByPrefix(ctx, prefix)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	current := auth.CheckAPIKey(key.SecretHash, secret)
+	previous := key.PreviousExpiresAt != nil && now.Before(*key.PreviousExpiresAt) &&
+		auth.CheckAPIKey(key.PreviousSecretHash, secret)
+	switch {
+	case !current && !previous,
+		key.RevokedAt != nil,
+		key.ExpiresAt != nil && !now.Before(*key.ExpiresAt):
+		return nil, ErrInvalidAPIKey
+	}
+	perms, err := auth.ScopePermissions(strings.Fields(key.Scopes))
+	if err != nil {
+		// Scopes are checked when the key is created, so this only happens
+		// after a scope is retired; such a key grants nothing
+		log.Printf("API key %s: %v", key.Prefix, err)
+	}
+
+	s.usage.add(key.ID, now)
+	return &auth.Principal{Username: key.Name, TenantID: key.TenantID, APIKeyID: key.ID, Permissions: perms}, nil
+}
+
+func (s *apiKeyService) FlushUsage(ctx context.Context) error {
+	pending := s.usage.take()
+	for id, use := range pending {
+		if err := s.uow.APIKeys().RecordUse(ctx, id, use.last, use.count); err != nil {
+			s.usage.restore(pending)
+			return err
+		}
+		delete(pending, id)
+	}
+	return nil
+}
+
+func (s *apiKeyService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.FlushUsage(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("Recording API key usage failed: %v", err)
+			}
+		}
+	}
+}
+
+// keyUsage counts the uses of API keys until they are written
+type keyUsage struct {
+	mu   sync.Mutex
+	uses map[uint]keyUse
+}
+
+// keyUse is how often a key was used and when it was last used
+type keyUse struct {
+	count int64
+	last  time.Time
+}
+
+func (u *keyUsage) add(id uint, at time.Time) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	use := u.uses[id]
+	use.count++
+	if at.After(use.last) {
+		use.last = at
+	}
+	u.uses[id] = use
+}
+
+// take returns the uses counted so far and starts counting afresh
+func (u *keyUsage) take() map[uint]keyUse {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	uses := u.uses
+	u.uses = make(map[uint]keyUse)
+	return uses
+}
+
+// restore adds back uses that could not be written
+func (u *keyUsage) restore(uses map[uint]keyUse) {
+	for id, use := range uses {
+		u.mu.Lock()
+		current := u.uses[id]
+		current.count += use.count
+		if use.last.After(current.last) {
+			current.last = use.last
+		}
+		u.uses[id] = current
+		u.mu.Unlock()
+	}
+}
+
+// getAPIKey loads an API key, reporting ErrAPIKeyNotFound if it does not exist
+func getAPIKey(ctx context.Context, repos repository.Repositories, id uint) (*models.APIKey, error) {
+	key, err := repos.APIKeys().GetByID(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrAPIKeyNotFound
+	}
+	return key, err
+}
@@ -0,0 +1,24 @@
+package service
+
+import (
+	"context"
+	"school-api/models"
+	"school-api/repository"
+)
+
+type AuditService interface {
+	// ListEntries returns a page of the audit log of the tenant of ctx
+	ListEntries(ctx context.Context, opts repository.QueryOptions) (*repository.Page[models.AuditEntry], error)
+}
+
+type auditService struct {
+	uow repository.UnitOfWork
+}
+
+func NewAuditService(uow repository.UnitOfWork) AuditService {
+	return &auditService{uow: uow}
+}
+
+func (s *auditService) ListEntries(ctx context.Context, opts repository.QueryOptions) (*repository.Page[models.AuditEntry], error) {
+	return s.uow.Audit().List(ctx, opts)
+}
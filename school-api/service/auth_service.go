@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"school-api/auth"
+	"school-api/models"
+	"school-api/repository"
+	"strings"
+	"time"
+)
+
+// TokenPair is what a successful login or refresh returns: a short-lived
+// access token and the refresh token that replaces it when it expires
+type TokenPair struct {
+	AccessToken      string
+	ExpiresAt        time.Time
+	RefreshToken     string
+	RefreshExpiresAt time.Time
+}
+
+type AuthService interface {
+	// Login checks a username and password and issues tokens for the user
+	Login(ctx context.Context, username, password string) (*TokenPair, error)
+	// Refresh exchanges a refresh token for new tokens. Each refresh token
+	// works once; presenting a used one revokes every token of its user,
+	// since either the user or a thief holds a copy.
+	Refresh(ctx context.Context, refreshToken string) (*TokenPair, error)
+	// Logout revokes a refresh token. Unknown tokens are ignored.
+	Logout(ctx context.Context, refreshToken string) error
+	// GetUser returns the user with the given ID
+	GetUser(ctx context.Context, id uint) (*models.User, error)
+}
+
+type authService struct {
+	uow        repository.UnitOfWork
+	issuer     *auth.Issuer
+	refreshTTL time.Duration
+}
+
+func NewAuthService(uow repository.UnitOfWork, issuer *auth.Issuer, refreshTTL time.Duration) AuthService {
+	return &authService{uow: uow, issuer: issuer, refreshTTL: refreshTTL}
+}
+
+func (s *authService) Login(ctx context.Context, username, password string) (*TokenPair, error) {
+	user, err := s.uow.Users().GetByUsername(ctx, normalizeUsername(username))
+	if errors.Is(err, repository.ErrNotFound) {
+		auth.CheckPassword("", password)
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !auth.CheckPassword(user.PasswordHash, password) {
+		return nil, ErrInvalidCredentials
+	}
+	if user.Disabled {
+		return nil, ErrUserDisabled
+	}
+	return s.issue(ctx, s.uow, user)
+}
+
+func (s *authService) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	var (
+		pair   *TokenPair
+		reused bool
+	)
+	err := s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		stored, err := repos.RefreshTokens().GetByHash(ctx, auth.HashRefreshToken(refreshToken))
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrInvalidRefreshToken
+		}
+		if err != nil {
+			return err
+		}
+
+		revoked, err := repos.RefreshTokens().Revoke(ctx, stored.ID)
+		if err != nil {
+			return err
+		}
+		if !revoked {
+			// Committed rather than rolled back, so the revocation sticks
+			reused = true
+			return repos.RefreshTokens().RevokeAll(ctx, stored.UserID)
+		}
+		if time.Now().After(stored.ExpiresAt) {
+			return ErrInvalidRefreshToken
+		}
+
+		user, err := repos.Users().GetByID(ctx, stored.UserID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrInvalidRefreshToken
+		}
+		if err != nil {
+			return err
+		}
+		if user.Disabled {
+			return ErrUserDisabled
+		}
+		pair, err = s.issue(ctx, repos, user)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		return nil, ErrInvalidRefreshToken
+	}
+	return pair, nil
+}
+
+func (s *authService) Logout(ctx context.Context, refreshToken string) error {
+	stored, err := s.uow.RefreshTokens().GetByHash(ctx, auth.HashRefreshToken(refreshToken))
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = s.uow.RefreshTokens().Revoke(ctx, stored.ID)
+	return err
+}
+
+func (s *authService) GetUser(ctx context.Context, id uint) (*models.User, error) {
+	return getUser(ctx, s.uow, id)
+}
+
+// issue signs an access token for user and stores a new refresh token.
+// Users of a disabled tenant get ErrTenantDisabled.
+func (s *authService) issue(ctx context.Context, repos repository.Repositories, user *models.User) (*TokenPair, error) {
+	tenant, err := repos.Tenants().GetByID(ctx, user.TenantID)
+	if err != nil {
+		return nil, tenantError(err)
+	}
+	if tenant.Disabled {
+		return nil, ErrTenantDisabled
+	}
+	access, expires, err := s.issuer.Issue(auth.Principal{UserID: user.ID, Username: user.Username, Role: auth.Role(user.Role), TenantID: user.TenantID})
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := auth.NewRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	stored := &models.RefreshToken{
+		UserID:    user.ID,
+		TokenHash: auth.HashRefreshToken(refresh),
+		ExpiresAt: time.Now().Add(s.refreshTTL),
+	}
+	if err := repos.RefreshTokens().Create(ctx, stored); err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:      access,
+		ExpiresAt:        expires,
+		RefreshToken:     refresh,
+		RefreshExpiresAt: stored.ExpiresAt,
+	}, nil
+}
+
+// normalizeUsername returns the form usernames are stored and looked up in
+func normalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// normalizeRole lower-cases a role name so it is matched like usernames are
+func normalizeRole(role string) string {
+	return strings.ToLower(strings.TrimSpace(role))
+}
@@ -1,42 +1,418 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"school-api/apperror"
+	"school-api/auth"
 	"school-api/models"
 	"school-api/repository"
+	"school-api/validation"
+	"strings"
+	"time"
 )
 
 type ClassService interface {
-	CreateClass(class *models.Class) error
-	GetAllClasses() ([]models.Class, error)
-	GetClassByID(id uint) (*models.Class, error)
-	UpdateClass(class *models.Class) error
-	DeleteClass(id uint) error
+	CreateClass(ctx context.Context, class *models.Class) error
+	GetAllClasses(ctx context.Context, opts repository.QueryOptions) (*repository.Page[models.Class], error)
+	ExportClasses(ctx context.Context, opts repository.QueryOptions, fn func(*models.Class) error) error
+	GetClassByID(ctx context.Context, id uint) (*models.Class, error)
+	// GetRoster returns the enrollments of a class in effect at the given time
+	GetRoster(ctx context.Context, id uint, at time.Time) ([]models.Enrollment, error)
+	UpdateClass(ctx context.Context, class *models.Class) error
+	UpsertClass(ctx context.Context, class *models.Class) (created bool, err error)
+	PatchClass(ctx context.Context, id, version uint, apply func(class *models.Class) error) (*models.Class, error)
+	DeleteClass(ctx context.Context, id uint, opts DeleteClassOptions) error
+	RestoreClass(ctx context.Context, id uint) (*models.Class, error)
+}
+
+// DeletePolicy decides what happens to a class's students when the class is deleted
+type DeletePolicy string
+
+const (
+	// DeleteRestrict refuses to delete a class that still has students
+	DeleteRestrict DeletePolicy = "restrict"
+	// DeleteCascade deletes the class's students along with it
+	DeleteCascade DeletePolicy = "cascade"
+	// DeleteReassign moves the class's students to another class first
+	DeleteReassign DeletePolicy = "reassign"
+)
+
+// ParseDeletePolicy validates a delete policy name
+func ParseDeletePolicy(s string) (DeletePolicy, error) {
+	switch p := DeletePolicy(strings.ToLower(strings.TrimSpace(s))); p {
+	case DeleteRestrict, DeleteCascade, DeleteReassign:
+		return p, nil
+	default:
+		return "", ErrInvalidDeletePolicy
+	}
+}
+
+// DeleteClassOptions selects the delete policy. Zero fields fall back to the
+// service defaults. A non-zero Version must match the stored class.
+type DeleteClassOptions struct {
+	Policy     DeletePolicy
+	ReassignTo uint
+	Version    uint
 }
 
 type classService struct {
-	repo repository.ClassRepository
+	uow            repository.UnitOfWork
+	deleteDefaults DeleteClassOptions
+}
+
+func NewClassService(uow repository.UnitOfWork, deleteDefaults DeleteClassOptions) ClassService {
+	if deleteDefaults.Policy == "" {
+		deleteDefaults.Policy = DeleteRestrict
+	}
+	return &classService{
+		uow:            uow,
+		deleteDefaults: deleteDefaults,
+	}
+}
+
+// CreateClass stores a new class together with any students listed in
+// class.Students. Either all of them are created or none are.
+func (s *classService) CreateClass(ctx context.Context, class *models.Class) error {
+	// A new class has no students yet, whatever the client sent
+	class.StudentCount = 0
+	if err := validation.Struct(class); err != nil {
+		return err
+	}
+
+	students := class.Students
+	class.Students = nil
+	return s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		if err := checkTeacher(ctx, repos, class); err != nil {
+			return err
+		}
+		if err := repos.Classes().Create(ctx, class); err != nil {
+			return err
+		}
+		if len(students) == 0 {
+			return nil
+		}
+
+		for i := range students {
+			student := &students[i]
+			student.ID = 0
+			student.ClassId = class.ID
+			if err := validation.Struct(student); err != nil {
+				return nestFieldErrors(err, fmt.Sprintf("students[%d].", i))
+			}
+			if err := repos.Students().Create(ctx, student); err != nil {
+				return err
+			}
+		}
+		if err := repos.Classes().RefreshStudentCounts(ctx, class.ID); err != nil {
+			return err
+		}
+		if err := reloadClass(ctx, repos.Classes(), class); err != nil {
+			return err
+		}
+		class.Students = students
+		return nil
+	})
+}
+
+func (s *classService) GetAllClasses(ctx context.Context, opts repository.QueryOptions) (*repository.Page[models.Class], error) {
+	opts.Scope = scopeFrom(ctx)
+	return s.uow.Classes().List(ctx, opts)
+}
+
+// ExportClasses calls fn for every class matching opts, in order, without
+// loading them all at once
+func (s *classService) ExportClasses(ctx context.Context, opts repository.QueryOptions, fn func(*models.Class) error) error {
+	opts.Scope = scopeFrom(ctx)
+	return s.uow.Classes().Stream(ctx, opts, fn)
+}
+
+func (s *classService) GetClassByID(ctx context.Context, id uint) (*models.Class, error) {
+	return getClass(ctx, s.uow, id)
 }
 
-func NewClassService(repo repository.ClassRepository) ClassService {
-	return &classService{repo: repo}
+// GetRoster is only allowed for classes the caller can see. Teachers see
+// the whole roster of their classes; guardians and students only the
+// students they can see now.
+func (s *classService) GetRoster(ctx context.Context, id uint, at time.Time) ([]models.Enrollment, error) {
+	if _, err := getClass(ctx, s.uow, id); err != nil {
+		return nil, err
+	}
+	scope := scopeFrom(ctx)
+	if scope.Kind == repository.ScopeTeacher {
+		scope = repository.Scope{}
+	}
+	return s.uow.Enrollments().Roster(ctx, id, at, scope)
 }
 
-func (s *classService) CreateClass(class *models.Class) error {
-	return s.repo.Create(class)
+// UpdateClass replaces a class. A non-zero class.Version must match the
+// stored version; zero overwrites whatever is stored.
+func (s *classService) UpdateClass(ctx context.Context, class *models.Class) error {
+	if err := validation.Struct(class); err != nil {
+		return err
+	}
+	return s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		existing, err := getClass(ctx, repos, class.ID)
+		if err != nil {
+			return err
+		}
+		if class.Version == 0 {
+			class.Version = existing.Version
+		}
+		if err := checkTeacher(ctx, repos, class); err != nil {
+			return err
+		}
+		if err := repos.Classes().Update(ctx, class); err != nil {
+			return classWriteError(err)
+		}
+		return reloadClass(ctx, repos.Classes(), class)
+	})
 }
 
-func (s *classService) GetAllClasses() ([]models.Class, error) {
-	return s.repo.GetAll()
+// UpsertClass updates the class with class.ID or creates it if there is
+// none. A non-zero class.Version requires the class to exist at that version.
+func (s *classService) UpsertClass(ctx context.Context, class *models.Class) (bool, error) {
+	if err := validation.Struct(class); err != nil {
+		return false, err
+	}
+	var created bool
+	err := s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		if err := checkTeacher(ctx, repos, class); err != nil {
+			return err
+		}
+		existing, err := repos.Classes().GetByID(ctx, class.ID)
+		switch {
+		case err == nil:
+			// A class outside the caller's scope must not be overwritten
+			if _, err := getClass(ctx, repos, class.ID); err != nil {
+				return err
+			}
+			if class.Version == 0 {
+				class.Version = existing.Version
+			}
+		case errors.Is(err, repository.ErrNotFound):
+			if class.Version != 0 {
+				return ErrVersionMismatch
+			}
+		default:
+			return err
+		}
+
+		if created, err = repos.Classes().Upsert(ctx, class); err != nil {
+			return classWriteError(err)
+		}
+		if created {
+			// The class may be new to the API but already have students on record
+			if err := repos.Classes().RefreshStudentCounts(ctx, class.ID); err != nil {
+				return err
+			}
+		}
+		return reloadClass(ctx, repos.Classes(), class)
+	})
+	if err != nil {
+		return false, err
+	}
+	return created, nil
+}
+
+// PatchClass loads a class, lets apply change it and stores the result as
+// UpdateClass would. The read and the write happen in one transaction. A
+// non-zero version must match the stored one.
+func (s *classService) PatchClass(ctx context.Context, id, version uint, apply func(class *models.Class) error) (*models.Class, error) {
+	var class *models.Class
+	err := s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		var err error
+		if class, err = getClass(ctx, repos, id); err != nil {
+			return err
+		}
+		if version != 0 && class.Version != version {
+			return ErrVersionMismatch
+		}
+		stored := class.Version
+		if err := apply(class); err != nil {
+			return err
+		}
+		class.ID, class.Version = id, stored
+		return s.UpdateClass(ctx, class)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return class, nil
+}
+
+// DeleteClass applies the delete policy to the class's students and removes
+// the class in one transaction
+func (s *classService) DeleteClass(ctx context.Context, id uint, opts DeleteClassOptions) error {
+	return s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		return s.deleteClass(ctx, repos, id, opts)
+	})
+}
+
+func (s *classService) deleteClass(ctx context.Context, repos repository.Repositories, id uint, opts DeleteClassOptions) error {
+	class, err := getClass(ctx, repos, id)
+	if err != nil {
+		return err
+	}
+	if opts.Version != 0 && class.Version != opts.Version {
+		return ErrVersionMismatch
+	}
+
+	defaults := s.deleteDefaultsFor(ctx)
+	policy := opts.Policy
+	if policy == "" {
+		policy = defaults.Policy
+	}
+
+	switch policy {
+	case DeleteRestrict:
+		count, err := repos.Students().CountByClass(ctx, id)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrClassHasStudents
+		}
+	case DeleteCascade:
+		if _, err := repos.Students().DeleteByClass(ctx, id); err != nil {
+			return err
+		}
+	case DeleteReassign:
+		target := opts.ReassignTo
+		if target == 0 {
+			target = defaults.ReassignTo
+		}
+		if target == 0 || target == id {
+			return ErrInvalidReassignTarget
+		}
+		exists, err := repos.Classes().ExistsInScope(ctx, scopeFrom(ctx), target)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrInvalidReassignTarget
+		}
+		if _, err := repos.Students().ReassignClass(ctx, id, target); err != nil {
+			return err
+		}
+		if err := repos.Classes().RefreshStudentCounts(ctx, target); err != nil {
+			return err
+		}
+	default:
+		return ErrInvalidDeletePolicy
+	}
+
+	if err := repos.Classes().Delete(ctx, id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrClassNotFound
+		}
+		return err
+	}
+	return nil
 }
 
-func (s *classService) GetClassByID(id uint) (*models.Class, error) {
-	return s.repo.GetByID(id)
+// deleteDefaultsFor returns the delete defaults of the tenant of ctx,
+// which override the server defaults when set
+func (s *classService) deleteDefaultsFor(ctx context.Context) DeleteClassOptions {
+	defaults := s.deleteDefaults
+	tenant, ok := repository.TenantFrom(ctx)
+	if !ok {
+		return defaults
+	}
+	if tenant.DeletePolicy != "" {
+		defaults.Policy = DeletePolicy(tenant.DeletePolicy)
+	}
+	if tenant.ReassignTo != 0 {
+		defaults.ReassignTo = tenant.ReassignTo
+	}
+	return defaults
 }
 
-func (s *classService) UpdateClass(class *models.Class) error {
-	return s.repo.Update(class)
+// RestoreClass brings back a soft-deleted class. Students deleted along
+// with it stay deleted and can be restored one by one.
+func (s *classService) RestoreClass(ctx context.Context, id uint) (*models.Class, error) {
+	var class *models.Class
+	err := s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		if err := repos.Classes().Restore(ctx, id); err != nil {
+			return classWriteError(err)
+		}
+		// Students may have been reassigned to the class while it was deleted
+		if err := repos.Classes().RefreshStudentCounts(ctx, id); err != nil {
+			return err
+		}
+		var err error
+		class, err = getClass(ctx, repos, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return class, nil
 }
 
-func (s *classService) DeleteClass(id uint) error {
-	return s.repo.Delete(id)
-} 
\ No newline at end of file
+// getClass loads a class, reporting ErrClassNotFound if it does not exist
+// or is outside the caller's scope
+func getClass(ctx context.Context, repos repository.Repositories, id uint) (*models.Class, error) {
+	class, err := repos.Classes().GetInScope(ctx, scopeFrom(ctx), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrClassNotFound
+	}
+	return class, err
+}
+
+// checkTeacher makes sure a class's teacher_id, if set, refers to a user
+// with the teacher role
+func checkTeacher(ctx context.Context, repos repository.Repositories, class *models.Class) error {
+	if class.TeacherID == nil {
+		return nil
+	}
+	user, err := repos.Users().GetByID(ctx, *class.TeacherID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrUnknownTeacher
+	}
+	if err != nil {
+		return err
+	}
+	if auth.Role(user.Role) != auth.RoleTeacher {
+		return ErrUnknownTeacher
+	}
+	return nil
+}
+
+// classWriteError maps repository errors from writing a class to service errors
+func classWriteError(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return ErrClassNotFound
+	case errors.Is(err, repository.ErrVersionConflict):
+		return ErrVersionMismatch
+	default:
+		return err
+	}
+}
+
+// reloadClass replaces class with its stored state so derived fields such as
+// student_count are current
+func reloadClass(ctx context.Context, classes repository.ClassRepository, class *models.Class) error {
+	stored, err := classes.GetByID(ctx, class.ID)
+	if err != nil {
+		return err
+	}
+	*class = *stored
+	return nil
+}
+
+// nestFieldErrors prefixes the field names of a validation error, so errors
+// on nested items point at the item they came from
+func nestFieldErrors(err error, prefix string) error {
+	var appErr *apperror.Error
+	if !errors.As(err, &appErr) || len(appErr.Fields) == 0 {
+		return err
+	}
+	fields := make([]apperror.FieldError, len(appErr.Fields))
+	for i, f := range appErr.Fields {
+		fields[i] = apperror.FieldError{Field: prefix + f.Field, Message: f.Message}
+	}
+	return apperror.Validation(appErr.Message, fields...)
+}
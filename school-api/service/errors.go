@@ -0,0 +1,63 @@
+package service
+
+import (
+	"net/http"
+	"school-api/apperror"
+)
+
+// Error codes specific to the school domain
+const (
+	CodeClassHasStudents      apperror.Code = "class_has_students"
+	CodeInvalidReassignTarget apperror.Code = "invalid_reassign_target"
+	CodeInvalidDeletePolicy   apperror.Code = "invalid_delete_policy"
+	CodeInvalidRoster         apperror.Code = "invalid_roster"
+	CodeInvalidCredentials    apperror.Code = "invalid_credentials"
+	CodeInvalidRefreshToken   apperror.Code = "invalid_refresh_token"
+	CodeUserDisabled          apperror.Code = "user_disabled"
+	CodeLastAdmin             apperror.Code = "last_admin"
+)
+
+var (
+	// ErrClassNotFound is returned when the class being operated on does not exist
+	ErrClassNotFound = apperror.NotFound("class not found")
+	// ErrStudentNotFound is returned when the student being operated on does not exist
+	ErrStudentNotFound = apperror.NotFound("student not found")
+	// ErrVersionMismatch is returned when a change was based on a version of
+	// the record that is no longer current
+	ErrVersionMismatch = apperror.PreconditionFailed("the record has been changed since the given version")
+	// ErrUnknownClass is returned when a student refers to a class that does not exist
+	ErrUnknownClass = apperror.Validation("class_id does not refer to an existing class",
+		apperror.FieldError{Field: "class_id", Message: "must refer to an existing class"})
+	// ErrClassHasStudents is returned when deleting a class that still has students under the restrict policy
+	ErrClassHasStudents = apperror.New(http.StatusConflict, CodeClassHasStudents, "class has students enrolled")
+	// ErrInvalidReassignTarget is returned when students cannot be moved to the requested class
+	ErrInvalidReassignTarget = apperror.New(http.StatusUnprocessableEntity, CodeInvalidReassignTarget,
+		"students must be reassigned to a different, existing class")
+	// ErrInvalidDeletePolicy is returned for an unrecognised delete policy name
+	ErrInvalidDeletePolicy = apperror.New(http.StatusBadRequest, CodeInvalidDeletePolicy,
+		"delete policy must be restrict, cascade or reassign")
+	// ErrUserNotFound is returned when the user being operated on does not exist
+	ErrUserNotFound = apperror.NotFound("user not found")
+	// ErrInvalidCredentials is returned by a login with an unknown username
+	// or a wrong password; which of the two is not revealed
+	ErrInvalidCredentials = apperror.New(http.StatusUnauthorized, CodeInvalidCredentials, "invalid username or password")
+	// ErrInvalidRefreshToken is returned for refresh tokens that are unknown, expired or already used
+	ErrInvalidRefreshToken = apperror.New(http.StatusUnauthorized, CodeInvalidRefreshToken, "refresh token is invalid or has expired")
+	// ErrUserDisabled is returned when a disabled user signs in or refreshes a token
+	ErrUserDisabled = apperror.New(http.StatusForbidden, CodeUserDisabled, "user is disabled")
+	// ErrLastAdmin is returned when a change would leave a tenant without an
+	// enabled administrator, and so without anyone able to manage users
+	ErrLastAdmin = apperror.New(http.StatusConflict, CodeLastAdmin,
+		"the last enabled administrator cannot be demoted or disabled")
+	// ErrUsernameTaken is returned when creating a user whose username is in use
+	ErrUsernameTaken = apperror.New(http.StatusConflict, apperror.CodeDuplicate, "username is already taken")
+	// ErrUnknownTeacher is returned when a class's teacher_id is not a user with the teacher role
+	ErrUnknownTeacher = apperror.Validation("teacher_id does not refer to a teacher",
+		apperror.FieldError{Field: "teacher_id", Message: "must refer to a user with the teacher role"})
+	// ErrUnknownStudent is returned when a user is linked to a student that does not exist
+	ErrUnknownStudent = apperror.Validation("student_id does not refer to an existing student",
+		apperror.FieldError{Field: "student_id", Message: "must refer to an existing student"})
+	// ErrPasswordTooLong is returned for passwords longer than 72 bytes
+	ErrPasswordTooLong = apperror.Validation("password is too long",
+		apperror.FieldError{Field: "password", Message: "must be at most 72 bytes"})
+)
@@ -0,0 +1,536 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"school-api/apperror"
+	"school-api/models"
+	"school-api/repository"
+	"school-api/spreadsheet"
+	"school-api/validation"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ImportAction is what an import does, or would do, with one record
+type ImportAction string
+
+const (
+	ImportCreate    ImportAction = "create"
+	ImportUpdate    ImportAction = "update"
+	ImportUnchanged ImportAction = "unchanged"
+	ImportDelete    ImportAction = "delete"
+	// ImportConflict marks a row that cannot be matched to stored records unambiguously
+	ImportConflict ImportAction = "conflict"
+	// ImportInvalid marks a row whose values fail validation
+	ImportInvalid ImportAction = "invalid"
+	// ImportSkipped marks a row describing something school-api does not store
+	ImportSkipped ImportAction = "skipped"
+)
+
+// Entities an import item can refer to
+const (
+	ImportEntityClass      = "class"
+	ImportEntityStudent    = "student"
+	ImportEntityEnrollment = "enrollment"
+)
+
+// ImportOptions controls a roster import
+type ImportOptions struct {
+	// DryRun reports what would change without writing anything
+	DryRun bool
+}
+
+// FieldChange is one field an import updates
+type FieldChange struct {
+	Field string
+	From  string
+	To    string
+}
+
+// ImportItem describes what happens to one record. Line is the line of the
+// file it came from (the header is line 1), or 0 for stored records a bulk
+// file deletes by leaving them out; File names that file when the import
+// reads several.
+type ImportItem struct {
+	File    string
+	Line    int
+	Entity  string
+	Action  ImportAction
+	ID      uint
+	Name    string
+	Changes []FieldChange
+	Message string
+}
+
+// ImportSummary counts import items by action
+type ImportSummary struct {
+	Creates   int
+	Updates   int
+	Unchanged int
+	Deletes   int
+	Conflicts int
+	Invalid   int
+	Skipped   int
+}
+
+// ImportReport is the outcome of a roster import. Nothing is written when
+// the import is a dry run or when any row is a conflict or invalid.
+type ImportReport struct {
+	DryRun         bool
+	Committed      bool
+	IgnoredColumns []string
+	Summary        ImportSummary
+	Items          []ImportItem
+}
+
+// Blocked reports whether conflicts or invalid rows prevent the import
+func (r *ImportReport) Blocked() bool {
+	return r.Summary.Conflicts > 0 || r.Summary.Invalid > 0
+}
+
+// ImportService loads rosters of classes and students from spreadsheets
+type ImportService interface {
+	// ImportRoster imports rows whose first row is a header naming the
+	// columns: class_name (required), student_name, student_section and id.
+	// Class names are matched to stored classes ignoring case and missing
+	// classes are created. Students are matched by id, or else by name
+	// within their class. The whole roster is applied in one transaction.
+	ImportRoster(ctx context.Context, sheet *spreadsheet.Sheet, opts ImportOptions) (*ImportReport, error)
+}
+
+type importService struct {
+	uow repository.UnitOfWork
+}
+
+func NewImportService(uow repository.UnitOfWork) ImportService {
+	return &importService{uow: uow}
+}
+
+// rosterColumns maps the header names accepted in roster files to fields
+var rosterColumns = map[string]string{
+	"id":              "id",
+	"student_id":      "id",
+	"student_name":    "student_name",
+	"student":         "student_name",
+	"name":            "student_name",
+	"class_name":      "class_name",
+	"class":           "class_name",
+	"student_section": "student_section",
+	"section":         "student_section",
+}
+
+// rosterRow is one data row of a roster
+type rosterRow struct {
+	line        int
+	id          uint
+	studentName string
+	className   string
+	section     string
+	err         error
+}
+
+// parseRoster maps data rows to fields using the header row
+func parseRoster(sheet *spreadsheet.Sheet) ([]rosterRow, []string, error) {
+	records := sheet.Rows
+	if len(records) == 0 {
+		return nil, nil, apperror.New(http.StatusBadRequest, CodeInvalidRoster, "roster is empty")
+	}
+
+	columns := make(map[string]int)
+	var ignored []string
+	for i, header := range records[0] {
+		name := strings.ToLower(strings.TrimSpace(header))
+		name = strings.NewReplacer(" ", "_", "-", "_").Replace(name)
+		field, ok := rosterColumns[name]
+		if !ok {
+			if name != "" {
+				ignored = append(ignored, strings.TrimSpace(header))
+			}
+			continue
+		}
+		if _, dup := columns[field]; dup {
+			return nil, nil, apperror.New(http.StatusBadRequest, CodeInvalidRoster,
+				fmt.Sprintf("roster has more than one %s column", field))
+		}
+		columns[field] = i
+	}
+	if _, ok := columns["class_name"]; !ok {
+		return nil, nil, apperror.New(http.StatusBadRequest, CodeInvalidRoster, "roster has no class_name column")
+	}
+
+	cell := func(record []string, field string) string {
+		i, ok := columns[field]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var rows []rosterRow
+	for n, record := range records[1:] {
+		row := rosterRow{
+			line:        sheet.Lines[n+1],
+			studentName: cell(record, "student_name"),
+			className:   cell(record, "class_name"),
+			section:     strings.ToUpper(cell(record, "student_section")),
+		}
+		if id := cell(record, "id"); id != "" {
+			parsed, err := strconv.ParseUint(id, 10, 32)
+			if err != nil || parsed == 0 {
+				row.err = fmt.Errorf("id %q is not a valid student ID", id)
+			}
+			row.id = uint(parsed)
+		}
+		if row.id == 0 && row.studentName == "" && row.className == "" && row.section == "" && row.err == nil {
+			continue // blank line
+		}
+		rows = append(rows, row)
+	}
+	return rows, ignored, nil
+}
+
+// rosterPlan is the set of changes an import makes
+type rosterPlan struct {
+	report      *ImportReport
+	newClasses  []models.Class
+	classItems  []int // report item of each new class
+	creates     []models.Student
+	createItems []int // report item of each created student
+	createClass []string
+	updates     []models.Student
+	updateItems []int    // report item of each update
+	updateClass []string // class name of each update, empty when unchanged
+	affected    map[uint]bool
+}
+
+func (s *importService) ImportRoster(ctx context.Context, sheet *spreadsheet.Sheet, opts ImportOptions) (*ImportReport, error) {
+	rows, ignored, err := parseRoster(sheet)
+	if err != nil {
+		return nil, err
+	}
+
+	var report *ImportReport
+	err = s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		plan, err := planRoster(ctx, repos, rows)
+		if err != nil {
+			return err
+		}
+		report = plan.report
+		report.DryRun = opts.DryRun
+		report.IgnoredColumns = ignored
+		if opts.DryRun || report.Blocked() {
+			return nil
+		}
+		if err := plan.apply(ctx, repos); err != nil {
+			return err
+		}
+		report.Committed = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// planRoster matches rows against stored classes and students and decides
+// what to do with each, without writing anything
+func planRoster(ctx context.Context, repos repository.Repositories, rows []rosterRow) (*rosterPlan, error) {
+	plan := &rosterPlan{report: &ImportReport{}, affected: make(map[uint]bool)}
+
+	// Resolve every class name in one query
+	var names []string
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		key := strings.ToLower(row.className)
+		if row.className != "" && !seen[key] {
+			seen[key] = true
+			names = append(names, row.className)
+		}
+	}
+	stored, err := repos.Classes().FindByNames(ctx, names)
+	if err != nil {
+		return nil, err
+	}
+	classes := make(map[string][]models.Class)
+	var classIDs []uint
+	for _, c := range stored {
+		key := strings.ToLower(strings.TrimSpace(c.ClassName))
+		classes[key] = append(classes[key], c)
+		classIDs = append(classIDs, c.ID)
+	}
+
+	// Load students that rows may refer to, by ID and by class
+	var ids []uint
+	for _, row := range rows {
+		if row.id != 0 {
+			ids = append(ids, row.id)
+		}
+	}
+	byID := make(map[uint]models.Student)
+	found, err := repos.Students().FindByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, st := range found {
+		byID[st.ID] = st
+	}
+	enrolled, err := repos.Students().FindByClasses(ctx, classIDs)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string][]models.Student)
+	for _, st := range enrolled {
+		byName[studentKey(st.ClassId, st.StudentName)] = append(byName[studentKey(st.ClassId, st.StudentName)], st)
+	}
+
+	// Name the current classes of students the roster may move
+	classNames := make(map[uint]string)
+	for _, c := range stored {
+		classNames[c.ID] = c.ClassName
+	}
+	var others []uint
+	for _, st := range found {
+		if _, ok := classNames[st.ClassId]; !ok {
+			classNames[st.ClassId] = ""
+			others = append(others, st.ClassId)
+		}
+	}
+	current, err := repos.Classes().FindByIDs(ctx, others)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range current {
+		classNames[c.ID] = c.ClassName
+	}
+
+	newClass := make(map[string]int) // lower-cased name -> index in newClasses
+	claimed := make(map[string]int)  // student identity -> line that claimed it
+
+	for _, row := range rows {
+		item := ImportItem{Line: row.line, Entity: ImportEntityStudent, Name: row.studentName}
+		if row.err != nil {
+			plan.report.add(item, ImportInvalid, row.err.Error())
+			continue
+		}
+
+		// Resolve the class, planning to create it if it is new
+		var classID uint
+		key := strings.ToLower(row.className)
+		switch matches := classes[key]; {
+		case row.className == "":
+			plan.report.add(item, ImportInvalid, "class_name is required")
+			continue
+		case len(matches) > 1:
+			plan.report.add(item, ImportConflict, fmt.Sprintf("%d classes are named %q", len(matches), row.className))
+			continue
+		case len(matches) == 1:
+			classID = matches[0].ID
+		default:
+			if _, ok := newClass[key]; !ok {
+				class := models.Class{ClassName: row.className}
+				classItem := ImportItem{Line: row.line, Entity: ImportEntityClass, Name: row.className}
+				if err := validation.Struct(&class); err != nil {
+					plan.report.add(item, ImportInvalid, "class_name "+describe(err))
+					continue
+				}
+				newClass[key] = len(plan.newClasses)
+				plan.newClasses = append(plan.newClasses, class)
+				plan.classItems = append(plan.classItems, plan.report.add(classItem, ImportCreate, ""))
+			}
+		}
+
+		// A row with only a class name just makes sure the class exists
+		if row.id == 0 && row.studentName == "" {
+			if row.section != "" {
+				plan.report.add(item, ImportInvalid, "student_name is required when student_section is given")
+			}
+			continue
+		}
+
+		student := models.Student{ID: row.id, StudentName: row.studentName, ClassId: classID, Section: row.section}
+		check := student
+		if check.ClassId == 0 {
+			check.ClassId = 1 // the class is created before the student
+		}
+		if err := validation.Struct(&check); err != nil {
+			plan.report.add(item, ImportInvalid, describe(err))
+			continue
+		}
+
+		// Match the row to a stored student
+		var existing *models.Student
+		identity := studentKey(classID, row.studentName)
+		if classID == 0 {
+			identity = "new:" + key + ":" + strings.ToLower(row.studentName)
+		}
+		if row.id != 0 {
+			st, ok := byID[row.id]
+			if !ok {
+				plan.report.add(item, ImportConflict, fmt.Sprintf("student %d does not exist", row.id))
+				continue
+			}
+			existing = &st
+			identity = "id:" + strconv.FormatUint(uint64(row.id), 10)
+		} else if classID != 0 {
+			switch matches := byName[identity]; len(matches) {
+			case 0:
+			case 1:
+				existing = &matches[0]
+				identity = "id:" + strconv.FormatUint(uint64(existing.ID), 10)
+			default:
+				plan.report.add(item, ImportConflict, fmt.Sprintf("%d students in class %q are named %q; add an id column to pick one",
+					len(matches), row.className, row.studentName))
+				continue
+			}
+		}
+		if line, dup := claimed[identity]; dup {
+			plan.report.add(item, ImportConflict, fmt.Sprintf("same student as line %d", line))
+			continue
+		}
+		claimed[identity] = row.line
+
+		if existing == nil {
+			plan.creates = append(plan.creates, student)
+			plan.createClass = append(plan.createClass, key)
+			plan.createItems = append(plan.createItems, plan.report.add(item, ImportCreate, ""))
+			continue
+		}
+
+		item.ID = existing.ID
+		var changes []FieldChange
+		if existing.StudentName != student.StudentName {
+			changes = append(changes, FieldChange{Field: "student_name", From: existing.StudentName, To: student.StudentName})
+		}
+		if classID == 0 || existing.ClassId != classID {
+			changes = append(changes, FieldChange{Field: "class_name", From: classNames[existing.ClassId], To: row.className})
+		}
+		if existing.Section != student.Section {
+			changes = append(changes, FieldChange{Field: "student_section", From: existing.Section, To: student.Section})
+		}
+		if len(changes) == 0 {
+			plan.report.add(item, ImportUnchanged, "")
+			continue
+		}
+		item.Changes = changes
+		plan.updateItems = append(plan.updateItems, plan.report.add(item, ImportUpdate, ""))
+
+		student.ID, student.Version = existing.ID, existing.Version
+		plan.updates = append(plan.updates, student)
+		plan.updateClass = append(plan.updateClass, key)
+		plan.affected[existing.ClassId] = true
+	}
+	return plan, nil
+}
+
+// add records an item with the given action and returns its index
+func (r *ImportReport) add(item ImportItem, action ImportAction, message string) int {
+	item.Action, item.Message = action, message
+	switch action {
+	case ImportCreate:
+		r.Summary.Creates++
+	case ImportUpdate:
+		r.Summary.Updates++
+	case ImportUnchanged:
+		r.Summary.Unchanged++
+	case ImportDelete:
+		r.Summary.Deletes++
+	case ImportConflict:
+		r.Summary.Conflicts++
+	case ImportInvalid:
+		r.Summary.Invalid++
+	case ImportSkipped:
+		r.Summary.Skipped++
+	}
+	r.Items = append(r.Items, item)
+	return len(r.Items) - 1
+}
+
+// itemError ties err to the report item at index i, naming the file and
+// line the item came from
+func (r *ImportReport) itemError(i int, err error) error {
+	var appErr *apperror.Error
+	if !errors.As(err, &appErr) {
+		return err
+	}
+	item := r.Items[i]
+	at := fmt.Sprintf("line %d", item.Line)
+	if item.File != "" {
+		at = item.File + " " + at
+	}
+	wrapped := apperror.Wrap(err, appErr.Status, appErr.Code, at+": "+appErr.Message)
+	wrapped.Fields = appErr.Fields
+	return wrapped
+}
+
+// apply writes the planned changes and fills in the IDs of created records
+func (p *rosterPlan) apply(ctx context.Context, repos repository.Repositories) error {
+	classIDs := make(map[string]uint)
+	if len(p.newClasses) > 0 {
+		if err := repos.Classes().CreateBatch(ctx, p.newClasses); err != nil {
+			return err
+		}
+		for i, class := range p.newClasses {
+			classIDs[strings.ToLower(class.ClassName)] = class.ID
+			p.report.Items[p.classItems[i]].ID = class.ID
+		}
+	}
+
+	for i := range p.creates {
+		if p.creates[i].ClassId == 0 {
+			p.creates[i].ClassId = classIDs[p.createClass[i]]
+		}
+		p.affected[p.creates[i].ClassId] = true
+	}
+	if len(p.creates) > 0 {
+		if err := repos.Students().CreateBatch(ctx, p.creates); err != nil {
+			return err
+		}
+		for i, student := range p.creates {
+			p.report.Items[p.createItems[i]].ID = student.ID
+		}
+	}
+
+	for i := range p.updates {
+		student := &p.updates[i]
+		if student.ClassId == 0 {
+			student.ClassId = classIDs[p.updateClass[i]]
+		}
+		p.affected[student.ClassId] = true
+		if err := repos.Students().Update(ctx, student); err != nil {
+			return p.report.itemError(p.updateItems[i], err)
+		}
+	}
+
+	if len(p.affected) == 0 {
+		return nil
+	}
+	ids := make([]uint, 0, len(p.affected))
+	for id := range p.affected {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return repos.Classes().RefreshStudentCounts(ctx, ids...)
+}
+
+// studentKey identifies a student by class and name, ignoring case
+func studentKey(classID uint, name string) string {
+	return strconv.FormatUint(uint64(classID), 10) + ":" + strings.ToLower(strings.TrimSpace(name))
+}
+
+// describe flattens a validation error into one line for an import report
+func describe(err error) string {
+	appErr := apperror.From(err)
+	if len(appErr.Fields) == 0 {
+		return appErr.Message
+	}
+	parts := make([]string, len(appErr.Fields))
+	for i, f := range appErr.Fields {
+		parts[i] = f.Field + " " + f.Message
+	}
+	return strings.Join(parts, "; ")
+}
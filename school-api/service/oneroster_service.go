@@ -0,0 +1,725 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"school-api/apperror"
+	"school-api/models"
+	"school-api/oneroster"
+	"school-api/repository"
+	"school-api/validation"
+	"sort"
+	"strings"
+)
+
+// OneRosterService exposes classes and students as OneRoster records and
+// exchanges them with other roster systems as OneRoster CSV bundles
+type OneRosterService interface {
+	Users(ctx context.Context, opts repository.QueryOptions) (*repository.Page[models.Student], error)
+	User(ctx context.Context, sourcedID string) (*models.Student, error)
+	Classes(ctx context.Context, opts repository.QueryOptions) (*repository.Page[models.Class], error)
+	Class(ctx context.Context, sourcedID string) (*models.Class, error)
+	// ClassSourcedIDs returns the sourced IDs of the classes the given students are in
+	ClassSourcedIDs(ctx context.Context, students []models.Student) (map[uint]string, error)
+	// ExportCSV writes every class and student as a bulk CSV bundle
+	ExportCSV(ctx context.Context, bundle *oneroster.BundleWriter) error
+	// ImportCSV applies the classes, users and enrollments of a CSV bundle,
+	// matching records by sourced ID. Only students and their enrollments
+	// are imported; other users are skipped. Files follow the mode the
+	// manifest gives them: absent files are ignored, delta files change the
+	// records they list and bulk classes and users files also delete stored
+	// records they leave out. Students always belong to a class, so a bulk
+	// enrollments file is applied like a delta one. Like ImportRoster,
+	// nothing is written on a dry run or when any record is a conflict or
+	// invalid.
+	ImportCSV(ctx context.Context, bundle oneroster.Bundle, opts ImportOptions) (*ImportReport, error)
+}
+
+type oneRosterService struct {
+	uow repository.UnitOfWork
+	// org is the organization of the default tenant; see TenantOrg
+	org oneroster.Org
+}
+
+func NewOneRosterService(uow repository.UnitOfWork, org oneroster.Org) OneRosterService {
+	return &oneRosterService{uow: uow, org: org}
+}
+
+func (s *oneRosterService) Users(ctx context.Context, opts repository.QueryOptions) (*repository.Page[models.Student], error) {
+	return s.uow.Students().List(ctx, opts)
+}
+
+func (s *oneRosterService) User(ctx context.Context, sourcedID string) (*models.Student, error) {
+	students, err := s.uow.Students().FindBySourcedIDs(ctx, []string{sourcedID})
+	if err != nil {
+		return nil, err
+	}
+	student, ok := indexStudents(students)[sourcedID]
+	if !ok {
+		return nil, ErrStudentNotFound
+	}
+	return student, nil
+}
+
+func (s *oneRosterService) Classes(ctx context.Context, opts repository.QueryOptions) (*repository.Page[models.Class], error) {
+	return s.uow.Classes().List(ctx, opts)
+}
+
+func (s *oneRosterService) Class(ctx context.Context, sourcedID string) (*models.Class, error) {
+	classes, err := s.uow.Classes().FindBySourcedIDs(ctx, []string{sourcedID})
+	if err != nil {
+		return nil, err
+	}
+	class, ok := indexClasses(classes)[sourcedID]
+	if !ok {
+		return nil, ErrClassNotFound
+	}
+	return class, nil
+}
+
+func (s *oneRosterService) ClassSourcedIDs(ctx context.Context, students []models.Student) (map[uint]string, error) {
+	ids := make(map[uint]string)
+	var classIDs []uint
+	for _, st := range students {
+		if _, ok := ids[st.ClassId]; !ok {
+			ids[st.ClassId] = oneroster.SourcedID("", st.ClassId)
+			classIDs = append(classIDs, st.ClassId)
+		}
+	}
+	classes, err := s.uow.Classes().FindByIDs(ctx, classIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range classes {
+		ids[c.ID] = oneroster.SourcedID(c.SourcedID, c.ID)
+	}
+	return ids, nil
+}
+
+func (s *oneRosterService) ExportCSV(ctx context.Context, bundle *oneroster.BundleWriter) error {
+	org := TenantOrg(ctx, s.org)
+	files := []string{oneroster.FileOrgs, oneroster.FileCourses, oneroster.FileClasses, oneroster.FileUsers, oneroster.FileEnrollments}
+	if err := bundle.WriteManifest(files...); err != nil {
+		return err
+	}
+	if err := bundle.Begin(oneroster.FileOrgs, oneroster.OrgColumns); err != nil {
+		return err
+	}
+	if err := bundle.Write(oneroster.OrgRecord(org)); err != nil {
+		return err
+	}
+
+	// Enrollments refer to classes by sourced ID; only imported classes
+	// have one that differs from their ID
+	classSourcedIDs := make(map[uint]string)
+	if err := bundle.Begin(oneroster.FileCourses, oneroster.CourseColumns); err != nil {
+		return err
+	}
+	err := s.uow.Classes().Stream(ctx, repository.QueryOptions{}, func(c *models.Class) error {
+		if c.SourcedID != "" {
+			classSourcedIDs[c.ID] = c.SourcedID
+		}
+		return bundle.Write(oneroster.CourseRecord(c, org))
+	})
+	if err != nil {
+		return err
+	}
+	if err := bundle.Begin(oneroster.FileClasses, oneroster.ClassColumns); err != nil {
+		return err
+	}
+	err = s.uow.Classes().Stream(ctx, repository.QueryOptions{}, func(c *models.Class) error {
+		return bundle.Write(oneroster.ClassRecord(c, org))
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := bundle.Begin(oneroster.FileUsers, oneroster.UserColumns); err != nil {
+		return err
+	}
+	err = s.uow.Students().Stream(ctx, repository.QueryOptions{}, func(st *models.Student) error {
+		return bundle.Write(oneroster.UserRecord(st, org))
+	})
+	if err != nil {
+		return err
+	}
+	if err := bundle.Begin(oneroster.FileEnrollments, oneroster.EnrollmentColumns); err != nil {
+		return err
+	}
+	return s.uow.Students().Stream(ctx, repository.QueryOptions{}, func(st *models.Student) error {
+		return bundle.Write(oneroster.EnrollmentRecord(st, oneroster.SourcedID(classSourcedIDs[st.ClassId], st.ClassId), org))
+	})
+}
+
+func (s *oneRosterService) ImportCSV(ctx context.Context, bundle oneroster.Bundle, opts ImportOptions) (*ImportReport, error) {
+	modes, err := checkBundle(bundle)
+	if err != nil {
+		return nil, err
+	}
+
+	var report *ImportReport
+	err = s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		plan, err := planBundle(ctx, repos, bundle, modes)
+		if err != nil {
+			return err
+		}
+		report = plan.report
+		report.DryRun = opts.DryRun
+		if opts.DryRun || report.Blocked() {
+			return nil
+		}
+		if err := plan.apply(ctx, repos); err != nil {
+			return err
+		}
+		report.Committed = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// bundleColumns are the columns each imported file must have
+var bundleColumns = map[string][]string{
+	oneroster.FileClasses:     {"sourcedId", "title"},
+	oneroster.FileUsers:       {"sourcedId", "role", "givenName", "familyName"},
+	oneroster.FileEnrollments: {"classSourcedId", "userSourcedId", "role"},
+}
+
+// checkBundle returns the mode of each file the import reads, leaving out
+// files the manifest marks absent. It rejects bundles with nothing to
+// import, files the manifest lists but the archive lacks, unknown modes and
+// missing columns.
+func checkBundle(bundle oneroster.Bundle) (map[string]string, error) {
+	modes := make(map[string]string)
+	for _, file := range []string{oneroster.FileClasses, oneroster.FileUsers, oneroster.FileEnrollments} {
+		mode := bundle.Mode(file)
+		table, ok := bundle[file]
+		switch {
+		case mode == oneroster.ModeAbsent:
+			continue
+		case mode != oneroster.ModeBulk && mode != oneroster.ModeDelta:
+			return nil, apperror.New(http.StatusBadRequest, CodeInvalidRoster,
+				fmt.Sprintf("manifest gives %s the unknown mode %q", file, mode))
+		case !ok:
+			return nil, apperror.New(http.StatusBadRequest, CodeInvalidRoster,
+				fmt.Sprintf("manifest lists %s as %s but the bundle has no such file", file, mode))
+		}
+		modes[file] = mode
+		for _, column := range bundleColumns[file] {
+			if !table.Has(column) {
+				return nil, apperror.New(http.StatusBadRequest, CodeInvalidRoster, fmt.Sprintf("%s has no %s column", file, column))
+			}
+		}
+	}
+	if len(modes) == 0 {
+		return nil, apperror.New(http.StatusBadRequest, CodeInvalidRoster,
+			"bundle has none of classes.csv, users.csv and enrollments.csv, or the manifest marks them absent")
+	}
+	return modes, nil
+}
+
+// classTarget is a class a student can be placed in: a stored class, or
+// one the import creates
+type classTarget struct {
+	id       uint
+	newIndex int // index in bundlePlan.newClasses when id is 0
+	deleted  bool
+}
+
+// enrollment is the class a bundle enrolls a student in
+type enrollment struct {
+	line     int
+	class    string
+	conflict string
+}
+
+// bundlePlan is the set of changes a bundle import makes
+type bundlePlan struct {
+	report           *ImportReport
+	newClasses       []models.Class
+	classItems       []int
+	classUpdates     []models.Class
+	classUpdateItems []int // report item of each class update
+	newStudents      []models.Student
+	studentItems     []int
+	studentClasses   []classTarget // class of each new student
+	updates          []models.Student
+	updateItems      []int         // report item of each student update
+	updateClasses    []classTarget // class of each updated student
+	deleteStudents   []uint
+	deleteClasses    []uint
+	deleteItems      []int // report item of each deleted class
+	leaving          map[uint]bool
+	affected         map[uint]bool
+}
+
+// planBundle matches the files of a bundle that modes names against stored
+// classes and students and decides what to do with each record, without
+// writing anything
+func planBundle(ctx context.Context, repos repository.Repositories, bundle oneroster.Bundle, modes map[string]string) (*bundlePlan, error) {
+	plan := &bundlePlan{report: &ImportReport{}, leaving: make(map[uint]bool), affected: make(map[uint]bool)}
+	table := func(file string) *oneroster.Table {
+		if _, ok := modes[file]; !ok {
+			return &oneroster.Table{}
+		}
+		return bundle[file]
+	}
+	classes, users, enrollments := table(oneroster.FileClasses), table(oneroster.FileUsers), table(oneroster.FileEnrollments)
+
+	// Load every class and student the bundle mentions
+	var classIDs, userIDs []string
+	for _, row := range classes.Rows {
+		classIDs = append(classIDs, classes.Get(row, "sourcedId"))
+	}
+	for _, row := range users.Rows {
+		userIDs = append(userIDs, users.Get(row, "sourcedId"))
+	}
+	for _, row := range enrollments.Rows {
+		classIDs = append(classIDs, enrollments.Get(row, "classSourcedId"))
+		userIDs = append(userIDs, enrollments.Get(row, "userSourcedId"))
+	}
+	storedClasses, err := repos.Classes().FindBySourcedIDs(ctx, classIDs)
+	if err != nil {
+		return nil, err
+	}
+	storedStudents, err := repos.Students().FindBySourcedIDs(ctx, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	classBySID, studentBySID := indexClasses(storedClasses), indexStudents(storedStudents)
+
+	// Name the current classes of stored students, for reporting moves
+	classSIDs := make(map[uint]string)
+	var others []uint
+	for _, c := range storedClasses {
+		classSIDs[c.ID] = oneroster.SourcedID(c.SourcedID, c.ID)
+	}
+	for _, st := range storedStudents {
+		if _, ok := classSIDs[st.ClassId]; !ok {
+			classSIDs[st.ClassId] = oneroster.SourcedID("", st.ClassId)
+			others = append(others, st.ClassId)
+		}
+	}
+	current, err := repos.Classes().FindByIDs(ctx, others)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range current {
+		classSIDs[c.ID] = oneroster.SourcedID(c.SourcedID, c.ID)
+	}
+
+	targets := make(map[string]classTarget)
+	for sid, c := range classBySID {
+		targets[sid] = classTarget{id: c.ID}
+	}
+	plan.planClasses(classes, classBySID, targets)
+	if modes[oneroster.FileClasses] == oneroster.ModeBulk {
+		if err := plan.deleteUnlistedClasses(ctx, repos, classes, targets); err != nil {
+			return nil, err
+		}
+	}
+
+	// Student enrollments decide which class each student is in
+	enrolled := make(map[string]*enrollment)
+	for n, row := range enrollments.Rows {
+		item := ImportItem{File: oneroster.FileEnrollments, Line: enrollments.Line(n), Entity: ImportEntityEnrollment, Name: enrollments.Get(row, "sourcedId")}
+		userSID, classSID := enrollments.Get(row, "userSourcedId"), enrollments.Get(row, "classSourcedId")
+		switch {
+		case !strings.EqualFold(enrollments.Get(row, "role"), oneroster.RoleStudent):
+			plan.report.add(item, ImportSkipped, "only student enrollments are imported")
+			continue
+		case strings.EqualFold(enrollments.Get(row, "status"), oneroster.StatusToBeDeleted):
+			plan.report.add(item, ImportSkipped, "students stay in their class until they are enrolled elsewhere or deleted")
+			continue
+		case userSID == "" || classSID == "":
+			plan.report.add(item, ImportInvalid, "userSourcedId and classSourcedId are required")
+			continue
+		}
+		if e, ok := enrolled[userSID]; ok {
+			if e.class != classSID {
+				e.conflict = fmt.Sprintf("enrolled in more than one class (lines %d and %d of %s)", e.line, enrollments.Line(n), oneroster.FileEnrollments)
+			}
+			continue
+		}
+		enrolled[userSID] = &enrollment{line: enrollments.Line(n), class: classSID}
+	}
+
+	// resolve finds the class a student is enrolled in
+	resolve := func(e *enrollment) (classTarget, string) {
+		if e.conflict != "" {
+			return classTarget{}, e.conflict
+		}
+		target, ok := targets[e.class]
+		switch {
+		case !ok:
+			return classTarget{}, fmt.Sprintf("enrolled in unknown class %q", e.class)
+		case target.deleted:
+			return classTarget{}, fmt.Sprintf("enrolled in class %q, which is being deleted", e.class)
+		}
+		return target, ""
+	}
+	targetSID := func(t classTarget) string {
+		if t.id == 0 {
+			return plan.newClasses[t.newIndex].SourcedID
+		}
+		return classSIDs[t.id]
+	}
+
+	seen := make(map[string]int)
+	for n, row := range users.Rows {
+		sid := users.Get(row, "sourcedId")
+		given, family := users.Get(row, "givenName"), users.Get(row, "familyName")
+		item := ImportItem{File: oneroster.FileUsers, Line: users.Line(n), Entity: ImportEntityStudent, Name: oneroster.JoinName(given, family)}
+		if !strings.EqualFold(users.Get(row, "role"), oneroster.RoleStudent) {
+			plan.report.add(item, ImportSkipped, "only students are imported")
+			continue
+		}
+		if sid == "" {
+			plan.report.add(item, ImportInvalid, "sourcedId is required")
+			continue
+		}
+		if line, dup := seen[sid]; dup {
+			plan.report.add(item, ImportConflict, fmt.Sprintf("same sourcedId as line %d", line))
+			continue
+		}
+		seen[sid] = users.Line(n)
+
+		existing := studentBySID[sid]
+		if existing != nil {
+			item.ID = existing.ID
+		}
+		if strings.EqualFold(users.Get(row, "status"), oneroster.StatusToBeDeleted) {
+			if existing == nil {
+				plan.report.add(item, ImportSkipped, "not stored")
+				continue
+			}
+			plan.deleteStudent(item, existing, "")
+			delete(enrolled, sid)
+			continue
+		}
+
+		student := models.Student{StudentName: item.Name, SourcedID: sid}
+		if users.Has("metadata.section") {
+			student.Section = strings.ToUpper(users.Get(row, "metadata.section"))
+		} else if existing != nil {
+			student.Section = existing.Section
+		}
+
+		var target classTarget
+		if e, ok := enrolled[sid]; ok {
+			t, conflict := resolve(e)
+			if conflict != "" {
+				plan.report.add(item, ImportConflict, conflict)
+				continue
+			}
+			target = t
+		} else if existing != nil {
+			target = classTarget{id: existing.ClassId}
+		} else {
+			plan.report.add(item, ImportInvalid, "a new student needs a student enrollment in a class")
+			continue
+		}
+		delete(enrolled, sid)
+
+		student.ClassId = target.id
+		if !plan.validate(item, student) {
+			continue
+		}
+		if existing == nil {
+			plan.newStudents = append(plan.newStudents, student)
+			plan.studentClasses = append(plan.studentClasses, target)
+			plan.studentItems = append(plan.studentItems, plan.report.add(item, ImportCreate, ""))
+			continue
+		}
+		plan.update(item, existing, student, target, classSIDs[existing.ClassId], targetSID(target))
+	}
+
+	if modes[oneroster.FileUsers] == oneroster.ModeBulk {
+		err := repos.Students().Stream(ctx, repository.QueryOptions{}, func(st *models.Student) error {
+			sid := oneroster.SourcedID(st.SourcedID, st.ID)
+			if _, listed := seen[sid]; !listed {
+				item := ImportItem{File: oneroster.FileUsers, Entity: ImportEntityStudent, ID: st.ID, Name: st.StudentName}
+				plan.deleteStudent(item, st, "not in the bulk file")
+				delete(enrolled, sid)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Enrollments of students the bundle has no user row for move them
+	var rest []string
+	for sid := range enrolled {
+		rest = append(rest, sid)
+	}
+	sort.Slice(rest, func(i, j int) bool { return enrolled[rest[i]].line < enrolled[rest[j]].line })
+	for _, sid := range rest {
+		e := enrolled[sid]
+		item := ImportItem{File: oneroster.FileEnrollments, Line: e.line, Entity: ImportEntityStudent, Name: sid}
+		existing := studentBySID[sid]
+		if existing == nil {
+			plan.report.add(item, ImportConflict, fmt.Sprintf("enrolls unknown user %q", sid))
+			continue
+		}
+		item.ID, item.Name = existing.ID, existing.StudentName
+		target, conflict := resolve(e)
+		if conflict != "" {
+			plan.report.add(item, ImportConflict, conflict)
+			continue
+		}
+		student := *existing
+		student.ClassId = target.id
+		plan.update(item, existing, student, target, classSIDs[existing.ClassId], targetSID(target))
+	}
+
+	// Classes can only be deleted once every student has left them
+	remaining, err := repos.Students().FindByClasses(ctx, plan.deleteClasses)
+	if err != nil {
+		return nil, err
+	}
+	left := make(map[uint]int)
+	for _, st := range remaining {
+		if !plan.leaving[st.ID] {
+			left[st.ClassId]++
+		}
+	}
+	for i, id := range plan.deleteClasses {
+		if left[id] > 0 {
+			item := &plan.report.Items[plan.deleteItems[i]]
+			item.Action, item.Message = ImportConflict, fmt.Sprintf("%d student(s) would still be enrolled", left[id])
+			plan.report.Summary.Deletes--
+			plan.report.Summary.Conflicts++
+		}
+	}
+	return plan, nil
+}
+
+// deleteStudent plans the deletion of a stored student
+func (p *bundlePlan) deleteStudent(item ImportItem, student *models.Student, message string) {
+	p.report.add(item, ImportDelete, message)
+	p.deleteStudents = append(p.deleteStudents, student.ID)
+	p.leaving[student.ID] = true
+	p.affected[student.ClassId] = true
+}
+
+// deleteUnlistedClasses plans the deletion of every stored class a bulk
+// classes.csv leaves out
+func (p *bundlePlan) deleteUnlistedClasses(ctx context.Context, repos repository.Repositories, classes *oneroster.Table, targets map[string]classTarget) error {
+	listed := make(map[string]bool)
+	for _, row := range classes.Rows {
+		listed[classes.Get(row, "sourcedId")] = true
+	}
+	return repos.Classes().Stream(ctx, repository.QueryOptions{}, func(c *models.Class) error {
+		sid := oneroster.SourcedID(c.SourcedID, c.ID)
+		if listed[sid] {
+			return nil
+		}
+		item := ImportItem{File: oneroster.FileClasses, Entity: ImportEntityClass, ID: c.ID, Name: c.ClassName}
+		p.deleteItems = append(p.deleteItems, p.report.add(item, ImportDelete, "not in the bulk file"))
+		p.deleteClasses = append(p.deleteClasses, c.ID)
+		targets[sid] = classTarget{id: c.ID, deleted: true}
+		return nil
+	})
+}
+
+// planClasses decides what to do with each row of classes.csv and records
+// the classes students can be enrolled in
+func (p *bundlePlan) planClasses(classes *oneroster.Table, stored map[string]*models.Class, targets map[string]classTarget) {
+	seen := make(map[string]int)
+	for n, row := range classes.Rows {
+		sid, title := classes.Get(row, "sourcedId"), classes.Get(row, "title")
+		item := ImportItem{File: oneroster.FileClasses, Line: classes.Line(n), Entity: ImportEntityClass, Name: title}
+		if sid == "" {
+			p.report.add(item, ImportInvalid, "sourcedId is required")
+			continue
+		}
+		if line, dup := seen[sid]; dup {
+			p.report.add(item, ImportConflict, fmt.Sprintf("same sourcedId as line %d", line))
+			continue
+		}
+		seen[sid] = classes.Line(n)
+
+		existing := stored[sid]
+		if existing != nil {
+			item.ID = existing.ID
+		}
+		if strings.EqualFold(classes.Get(row, "status"), oneroster.StatusToBeDeleted) {
+			if existing == nil {
+				p.report.add(item, ImportSkipped, "not stored")
+				continue
+			}
+			p.deleteItems = append(p.deleteItems, p.report.add(item, ImportDelete, ""))
+			p.deleteClasses = append(p.deleteClasses, existing.ID)
+			targets[sid] = classTarget{id: existing.ID, deleted: true}
+			continue
+		}
+
+		class := models.Class{ClassName: title, SourcedID: sid}
+		if !p.validate(item, &class) {
+			continue
+		}
+		switch {
+		case existing == nil:
+			targets[sid] = classTarget{newIndex: len(p.newClasses)}
+			p.newClasses = append(p.newClasses, class)
+			p.classItems = append(p.classItems, p.report.add(item, ImportCreate, ""))
+		case existing.ClassName == title:
+			p.report.add(item, ImportUnchanged, "")
+		default:
+			item.Changes = []FieldChange{{Field: "class_name", From: existing.ClassName, To: title}}
+			p.classUpdateItems = append(p.classUpdateItems, p.report.add(item, ImportUpdate, ""))
+			updated := *existing
+			updated.ClassName = title
+			p.classUpdates = append(p.classUpdates, updated)
+		}
+	}
+}
+
+// validate checks a class or student, recording the item as invalid if it
+// fails. Students of classes the import creates are checked as if the
+// class already existed.
+func (p *bundlePlan) validate(item ImportItem, entity any) bool {
+	if student, ok := entity.(models.Student); ok {
+		if student.ClassId == 0 {
+			student.ClassId = 1
+		}
+		entity = &student
+	}
+	if err := validation.Struct(entity); err != nil {
+		p.report.add(item, ImportInvalid, describe(err))
+		return false
+	}
+	return true
+}
+
+// update plans the changes to a stored student, if there are any
+func (p *bundlePlan) update(item ImportItem, existing *models.Student, student models.Student, target classTarget, fromClass, toClass string) {
+	var changes []FieldChange
+	if existing.StudentName != student.StudentName {
+		changes = append(changes, FieldChange{Field: "student_name", From: existing.StudentName, To: student.StudentName})
+	}
+	if target.id != existing.ClassId {
+		changes = append(changes, FieldChange{Field: "class", From: fromClass, To: toClass})
+		p.leaving[existing.ID] = true
+	}
+	if existing.Section != student.Section {
+		changes = append(changes, FieldChange{Field: "student_section", From: existing.Section, To: student.Section})
+	}
+	if len(changes) == 0 {
+		p.report.add(item, ImportUnchanged, "")
+		return
+	}
+	item.Changes = changes
+	p.updateItems = append(p.updateItems, p.report.add(item, ImportUpdate, ""))
+
+	student.ID, student.Version = existing.ID, existing.Version
+	p.updates = append(p.updates, student)
+	p.updateClasses = append(p.updateClasses, target)
+	p.affected[existing.ClassId] = true
+}
+
+// apply writes the planned changes and fills in the IDs of created records
+func (p *bundlePlan) apply(ctx context.Context, repos repository.Repositories) error {
+	if len(p.newClasses) > 0 {
+		if err := repos.Classes().CreateBatch(ctx, p.newClasses); err != nil {
+			return err
+		}
+		for i, class := range p.newClasses {
+			p.report.Items[p.classItems[i]].ID = class.ID
+		}
+	}
+	for i := range p.classUpdates {
+		if err := repos.Classes().Update(ctx, &p.classUpdates[i]); err != nil {
+			return p.report.itemError(p.classUpdateItems[i], err)
+		}
+	}
+
+	classID := func(t classTarget) uint {
+		if t.id == 0 {
+			return p.newClasses[t.newIndex].ID
+		}
+		return t.id
+	}
+	for i := range p.newStudents {
+		p.newStudents[i].ClassId = classID(p.studentClasses[i])
+		p.affected[p.newStudents[i].ClassId] = true
+	}
+	if len(p.newStudents) > 0 {
+		if err := repos.Students().CreateBatch(ctx, p.newStudents); err != nil {
+			return err
+		}
+		for i, student := range p.newStudents {
+			p.report.Items[p.studentItems[i]].ID = student.ID
+		}
+	}
+	for i := range p.updates {
+		p.updates[i].ClassId = classID(p.updateClasses[i])
+		p.affected[p.updates[i].ClassId] = true
+		if err := repos.Students().Update(ctx, &p.updates[i]); err != nil {
+			return p.report.itemError(p.updateItems[i], err)
+		}
+	}
+	for _, id := range p.deleteStudents {
+		if err := repos.Students().Delete(ctx, id); err != nil {
+			return err
+		}
+	}
+
+	// Classes are only deleted once none of their students remain
+	for _, id := range p.deleteClasses {
+		count, err := repos.Students().CountByClass(ctx, id)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return apperror.New(http.StatusConflict, CodeClassHasStudents,
+				fmt.Sprintf("class %d cannot be deleted while %d students are enrolled", id, count))
+		}
+		if err := repos.Classes().Delete(ctx, id); err != nil {
+			return err
+		}
+		delete(p.affected, id)
+	}
+
+	if len(p.affected) == 0 {
+		return nil
+	}
+	ids := make([]uint, 0, len(p.affected))
+	for id := range p.affected {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return repos.Classes().RefreshStudentCounts(ctx, ids...)
+}
+
+// indexClasses keys classes by sourced ID. A class imported with a sourced
+// ID wins over one whose numeric ID happens to look the same.
+func indexClasses(classes []models.Class) map[string]*models.Class {
+	index := make(map[string]*models.Class, len(classes))
+	for i := range classes {
+		c := &classes[i]
+		sid := oneroster.SourcedID(c.SourcedID, c.ID)
+		if _, taken := index[sid]; !taken || c.SourcedID != "" {
+			index[sid] = c
+		}
+	}
+	return index
+}
+
+// indexStudents keys students by sourced ID, like indexClasses
+func indexStudents(students []models.Student) map[string]*models.Student {
+	index := make(map[string]*models.Student, len(students))
+	for i := range students {
+		s := &students[i]
+		sid := oneroster.SourcedID(s.SourcedID, s.ID)
+		if _, taken := index[sid]; !taken || s.SourcedID != "" {
+			index[sid] = s
+		}
+	}
+	return index
+}
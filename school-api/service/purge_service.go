@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"log"
+	"school-api/repository"
+	"time"
+)
+
+// PurgeResult reports what a purge removed
+type PurgeResult struct {
+	DeletedBefore time.Time
+	Classes       int64
+	Students      int64
+	// RefreshTokens counts expired refresh tokens, which are removed by
+	// every purge regardless of the retention period
+	RefreshTokens int64
+}
+
+// PurgeService permanently removes records that were soft-deleted longer
+// ago than the retention period
+type PurgeService interface {
+	// Purge removes records deleted more than olderThan ago, or more than
+	// the retention period ago when olderThan is zero
+	Purge(ctx context.Context, olderThan time.Duration) (*PurgeResult, error)
+	// Run purges every interval until ctx is done
+	Run(ctx context.Context, interval time.Duration)
+}
+
+type purgeService struct {
+	uow       repository.UnitOfWork
+	retention time.Duration
+}
+
+func NewPurgeService(uow repository.UnitOfWork, retention time.Duration) PurgeService {
+	return &purgeService{uow: uow, retention: retention}
+}
+
+func (s *purgeService) Purge(ctx context.Context, olderThan time.Duration) (*PurgeResult, error) {
+	if olderThan <= 0 {
+		olderThan = s.retention
+	}
+	result := &PurgeResult{DeletedBefore: time.Now().Add(-olderThan).UTC()}
+
+	err := s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		var err error
+		// Students go first so that their classes are no longer referenced
+		if result.Students, err = repos.Students().Purge(ctx, result.DeletedBefore); err != nil {
+			return err
+		}
+		if result.Classes, err = repos.Classes().Purge(ctx, result.DeletedBefore); err != nil {
+			return err
+		}
+		result.RefreshTokens, err = repos.RefreshTokens().Purge(ctx, time.Now())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *purgeService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			result, err := s.Purge(ctx, 0)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("Scheduled purge failed: %v", err)
+				}
+				continue
+			}
+			if result.Classes > 0 || result.Students > 0 {
+				log.Printf("Purged %d classes and %d students deleted before %s",
+					result.Classes, result.Students, result.DeletedBefore.Format(time.RFC3339))
+			}
+		}
+	}
+}
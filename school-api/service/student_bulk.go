@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"school-api/apperror"
+	"school-api/models"
+	"school-api/repository"
+	"school-api/validation"
+	"strings"
+)
+
+// CodeInvalidBulkMode is returned for an unrecognised bulk mode name
+const CodeInvalidBulkMode apperror.Code = "invalid_bulk_mode"
+
+// ErrInvalidBulkMode is returned for an unrecognised bulk mode name
+var ErrInvalidBulkMode = apperror.New(http.StatusBadRequest, CodeInvalidBulkMode, "bulk mode must be atomic or partial")
+
+// BulkMode decides how a batch reacts to items that fail
+type BulkMode string
+
+const (
+	// BulkAtomic applies every item or, if any fails, none of them
+	BulkAtomic BulkMode = "atomic"
+	// BulkPartial applies each item that succeeds and reports the others
+	BulkPartial BulkMode = "partial"
+)
+
+// ParseBulkMode validates a bulk mode name
+func ParseBulkMode(s string) (BulkMode, error) {
+	switch m := BulkMode(strings.ToLower(strings.TrimSpace(s))); m {
+	case BulkAtomic, BulkPartial:
+		return m, nil
+	default:
+		return "", ErrInvalidBulkMode
+	}
+}
+
+// BulkResult is the outcome of one item of a batch. Student is the stored
+// record after a create or update and nil after a delete or on failure.
+type BulkResult struct {
+	Index   int
+	ID      uint
+	Student *models.Student
+	Err     error
+}
+
+// BulkDeleteItem names a student to delete. Version is the version the
+// deletion is based on, or 0 to delete whatever version is stored.
+type BulkDeleteItem struct {
+	ID      uint
+	Version uint
+}
+
+// BulkCreateStudents creates students in one transaction. In atomic mode
+// every item is checked first and, if all pass, inserted in batches; any
+// failure stores nothing. In partial mode each item is inserted on its own
+// and failures are reported per item.
+func (s *studentService) BulkCreateStudents(ctx context.Context, students []models.Student, mode BulkMode) ([]BulkResult, error) {
+	results := make([]BulkResult, len(students))
+	err := s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		for i := range students {
+			students[i].ID = 0
+			results[i].Index = i
+		}
+		checks, err := checkStudents(ctx, repos, students)
+		if err != nil {
+			return err
+		}
+
+		var classIDs []uint
+		if mode == BulkAtomic {
+			if err := joinItemErrors(checks); err != nil {
+				return err
+			}
+			if err := repos.Students().CreateBatch(ctx, students); err != nil {
+				return err
+			}
+			for i := range students {
+				results[i].ID, results[i].Student = students[i].ID, &students[i]
+				classIDs = append(classIDs, students[i].ClassId)
+			}
+		} else {
+			for i := range students {
+				if results[i].Err = checks[i]; results[i].Err != nil {
+					continue
+				}
+				student := &students[i]
+				results[i].Err = s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+					return repos.Students().Create(ctx, student)
+				})
+				if results[i].Err == nil {
+					results[i].ID, results[i].Student = student.ID, student
+					classIDs = append(classIDs, student.ClassId)
+				}
+			}
+		}
+
+		if len(classIDs) == 0 {
+			return nil
+		}
+		return repos.Classes().RefreshStudentCounts(ctx, classIDs...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// BulkUpdateStudents replaces students as UpdateStudent would, all in one
+// transaction. Atomic mode stops and rolls back at the first failure;
+// partial mode rolls back only the failing item.
+func (s *studentService) BulkUpdateStudents(ctx context.Context, students []models.Student, mode BulkMode) ([]BulkResult, error) {
+	return s.bulk(ctx, len(students), mode, func(ctx context.Context, i int) (BulkResult, error) {
+		student := &students[i]
+		if err := s.UpdateStudent(ctx, student); err != nil {
+			return BulkResult{ID: student.ID}, err
+		}
+		return BulkResult{ID: student.ID, Student: student}, nil
+	})
+}
+
+// BulkDeleteStudents deletes students as DeleteStudent would, all in one
+// transaction. Atomic mode stops and rolls back at the first failure;
+// partial mode rolls back only the failing item.
+func (s *studentService) BulkDeleteStudents(ctx context.Context, items []BulkDeleteItem, mode BulkMode) ([]BulkResult, error) {
+	return s.bulk(ctx, len(items), mode, func(ctx context.Context, i int) (BulkResult, error) {
+		return BulkResult{ID: items[i].ID}, s.DeleteStudent(ctx, items[i].ID, items[i].Version)
+	})
+}
+
+// bulk runs apply for n items inside one transaction, each item in its own
+// savepoint
+func (s *studentService) bulk(ctx context.Context, n int, mode BulkMode, apply func(ctx context.Context, i int) (BulkResult, error)) ([]BulkResult, error) {
+	results := make([]BulkResult, n)
+	err := s.uow.Do(ctx, func(ctx context.Context, _ repository.Repositories) error {
+		for i := range results {
+			var result BulkResult
+			err := s.uow.Do(ctx, func(ctx context.Context, _ repository.Repositories) error {
+				var err error
+				result, err = apply(ctx, i)
+				return err
+			})
+			if err != nil && mode == BulkAtomic {
+				return itemError(i, err)
+			}
+			result.Index, result.Err = i, err
+			results[i] = result
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// checkStudents runs checkStudent on every student, looking each class up
+// once. It returns one error per student, nil for those that pass, and a
+// non-nil error only if the checks themselves failed.
+func checkStudents(ctx context.Context, repos repository.Repositories, students []models.Student) ([]error, error) {
+	checks := make([]error, len(students))
+	classExists := make(map[uint]bool)
+	scope := scopeFrom(ctx)
+	for i := range students {
+		if err := validation.Struct(&students[i]); err != nil {
+			checks[i] = err
+			continue
+		}
+		classID := students[i].ClassId
+		exists, ok := classExists[classID]
+		if !ok {
+			var err error
+			if exists, err = repos.Classes().ExistsInScope(ctx, scope, classID); err != nil {
+				return nil, err
+			}
+			classExists[classID] = exists
+		}
+		if !exists {
+			checks[i] = ErrUnknownClass
+		}
+	}
+	return checks, nil
+}
+
+// joinItemErrors combines the failures of a batch into one validation error
+// whose fields are prefixed with the item index, or returns nil if every
+// item passed
+func joinItemErrors(errs []error) error {
+	var fields []apperror.FieldError
+	failed := 0
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		failed++
+		var appErr *apperror.Error
+		if !errors.As(itemError(i, err), &appErr) || len(appErr.Fields) == 0 {
+			return itemError(i, err)
+		}
+		fields = append(fields, appErr.Fields...)
+	}
+	if failed == 0 {
+		return nil
+	}
+	return apperror.Validation(fmt.Sprintf("%d of %d items failed validation", failed, len(errs)), fields...)
+}
+
+// itemError ties err to the batch item at index i
+func itemError(i int, err error) error {
+	var appErr *apperror.Error
+	if !errors.As(err, &appErr) {
+		return err
+	}
+	if len(appErr.Fields) > 0 {
+		return nestFieldErrors(err, fmt.Sprintf("[%d].", i))
+	}
+	return apperror.Wrap(err, appErr.Status, appErr.Code, fmt.Sprintf("item %d: %s", i, appErr.Message))
+}
@@ -1,44 +1,260 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"school-api/models"
 	"school-api/repository"
+	"school-api/validation"
 )
 
 type StudentService interface {
-	CreateStudent(student *models.Student) error
-	GetAllStudents() ([]models.Student, error)
-	GetStudentByID(id uint) (*models.Student, error)
-	UpdateStudent(student *models.Student) error
-	DeleteStudent(id uint) error
+	CreateStudent(ctx context.Context, student *models.Student) error
+	GetAllStudents(ctx context.Context, opts repository.QueryOptions) (*repository.Page[models.Student], error)
+	ExportStudents(ctx context.Context, opts repository.QueryOptions, fn func(*models.Student) error) error
+	GetStudentByID(ctx context.Context, id uint) (*models.Student, error)
+	// GetClassHistory returns every class a student has been in, oldest first
+	GetClassHistory(ctx context.Context, id uint) ([]models.Enrollment, error)
+	UpdateStudent(ctx context.Context, student *models.Student) error
+	UpsertStudent(ctx context.Context, student *models.Student) (created bool, err error)
+	PatchStudent(ctx context.Context, id, version uint, apply func(student *models.Student) error) (*models.Student, error)
+	DeleteStudent(ctx context.Context, id, version uint) error
+	RestoreStudent(ctx context.Context, id uint) (*models.Student, error)
+	BulkCreateStudents(ctx context.Context, students []models.Student, mode BulkMode) ([]BulkResult, error)
+	BulkUpdateStudents(ctx context.Context, students []models.Student, mode BulkMode) ([]BulkResult, error)
+	BulkDeleteStudents(ctx context.Context, items []BulkDeleteItem, mode BulkMode) ([]BulkResult, error)
 }
 
 type studentService struct {
-	studentRepo repository.StudentRepository
+	uow repository.UnitOfWork
 }
 
-func NewStudentService(studentRepo repository.StudentRepository) StudentService {
-	return &studentService{
-		studentRepo: studentRepo,
+func NewStudentService(uow repository.UnitOfWork) StudentService {
+	return &studentService{uow: uow}
+}
+
+func (s *studentService) CreateStudent(ctx context.Context, student *models.Student) error {
+	return s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		if err := checkStudent(ctx, repos, student); err != nil {
+			return err
+		}
+		if err := repos.Students().Create(ctx, student); err != nil {
+			return err
+		}
+		return repos.Classes().RefreshStudentCounts(ctx, student.ClassId)
+	})
+}
+
+func (s *studentService) GetAllStudents(ctx context.Context, opts repository.QueryOptions) (*repository.Page[models.Student], error) {
+	opts.Scope = scopeFrom(ctx)
+	return s.uow.Students().List(ctx, opts)
+}
+
+// ExportStudents calls fn for every student matching opts, in order, without
+// loading them all at once
+func (s *studentService) ExportStudents(ctx context.Context, opts repository.QueryOptions, fn func(*models.Student) error) error {
+	opts.Scope = scopeFrom(ctx)
+	return s.uow.Students().Stream(ctx, opts, fn)
+}
+
+func (s *studentService) GetStudentByID(ctx context.Context, id uint) (*models.Student, error) {
+	return getStudent(ctx, s.uow, id)
+}
+
+func (s *studentService) GetClassHistory(ctx context.Context, id uint) ([]models.Enrollment, error) {
+	if _, err := getStudent(ctx, s.uow, id); err != nil {
+		return nil, err
 	}
+	return s.uow.Enrollments().History(ctx, id)
 }
 
-func (s *studentService) CreateStudent(student *models.Student) error {
-	return s.studentRepo.Create(student)
+// UpdateStudent replaces a student and, if it moved class, corrects the
+// counts of both classes in the same transaction. A non-zero
+// student.Version must match the stored version; zero overwrites whatever
+// is stored.
+func (s *studentService) UpdateStudent(ctx context.Context, student *models.Student) error {
+	return s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		if err := checkStudent(ctx, repos, student); err != nil {
+			return err
+		}
+
+		existing, err := getStudent(ctx, repos, student.ID)
+		if err != nil {
+			return err
+		}
+		if student.Version == 0 {
+			student.Version = existing.Version
+		}
+
+		if err := repos.Students().Update(ctx, student); err != nil {
+			return studentWriteError(err)
+		}
+		if err := repos.Classes().RefreshStudentCounts(ctx, existing.ClassId, student.ClassId); err != nil {
+			return err
+		}
+		return reloadStudent(ctx, repos.Students(), student)
+	})
 }
 
-func (s *studentService) GetAllStudents() ([]models.Student, error) {
-	return s.studentRepo.GetAll()
+// UpsertStudent updates the student with student.ID or creates it if there
+// is none. A non-zero student.Version requires the student to exist at that
+// version.
+func (s *studentService) UpsertStudent(ctx context.Context, student *models.Student) (bool, error) {
+	var created bool
+	err := s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		if err := checkStudent(ctx, repos, student); err != nil {
+			return err
+		}
+
+		classIDs := []uint{student.ClassId}
+		existing, err := repos.Students().GetByID(ctx, student.ID)
+		switch {
+		case err == nil:
+			// A student outside the caller's scope must not be overwritten
+			if _, err := getStudent(ctx, repos, student.ID); err != nil {
+				return err
+			}
+			classIDs = append(classIDs, existing.ClassId)
+			if student.Version == 0 {
+				student.Version = existing.Version
+			}
+		case errors.Is(err, repository.ErrNotFound):
+			if student.Version != 0 {
+				return ErrVersionMismatch
+			}
+		default:
+			return err
+		}
+
+		if created, err = repos.Students().Upsert(ctx, student); err != nil {
+			return studentWriteError(err)
+		}
+		if err := repos.Classes().RefreshStudentCounts(ctx, classIDs...); err != nil {
+			return err
+		}
+		return reloadStudent(ctx, repos.Students(), student)
+	})
+	if err != nil {
+		return false, err
+	}
+	return created, nil
 }
 
-func (s *studentService) GetStudentByID(id uint) (*models.Student, error) {
-	return s.studentRepo.GetByID(id)
+// PatchStudent loads a student, lets apply change it and stores the result
+// as UpdateStudent would. The read and the write happen in one transaction.
+// A non-zero version must match the stored one.
+func (s *studentService) PatchStudent(ctx context.Context, id, version uint, apply func(student *models.Student) error) (*models.Student, error) {
+	var student *models.Student
+	err := s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		var err error
+		if student, err = getStudent(ctx, repos, id); err != nil {
+			return err
+		}
+		if version != 0 && student.Version != version {
+			return ErrVersionMismatch
+		}
+		stored := student.Version
+		if err := apply(student); err != nil {
+			return err
+		}
+		student.ID, student.Version = id, stored
+		return s.UpdateStudent(ctx, student)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return student, nil
 }
 
-func (s *studentService) UpdateStudent(student *models.Student) error {
-	return s.studentRepo.Update(student)
+// DeleteStudent removes a student. A non-zero version must match the stored one.
+func (s *studentService) DeleteStudent(ctx context.Context, id, version uint) error {
+	return s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		existing, err := getStudent(ctx, repos, id)
+		if err != nil {
+			return err
+		}
+		if version != 0 && existing.Version != version {
+			return ErrVersionMismatch
+		}
+
+		if err := repos.Students().Delete(ctx, id); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return ErrStudentNotFound
+			}
+			return err
+		}
+		return repos.Classes().RefreshStudentCounts(ctx, existing.ClassId)
+	})
 }
 
-func (s *studentService) DeleteStudent(id uint) error {
-	return s.studentRepo.Delete(id)
-} 
\ No newline at end of file
+// RestoreStudent brings back a soft-deleted student. Its class must exist
+// and not be deleted itself.
+func (s *studentService) RestoreStudent(ctx context.Context, id uint) (*models.Student, error) {
+	var student *models.Student
+	err := s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		if err := repos.Students().Restore(ctx, id); err != nil {
+			return studentWriteError(err)
+		}
+		var err error
+		if student, err = getStudent(ctx, repos, id); err != nil {
+			return err
+		}
+		if err := checkStudent(ctx, repos, student); err != nil {
+			return err
+		}
+		return repos.Classes().RefreshStudentCounts(ctx, student.ClassId)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return student, nil
+}
+
+// getStudent loads a student, reporting ErrStudentNotFound if it does not
+// exist or is outside the caller's scope
+func getStudent(ctx context.Context, repos repository.Repositories, id uint) (*models.Student, error) {
+	student, err := repos.Students().GetInScope(ctx, scopeFrom(ctx), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrStudentNotFound
+	}
+	return student, err
+}
+
+// studentWriteError maps repository errors from writing a student to service errors
+func studentWriteError(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return ErrStudentNotFound
+	case errors.Is(err, repository.ErrVersionConflict):
+		return ErrVersionMismatch
+	default:
+		return err
+	}
+}
+
+// reloadStudent replaces student with its stored state so fields the write
+// leaves alone, such as sourced_id, are returned as stored
+func reloadStudent(ctx context.Context, students repository.StudentRepository, student *models.Student) error {
+	stored, err := students.GetByID(ctx, student.ID)
+	if err != nil {
+		return err
+	}
+	*student = *stored
+	return nil
+}
+
+// checkStudent validates a student's fields and makes sure its class_id
+// refers to an existing class in the caller's scope
+func checkStudent(ctx context.Context, repos repository.Repositories, student *models.Student) error {
+	if err := validation.Struct(student); err != nil {
+		return err
+	}
+	exists, err := repos.Classes().ExistsInScope(ctx, scopeFrom(ctx), student.ClassId)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrUnknownClass
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"school-api/apperror"
+	"school-api/auth"
+	"school-api/models"
+	"school-api/oneroster"
+	"school-api/repository"
+	"strings"
+)
+
+// Error codes for tenant resolution
+const (
+	CodeTenantMismatch apperror.Code = "tenant_mismatch"
+	CodeTenantDisabled apperror.Code = "tenant_disabled"
+)
+
+var (
+	// ErrTenantNotFound is returned when a request names a tenant that does not exist
+	ErrTenantNotFound = apperror.NotFound("tenant not found")
+	// ErrTenantMismatch is returned when a request names a tenant other than
+	// the one its credentials belong to
+	ErrTenantMismatch = apperror.New(http.StatusForbidden, CodeTenantMismatch,
+		"the credentials belong to a different tenant")
+	// ErrTenantDisabled is returned for requests to, and sign-ins of users of, a disabled tenant
+	ErrTenantDisabled = apperror.New(http.StatusForbidden, CodeTenantDisabled, "tenant is disabled")
+)
+
+// slugPattern is a DNS label, so every slug can be used as a subdomain
+var slugPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// TenantSettings are the settings a tenant may override; empty or zero
+// fields fall back to the server configuration
+type TenantSettings struct {
+	DeletePolicy          string
+	ReassignTo            uint
+	OneRosterOrgSourcedID string
+	OneRosterOrgName      string
+}
+
+type TenantService interface {
+	// Resolve returns the tenant a request is for. A principal's tenant
+	// wins; slug, taken from the request, must then name the same tenant.
+	// Anonymous requests, which only reach the API with authentication off,
+	// get the tenant slug names, or the default tenant.
+	Resolve(ctx context.Context, slug string, principal *auth.Principal) (*models.Tenant, error)
+	CreateTenant(ctx context.Context, slug, name string) (*models.Tenant, error)
+	GetTenantBySlug(ctx context.Context, slug string) (*models.Tenant, error)
+	// UpdateSettings changes the settings of the tenant of ctx
+	UpdateSettings(ctx context.Context, settings TenantSettings) (*models.Tenant, error)
+}
+
+type tenantService struct {
+	uow repository.UnitOfWork
+}
+
+func NewTenantService(uow repository.UnitOfWork) TenantService {
+	return &tenantService{uow: uow}
+}
+
+func (s *tenantService) Resolve(ctx context.Context, slug string, principal *auth.Principal) (*models.Tenant, error) {
+	slug = strings.ToLower(strings.TrimSpace(slug))
+	var (
+		tenant *models.Tenant
+		err    error
+	)
+	switch {
+	case principal != nil:
+		id := principal.TenantID
+		if id == 0 {
+			id = models.DefaultTenantID
+		}
+		if tenant, err = s.uow.Tenants().GetByID(ctx, id); err != nil {
+			return nil, tenantError(err)
+		}
+		if slug != "" && slug != tenant.Slug {
+			return nil, ErrTenantMismatch
+		}
+	case slug != "":
+		if tenant, err = s.uow.Tenants().GetBySlug(ctx, slug); err != nil {
+			return nil, tenantError(err)
+		}
+	default:
+		if tenant, err = s.uow.Tenants().GetByID(ctx, models.DefaultTenantID); err != nil {
+			return nil, tenantError(err)
+		}
+	}
+	if tenant.Disabled {
+		return nil, ErrTenantDisabled
+	}
+	return tenant, nil
+}
+
+func (s *tenantService) CreateTenant(ctx context.Context, slug, name string) (*models.Tenant, error) {
+	tenant := &models.Tenant{Slug: strings.ToLower(strings.TrimSpace(slug)), Name: strings.TrimSpace(name)}
+	var fields []apperror.FieldError
+	if !slugPattern.MatchString(tenant.Slug) {
+		fields = append(fields, apperror.FieldError{Field: "slug",
+			Message: "must be 1-63 lower-case letters, digits and hyphens, not starting or ending with a hyphen"})
+	}
+	if tenant.Name == "" || len(tenant.Name) > 100 {
+		fields = append(fields, apperror.FieldError{Field: "name", Message: "must be 1-100 characters"})
+	}
+	if len(fields) > 0 {
+		return nil, apperror.Validation("Request validation failed", fields...)
+	}
+
+	err := s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		_, err := repos.Tenants().GetBySlug(ctx, tenant.Slug)
+		if err == nil {
+			return apperror.New(http.StatusConflict, apperror.CodeDuplicate, "slug is already taken")
+		}
+		if !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+		return repos.Tenants().Create(ctx, tenant)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tenant, nil
+}
+
+func (s *tenantService) GetTenantBySlug(ctx context.Context, slug string) (*models.Tenant, error) {
+	tenant, err := s.uow.Tenants().GetBySlug(ctx, strings.ToLower(strings.TrimSpace(slug)))
+	if err != nil {
+		return nil, tenantError(err)
+	}
+	return tenant, nil
+}
+
+func (s *tenantService) UpdateSettings(ctx context.Context, settings TenantSettings) (*models.Tenant, error) {
+	current, ok := repository.TenantFrom(ctx)
+	if !ok {
+		return nil, ErrTenantNotFound
+	}
+	var fields []apperror.FieldError
+	for name, v := range map[string]string{
+		"oneroster_org_sourced_id": settings.OneRosterOrgSourcedID,
+		"oneroster_org_name":       settings.OneRosterOrgName,
+	} {
+		if len(v) > 255 {
+			fields = append(fields, apperror.FieldError{Field: name, Message: "must be at most 255 characters"})
+		}
+	}
+	if len(fields) > 0 {
+		return nil, apperror.Validation("Request validation failed", fields...)
+	}
+	if settings.DeletePolicy != "" {
+		policy, err := ParseDeletePolicy(settings.DeletePolicy)
+		if err != nil {
+			return nil, err
+		}
+		settings.DeletePolicy = string(policy)
+	}
+
+	var tenant *models.Tenant
+	err := s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		var err error
+		if tenant, err = repos.Tenants().GetByID(ctx, current.ID); err != nil {
+			return tenantError(err)
+		}
+		if settings.ReassignTo != 0 {
+			// Only classes of the tenant itself are visible here
+			exists, err := repos.Classes().Exists(ctx, settings.ReassignTo)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				return ErrInvalidReassignTarget
+			}
+		} else if settings.DeletePolicy == string(DeleteReassign) {
+			return ErrInvalidReassignTarget
+		}
+		tenant.DeletePolicy = settings.DeletePolicy
+		tenant.ReassignTo = settings.ReassignTo
+		tenant.OneRosterOrgSourcedID = strings.TrimSpace(settings.OneRosterOrgSourcedID)
+		tenant.OneRosterOrgName = strings.TrimSpace(settings.OneRosterOrgName)
+		return repos.Tenants().Update(ctx, tenant)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tenant, nil
+}
+
+// TenantOrg returns the OneRoster organization of the tenant of ctx. A
+// tenant's own settings win; other tenants than the default one are named
+// after themselves rather than sharing the configured organization.
+func TenantOrg(ctx context.Context, configured oneroster.Org) oneroster.Org {
+	tenant, ok := repository.TenantFrom(ctx)
+	if !ok {
+		return configured
+	}
+	org := configured
+	if tenant.ID != models.DefaultTenantID {
+		org = oneroster.Org{SourcedID: tenant.Slug, Name: tenant.Name}
+	}
+	if tenant.OneRosterOrgSourcedID != "" {
+		org.SourcedID = tenant.OneRosterOrgSourcedID
+	}
+	if tenant.OneRosterOrgName != "" {
+		org.Name = tenant.OneRosterOrgName
+	}
+	return org
+}
+
+// tenantError maps repository errors from loading a tenant to service errors
+func tenantError(err error) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrTenantNotFound
+	}
+	return err
+}
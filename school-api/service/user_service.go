@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"school-api/apperror"
+	"school-api/auth"
+	"school-api/models"
+	"school-api/repository"
+	"school-api/validation"
+	"slices"
+)
+
+// CodeNotGuardian is returned when linking students to a user who is not a guardian
+const CodeNotGuardian apperror.Code = "not_guardian"
+
+// ErrNotGuardian is returned when linking students to a user who is not a guardian
+var ErrNotGuardian = apperror.New(http.StatusUnprocessableEntity, CodeNotGuardian,
+	"students can only be linked to users with the guardian role")
+
+// NewUser is the input to CreateUser
+type NewUser struct {
+	Username string `json:"username" validate:"required,notblank,max=100,excludesrune= "`
+	Password string `json:"password" validate:"required,min=8,max=72"`
+	Role     string `json:"role" validate:"required,oneof=admin teacher guardian student"`
+	// StudentID links a user with the student role to their record
+	StudentID *uint `json:"student_id"`
+}
+
+// UserUpdate is the input to UpdateUser
+type UserUpdate struct {
+	Role      string `json:"role" validate:"required,oneof=admin teacher guardian student"`
+	StudentID *uint  `json:"student_id"`
+	Disabled  bool   `json:"disabled"`
+}
+
+type UserService interface {
+	// CreateUser adds a user with the given password and role
+	CreateUser(ctx context.Context, input NewUser) (*models.User, error)
+	ListUsers(ctx context.Context) ([]models.User, error)
+	GetUser(ctx context.Context, id uint) (*models.User, error)
+	// UpdateUser changes a user's role, student link and disabled flag.
+	// The change reaches access tokens when they are next refreshed. The
+	// last enabled administrator cannot be demoted or disabled.
+	UpdateUser(ctx context.Context, id uint, update UserUpdate) (*models.User, error)
+	// GetGuardianStudents returns the IDs of the students a guardian may see
+	GetGuardianStudents(ctx context.Context, id uint) ([]uint, error)
+	// SetGuardianStudents replaces the students a guardian may see
+	SetGuardianStudents(ctx context.Context, id uint, studentIDs []uint) ([]uint, error)
+}
+
+type userService struct {
+	uow repository.UnitOfWork
+}
+
+func NewUserService(uow repository.UnitOfWork) UserService {
+	return &userService{uow: uow}
+}
+
+func (s *userService) CreateUser(ctx context.Context, input NewUser) (*models.User, error) {
+	input.Username, input.Role = normalizeUsername(input.Username), normalizeRole(input.Role)
+	if err := validation.Struct(input); err != nil {
+		return nil, err
+	}
+	hash, err := auth.HashPassword(input.Password)
+	if errors.Is(err, auth.ErrPasswordTooLong) {
+		return nil, ErrPasswordTooLong
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	user := &models.User{
+		Username:     input.Username,
+		PasswordHash: hash,
+		Role:         input.Role,
+		StudentID:    input.StudentID,
+	}
+	err = s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		if err := checkStudentLink(ctx, repos, user); err != nil {
+			return err
+		}
+		_, err := repos.Users().GetByUsername(ctx, user.Username)
+		if err == nil {
+			return ErrUsernameTaken
+		}
+		if !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+		return repos.Users().Create(ctx, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *userService) ListUsers(ctx context.Context) ([]models.User, error) {
+	return s.uow.Users().List(ctx)
+}
+
+func (s *userService) GetUser(ctx context.Context, id uint) (*models.User, error) {
+	return getUser(ctx, s.uow, id)
+}
+
+func (s *userService) UpdateUser(ctx context.Context, id uint, update UserUpdate) (*models.User, error) {
+	update.Role = normalizeRole(update.Role)
+	if err := validation.Struct(update); err != nil {
+		return nil, err
+	}
+	var user *models.User
+	err := s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		var err error
+		if user, err = getUser(ctx, repos, id); err != nil {
+			return err
+		}
+		wasAdmin := isEnabledAdmin(user)
+		user.Role, user.StudentID, user.Disabled = update.Role, update.StudentID, update.Disabled
+		if err := checkStudentLink(ctx, repos, user); err != nil {
+			return err
+		}
+		if err := repos.Users().Update(ctx, user); err != nil {
+			return err
+		}
+		if wasAdmin && !isEnabledAdmin(user) {
+			admins, err := repos.Users().CountEnabled(ctx, auth.RoleAdmin)
+			if err != nil {
+				return err
+			}
+			if admins == 0 {
+				return ErrLastAdmin
+			}
+		}
+		if user.Disabled {
+			// Disabled users cannot refresh anyway; revoking says so in the data
+			return repos.RefreshTokens().RevokeAll(ctx, user.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *userService) GetGuardianStudents(ctx context.Context, id uint) ([]uint, error) {
+	if _, err := getUser(ctx, s.uow, id); err != nil {
+		return nil, err
+	}
+	return s.uow.Users().GuardianStudentIDs(ctx, id)
+}
+
+func (s *userService) SetGuardianStudents(ctx context.Context, id uint, studentIDs []uint) ([]uint, error) {
+	ids := slices.Clone(studentIDs)
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+	err := s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		user, err := getUser(ctx, repos, id)
+		if err != nil {
+			return err
+		}
+		if auth.Role(user.Role) != auth.RoleGuardian {
+			return ErrNotGuardian
+		}
+		found, err := repos.Students().FindByIDs(ctx, ids)
+		if err != nil {
+			return err
+		}
+		if len(found) != len(ids) {
+			return apperror.Validation("student_ids must refer to existing students",
+				apperror.FieldError{Field: "student_ids", Message: "must refer to existing students"})
+		}
+		return repos.Users().SetGuardianStudents(ctx, id, ids)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// isEnabledAdmin reports whether a user can currently manage users
+func isEnabledAdmin(user *models.User) bool {
+	return auth.Role(user.Role) == auth.RoleAdmin && !user.Disabled
+}
+
+// getUser loads a user, reporting ErrUserNotFound if it does not exist
+func getUser(ctx context.Context, repos repository.Repositories, id uint) (*models.User, error) {
+	user, err := repos.Users().GetByID(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrUserNotFound
+	}
+	return user, err
+}
+
+// checkStudentLink makes sure a user's student_id is set exactly when the
+// user has the student role, and refers to an existing student
+func checkStudentLink(ctx context.Context, repos repository.Repositories, user *models.User) error {
+	if auth.Role(user.Role) != auth.RoleStudent {
+		if user.StudentID != nil {
+			return apperror.Validation("student_id is only allowed for the student role",
+				apperror.FieldError{Field: "student_id", Message: "must be empty unless role is student"})
+		}
+		return nil
+	}
+	if user.StudentID == nil {
+		return apperror.Validation("student_id is required for the student role",
+			apperror.FieldError{Field: "student_id", Message: "is required when role is student"})
+	}
+	exists, err := repos.Students().Exists(ctx, *user.StudentID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrUnknownStudent
+	}
+	return nil
+}
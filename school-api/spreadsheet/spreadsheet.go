@@ -0,0 +1,140 @@
+// Package spreadsheet reads and writes tabular data as CSV or Excel (XLSX)
+package spreadsheet
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path/filepath"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Format is a supported spreadsheet file format
+type Format string
+
+// Supported formats
+const (
+	CSV  Format = "csv"
+	XLSX Format = "xlsx"
+)
+
+// Media types of the supported formats
+const (
+	CSVMediaType  = "text/csv"
+	XLSXMediaType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+// ErrUnsupportedFormat is returned for files that are neither CSV nor XLSX
+var ErrUnsupportedFormat = errors.New("unsupported spreadsheet format")
+
+// ParseFormat validates a format name
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(strings.TrimSpace(s))); f {
+	case CSV, XLSX:
+		return f, nil
+	default:
+		return "", fmt.Errorf("%w %q: expected csv or xlsx", ErrUnsupportedFormat, s)
+	}
+}
+
+// FormatFromFilename picks the format from a file extension
+func FormatFromFilename(name string) (Format, error) {
+	return ParseFormat(strings.TrimPrefix(filepath.Ext(name), "."))
+}
+
+// FormatFromMediaType picks the format from a Content-Type header value
+func FormatFromMediaType(contentType string) (Format, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", fmt.Errorf("%w %q", ErrUnsupportedFormat, contentType)
+	}
+	switch mediaType {
+	case CSVMediaType, "application/csv":
+		return CSV, nil
+	case XLSXMediaType:
+		return XLSX, nil
+	default:
+		return "", fmt.Errorf("%w %q: expected %s or %s", ErrUnsupportedFormat, mediaType, CSVMediaType, XLSXMediaType)
+	}
+}
+
+// MediaType returns the Content-Type for the format
+func (f Format) MediaType() string {
+	if f == XLSX {
+		return XLSXMediaType
+	}
+	return CSVMediaType + "; charset=utf-8"
+}
+
+// Sheet is the rows of a spreadsheet along with where each came from
+type Sheet struct {
+	Rows [][]string
+	// Lines holds the line of the file each row starts on, counting from 1.
+	// CSV rows may span lines and blank lines are skipped, so it can differ
+	// from the row's position.
+	Lines []int
+}
+
+// ReadAll reads every row of r. For XLSX files only the first sheet is read.
+// Rows may have different lengths.
+func ReadAll(r io.Reader, format Format) ([][]string, error) {
+	sheet, err := ReadSheet(r, format)
+	if err != nil {
+		return nil, err
+	}
+	return sheet.Rows, nil
+}
+
+// ReadSheet reads every row of r as ReadAll does, keeping the line each
+// row starts on. For XLSX files that is the row number.
+func ReadSheet(r io.Reader, format Format) (*Sheet, error) {
+	switch format {
+	case CSV:
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		sheet := &Sheet{}
+		for {
+			row, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("read csv: %w", err)
+			}
+			line, _ := reader.FieldPos(0)
+			sheet.Rows = append(sheet.Rows, row)
+			sheet.Lines = append(sheet.Lines, line)
+		}
+		// Excel writes a byte order mark at the start of UTF-8 CSV files
+		if len(sheet.Rows) > 0 && len(sheet.Rows[0]) > 0 {
+			sheet.Rows[0][0] = strings.TrimPrefix(sheet.Rows[0][0], "\ufeff")
+		}
+		return sheet, nil
+	case XLSX:
+		file, err := excelize.OpenReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("read xlsx: %w", err)
+		}
+		defer file.Close()
+		sheets := file.GetSheetList()
+		if len(sheets) == 0 {
+			return &Sheet{}, nil
+		}
+		rows, err := file.GetRows(sheets[0])
+		if err != nil {
+			return nil, fmt.Errorf("read xlsx: %w", err)
+		}
+		sheet := &Sheet{Rows: rows, Lines: make([]int, len(rows))}
+		for i := range rows {
+			sheet.Lines[i] = i + 1
+		}
+		return sheet, nil
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnsupportedFormat, format)
+	}
+}
@@ -0,0 +1,145 @@
+package spreadsheet
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Writer writes rows of a spreadsheet. Values may be strings, integers,
+// floats, booleans, times or nil for an empty cell. Close must be called
+// to complete the file.
+type Writer interface {
+	Write(row []any) error
+	Close() error
+}
+
+// NewWriter returns a Writer producing the given format on w. CSV rows are
+// written as they come; XLSX rows are buffered by the workbook (spilling to
+// a temporary file when large) and written to w on Close.
+func NewWriter(w io.Writer, format Format) (Writer, error) {
+	switch format {
+	case CSV:
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case XLSX:
+		return newXLSXWriter(w)
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnsupportedFormat, format)
+	}
+}
+
+type csvWriter struct {
+	w      *csv.Writer
+	record []string
+}
+
+func (c *csvWriter) Write(row []any) error {
+	c.record = c.record[:0]
+	for _, v := range row {
+		c.record = append(c.record, formatCell(v))
+	}
+	return c.w.Write(c.record)
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// formatCell renders a value as CSV text; times use RFC 3339
+func formatCell(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return escapeFormula(v)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return v.UTC().Format(time.RFC3339)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// escapeFormula keeps text that spreadsheet programs would take for a
+// formula, such as a name starting with "=", from being evaluated when a CSV
+// file is opened by prefixing it with an apostrophe. XLSX cells need no
+// escaping: text is stored as an inline string, never as a formula.
+func escapeFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+type xlsxWriter struct {
+	out       io.Writer
+	file      *excelize.File
+	stream    *excelize.StreamWriter
+	row       int
+	timeStyle int
+}
+
+func newXLSXWriter(w io.Writer) (*xlsxWriter, error) {
+	file := excelize.NewFile()
+	sheet := file.GetSheetName(0)
+	stream, err := file.NewStreamWriter(sheet)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("write xlsx: %w", err)
+	}
+	format := "yyyy-mm-dd hh:mm:ss"
+	timeStyle, err := file.NewStyle(&excelize.Style{CustomNumFmt: &format})
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("write xlsx: %w", err)
+	}
+	return &xlsxWriter{out: w, file: file, stream: stream, timeStyle: timeStyle}, nil
+}
+
+func (x *xlsxWriter) Write(row []any) error {
+	x.row++
+	cells := make([]any, len(row))
+	for i, v := range row {
+		switch t := v.(type) {
+		case time.Time:
+			cells[i] = excelize.Cell{StyleID: x.timeStyle, Value: t.UTC()}
+		case *time.Time:
+			if t != nil {
+				cells[i] = excelize.Cell{StyleID: x.timeStyle, Value: t.UTC()}
+			}
+		default:
+			cells[i] = v
+		}
+	}
+	cell, err := excelize.CoordinatesToCellName(1, x.row)
+	if err != nil {
+		return fmt.Errorf("write xlsx: %w", err)
+	}
+	if err := x.stream.SetRow(cell, cells); err != nil {
+		return fmt.Errorf("write xlsx: %w", err)
+	}
+	return nil
+}
+
+func (x *xlsxWriter) Close() error {
+	defer x.file.Close()
+	if err := x.stream.Flush(); err != nil {
+		return fmt.Errorf("write xlsx: %w", err)
+	}
+	if _, err := x.file.WriteTo(x.out); err != nil {
+		return fmt.Errorf("write xlsx: %w", err)
+	}
+	return nil
+}
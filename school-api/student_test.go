@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"school-api/auth"
+	"school-api/dto"
+	"school-api/handler"
+	"school-api/models"
+	"testing"
+)
+
+// TestStudentWritesKeepSourcedID checks that every way of replacing a
+// student answers with the student as stored, including the sourced_id the
+// request cannot set
+func TestStudentWritesKeepSourcedID(t *testing.T) {
+	a := newTestApp(t)
+	token := a.login("default", "admin", auth.RoleAdmin)
+	class := decode[dto.ClassResponse](t, expect(t, a.do(t, token, http.MethodPost, "/api/classes",
+		dto.CreateClassRequest{ClassName: "Grade 5"}), http.StatusCreated))
+	student := decode[dto.StudentResponse](t, expect(t, a.do(t, token, http.MethodPost, "/api/students",
+		dto.CreateStudentRequest{StudentName: "Jane Doe", ClassID: class.ID, Section: "A"}), http.StatusCreated))
+	err := a.db.Model(&models.Student{}).Where("id = ?", student.ID).Update("sourced_id", "usr-2001").Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := fmt.Sprintf("/api/students/%d", student.ID)
+	update := dto.UpdateStudentRequest{StudentName: "Jane Roe", ClassID: class.ID, Section: "B"}
+
+	for _, tc := range []struct {
+		name string
+		do   func(t *testing.T) dto.StudentResponse
+	}{
+		{name: "put", do: func(t *testing.T) dto.StudentResponse {
+			return decode[dto.StudentResponse](t, expect(t, a.do(t, token, http.MethodPut, path, update), http.StatusOK))
+		}},
+		{name: "patch", do: func(t *testing.T) dto.StudentResponse {
+			return decode[dto.StudentResponse](t, expect(t, a.do(t, token, http.MethodPatch, path,
+				`{"student_section":"C"}`, "Content-Type", "application/merge-patch+json"), http.StatusOK))
+		}},
+		{name: "upsert", do: func(t *testing.T) dto.StudentResponse {
+			return decode[dto.StudentResponse](t, expect(t, a.do(t, token, http.MethodPut, path+"/upsert", update), http.StatusOK))
+		}},
+		{name: "bulk", do: func(t *testing.T) dto.StudentResponse {
+			bulk := decode[handler.BulkResponse[dto.StudentResponse]](t, expect(t, a.do(t, token, http.MethodPut, "/api/students/bulk",
+				[]dto.BulkUpdateStudentRequest{{ID: student.ID, StudentName: "Jane Doe", ClassID: class.ID, Section: "A"}}),
+				http.StatusOK))
+			return *bulk.Results[0].Data
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.do(t); got.SourcedID != "usr-2001" {
+				t.Errorf("%s answered with sourced_id %q, want %q", tc.name, got.SourcedID, "usr-2001")
+			}
+		})
+	}
+}
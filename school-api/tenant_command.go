@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"school-api/config"
+	"school-api/database"
+	"school-api/repository"
+	"school-api/service"
+)
+
+// runCreateTenant implements "school-api create-tenant [flags] SLUG NAME",
+// which adds a tenant to the database configured by the usual flags. The
+// slug names the tenant in the X-Tenant header and as a subdomain.
+func runCreateTenant(args []string) error {
+	fs := flag.NewFlagSet("school-api create-tenant", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: school-api create-tenant [flags] SLUG NAME")
+		fmt.Fprintln(fs.Output(), "Add users to the tenant with: school-api create-user -tenant SLUG USERNAME")
+		fs.PrintDefaults()
+	}
+	cfg, err := config.LoadFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errors.New("create-tenant needs a slug and a name")
+	}
+
+	db, err := database.Open(cfg.Database.Driver, cfg.Database.DSN)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer database.Close(db)
+	if err := database.Migrate(db); err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	tenantService := service.NewTenantService(repository.NewUnitOfWork(db, cfg.RepositoryTimeouts()))
+	tenant, err := tenantService.CreateTenant(context.Background(), fs.Arg(0), fs.Arg(1))
+	if err != nil {
+		return commandError(err)
+	}
+	fmt.Printf("Created tenant %q (id %d)\n", tenant.Slug, tenant.ID)
+	return nil
+}
+
+// tenantContext confines ctx to the tenant with the given slug, as the
+// server does for each request
+func tenantContext(ctx context.Context, uow repository.UnitOfWork, slug string) (context.Context, error) {
+	tenant, err := service.NewTenantService(uow).GetTenantBySlug(ctx, slug)
+	if err != nil {
+		return nil, fmt.Errorf("tenant %q: %w", slug, err)
+	}
+	return repository.WithTenant(ctx, tenant), nil
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"school-api/apperror"
+	"school-api/auth"
+	"school-api/dto"
+	"school-api/handler"
+	"school-api/oneroster"
+	"school-api/service"
+	"strings"
+	"testing"
+)
+
+// TestTenantIsolation checks that a tenant cannot read or change another
+// tenant's records through any route that takes record IDs or returns records
+func TestTenantIsolation(t *testing.T) {
+	a := newTestApp(t)
+	tokenA := a.login("default", "admin-a", auth.RoleAdmin)
+	tokenB := a.login("b", "admin-b", auth.RoleAdmin)
+
+	classA := decode[dto.ClassResponse](t, expect(t, a.do(t, tokenA, http.MethodPost, "/api/classes",
+		dto.CreateClassRequest{ClassName: "Tenant A class"}), http.StatusCreated))
+	expect(t, a.do(t, tokenA, http.MethodPost, "/api/students",
+		dto.CreateStudentRequest{StudentName: "Tenant A student", ClassID: classA.ID, Section: "A"}), http.StatusCreated)
+
+	classB := decode[dto.ClassResponse](t, expect(t, a.do(t, tokenB, http.MethodPost, "/api/classes",
+		dto.CreateClassRequest{ClassName: "Tenant B class"}), http.StatusCreated))
+	studentB := decode[dto.StudentResponse](t, expect(t, a.do(t, tokenB, http.MethodPost, "/api/students",
+		dto.CreateStudentRequest{StudentName: "Tenant B student", ClassID: classB.ID, Section: "A"}), http.StatusCreated))
+	deletedB := decode[dto.StudentResponse](t, expect(t, a.do(t, tokenB, http.MethodPost, "/api/students",
+		dto.CreateStudentRequest{StudentName: "Tenant B deleted", ClassID: classB.ID, Section: "A"}), http.StatusCreated))
+	expect(t, a.do(t, tokenB, http.MethodDelete, fmt.Sprintf("/api/students/%d", deletedB.ID), nil), http.StatusNoContent)
+
+	classPath := fmt.Sprintf("/api/classes/%d", classB.ID)
+	// The class as it stands once its students are in
+	classB = decode[dto.ClassResponse](t, expect(t, a.do(t, tokenB, http.MethodGet, classPath, nil), http.StatusOK))
+	studentPath := fmt.Sprintf("/api/students/%d", studentB.ID)
+	update := dto.UpdateStudentRequest{StudentName: "Renamed", ClassID: classA.ID, Section: "B"}
+
+	t.Run("get", func(t *testing.T) {
+		expect(t, a.do(t, tokenA, http.MethodGet, classPath, nil), http.StatusNotFound)
+		expect(t, a.do(t, tokenA, http.MethodGet, studentPath, nil), http.StatusNotFound)
+		expect(t, a.do(t, tokenA, http.MethodGet, classPath+"/roster", nil), http.StatusNotFound)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		classes := decode[handler.ListResponse[dto.ClassResponse]](t, expect(t,
+			a.do(t, tokenA, http.MethodGet, "/api/classes?include_deleted=true", nil), http.StatusOK))
+		if classes.Total != 1 || classes.Data[0].ID != classA.ID {
+			t.Errorf("tenant A lists classes %+v, want only its own", classes.Data)
+		}
+		students := decode[handler.ListResponse[dto.StudentResponse]](t, expect(t,
+			a.do(t, tokenA, http.MethodGet, "/api/students?include_deleted=true", nil), http.StatusOK))
+		for _, s := range students.Data {
+			if strings.HasPrefix(s.StudentName, "Tenant B") {
+				t.Errorf("tenant A lists tenant B's student %q", s.StudentName)
+			}
+		}
+		if students.Total != 1 {
+			t.Errorf("tenant A lists %d students, want 1", students.Total)
+		}
+	})
+
+	t.Run("update", func(t *testing.T) {
+		expect(t, a.do(t, tokenA, http.MethodPut, studentPath, update), http.StatusNotFound)
+		expect(t, a.do(t, tokenA, http.MethodPut, classPath, dto.UpdateClassRequest{ClassName: "Renamed"}), http.StatusNotFound)
+	})
+
+	t.Run("upsert", func(t *testing.T) {
+		// A taken ID must look just like a free one that cannot be used
+		expect(t, a.do(t, tokenA, http.MethodPut, studentPath+"/upsert", update), http.StatusNotFound)
+		expect(t, a.do(t, tokenA, http.MethodPut, classPath+"/upsert", dto.UpdateClassRequest{ClassName: "Renamed"}), http.StatusNotFound)
+		expect(t, a.do(t, tokenA, http.MethodPut, fmt.Sprintf("/api/students/%d/upsert", deletedB.ID), update), http.StatusNotFound)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		expect(t, a.do(t, tokenA, http.MethodDelete, studentPath, nil), http.StatusNotFound)
+		expect(t, a.do(t, tokenA, http.MethodDelete, classPath, nil), http.StatusNotFound)
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		expect(t, a.do(t, tokenA, http.MethodPost, fmt.Sprintf("/api/students/%d/restore", deletedB.ID), nil), http.StatusNotFound)
+	})
+
+	t.Run("bulk", func(t *testing.T) {
+		updates := decode[handler.BulkResponse[dto.StudentResponse]](t, expect(t, a.do(t, tokenA, http.MethodPut, "/api/students/bulk?mode=partial",
+			[]dto.BulkUpdateStudentRequest{{ID: studentB.ID, StudentName: "Renamed", ClassID: classA.ID, Section: "B"}}),
+			http.StatusMultiStatus))
+		if updates.Failed != 1 || updates.Results[0].Status != http.StatusNotFound {
+			t.Errorf("bulk update of tenant B's student gave %+v, want a 404", updates.Results)
+		}
+		deletes := decode[handler.BulkResponse[dto.StudentResponse]](t, expect(t, a.do(t, tokenA, http.MethodDelete, "/api/students/bulk?mode=partial",
+			[]dto.BulkDeleteStudentRequest{{ID: studentB.ID}}), http.StatusMultiStatus))
+		if deletes.Failed != 1 || deletes.Results[0].Status != http.StatusNotFound {
+			t.Errorf("bulk delete of tenant B's student gave %+v, want a 404", deletes.Results)
+		}
+	})
+
+	t.Run("import", func(t *testing.T) {
+		roster := fmt.Sprintf("class_name,student_name,student_section,id\nTenant A class,Renamed,B,%d\n", studentB.ID)
+		report := decode[handler.ImportResponse](t, expect(t, a.do(t, tokenA, http.MethodPost, "/api/import", roster,
+			"Content-Type", "text/csv"), http.StatusUnprocessableEntity))
+		if report.Summary.Conflicts != 1 || report.Committed {
+			t.Errorf("import naming tenant B's student gave %+v, want one conflict", report.Summary)
+		}
+	})
+
+	t.Run("export", func(t *testing.T) {
+		for _, path := range []string{"/api/classes/export?format=csv", "/api/students/export?format=csv&include_deleted=true"} {
+			rec := expect(t, a.do(t, tokenA, http.MethodGet, path, nil), http.StatusOK)
+			if strings.Contains(rec.Body.String(), "Tenant B") {
+				t.Errorf("%s includes tenant B's records:\n%s", path, rec.Body)
+			}
+		}
+	})
+
+	t.Run("oneroster", func(t *testing.T) {
+		expect(t, a.do(t, tokenA, http.MethodGet, fmt.Sprintf("%s/users/%d", oneroster.BasePath, studentB.ID), nil), http.StatusNotFound)
+		expect(t, a.do(t, tokenA, http.MethodGet, fmt.Sprintf("%s/classes/%d", oneroster.BasePath, classB.ID), nil), http.StatusNotFound)
+		expect(t, a.do(t, tokenA, http.MethodGet, fmt.Sprintf("%s/classes/%d/students", oneroster.BasePath, classB.ID), nil), http.StatusNotFound)
+		for _, path := range []string{"/users", "/classes", "/enrollments"} {
+			rec := expect(t, a.do(t, tokenA, http.MethodGet, oneroster.BasePath+path, nil), http.StatusOK)
+			if strings.Contains(rec.Body.String(), "Tenant B") {
+				t.Errorf("%s includes tenant B's records:\n%s", path, rec.Body)
+			}
+		}
+
+		rec := expect(t, a.do(t, tokenA, http.MethodGet, oneroster.BasePath+"/csv", nil), http.StatusOK)
+		archive, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range archive.File {
+			r, err := f.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := io.ReadAll(r)
+			r.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bytes.Contains(data, []byte("Tenant B")) {
+				t.Errorf("CSV export file %s includes tenant B's records:\n%s", f.Name, data)
+			}
+		}
+	})
+
+	t.Run("mismatched tenant header", func(t *testing.T) {
+		for _, path := range []string{"/api/students", oneroster.BasePath + "/users"} {
+			rec := expect(t, a.do(t, tokenA, http.MethodGet, path, nil, "X-Tenant", "b"), http.StatusForbidden)
+			if strings.Contains(rec.Body.String(), "Tenant B") {
+				t.Errorf("%s with a mismatched X-Tenant returned tenant B's records", path)
+			}
+		}
+		problem := decode[apperror.Problem](t, a.do(t, tokenA, http.MethodGet, "/api/students", nil, "X-Tenant", "b"))
+		if problem.Code != service.CodeTenantMismatch {
+			t.Errorf("got problem code %q, want %q", problem.Code, service.CodeTenantMismatch)
+		}
+		expect(t, a.do(t, tokenA, http.MethodGet, "/api/students", nil, "X-Tenant", "default"), http.StatusOK)
+	})
+
+	// Tenant B's records came through every attempt unchanged
+	got := decode[dto.StudentResponse](t, expect(t, a.do(t, tokenB, http.MethodGet, studentPath, nil), http.StatusOK))
+	if got.StudentName != studentB.StudentName || got.ClassID != classB.ID || got.Version != studentB.Version {
+		t.Errorf("tenant B's student is now %+v, want %+v", got, studentB)
+	}
+	class := decode[dto.ClassResponse](t, expect(t, a.do(t, tokenB, http.MethodGet, classPath, nil), http.StatusOK))
+	if class.ClassName != classB.ClassName || class.Version != classB.Version || class.StudentCount != classB.StudentCount {
+		t.Errorf("tenant B's class is now %+v, want %+v", class, classB)
+	}
+	expect(t, a.do(t, tokenB, http.MethodGet, fmt.Sprintf("/api/students/%d", deletedB.ID), nil), http.StatusNotFound)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"school-api/apperror"
+	"school-api/config"
+	"school-api/database"
+	"school-api/repository"
+	"school-api/service"
+	"strings"
+)
+
+// runCreateUser implements "school-api create-user [flags] USERNAME",
+// which adds a user to the database configured by the usual flags. The
+// password is read from the first line of standard input.
+func runCreateUser(args []string) error {
+	fs := flag.NewFlagSet("school-api create-user", flag.ContinueOnError)
+	role := fs.String("role", "admin", "role of the user: admin, teacher, guardian or student")
+	studentID := fs.Uint("student", 0, "ID of the student record of a user with the student role")
+	tenant := fs.String("tenant", "default", "slug of the tenant the user belongs to")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: school-api create-user [flags] USERNAME")
+		fmt.Fprintln(fs.Output(), "The password is read from standard input, e.g. echo \"$PASSWORD\" | school-api create-user admin")
+		fs.PrintDefaults()
+	}
+	cfg, err := config.LoadFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("create-user needs exactly one username")
+	}
+
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		fmt.Fprint(os.Stderr, "Password (shown as typed): ")
+	}
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && password == "" {
+		return errors.New("no password given on standard input")
+	}
+	password = strings.TrimRight(password, "\r\n")
+
+	db, err := database.Open(cfg.Database.Driver, cfg.Database.DSN)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer database.Close(db)
+	if err := database.Migrate(db); err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	input := service.NewUser{Username: fs.Arg(0), Password: password, Role: strings.ToLower(*role)}
+	if *studentID != 0 {
+		id := uint(*studentID)
+		input.StudentID = &id
+	}
+	uow := repository.NewUnitOfWork(db, cfg.RepositoryTimeouts())
+	ctx, err := tenantContext(context.Background(), uow, *tenant)
+	if err != nil {
+		return err
+	}
+	user, err := service.NewUserService(uow).CreateUser(ctx, input)
+	if err != nil {
+		return commandError(err)
+	}
+	fmt.Printf("Created %s %q (id %d) in tenant %s\n", user.Role, user.Username, user.ID, *tenant)
+	return nil
+}
+
+// commandError spells out the fields of a validation error, which API
+// clients get as a list but a terminal only shows as one line
+func commandError(err error) error {
+	var appErr *apperror.Error
+	if errors.As(err, &appErr) && len(appErr.Fields) > 0 {
+		details := make([]string, len(appErr.Fields))
+		for i, f := range appErr.Fields {
+			details[i] = f.Field + " " + f.Message
+		}
+		return fmt.Errorf("%s: %s", appErr.Message, strings.Join(details, "; "))
+	}
+	return err
+}